@@ -1,9 +1,14 @@
 package golgi
 
 import (
+	"fmt"
 	"math"
+	"reflect"
+	"strings"
+	"sync"
 
 	"github.com/chewxy/math32"
+	"github.com/pkg/errors"
 	G "gorgonia.org/gorgonia"
 )
 
@@ -11,6 +16,9 @@ import (
 //
 // The Activation type is useful as it allows the models to be serialized (you cannot serialize ActivationFunction).
 // Use ActivationMap() to get the relevant ActivationFunction.
+//
+// Activations are serialized by name (see MarshalText), so custom activation functions registered with RegisterActivation
+// round-trip as long as they are registered under the same name before deserialization.
 type Activation int
 
 const (
@@ -23,30 +31,169 @@ const (
 	ELU
 	Cube
 	SoftMax
+	Swish
+	Mish
+	HardSigmoid
+	HardSwish
+	Softplus
+	Softsign
+	SELU
+	LogSoftMax
 )
 
-var maxact = Cube
+// SiLU is another name for Swish.
+const SiLU = Swish
+
+var maxact = LogSoftMax
 
 var internalmaps = map[Activation]ActivationFunction{
-	Identity:  nil,
-	Sigmoid:   G.Sigmoid,
-	Tanh:      G.Tanh,
-	ReLU:      G.Rectify,
-	GeLU:      GeLUFn, // TODO
-	LeakyReLU: nil,    // TODO
-	ELU:       nil,    //TODO
-	Cube:      G.Cube,
-	SoftMax:   SoftMaxFn,
+	Identity:    nil,
+	Sigmoid:     G.Sigmoid,
+	Tanh:        G.Tanh,
+	ReLU:        G.Rectify,
+	GeLU:        GeLUFn,
+	LeakyReLU:   LeakyReLUFn,
+	ELU:         ELUFn,
+	Cube:        G.Cube,
+	SoftMax:     SoftMaxFn,
+	Swish:       SwishFn,
+	Mish:        MishFn,
+	HardSigmoid: HardSigmoidFn,
+	HardSwish:   HardSwishFn,
+	Softplus:    G.Softplus,
+	Softsign:    SoftsignFn,
+	SELU:        SELUFn,
+	LogSoftMax:  LogSoftMaxFn,
+}
+
+var activationNames = map[Activation]string{
+	Identity:    "identity",
+	Sigmoid:     "sigmoid",
+	Tanh:        "tanh",
+	ReLU:        "relu",
+	GeLU:        "gelu",
+	LeakyReLU:   "leakyrelu",
+	ELU:         "elu",
+	Cube:        "cube",
+	SoftMax:     "softmax",
+	Swish:       "swish",
+	Mish:        "mish",
+	HardSigmoid: "hardsigmoid",
+	HardSwish:   "hardswish",
+	Softplus:    "softplus",
+	Softsign:    "softsign",
+	SELU:        "selu",
+	LogSoftMax:  "logsoftmax",
+}
+
+var activationsByName = map[string]Activation{
+	"silu": SiLU,
+}
+
+// activationsMu guards the maps above, as custom activations may be registered at any time.
+var activationsMu sync.RWMutex
+
+func init() {
+	for a, name := range activationNames {
+		activationsByName[name] = a
+	}
+}
+
+// RegisterActivation registers a custom ActivationFunction under the given name, and returns the Activation that represents it.
+//
+// Registering a name that has already been registered replaces the ActivationFunction and returns the same Activation.
+// RegisterActivation panics if the name is empty, or is the name of one of the built-in Activations.
+func RegisterActivation(name string, fn ActivationFunction) Activation {
+	key := strings.ToLower(name)
+	activationsMu.Lock()
+	defer activationsMu.Unlock()
+	if key == "" {
+		panic("Cannot register an activation function without a name")
+	}
+	a, ok := activationsByName[key]
+	switch {
+	case ok && a <= maxact:
+		panic(fmt.Sprintf("Cannot register %q. It is the name of a built-in activation function", name))
+	case !ok:
+		a = Activation(len(activationNames))
+		activationNames[a] = key
+		activationsByName[key] = a
+	}
+	internalmaps[a] = fn
+	return a
 }
 
 // ActivationMap is a map from Activation to ActivationFunction. The mapping function is finite. If an invalid Activation is passed in, nil will be returned.
-func ActivationMap(a Activation) ActivationFunction { return internalmaps[a] }
+func ActivationMap(a Activation) ActivationFunction {
+	activationsMu.RLock()
+	defer activationsMu.RUnlock()
+	return internalmaps[a]
+}
+
+// activationOf is the inverse of ActivationMap. A nil ActivationFunction is the Identity.
+// An error is returned if the ActivationFunction is not one that Golgi understands.
+func activationOf(fn ActivationFunction) (Activation, error) {
+	if fn == nil {
+		return Identity, nil
+	}
+	activationsMu.RLock()
+	defer activationsMu.RUnlock()
+	ptr := reflect.ValueOf(fn).Pointer()
+	for a, f := range internalmaps {
+		if f != nil && reflect.ValueOf(f).Pointer() == ptr {
+			return a, nil
+		}
+	}
+	return Identity, errors.Errorf("Unable to serialize the activation function %v", runtimeFuncName(fn))
+}
+
+// String returns the name of the Activation.
+func (a Activation) String() string {
+	activationsMu.RLock()
+	defer activationsMu.RUnlock()
+	if name, ok := activationNames[a]; ok {
+		return name
+	}
+	return fmt.Sprintf("Activation(%d)", int(a))
+}
+
+// MarshalText implements encoding.TextMarshaler. An Activation is marshalled as its name.
+func (a Activation) MarshalText() ([]byte, error) {
+	activationsMu.RLock()
+	defer activationsMu.RUnlock()
+	name, ok := activationNames[a]
+	if !ok {
+		return nil, errors.Errorf("Unable to marshal unknown Activation %d", int(a))
+	}
+	return []byte(name), nil
+}
+
+// UnmarshalText implements encoding.TextUnmarshaler. Names are case insensitive.
+// Custom activations have to be registered with RegisterActivation before they can be unmarshalled.
+func (a *Activation) UnmarshalText(text []byte) error {
+	activationsMu.RLock()
+	defer activationsMu.RUnlock()
+	act, ok := activationsByName[strings.ToLower(string(text))]
+	if !ok {
+		return errors.Errorf("Unknown Activation %q. Custom activations must be registered with RegisterActivation", text)
+	}
+	*a = act
+	return nil
+}
 
 var elmul = G.Lift2(G.HadamardProd)
 var tanh = G.Lift1(G.Tanh)
 var add = G.Lift2(G.Add)
 var mul = G.Lift2(G.Mul)
 var cube = G.Lift1(G.Cube)
+var sub = G.Lift2(G.Sub)
+var eldiv = G.Lift2(G.HadamardDiv)
+var sigmoid = G.Lift1(G.Sigmoid)
+var rectify = G.Lift1(G.Rectify)
+var neg = G.Lift1(G.Neg)
+var exp = G.Lift1(G.Exp)
+var abs = G.Lift1(G.Abs)
+var softplus = G.Lift1(G.Softplus)
 
 // GeLUFn is an activation function. See https://arxiv.org/abs/1606.08415.
 func GeLUFn(a *G.Node) (*G.Node, error) {
@@ -85,3 +232,131 @@ func GeLUFn(a *G.Node) (*G.Node, error) {
 func SoftMaxFn(a *G.Node) (*G.Node, error) {
 	return G.SoftMax(a)
 }
+
+// LeakyReLUFn is an activation function. The slope of the negative part is 0.01.
+func LeakyReLUFn(a *G.Node) (*G.Node, error) {
+	return G.LeakyRelu(a, 0.01)
+}
+
+// ELUFn is an activation function. See https://arxiv.org/abs/1511.07289. α is 1.
+func ELUFn(a *G.Node) (*G.Node, error) {
+	return elu(a, 1)
+}
+
+// SELUFn is an activation function. See https://arxiv.org/abs/1706.02515.
+func SELUFn(a *G.Node) (*G.Node, error) {
+	const (
+		alpha = 1.6732632423543772848170429916717
+		scale = 1.0507009873554804934193349852946
+	)
+	e, err := elu(a, alpha)
+	if err != nil {
+		return nil, err
+	}
+	s, err := constOf(a, scale)
+	if err != nil {
+		return nil, err
+	}
+	return G.HadamardProd(s, e)
+}
+
+// elu computes max(0, x) + α(exp(min(0, x)) - 1). min(0, x) is computed as -max(0, -x) so that the function is differentiable.
+func elu(a *G.Node, alpha float64) (*G.Node, error) {
+	α, err := constOf(a, alpha)
+	if err != nil {
+		return nil, err
+	}
+	one, err := constOf(a, 1)
+	if err != nil {
+		return nil, err
+	}
+	retVal := add(
+		rectify(a),
+		elmul(α, sub(exp(neg(rectify(neg(a)))), one)),
+	)
+	return retVal.Node(), retVal.Err()
+}
+
+// SwishFn is an activation function, also known as SiLU. See https://arxiv.org/abs/1710.05941.
+func SwishFn(a *G.Node) (*G.Node, error) {
+	retVal := elmul(a, sigmoid(a))
+	return retVal.Node(), retVal.Err()
+}
+
+// MishFn is an activation function. See https://arxiv.org/abs/1908.08681.
+func MishFn(a *G.Node) (*G.Node, error) {
+	retVal := elmul(a, tanh(softplus(a)))
+	return retVal.Node(), retVal.Err()
+}
+
+// HardSigmoidFn is an activation function. It is a piecewise linear approximation of the sigmoid: max(0, min(1, x/6 + 1/2)).
+func HardSigmoidFn(a *G.Node) (*G.Node, error) {
+	sixth, err := constOf(a, 1.0/6.0)
+	if err != nil {
+		return nil, err
+	}
+	half, err := constOf(a, 0.5)
+	if err != nil {
+		return nil, err
+	}
+	// clipping y to [0, 1] is the same as max(0, y) - max(0, y - 1)
+	y := elmul(sixth, a)
+	retVal := sub(
+		rectify(add(y, half)),
+		rectify(sub(y, half)),
+	)
+	return retVal.Node(), retVal.Err()
+}
+
+// HardSwishFn is an activation function. See https://arxiv.org/abs/1905.02244. It is x * HardSigmoid(x).
+func HardSwishFn(a *G.Node) (*G.Node, error) {
+	retVal := elmul(a, G.LiftResult(HardSigmoidFn(a)))
+	return retVal.Node(), retVal.Err()
+}
+
+// SoftsignFn is an activation function: x / (1 + |x|).
+func SoftsignFn(a *G.Node) (*G.Node, error) {
+	one, err := constOf(a, 1)
+	if err != nil {
+		return nil, err
+	}
+	retVal := eldiv(a, add(one, abs(a)))
+	return retVal.Node(), retVal.Err()
+}
+
+// LogSoftMaxFn implements the logarithm of the softmax along the last axis. It is computed as x - max(x) - log(Σexp(x - max(x))), which is stable.
+func LogSoftMaxFn(a *G.Node) (retVal *G.Node, err error) {
+	last := a.Dims() - 1
+	if last < 0 {
+		return nil, errors.Errorf("LogSoftMax expects a vector or a tensor. Got a scalar instead")
+	}
+	axis := []byte{byte(last)}
+	var max, shifted, sum, logSum *G.Node
+	if max, err = G.KeepDims(a, false, func(x *G.Node) (*G.Node, error) { return G.Max(x, last) }); err != nil {
+		return nil, errors.Wrap(err, "Unable to find the max of the last axis")
+	}
+	if shifted, err = G.BroadcastSub(a, max, nil, axis); err != nil {
+		return nil, errors.Wrap(err, "Unable to compute x - max(x)")
+	}
+	if sum, err = G.Exp(shifted); err != nil {
+		return nil, err
+	}
+	if sum, err = G.KeepDims(sum, false, func(x *G.Node) (*G.Node, error) { return G.Sum(x, last) }); err != nil {
+		return nil, errors.Wrap(err, "Unable to sum the last axis")
+	}
+	if logSum, err = G.Log(sum); err != nil {
+		return nil, err
+	}
+	return G.BroadcastSub(shifted, logSum, nil, axis)
+}
+
+// constOf creates a constant with the same Dtype as a.
+func constOf(a *G.Node, v float64) (*G.Node, error) {
+	switch a.Dtype() {
+	case G.Float64:
+		return G.NewConstant(v), nil
+	case G.Float32:
+		return G.NewConstant(float32(v)), nil
+	}
+	return nil, errors.Errorf("Activation functions only support Float32 and Float64. Got %v instead", a.Dtype())
+}
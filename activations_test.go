@@ -0,0 +1,199 @@
+package golgi
+
+import (
+	"bytes"
+	"math"
+	"testing"
+
+	"github.com/stretchr/testify/require"
+	"gorgonia.org/gorgonia"
+	"gorgonia.org/tensor"
+)
+
+// rowwise applies fn to each row of a (rows, cols) matrix.
+func rowwise(fn func([]float64) []float64) func([]float64) []float64 {
+	return func(xs []float64) []float64 {
+		var retVal []float64
+		for i := 0; i < len(xs); i += 3 {
+			retVal = append(retVal, fn(xs[i:i+3])...)
+		}
+		return retVal
+	}
+}
+
+func elementwise(fn func(float64) float64) func([]float64) []float64 {
+	return func(xs []float64) []float64 {
+		retVal := make([]float64, len(xs))
+		for i, x := range xs {
+			retVal[i] = fn(x)
+		}
+		return retVal
+	}
+}
+
+func logSoftMaxRef(xs []float64) []float64 {
+	var sum float64
+	for _, x := range xs {
+		sum += math.Exp(x)
+	}
+	retVal := make([]float64, len(xs))
+	for i, x := range xs {
+		retVal[i] = x - math.Log(sum)
+	}
+	return retVal
+}
+
+func sigmoidRef(x float64) float64  { return 1 / (1 + math.Exp(-x)) }
+func softplusRef(x float64) float64 { return math.Log1p(math.Exp(x)) }
+func hardSigmoidRef(x float64) float64 {
+	return math.Max(0, math.Min(1, x/6+0.5))
+}
+func eluRef(alpha float64) func(float64) float64 {
+	return func(x float64) float64 {
+		if x > 0 {
+			return x
+		}
+		return alpha * (math.Exp(x) - 1)
+	}
+}
+
+var activationRefs = map[Activation]func([]float64) []float64{
+	Identity: elementwise(func(x float64) float64 { return x }),
+	Sigmoid:  elementwise(sigmoidRef),
+	Tanh:     elementwise(math.Tanh),
+	ReLU:     elementwise(func(x float64) float64 { return math.Max(0, x) }),
+	GeLU: elementwise(func(x float64) float64 {
+		return 0.5 * x * (1 + math.Tanh(math.Sqrt(2/math.Pi)*(x+0.044715*x*x*x)))
+	}),
+	LeakyReLU: elementwise(func(x float64) float64 {
+		if x < 0 {
+			return 0.01 * x
+		}
+		return x
+	}),
+	ELU:  elementwise(eluRef(1)),
+	Cube: elementwise(func(x float64) float64 { return x * x * x }),
+	SoftMax: rowwise(func(xs []float64) []float64 {
+		ls := logSoftMaxRef(xs)
+		return elementwise(math.Exp)(ls)
+	}),
+	Swish:       elementwise(func(x float64) float64 { return x * sigmoidRef(x) }),
+	Mish:        elementwise(func(x float64) float64 { return x * math.Tanh(softplusRef(x)) }),
+	HardSigmoid: elementwise(hardSigmoidRef),
+	HardSwish:   elementwise(func(x float64) float64 { return x * hardSigmoidRef(x) }),
+	Softplus:    elementwise(softplusRef),
+	Softsign:    elementwise(func(x float64) float64 { return x / (1 + math.Abs(x)) }),
+	SELU: elementwise(func(x float64) float64 {
+		return 1.0507009873554804934193349852946 * eluRef(1.6732632423543772848170429916717)(x)
+	}),
+	LogSoftMax: rowwise(logSoftMaxRef),
+}
+
+func TestActivations(t *testing.T) {
+	xs := []float64{-4, -2.5, -0.5, 0.25, 1, 3.5}
+	for a := Identity; a <= maxact; a++ {
+		ref, ok := activationRefs[a]
+		if !ok {
+			t.Errorf("No reference for %v", a)
+			continue
+		}
+		want := ref(xs)
+		for _, dt := range []tensor.Dtype{tensor.Float64, tensor.Float32} {
+			t.Run(a.String()+"/"+dt.String(), func(t *testing.T) {
+				c := require.New(t)
+				fn := ActivationMap(a)
+				if a == Identity {
+					c.Nil(fn)
+					return
+				}
+				c.NotNil(fn)
+
+				g := gorgonia.NewGraph()
+				var xT *tensor.Dense
+				if dt == tensor.Float32 {
+					backing := make([]float32, len(xs))
+					for i := range xs {
+						backing[i] = float32(xs[i])
+					}
+					xT = tensor.New(tensor.WithShape(2, 3), tensor.WithBacking(backing))
+				} else {
+					xT = tensor.New(tensor.WithShape(2, 3), tensor.WithBacking(append([]float64(nil), xs...)))
+				}
+				x := gorgonia.NewMatrix(g, dt, gorgonia.WithName("x"), gorgonia.WithShape(2, 3), gorgonia.WithValue(xT))
+				y, err := fn(x)
+				c.NoError(err)
+				c.Equal(x.Shape(), y.Shape())
+
+				// every activation has to be differentiable
+				cost, err := gorgonia.Sum(y)
+				c.NoError(err)
+				_, err = gorgonia.Grad(cost, x)
+				c.NoError(err)
+
+				var v gorgonia.Value
+				gorgonia.Read(y, &v)
+				m := gorgonia.NewTapeMachine(g)
+				defer m.Close()
+				c.NoError(m.RunAll())
+
+				var got []float64
+				switch data := v.Data().(type) {
+				case []float64:
+					got = data
+				case []float32:
+					for _, d := range data {
+						got = append(got, float64(d))
+					}
+				}
+				delta := 1e-10
+				if dt == tensor.Float32 {
+					delta = 1e-5
+				}
+				c.InDeltaSlice(want, got, delta)
+			})
+		}
+	}
+}
+
+func TestActivation_Text(t *testing.T) {
+	c := require.New(t)
+	for a := Identity; a <= maxact; a++ {
+		text, err := a.MarshalText()
+		c.NoError(err)
+		var b Activation
+		c.NoError(b.UnmarshalText(text))
+		c.Equal(a, b)
+	}
+
+	var a Activation
+	c.NoError(a.UnmarshalText([]byte("SiLU")))
+	c.Equal(Swish, a)
+	c.Error(a.UnmarshalText([]byte("unknown")))
+	_, err := Activation(1000).MarshalText()
+	c.Error(err)
+}
+
+func TestRegisterActivation(t *testing.T) {
+	c := require.New(t)
+	c.Panics(func() { RegisterActivation("ReLU", gorgonia.Rectify) })
+	c.Panics(func() { RegisterActivation("", gorgonia.Rectify) })
+
+	square := RegisterActivation("square", gorgonia.Square)
+	c.True(square > maxact)
+	c.Equal("square", square.String())
+	c.Equal(square, RegisterActivation("square", gorgonia.Square))
+	c.NotNil(ActivationMap(square))
+
+	// custom activations are serialized by name
+	g := gorgonia.NewGraph()
+	x := gorgonia.NewMatrix(g, tensor.Float64, gorgonia.WithShape(2, 3), gorgonia.WithInit(gorgonia.GlorotU(1)))
+	fc, err := ConsFC(x, WithSize(4), WithActivation(gorgonia.Square))
+	c.NoError(err)
+	var buf bytes.Buffer
+	c.NoError(SaveCheckpoint(&buf, fc))
+	loaded, err := LoadCheckpoint(gorgonia.NewGraph(), &buf)
+	c.NoError(err)
+	act, err := activationOf(loaded.(*FC).act)
+	c.NoError(err)
+	c.Equal(square, act)
+}
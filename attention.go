@@ -0,0 +1,543 @@
+package golgi
+
+import (
+	"math"
+
+	"github.com/chewxy/hm"
+	"github.com/pkg/errors"
+	"gorgonia.org/golgi/onnx"
+	G "gorgonia.org/gorgonia"
+	"gorgonia.org/tensor"
+)
+
+var (
+	_ Layer              = &MultiHeadAttention{}
+	_ ByNamer            = &MultiHeadAttention{}
+	_ namesetter         = &MultiHeadAttention{}
+	_ dropoutConfiger    = &MultiHeadAttention{}
+	_ computeFLOPsSetter = &MultiHeadAttention{}
+)
+
+// WithHeads is a ConsOpt for constructing attention layers and transformer encoder blocks only. It sets the number of heads.
+func WithHeads(heads int) ConsOpt {
+	return func(layer Layer) (Layer, error) {
+		switch l := layer.(type) {
+		case *MultiHeadAttention:
+			if heads <= 0 {
+				return nil, errors.Errorf("Expected a positive number of heads. Got %d instead", heads)
+			}
+			l.heads = heads
+			return l, nil
+		case *EncoderBlock:
+			if heads <= 0 {
+				return nil, errors.Errorf("Expected a positive number of heads. Got %d instead", heads)
+			}
+			l.heads = heads
+			return l, nil
+		case Pass:
+			return layer, nil
+		}
+		return nil, errors.Errorf("WithHeads Unhandled Layer type: %T", layer)
+	}
+}
+
+// WithKeyValueDims is a ConsOpt for constructing attention layers and transformer encoder blocks only. It sets the dimensions of the keys (and queries) and of the
+// values of each head.
+func WithKeyValueDims(keyDims, valueDims int) ConsOpt {
+	return func(layer Layer) (Layer, error) {
+		switch l := layer.(type) {
+		case *MultiHeadAttention:
+			if keyDims <= 0 || valueDims <= 0 {
+				return nil, errors.Errorf("Expected positive key and value dims. Got %d and %d instead", keyDims, valueDims)
+			}
+			l.keyDims = keyDims
+			l.valueDims = valueDims
+			return l, nil
+		case *EncoderBlock:
+			if keyDims <= 0 || valueDims <= 0 {
+				return nil, errors.Errorf("Expected positive key and value dims. Got %d and %d instead", keyDims, valueDims)
+			}
+			l.keyDims = keyDims
+			l.valueDims = valueDims
+			return l, nil
+		case Pass:
+			return layer, nil
+		}
+		return nil, errors.Errorf("WithKeyValueDims Unhandled Layer type: %T", layer)
+	}
+}
+
+// WithMask is a ConsOpt for constructing attention layers and transformer encoder blocks only. The mask is added to the attention scores before the softmax, so
+// positions that should not be attended to are masked with a large negative number.
+//
+// The mask is either of shape (T, S), which is shared by all the sequences of the batch, or of shape (N, T, S).
+func WithMask(mask *G.Node) ConsOpt {
+	return func(layer Layer) (Layer, error) {
+		switch l := layer.(type) {
+		case *MultiHeadAttention:
+			l.mask = mask
+			return l, nil
+		case *EncoderBlock:
+			l.mask = mask
+			return l, nil
+		case Pass:
+			return layer, nil
+		}
+		return nil, errors.Errorf("WithMask Unhandled Layer type: %T", layer)
+	}
+}
+
+// MultiHeadAttention is a multi-head scaled dot-product attention layer as per https://arxiv.org/abs/1706.03762
+//
+//	head_i = softmax((QWq_i)(KWk_i)ᵀ/√dk + mask)(VWv_i)
+//	out = concat(head_1, ..., head_h)Wo
+//
+// The queries are of shape (N, T, D), and the keys and values are of shape (N, S, Dk) and (N, S, Dv). The output is of shape (N, T, D).
+// Unbatched inputs of shape (T, D) are also accepted.
+type MultiHeadAttention struct {
+	q, k, v, o *FC
+
+	name      string
+	heads     int
+	keyDims   int // per head
+	valueDims int // per head
+	mask      *G.Node
+	dropout   *float64
+
+	// the number of dims of the last input, and whether it was self-attention. Only used for describing the layer
+	inputDims int
+	self      bool
+
+	initialized  bool
+	computeFLOPs bool
+	flops        int
+}
+
+// NewMultiHeadAttention creates a new multi-head attention layer. It does not initialize the layer.
+// Defaults:
+//
+//	heads: 1
+//	key and value dims: D / heads
+func NewMultiHeadAttention(opts ...ConsOpt) (*MultiHeadAttention, error) {
+	l := &MultiHeadAttention{heads: 1}
+	for _, opt := range opts {
+		var (
+			o   Layer
+			ok  bool
+			err error
+		)
+		if o, err = opt(l); err != nil {
+			return nil, err
+		}
+		if l, ok = o.(*MultiHeadAttention); !ok {
+			return nil, errors.Errorf("Construction Option returned a non MultiHeadAttention. Got %T instead", o)
+		}
+	}
+	return l, nil
+}
+
+// ConsMultiHeadAttention is a construction function for a multi-head attention layer. The input is either a single *Node for
+// self-attention, or a tuple of (query, key, value), such as a gorgonia.Nodes.
+func ConsMultiHeadAttention(in G.Input, opts ...ConsOpt) (retVal Layer, err error) {
+	l, err := NewMultiHeadAttention(opts...)
+	if err != nil {
+		return nil, err
+	}
+	q, k, v, err := attentionInputs(in)
+	if err != nil {
+		return nil, err
+	}
+	if err = l.Init(q, k, v); err != nil {
+		return nil, err
+	}
+	return l, nil
+}
+
+// attentionInputs unpacks the query, key and value.
+func attentionInputs(in G.Input) (q, k, v *G.Node, err error) {
+	ns := in.Nodes()
+	switch len(ns) {
+	case 1:
+		q, k, v = ns[0], ns[0], ns[0]
+	case 3:
+		q, k, v = ns[0], ns[1], ns[2]
+	default:
+		return nil, nil, nil, errors.Errorf("Expected either a single node or a tuple of (query, key, value). Got %d nodes instead", len(ns))
+	}
+	for _, n := range []*G.Node{q, k, v} {
+		if n == nil {
+			return nil, nil, nil, errors.New("Expected the query, key and value to be non-nil")
+		}
+		if n.Dims() != 2 && n.Dims() != 3 {
+			return nil, nil, nil, errors.Errorf("Expected the query, key and value to be of shape (N, T, D) or (T, D). Got %v instead", n.Shape())
+		}
+		if n.Dims() != q.Dims() {
+			return nil, nil, nil, errors.Errorf("Expected the query, key and value to have the same number of dimensions. Got %v and %v", q.Shape(), n.Shape())
+		}
+	}
+	ks, vs := k.Shape(), v.Shape()
+	if ks[ks.Dims()-2] != vs[vs.Dims()-2] {
+		return nil, nil, nil, errors.Errorf("Expected the keys and values to have the same length. Got %v and %v", ks, vs)
+	}
+	return q, k, v, nil
+}
+
+// Init initializes the projections of the query, key, value and output.
+func (l *MultiHeadAttention) Init(xs ...*G.Node) (err error) {
+	if len(xs) != 3 {
+		return errors.Errorf("Expected a query, key and value to initialize MultiHeadAttention %v. Got %d nodes", l.name, len(xs))
+	}
+	q, k, v := xs[0], xs[1], xs[2]
+	model := q.Shape()[q.Dims()-1]
+	if l.keyDims == 0 {
+		if model%l.heads != 0 {
+			return errors.Errorf("Unable to split %d dims into %d heads", model, l.heads)
+		}
+		l.keyDims = model / l.heads
+		l.valueDims = l.keyDims
+	}
+
+	g := q.Graph()
+	of := q.Dtype()
+	l.q = projection(g, of, l.name+"_q", model, l.heads*l.keyDims)
+	l.k = projection(g, of, l.name+"_k", k.Shape()[k.Dims()-1], l.heads*l.keyDims)
+	l.v = projection(g, of, l.name+"_v", v.Shape()[v.Dims()-1], l.heads*l.valueDims)
+	l.o = projection(g, of, l.name+"_o", l.heads*l.valueDims, model)
+	l.initialized = true
+	return nil
+}
+
+// projection creates a batched FC without an activation.
+func projection(g *G.ExprGraph, of tensor.Dtype, name string, in, out int) *FC {
+	w := G.NewMatrix(g, of, G.WithShape(in, out), G.WithInit(G.GlorotU(1)), G.WithName(name+"_W"))
+	b := G.NewMatrix(g, of, G.WithShape(1, out), G.WithInit(G.Zeroes()), G.WithName(name+"_B"))
+	fc := MakeFC(w, b, nil, name, true)
+	fc.size = out
+	return &fc
+}
+
+// Reinit re-initializes the query, key, value and output projections in place.
+func (l *MultiHeadAttention) Reinit() error {
+	if !l.initialized {
+		return nil
+	}
+	for _, p := range []*FC{l.q, l.k, l.v, l.o} {
+		if err := p.Reinit(); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// Model returns the weights of the query, key, value and output projections.
+func (l *MultiHeadAttention) Model() (retVal G.Nodes) {
+	for _, fc := range []*FC{l.q, l.k, l.v, l.o} {
+		if fc != nil {
+			retVal = append(retVal, fc.Model()...)
+		}
+	}
+	return retVal
+}
+
+// Fwd runs the equation forwards. The input is either a single *Node for self-attention, or a tuple of (query, key, value).
+func (l *MultiHeadAttention) Fwd(a G.Input) G.Result {
+	if err := G.CheckOne(a); err != nil {
+		return wrapErr(l, "checking input: %w", err)
+	}
+	q, k, v, err := attentionInputs(a)
+	if err != nil {
+		return wrapErr(l, "checking input: %w", err)
+	}
+	if !l.initialized {
+		if err = l.Init(q, k, v); err != nil {
+			return wrapErr(l, "Initializing a previously uninitialized MultiHeadAttention layer: %w", err)
+		}
+	}
+
+	// unbatched inputs are treated as a batch of 1
+	l.inputDims = q.Dims()
+	l.self = q == k && k == v
+	qshp := q.Shape().Clone()
+	if l.inputDims == 2 {
+		qshp = append(tensor.Shape{1}, qshp...)
+	}
+	n, t, model := qshp[0], qshp[1], qshp[2]
+	s := k.Shape()[k.Dims()-2]
+
+	var Q, K, V *G.Node
+	if Q, err = l.project(l.q, q, n, l.keyDims); err != nil {
+		return wrapErr(l, "projecting the queries: %w", err)
+	}
+	if K, err = l.project(l.k, k, n, l.keyDims); err != nil {
+		return wrapErr(l, "projecting the keys: %w", err)
+	}
+	if V, err = l.project(l.v, v, n, l.valueDims); err != nil {
+		return wrapErr(l, "projecting the values: %w", err)
+	}
+
+	// scores: (N·h, T, dk) × (N·h, S, dk)ᵀ = (N·h, T, S)
+	var scores, scale *G.Node
+	if scores, err = G.BatchedMatMul(Q, K, false, true); err != nil {
+		return wrapErr(l, "computing the scores: %w", err)
+	}
+	if scale, err = constOf(scores, 1/math.Sqrt(float64(l.keyDims))); err != nil {
+		return wrapErr(l, "creating the scale: %w", err)
+	}
+	if scores, err = G.HadamardProd(scores, scale); err != nil {
+		return wrapErr(l, "scaling the scores: %w", err)
+	}
+	if l.mask != nil {
+		if scores, err = l.applyMask(scores, n, t, s); err != nil {
+			return wrapErr(l, "masking the scores: %w", err)
+		}
+	}
+
+	// softmax over the keys
+	var attn *G.Node
+	if attn, err = G.Reshape(scores, tensor.Shape{n * l.heads * t, s}); err != nil {
+		return wrapErr(l, "reshaping the scores: %w", err)
+	}
+	if attn, err = G.SoftMax(attn); err != nil {
+		return wrapErr(l, "softmax: %w", err)
+	}
+	if attn, err = G.Reshape(attn, tensor.Shape{n * l.heads, t, s}); err != nil {
+		return wrapErr(l, "reshaping the attention: %w", err)
+	}
+	if l.dropout != nil {
+		if attn, err = G.Dropout(attn, *l.dropout); err != nil {
+			return wrapErr(l, "applying dropout: %w", err)
+		}
+	}
+
+	// (N·h, T, S) × (N·h, S, dv) = (N·h, T, dv) → (N, h, T, dv) → (N, T, h, dv) → (N·T, h·dv)
+	var heads *G.Node
+	if heads, err = G.BatchedMatMul(attn, V); err != nil {
+		return wrapErr(l, "attending to the values: %w", err)
+	}
+	if heads, err = G.Reshape(heads, tensor.Shape{n, l.heads, t, l.valueDims}); err != nil {
+		return wrapErr(l, "reshaping the heads: %w", err)
+	}
+	if heads, err = G.Transpose(heads, 0, 2, 1, 3); err != nil {
+		return wrapErr(l, "transposing the heads: %w", err)
+	}
+	if heads, err = G.Reshape(heads, tensor.Shape{n * t, l.heads * l.valueDims}); err != nil {
+		return wrapErr(l, "concatenating the heads: %w", err)
+	}
+
+	out := l.o.Fwd(heads)
+	if err = G.CheckOne(out); err != nil {
+		return wrapErr(l, "projecting the output: %w", err)
+	}
+	outshp := tensor.Shape{n, t, model}
+	if l.inputDims == 2 {
+		outshp = tensor.Shape{t, model}
+	}
+	retVal, err := G.Reshape(out.Node(), outshp)
+	if err != nil {
+		return wrapErr(l, "reshaping the output: %w", err)
+	}
+
+	if l.computeFLOPs {
+		l.flops = l.doComputeFLOPs(n, t, s, model, k.Shape()[k.Dims()-1], v.Shape()[v.Dims()-1])
+	}
+	logf("%T shape %s: %v", l, l.name, retVal.Shape())
+	return retVal
+}
+
+// project projects x with the given FC, and splits the heads:
+//
+//	(N, T, D) → (N·T, D) → (N·T, h·d) → (N, T, h, d) → (N, h, T, d) → (N·h, T, d)
+func (l *MultiHeadAttention) project(fc *FC, x *G.Node, n, dims int) (retVal *G.Node, err error) {
+	shp := x.Shape()
+	t, features := shp[shp.Dims()-2], shp[shp.Dims()-1]
+	if retVal, err = G.Reshape(x, tensor.Shape{n * t, features}); err != nil {
+		return nil, err
+	}
+	res := fc.Fwd(retVal)
+	if err = G.CheckOne(res); err != nil {
+		return nil, err
+	}
+	if retVal, err = G.Reshape(res.Node(), tensor.Shape{n, t, l.heads, dims}); err != nil {
+		return nil, err
+	}
+	if retVal, err = G.Transpose(retVal, 0, 2, 1, 3); err != nil {
+		return nil, err
+	}
+	return G.Reshape(retVal, tensor.Shape{n * l.heads, t, dims})
+}
+
+// applyMask adds the mask to the (N·h, T, S) scores.
+func (l *MultiHeadAttention) applyMask(scores *G.Node, n, t, s int) (retVal *G.Node, err error) {
+	mshp := l.mask.Shape()
+	switch {
+	case mshp.Eq(tensor.Shape{t, s}):
+		// shared by every sequence and every head
+		var mask *G.Node
+		if mask, err = G.Reshape(l.mask, tensor.Shape{1, t, s}); err != nil {
+			return nil, err
+		}
+		return BroadcastAdd(scores, mask, nil, []byte{0})
+	case mshp.Eq(tensor.Shape{n, t, s}):
+		// shared by every head of a sequence
+		var mask, s4 *G.Node
+		if mask, err = G.Reshape(l.mask, tensor.Shape{n, 1, t, s}); err != nil {
+			return nil, err
+		}
+		if s4, err = G.Reshape(scores, tensor.Shape{n, l.heads, t, s}); err != nil {
+			return nil, err
+		}
+		if retVal, err = BroadcastAdd(s4, mask, nil, []byte{1}); err != nil {
+			return nil, err
+		}
+		return G.Reshape(retVal, tensor.Shape{n * l.heads, t, s})
+	}
+	return nil, errors.Errorf("Expected the mask to be of shape (%d, %d) or (%d, %d, %d). Got %v instead", t, s, n, t, s, mshp)
+}
+
+// doComputeFLOPs computes the rough number of floating point operations for this layer.
+func (l *MultiHeadAttention) doComputeFLOPs(n, t, s, model, keyIn, valueIn int) int {
+	retVal := l.q.doComputeFLOPs(tensor.Shape{n * t, model})
+	retVal += l.k.doComputeFLOPs(tensor.Shape{n * s, keyIn})
+	retVal += l.v.doComputeFLOPs(tensor.Shape{n * s, valueIn})
+	retVal += l.o.doComputeFLOPs(tensor.Shape{n * t, l.heads * l.valueDims})
+
+	scores := n * l.heads * t * s
+	retVal += scores * (2*l.keyDims - 1) // QKᵀ
+	retVal += scores                     // scaling
+	if l.mask != nil {
+		retVal += scores
+	}
+	retVal += 3 * scores                                // softmax: exp, sum and division
+	retVal += n * l.heads * t * l.valueDims * (2*s - 1) // attention × V
+	return retVal
+}
+
+// ByName returns the projection with the given name. The projections are named with the suffixes "_q", "_k", "_v" and "_o".
+func (l *MultiHeadAttention) ByName(name string) Term {
+	for _, fc := range []*FC{l.q, l.k, l.v, l.o} {
+		if fc != nil && fc.name == name {
+			return fc
+		}
+	}
+	return nil
+}
+
+// SetName sets the name of the layer.
+func (l *MultiHeadAttention) SetName(n string) error {
+	l.name = n
+	return nil
+}
+
+// SetDropout sets the dropout of the attention weights.
+func (l *MultiHeadAttention) SetDropout(prob float64) error {
+	l.dropout = &prob
+	return nil
+}
+
+// SetComputeFLOPs sets whether the FLOPs are computed when the input is forwarded.
+func (l *MultiHeadAttention) SetComputeFLOPs(toCompute bool) error {
+	l.computeFLOPs = toCompute
+	return nil
+}
+
+// FLOPs returns the FLOPs computed by the last Fwd.
+func (l *MultiHeadAttention) FLOPs() int { return l.flops }
+
+// Type will return the hm.Type of the attention layer
+func (l *MultiHeadAttention) Type() hm.Type {
+	return hm.NewFnType(hm.TypeVariable('a'), hm.TypeVariable('a'))
+}
+
+// Shape returns the shape of the output projection.
+func (l *MultiHeadAttention) Shape() tensor.Shape { return l.o.w.Shape() }
+
+// Name will return the name of the attention layer
+func (l *MultiHeadAttention) Name() string { return l.name }
+
+// Describe will describe a multi-head self-attention layer with MatMuls, Reshapes, Transposes and a Softmax.
+//
+// Attention over separate keys and values cannot be described, as a fragment has a single input.
+func (l *MultiHeadAttention) Describe() (*onnx.GraphProto, error) {
+	if !l.initialized {
+		return nil, errors.Errorf("Unable to describe MultiHeadAttention %v. It has not been initialized", l.name)
+	}
+	if !l.self {
+		return nil, errors.Errorf("Unable to describe MultiHeadAttention %v. Only self-attention can be described", l.name)
+	}
+	f := newFragment(l.name, "MultiHeadAttention", l.q.w.Dtype())
+	if l.inputDims == 2 {
+		f.apply("Unsqueeze", []string{f.ints(f.prefix+"_unbatched", 0)})
+	}
+	x := f.last
+
+	// project splits the heads of the projection of x: (N, T, D) → (N, T, h, d) → (N, h, T, d)
+	project := func(fc *FC, dims int, perm ...int) (string, error) {
+		w, err := f.weight(fc.w)
+		if err != nil {
+			return "", err
+		}
+		b, err := f.weight(fc.b)
+		if err != nil {
+			return "", err
+		}
+		f.node("MatMul", []string{x, w})
+		f.apply("Add", []string{b})
+		f.apply("Reshape", []string{f.ints(fc.name+"_heads", 0, 0, l.heads, dims)})
+		return f.apply("Transpose", nil, attrInts("perm", perm...)), nil
+	}
+	q, err := project(l.q, l.keyDims, 0, 2, 1, 3)
+	if err != nil {
+		return nil, err
+	}
+	// the keys are transposed to (N, h, d, S)
+	k, err := project(l.k, l.keyDims, 0, 2, 3, 1)
+	if err != nil {
+		return nil, err
+	}
+	v, err := project(l.v, l.valueDims, 0, 2, 1, 3)
+	if err != nil {
+		return nil, err
+	}
+
+	scale, err := f.scalar(f.prefix+"_scale", 1/math.Sqrt(float64(l.keyDims)))
+	if err != nil {
+		return nil, err
+	}
+	f.node("MatMul", []string{q, k})
+	f.apply("Mul", []string{scale})
+	if l.mask != nil {
+		mask, err := f.weight(l.mask)
+		if err != nil {
+			return nil, err
+		}
+		if l.mask.Dims() == 3 {
+			// (N, T, S) → (N, 1, T, S)
+			scores := f.last
+			mask = f.node("Unsqueeze", []string{mask, f.ints(f.prefix+"_mask_axes", 1)})
+			f.node("Add", []string{scores, mask})
+		} else {
+			f.apply("Add", []string{mask})
+		}
+	}
+	f.apply("Softmax", nil, attrInt("axis", -1))
+	if l.dropout != nil {
+		f.dropout(*l.dropout)
+	}
+	f.apply("MatMul", []string{v})
+	f.apply("Transpose", nil, attrInts("perm", 0, 2, 1, 3))
+	f.apply("Reshape", []string{f.ints(f.prefix+"_concat", 0, 0, l.heads*l.valueDims)})
+	wo, err := f.weight(l.o.w)
+	if err != nil {
+		return nil, err
+	}
+	bo, err := f.weight(l.o.b)
+	if err != nil {
+		return nil, err
+	}
+	f.apply("MatMul", []string{wo})
+	f.apply("Add", []string{bo})
+	if l.inputDims == 2 {
+		f.apply("Squeeze", []string{f.ints(f.prefix+"_unbatched_out", 0)})
+	}
+	return f.graph(), nil
+}
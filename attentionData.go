@@ -0,0 +1,98 @@
+package golgi
+
+import (
+	"github.com/pkg/errors"
+	G "gorgonia.org/gorgonia"
+	"gorgonia.org/tensor"
+)
+
+var (
+	_ Data   = &MultiHeadAttentionData{}
+	_ Dataer = &MultiHeadAttention{}
+)
+
+// MultiHeadAttentionData represents the data of a multi-head attention layer. Q, K, V and O are the data of the projections.
+// Mask is nil if the layer has no mask.
+type MultiHeadAttentionData struct {
+	Name       string
+	Heads      int
+	KeyDims    int
+	ValueDims  int
+	Q, K, V, O *FCData
+	Mask       *tensor.Dense
+	Dropout    *float64
+	InputDims  int
+	Self       bool
+}
+
+// Make creates a *MultiHeadAttention in the given graph. If name is empty, the name of the snapshotted layer is used.
+func (d *MultiHeadAttentionData) Make(g *G.ExprGraph, name string) (Layer, error) {
+	if name == "" {
+		name = d.Name
+	}
+	l := &MultiHeadAttention{
+		name:        name,
+		heads:       d.Heads,
+		keyDims:     d.KeyDims,
+		valueDims:   d.ValueDims,
+		dropout:     cloneProb(d.Dropout),
+		inputDims:   d.InputDims,
+		self:        d.Self,
+		initialized: true,
+	}
+	for _, p := range []struct {
+		fc   **FC
+		data *FCData
+		name string
+	}{{&l.q, d.Q, "_q"}, {&l.k, d.K, "_k"}, {&l.v, d.V, "_v"}, {&l.o, d.O, "_o"}} {
+		if p.data == nil {
+			return nil, errors.Errorf("Unable to make MultiHeadAttention %v without the %v projection", name, p.name[1:])
+		}
+		fc, err := p.data.Make(g, name+p.name)
+		if err != nil {
+			return nil, err
+		}
+		*p.fc = fc.(*FC)
+	}
+	if d.Mask != nil {
+		l.mask = weightFromData(g, d.Mask, name+"_mask")
+	}
+	return l, nil
+}
+
+// ToData snapshots the projections and configuration of the multi-head attention layer. A mask must have a value to be snapshotted.
+func (l *MultiHeadAttention) ToData() (Data, error) {
+	if !l.initialized {
+		return nil, errors.Errorf("Unable to take a snapshot of MultiHeadAttention %v. It has not been initialized", l.name)
+	}
+	retVal := &MultiHeadAttentionData{
+		Name:      l.name,
+		Heads:     l.heads,
+		KeyDims:   l.keyDims,
+		ValueDims: l.valueDims,
+		Dropout:   cloneProb(l.dropout),
+		InputDims: l.inputDims,
+		Self:      l.self,
+	}
+	for _, p := range []struct {
+		data **FCData
+		fc   *FC
+	}{{&retVal.Q, l.q}, {&retVal.K, l.k}, {&retVal.V, l.v}, {&retVal.O, l.o}} {
+		data, err := p.fc.ToData()
+		if err != nil {
+			return nil, errors.Wrapf(err, "ToData of MultiHeadAttention %v", l.name)
+		}
+		*p.data = data.(*FCData)
+	}
+	if l.mask != nil {
+		if l.mask.Value() == nil {
+			return nil, errors.Errorf("Unable to take a snapshot of the mask of MultiHeadAttention %v. It has no value", l.name)
+		}
+		mask, err := snapshot(l.mask)
+		if err != nil {
+			return nil, err
+		}
+		retVal.Mask = mask
+	}
+	return retVal, nil
+}
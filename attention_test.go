@@ -0,0 +1,138 @@
+package golgi
+
+import (
+	"bytes"
+	"math"
+	"testing"
+
+	"github.com/stretchr/testify/require"
+	"gorgonia.org/gorgonia"
+	"gorgonia.org/tensor"
+)
+
+// attentionRef computes multi-head attention over a single sequence with plain loops. mask may be nil.
+func attentionRef(l *MultiHeadAttention, q, k, v []float64, t, s int, mask []float64) []float64 {
+	project := func(fc *FC, x []float64, rows int) []float64 {
+		w := fc.w.Value().Data().([]float64)
+		b := fc.b.Value().Data().([]float64)
+		inner, outer := fc.w.Shape()[0], fc.w.Shape()[1]
+		retVal := make([]float64, rows*outer)
+		for i := 0; i < rows; i++ {
+			for j := 0; j < outer; j++ {
+				acc := b[j]
+				for k := 0; k < inner; k++ {
+					acc += x[i*inner+k] * w[k*outer+j]
+				}
+				retVal[i*outer+j] = acc
+			}
+		}
+		return retVal
+	}
+	h, dk, dv := l.heads, l.keyDims, l.valueDims
+	Q, K, V := project(l.q, q, t), project(l.k, k, s), project(l.v, v, s)
+	heads := make([]float64, t*h*dv)
+	for hd := 0; hd < h; hd++ {
+		for i := 0; i < t; i++ {
+			scores := make([]float64, s)
+			var sum float64
+			for j := 0; j < s; j++ {
+				for d := 0; d < dk; d++ {
+					scores[j] += Q[i*h*dk+hd*dk+d] * K[j*h*dk+hd*dk+d]
+				}
+				scores[j] /= math.Sqrt(float64(dk))
+				if mask != nil {
+					scores[j] += mask[i*s+j]
+				}
+			}
+			max := scores[0]
+			for _, sc := range scores {
+				max = math.Max(max, sc)
+			}
+			for j := range scores {
+				scores[j] = math.Exp(scores[j] - max)
+				sum += scores[j]
+			}
+			for j := range scores {
+				for d := 0; d < dv; d++ {
+					heads[i*h*dv+hd*dv+d] += scores[j] / sum * V[j*h*dv+hd*dv+d]
+				}
+			}
+		}
+	}
+	return project(l.o, heads, t)
+}
+
+func TestMultiHeadAttention(t *testing.T) {
+	c := require.New(t)
+	xs := []float64{0.1, -0.2, 0.3, 0.4, 0.5, -0.6, 0.7, 0.8, -0.9, 1.0, 0.2, 0.1}
+	g := gorgonia.NewGraph()
+	x := gorgonia.NewTensor(g, tensor.Float64, 3, gorgonia.WithName("x"), gorgonia.WithShape(1, 3, 4), gorgonia.WithValue(tensor.New(tensor.WithShape(1, 3, 4), tensor.WithBacking(xs))))
+
+	l, err := ConsMultiHeadAttention(x, WithName("attn"), WithHeads(2), ComputeFLOPs(true))
+	c.NoError(err)
+	mha := l.(*MultiHeadAttention)
+	c.Len(mha.Model(), 8)
+	c.Equal("attn_q_W", mha.Model()[0].Name())
+	c.Equal(2, mha.keyDims)
+	c.NotNil(mha.ByName("attn_o"))
+
+	out := mha.Fwd(x)
+	c.NoError(gorgonia.CheckOne(out))
+	c.Equal(tensor.Shape{1, 3, 4}, out.Node().Shape())
+	c.NotZero(mha.FLOPs())
+	cost, err := gorgonia.Sum(out.Node())
+	c.NoError(err)
+	_, err = gorgonia.Grad(cost, mha.Model()...)
+	c.NoError(err)
+	c.InDeltaSlice(attentionRef(mha, xs, xs, xs, 3, 3, nil), runValue(t, g, out.Node()), 1e-10)
+
+	desc, err := mha.Describe()
+	c.NoError(err)
+	c.Contains(opTypes(desc), "Softmax")
+	checkWellFormed(t, desc)
+}
+
+func TestMultiHeadAttention_Cross(t *testing.T) {
+	c := require.New(t)
+	qs := []float64{0.1, -0.2, 0.3, 0.4, 0.5, -0.6}
+	kvs := []float64{0.7, 0.8, -0.9, 1.0, 0.2, 0.1, -0.3, 0.5}
+	masks := []float64{0, -1e9, -1e9, 0, 0, 0, -1e9, 0}
+
+	g := gorgonia.NewGraph()
+	q := gorgonia.NewMatrix(g, tensor.Float64, gorgonia.WithName("q"), gorgonia.WithShape(2, 3), gorgonia.WithValue(tensor.New(tensor.WithShape(2, 3), tensor.WithBacking(qs))))
+	kv := gorgonia.NewMatrix(g, tensor.Float64, gorgonia.WithName("kv"), gorgonia.WithShape(4, 2), gorgonia.WithValue(tensor.New(tensor.WithShape(4, 2), tensor.WithBacking(kvs))))
+	mask := gorgonia.NewMatrix(g, tensor.Float64, gorgonia.WithName("mask"), gorgonia.WithShape(2, 4), gorgonia.WithValue(tensor.New(tensor.WithShape(2, 4), tensor.WithBacking(masks))))
+
+	mha, err := NewMultiHeadAttention(WithName("cross"), WithHeads(2), WithKeyValueDims(3, 2), WithMask(mask))
+	c.NoError(err)
+	out := mha.Fwd(gorgonia.Nodes{q, kv, kv})
+	c.NoError(gorgonia.CheckOne(out))
+	c.Equal(tensor.Shape{2, 3}, out.Node().Shape())
+	c.Equal(tensor.Shape{3, 6}, mha.q.w.Shape())
+	c.Equal(tensor.Shape{2, 4}, mha.v.w.Shape())
+	want := runValue(t, g, out.Node())
+	c.InDeltaSlice(attentionRef(mha, qs, kvs, kvs, 2, 4, masks), want, 1e-10)
+
+	_, err = mha.Describe()
+	c.Error(err)
+
+	// a checkpoint keeps the projections and the mask
+	var buf bytes.Buffer
+	c.NoError(SaveCheckpoint(&buf, mha))
+	g2 := gorgonia.NewGraph()
+	q2 := gorgonia.NewMatrix(g2, tensor.Float64, gorgonia.WithName("q"), gorgonia.WithShape(2, 3), gorgonia.WithValue(tensor.New(tensor.WithShape(2, 3), tensor.WithBacking(qs))))
+	kv2 := gorgonia.NewMatrix(g2, tensor.Float64, gorgonia.WithName("kv"), gorgonia.WithShape(4, 2), gorgonia.WithValue(tensor.New(tensor.WithShape(4, 2), tensor.WithBacking(kvs))))
+	loaded, err := LoadCheckpoint(g2, &buf)
+	c.NoError(err)
+	c.Equal("cross", loaded.Name())
+	out2 := loaded.Fwd(gorgonia.Nodes{q2, kv2, kv2})
+	c.NoError(gorgonia.CheckOne(out2))
+	c.Equal(want, runValue(t, g2, out2.Node()))
+
+	// errors
+	c.Error(gorgonia.CheckOne(mha.Fwd(gorgonia.Nodes{q, kv})))
+	_, err = NewMultiHeadAttention(WithHeads(0))
+	c.Error(err)
+	_, err = ConsMultiHeadAttention(q, WithHeads(2))
+	c.Error(err)
+}
@@ -0,0 +1,377 @@
+package golgi
+
+import (
+	"github.com/chewxy/hm"
+	"github.com/pkg/errors"
+	"gorgonia.org/golgi/onnx"
+	G "gorgonia.org/gorgonia"
+	"gorgonia.org/tensor"
+)
+
+var (
+	_ Layer              = &BatchNorm{}
+	_ Runner             = &BatchNorm{}
+	_ postStepper        = &BatchNorm{}
+	_ namesetter         = &BatchNorm{}
+	_ computeFLOPsSetter = &BatchNorm{}
+)
+
+// WithMomentum is a ConsOpt for constructing BatchNorms only. It sets the momentum of the running statistics:
+//
+//	running = momentum × running + (1 - momentum) × batch
+func WithMomentum(momentum float64) ConsOpt {
+	return func(layer Layer) (Layer, error) {
+		switch l := layer.(type) {
+		case *BatchNorm:
+			if momentum < 0 || momentum >= 1 {
+				return nil, errors.Errorf("Expected momentum to be in [0, 1). Got %v instead", momentum)
+			}
+			l.momentum = momentum
+			return l, nil
+		case Pass:
+			return layer, nil
+		}
+		return nil, errors.Errorf("WithMomentum Unhandled Layer type: %T", layer)
+	}
+}
+
+// AsInference is a ConsOpt for constructing BatchNorms only. A BatchNorm in inference mode normalizes its input with
+// the running statistics instead of the statistics of the batch.
+//
+// Because the mode is baked into the expression graph, it has to be set before Fwd is called. A trained BatchNorm
+// may be switched to inference with Redefine, and then forwarded again.
+func AsInference(inference bool) ConsOpt {
+	return func(layer Layer) (Layer, error) {
+		switch l := layer.(type) {
+		case *BatchNorm:
+			l.inference = inference
+			return l, nil
+		case Pass:
+			return layer, nil
+		}
+		return nil, errors.Errorf("AsInference Unhandled Layer type: %T", layer)
+	}
+}
+
+// BatchNorm performs batch normalization as per https://arxiv.org/abs/1502.03167
+//
+// The input is either a matrix of shape (N, F), or a 4-tensor of shape (N, C, H, W). The statistics are computed per feature
+// (or per channel), over all the other axes.
+//
+// The learnable scale and shift are returned by Model(). The running mean and variance are not: they are not learnt, and are
+// instead updated outside the graph when the BatchNorm is Run, after the machine has been run. In inference mode, the
+// running statistics are used to normalize the input.
+type BatchNorm struct {
+	scale, shift *G.Node
+
+	// running statistics. These are not part of the model.
+	mean, variance *G.Node
+
+	// statistics of the last batch, read out of the graph
+	batchMean, batchVar G.Value
+
+	name      string
+	eps       float64
+	momentum  float64
+	inference bool
+
+	initialized  bool
+	computeFLOPs bool
+	flops        int
+}
+
+// NewBatchNorm creates a new batch normalization layer. It does not initialize the layer.
+// Defaults:
+//
+//	eps: 1e-5
+//	momentum: 0.9
+func NewBatchNorm(opts ...ConsOpt) (*BatchNorm, error) {
+	l := &BatchNorm{
+		eps:      1e-5,
+		momentum: 0.9,
+	}
+	for _, opt := range opts {
+		var (
+			o   Layer
+			ok  bool
+			err error
+		)
+		if o, err = opt(l); err != nil {
+			return nil, err
+		}
+		if l, ok = o.(*BatchNorm); !ok {
+			return nil, errors.Errorf("Construction Option returned a non BatchNorm. Got %T instead", o)
+		}
+	}
+	if l.scale != nil && l.shift != nil && l.mean != nil && l.variance != nil {
+		l.initialized = true
+	}
+	return l, nil
+}
+
+// ConsBatchNorm is a construction function for a batch normalization layer. `in` has to be at least a *gorgonia.Node
+func ConsBatchNorm(in G.Input, opts ...ConsOpt) (retVal Layer, err error) {
+	x := in.Node()
+	if x == nil {
+		return nil, errors.Errorf("ConsBatchNorm expects a *Node. Got input %v of %T instead", in, in)
+	}
+	if _, _, err = batchNormAxes(x.Shape()); err != nil {
+		return nil, err
+	}
+
+	l, err := NewBatchNorm(opts...)
+	if err != nil {
+		return nil, err
+	}
+	if err = l.Init(x); err != nil {
+		return nil, err
+	}
+	return l, nil
+}
+
+// batchNormAxes returns the axes that the statistics are computed over, and the shape of the statistics.
+func batchNormAxes(shp tensor.Shape) (along []int, statShape tensor.Shape, err error) {
+	switch shp.Dims() {
+	case 2:
+		return []int{0}, tensor.Shape{1, shp[1]}, nil
+	case 4:
+		return []int{0, 2, 3}, tensor.Shape{1, shp[1], 1, 1}, nil
+	}
+	return nil, nil, errors.Errorf("Expected the input of a BatchNorm to be either (N, F) or (N, C, H, W). Got %v instead", shp)
+}
+
+// Init initializes the scale, the shift and the running statistics.
+func (l *BatchNorm) Init(xs ...*G.Node) (err error) {
+	x := xs[0]
+	g := x.Graph()
+	of := x.Dtype()
+	_, statShape, err := batchNormAxes(x.Shape())
+	if err != nil {
+		return err
+	}
+	dims := statShape.Dims()
+	l.scale = G.NewTensor(g, of, dims, G.WithShape(statShape...), G.WithInit(G.Ones()), G.WithName(l.name+"_scale"))
+	l.shift = G.NewTensor(g, of, dims, G.WithShape(statShape...), G.WithInit(G.Zeroes()), G.WithName(l.name+"_shift"))
+	l.mean = G.NewTensor(g, of, dims, G.WithShape(statShape...), G.WithInit(G.Zeroes()), G.WithName(l.name+"_mean"))
+	l.variance = G.NewTensor(g, of, dims, G.WithShape(statShape...), G.WithInit(G.Ones()), G.WithName(l.name+"_var"))
+	l.initialized = true
+	return nil
+}
+
+// Reinit re-initializes the scale and the shift in place, and resets the running statistics.
+func (l *BatchNorm) Reinit() error {
+	for _, s := range []struct {
+		n  *G.Node
+		fn G.InitWFn
+	}{{l.scale, G.Ones()}, {l.shift, G.Zeroes()}, {l.mean, G.Zeroes()}, {l.variance, G.Ones()}} {
+		if err := reinitNode(s.n, s.fn); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// Model returns the scale and the shift. The running statistics are not part of the model.
+func (l *BatchNorm) Model() G.Nodes { return G.Nodes{l.scale, l.shift} }
+
+// Fwd normalizes the input.
+func (l *BatchNorm) Fwd(a G.Input) G.Result {
+	if err := G.CheckOne(a); err != nil {
+		return wrapErr(l, "checking input: %w", err)
+	}
+	x := a.Node()
+	along, statShape, err := batchNormAxes(x.Shape())
+	if err != nil {
+		return wrapErr(l, "checking input: %w", err)
+	}
+
+	if !l.initialized {
+		if err = l.Init(x); err != nil {
+			return wrapErr(l, "Initializing a previously uninitialized BatchNorm layer: %w", err)
+		}
+	}
+	if !l.scale.Shape().Eq(statShape) {
+		return wrapErr(l, "expected the statistics of %v to be of shape %v. Got %v instead", x.Shape(), statShape, l.scale.Shape())
+	}
+
+	broadcastOn := make([]byte, len(along))
+	for i, axis := range along {
+		broadcastOn[i] = byte(axis)
+	}
+
+	var μ, xmμ, σ2, eps, sd, newX, retVal *G.Node
+	if l.inference {
+		μ = l.mean
+		if xmμ, err = G.BroadcastSub(x, μ, nil, broadcastOn); err != nil {
+			return wrapErr(l, "computing x-μ: %w", err)
+		}
+		σ2 = l.variance
+	} else {
+		if μ, err = G.Mean(x, along...); err != nil {
+			return wrapErr(l, "computing the batch mean: %w", err)
+		}
+		if μ, err = G.Reshape(μ, statShape); err != nil {
+			return wrapErr(l, "reshaping the batch mean: %w", err)
+		}
+		if xmμ, err = G.BroadcastSub(x, μ, nil, broadcastOn); err != nil {
+			return wrapErr(l, "computing x-μ: %w", err)
+		}
+		if σ2, err = G.Square(xmμ); err != nil {
+			return wrapErr(l, "computing (x-μ)²: %w", err)
+		}
+		if σ2, err = G.Mean(σ2, along...); err != nil {
+			return wrapErr(l, "computing the batch variance: %w", err)
+		}
+		if σ2, err = G.Reshape(σ2, statShape); err != nil {
+			return wrapErr(l, "reshaping the batch variance: %w", err)
+		}
+
+		// the statistics of the batch are read out so that the running statistics may be updated when the layer is Run.
+		G.Read(μ, &l.batchMean)
+		G.Read(σ2, &l.batchVar)
+	}
+
+	if eps, err = constOf(x, l.eps); err != nil {
+		return wrapErr(l, "creating eps: %w", err)
+	}
+	if sd, err = G.Add(σ2, eps); err != nil {
+		return wrapErr(l, "perturbing the variance: %w", err)
+	}
+	if sd, err = G.Sqrt(sd); err != nil {
+		return wrapErr(l, "computing the standard deviation: %w", err)
+	}
+	if newX, err = G.BroadcastHadamardDiv(xmμ, sd, nil, broadcastOn); err != nil {
+		return wrapErr(l, "computing (x-μ)/σ: %w", err)
+	}
+	if retVal, err = G.BroadcastHadamardProd(newX, l.scale, nil, broadcastOn); err != nil {
+		return wrapErr(l, "scaling: %w", err)
+	}
+	if retVal, err = G.BroadcastAdd(retVal, l.shift, nil, broadcastOn); err != nil {
+		return wrapErr(l, "shifting: %w", err)
+	}
+
+	if l.computeFLOPs {
+		l.flops = l.doComputeFLOPs(x.Shape())
+	}
+	logf("%T shape %s: %v", l, l.name, retVal.Shape())
+	return retVal
+}
+
+// Run updates the running statistics with the statistics of the last batch. It has to be called after the machine has been run.
+// The input is ignored.
+//
+// Run is a no-op in inference mode, or if the machine has not been run since the last update.
+func (l *BatchNorm) Run(a G.Input) error {
+	if l.inference || l.batchMean == nil || l.batchVar == nil {
+		return nil
+	}
+	if err := l.update(l.mean, l.batchMean); err != nil {
+		return errors.Wrapf(err, "Unable to update the running mean of BatchNorm %v", l.name)
+	}
+	if err := l.update(l.variance, l.batchVar); err != nil {
+		return errors.Wrapf(err, "Unable to update the running variance of BatchNorm %v", l.name)
+	}
+
+	// the next run of the machine will read fresh statistics into these.
+	l.batchMean, l.batchVar = nil, nil
+	return nil
+}
+
+// Runners returns the BatchNorm itself.
+func (l *BatchNorm) Runners() []Runner { return []Runner{l} }
+
+// postStep updates the running statistics. The statistics are those of the batch of the last step, so a Trainer runs it after
+// the step of the solver instead of before the step.
+func (l *BatchNorm) postStep() error { return l.Run(nil) }
+
+// update updates the value of a running statistic in place.
+func (l *BatchNorm) update(running *G.Node, batch G.Value) error {
+	m := l.momentum
+	switch r := running.Value().Data().(type) {
+	case []float64:
+		b, ok := batch.Data().([]float64)
+		if !ok || len(b) != len(r) {
+			return errors.Errorf("Expected %d float64s. Got %v instead", len(r), batch)
+		}
+		for i := range r {
+			r[i] = m*r[i] + (1-m)*b[i]
+		}
+	case []float32:
+		b, ok := batch.Data().([]float32)
+		if !ok || len(b) != len(r) {
+			return errors.Errorf("Expected %d float32s. Got %v instead", len(r), batch)
+		}
+		m32 := float32(m)
+		for i := range r {
+			r[i] = m32*r[i] + (1-m32)*b[i]
+		}
+	default:
+		return errors.Errorf("BatchNorm only supports Float32 or Float64. Got %v instead", running.Dtype())
+	}
+	return nil
+}
+
+// Stats returns the running mean and variance.
+func (l *BatchNorm) Stats() (mean, variance *G.Node) { return l.mean, l.variance }
+
+// SetName sets the name of the layer.
+func (l *BatchNorm) SetName(n string) error {
+	l.name = n
+	return nil
+}
+
+// SetComputeFLOPs sets whether the FLOPs are computed when the input is forwarded.
+func (l *BatchNorm) SetComputeFLOPs(toCompute bool) error {
+	l.computeFLOPs = toCompute
+	return nil
+}
+
+// Type will return the hm.Type of the batch normalization layer
+func (l *BatchNorm) Type() hm.Type { return hm.NewFnType(hm.TypeVariable('a'), hm.TypeVariable('a')) }
+
+// Shape returns the shape of the scale.
+func (l *BatchNorm) Shape() tensor.Shape { return l.scale.Shape() }
+
+// Name will return the name of the batch normalization layer
+func (l *BatchNorm) Name() string { return l.name }
+
+// FLOPs returns the FLOPs computed by the last Fwd.
+func (l *BatchNorm) FLOPs() int { return l.flops }
+
+// Describe will describe a batch normalization layer as a BatchNormalization.
+func (l *BatchNorm) Describe() (*onnx.GraphProto, error) {
+	if l.scale == nil {
+		return nil, errors.Errorf("Unable to describe BatchNorm %v. It has not been initialized", l.name)
+	}
+	f := newFragment(l.name, "BatchNorm", l.scale.Dtype())
+	var inputs []string
+	for _, n := range []*G.Node{l.scale, l.shift, l.mean, l.variance} {
+		// ONNX expects the statistics as vectors of length C
+		v, err := snapshot(n)
+		if err != nil {
+			return nil, err
+		}
+		if err = v.Reshape(v.Shape().TotalSize()); err != nil {
+			return nil, err
+		}
+		name, err := f.value(n.Name(), v)
+		if err != nil {
+			return nil, err
+		}
+		inputs = append(inputs, name)
+	}
+	f.apply("BatchNormalization", inputs, attrFloat("epsilon", l.eps), attrFloat("momentum", l.momentum))
+	return f.graph(), nil
+}
+
+// doComputeFLOPs computes the rough number of floating point operations for this layer.
+func (l *BatchNorm) doComputeFLOPs(input tensor.Shape) int {
+	n := input.TotalSize()
+	stats := l.scale.Shape().TotalSize()
+	retVal := 4 * n     // (x-μ), division by σ, scale and shift
+	retVal += 2 * stats // perturbation and sqrt
+	if !l.inference {
+		retVal += 3 * n // mean, (x-μ)² and variance
+	}
+	return retVal
+}
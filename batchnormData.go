@@ -0,0 +1,60 @@
+package golgi
+
+import (
+	"github.com/pkg/errors"
+	G "gorgonia.org/gorgonia"
+	"gorgonia.org/tensor"
+)
+
+var (
+	_ Data   = &BatchNormData{}
+	_ Dataer = &BatchNorm{}
+)
+
+// BatchNormData represents the data of a batch normalization layer, including its running statistics.
+type BatchNormData struct {
+	Name                    string
+	Scale, Shift, Mean, Var *tensor.Dense
+	Eps, Momentum           float64
+	Inference               bool
+}
+
+// Make creates a *BatchNorm in the given graph. If name is empty, the name of the snapshotted layer is used.
+func (d *BatchNormData) Make(g *G.ExprGraph, name string) (Layer, error) {
+	if name == "" {
+		name = d.Name
+	}
+	l, err := NewBatchNorm(WithName(name), WithEps(d.Eps), WithMomentum(d.Momentum), AsInference(d.Inference))
+	if err != nil {
+		return nil, err
+	}
+	l.scale = weightFromData(g, d.Scale, name+"_scale")
+	l.shift = weightFromData(g, d.Shift, name+"_shift")
+	l.mean = weightFromData(g, d.Mean, name+"_mean")
+	l.variance = weightFromData(g, d.Var, name+"_var")
+	l.initialized = l.scale != nil && l.shift != nil && l.mean != nil && l.variance != nil
+	return l, nil
+}
+
+// ToData snapshots the weights, running statistics and configuration of the batch normalization layer.
+func (l *BatchNorm) ToData() (Data, error) {
+	if l.scale == nil {
+		return nil, errors.Errorf("Unable to take a snapshot of BatchNorm %v. It has not been initialized", l.name)
+	}
+	retVal := &BatchNormData{
+		Name:      l.name,
+		Eps:       l.eps,
+		Momentum:  l.momentum,
+		Inference: l.inference,
+	}
+	var err error
+	for _, s := range []struct {
+		n    *G.Node
+		into **tensor.Dense
+	}{{l.scale, &retVal.Scale}, {l.shift, &retVal.Shift}, {l.mean, &retVal.Mean}, {l.variance, &retVal.Var}} {
+		if *s.into, err = snapshot(s.n); err != nil {
+			return nil, err
+		}
+	}
+	return retVal, nil
+}
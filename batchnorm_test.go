@@ -0,0 +1,113 @@
+package golgi
+
+import (
+	"bytes"
+	"math"
+	"testing"
+
+	"github.com/stretchr/testify/require"
+	"gorgonia.org/gorgonia"
+	"gorgonia.org/tensor"
+)
+
+func TestBatchNorm(t *testing.T) {
+	c := require.New(t)
+	g := gorgonia.NewGraph()
+	xT := tensor.New(tensor.WithShape(4, 2), tensor.WithBacking([]float64{1, 10, 2, 20, 3, 30, 4, 40}))
+	x := gorgonia.NewMatrix(g, tensor.Float64, gorgonia.WithName("x"), gorgonia.WithShape(4, 2), gorgonia.WithValue(xT))
+
+	l, err := ConsBatchNorm(x, WithName("bn"), WithMomentum(0.5), WithEps(0), ComputeFLOPs(true))
+	c.NoError(err)
+	bn := l.(*BatchNorm)
+	c.Len(bn.Model(), 2)
+
+	out := bn.Fwd(x)
+	c.NoError(gorgonia.CheckOne(out))
+	c.Equal(tensor.Shape{4, 2}, out.Node().Shape())
+	c.NotZero(bn.FLOPs())
+	cost, err := gorgonia.Sum(out.Node())
+	c.NoError(err)
+	_, err = gorgonia.Grad(cost, bn.Model()...)
+	c.NoError(err)
+
+	m := gorgonia.NewTapeMachine(g)
+	defer m.Close()
+	c.NoError(m.RunAll())
+
+	// each column is normalized with the statistics of the batch
+	sd := math.Sqrt(1.25)
+	want := []float64{-1.5 / sd, -1.5 / sd, -0.5 / sd, -0.5 / sd, 0.5 / sd, 0.5 / sd, 1.5 / sd, 1.5 / sd}
+	c.InDeltaSlice(want, out.Node().Value().Data(), 1e-10)
+
+	// the running statistics are only updated after the step, and only once per run of the machine
+	mean, variance := bn.Stats()
+	c.Equal([]float64{0, 0}, mean.Value().Data())
+	c.NoError(bn.Run(nil))
+	c.InDeltaSlice([]float64{1.25, 12.5}, mean.Value().Data(), 1e-10)
+	c.InDeltaSlice([]float64{0.5 + 0.5*1.25, 0.5 + 0.5*125}, variance.Value().Data(), 1e-10)
+	c.NoError(bn.Run(nil))
+	c.InDeltaSlice([]float64{1.25, 12.5}, mean.Value().Data(), 1e-10)
+
+	// inference uses the running statistics
+	_, err = Redefine(bn, AsInference(true))
+	c.NoError(err)
+	inf := bn.Fwd(x)
+	c.NoError(gorgonia.CheckOne(inf))
+	m2 := gorgonia.NewTapeMachine(g)
+	defer m2.Close()
+	c.NoError(m2.RunAll())
+	got := inf.Node().Value().Data().([]float64)
+	c.InDelta((1-1.25)/math.Sqrt(0.5+0.5*1.25), got[0], 1e-10)
+	c.InDelta((40-12.5)/math.Sqrt(0.5+0.5*125), got[7], 1e-10)
+
+	// the running statistics survive a checkpoint
+	var buf bytes.Buffer
+	c.NoError(SaveCheckpoint(&buf, bn))
+	loaded, err := LoadCheckpoint(gorgonia.NewGraph(), &buf)
+	c.NoError(err)
+	lmean, _ := loaded.(*BatchNorm).Stats()
+	c.Equal(mean.Value().Data(), lmean.Value().Data())
+	c.True(loaded.(*BatchNorm).inference)
+}
+
+func TestBatchNorm_4D(t *testing.T) {
+	c := require.New(t)
+	g := gorgonia.NewGraph()
+	x := gorgonia.NewTensor(g, tensor.Float32, 4, gorgonia.WithName("x"), gorgonia.WithShape(2, 3, 4, 4), gorgonia.WithInit(gorgonia.GlorotU(1)))
+
+	nn := Compose(
+		L(ConsConv, WithName("conv"), WithSize(3, 3), WithKernelShape(tensor.Shape{3, 3}), WithActivation(nil), WithBias(false)),
+		L(ConsBatchNorm, WithName("bn")),
+	)
+	out := nn.Fwd(x)
+	c.NoError(gorgonia.CheckOne(out))
+	c.Equal(tensor.Shape{2, 3, 4, 4}, out.Node().Shape())
+	c.Len(nn.Model(), 3)
+
+	m := gorgonia.NewTapeMachine(g)
+	defer m.Close()
+	c.NoError(m.RunAll())
+
+	// every channel has a mean of 0 and a variance of 1
+	data := out.Node().Value().Data().([]float32)
+	for ch := 0; ch < 3; ch++ {
+		var sum, sumSq float64
+		for n := 0; n < 2; n++ {
+			for _, v := range data[(n*3+ch)*16 : (n*3+ch+1)*16] {
+				sum += float64(v)
+				sumSq += float64(v) * float64(v)
+			}
+		}
+		c.InDelta(0, sum/32, 1e-4)
+		c.InDelta(1, sumSq/32, 1e-2)
+	}
+
+	rs := nn.Runners()
+	c.Len(rs, 1)
+	c.NoError(rs[0].Run(nil))
+
+	_, err := ConsBatchNorm(gorgonia.NewVector(g, tensor.Float32, gorgonia.WithShape(3)))
+	c.Error(err)
+	_, err = NewBatchNorm(WithMomentum(1))
+	c.Error(err)
+}
@@ -0,0 +1,347 @@
+package golgi
+
+import (
+	"math"
+
+	"github.com/chewxy/hm"
+	"github.com/pkg/errors"
+	"gorgonia.org/golgi/onnx"
+	G "gorgonia.org/gorgonia"
+	"gorgonia.org/tensor"
+)
+
+var (
+	_ Layer     = &Bidir{}
+	_ ByNamer   = &Bidir{}
+	_ Runnerser = &Bidir{}
+	_ flopser   = &Bidir{}
+)
+
+// MergeMode is how the results of the two directions of a bidirectional layer are merged.
+type MergeMode int
+
+const (
+	// MergeConcat concatenates the results along the last axis.
+	MergeConcat MergeMode = iota
+	// MergeSum adds the results.
+	MergeSum
+	// MergeMean averages the results.
+	MergeMean
+)
+
+// sequencer is any recurrent layer that processes whole sequences.
+type sequencer interface {
+	// timeAxis returns the axis of time of the sequences the layer processes. It returns false if the layer only computes a single time step.
+	timeAxis() (int, bool)
+}
+
+// Bidir is a bidirectional recurrent layer. It is made of two copies of a recurrent layer: the forwards copy is fed the sequence,
+// and the backwards copy is fed the time-reversed sequence. The results of both copies are then merged.
+//
+// If the result of the recurrent layer is a sequence, the result of the backwards copy is reversed again before merging,
+// so that both results are aligned in time.
+type Bidir struct {
+	cons  LayerCons
+	opts  []ConsOpt
+	merge MergeMode
+
+	name   string
+	fw, bw Layer
+
+	// set when the copies are constructed
+	axis        int
+	sequenceOut bool
+}
+
+// Bidirectional creates a bidirectional layer out of any recurrent layer that processes whole sequences, such as a LSTM constructed
+// AsSequence. The copies are constructed lazily when the layer is first forwarded.
+//
+// The options are passed to both copies. The copies are named by suffixing the name given by the options with "_fw" and "_bw",
+// so that their weights have distinct names.
+//
+// e.g.
+//
+//	bi := Bidirectional(ConsLSTM, MergeConcat, WithName("enc"), WithSize(64), AsSequence(TimeMajor), ReturnSequences(true))
+func Bidirectional(layer LayerCons, merge MergeMode, opts ...ConsOpt) *Bidir {
+	return &Bidir{
+		cons:  layer,
+		opts:  opts,
+		merge: merge,
+	}
+}
+
+// withNameSuffix is a construction option that suffixes the name that was given to the layer by the preceding construction options.
+// The unsuffixed name is recorded in base.
+func withNameSuffix(base *string, suffix string) ConsOpt {
+	return func(layer Layer) (Layer, error) {
+		ns, ok := layer.(namesetter)
+		if !ok {
+			return nil, errors.Errorf("Unable to name %T. It is not nameable", layer)
+		}
+		*base = layer.Name()
+		name := suffix
+		if *base != "" {
+			name = *base + "_" + suffix
+		}
+		return layer, ns.SetName(name)
+	}
+}
+
+// Reinit re-initializes both copies in place.
+func (l *Bidir) Reinit() error {
+	for _, c := range []Layer{l.fw, l.bw} {
+		if c == nil {
+			continue
+		}
+		if err := Reinit(c); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// Model returns the weights of the forwards copy, followed by the weights of the backwards copy.
+func (l *Bidir) Model() (retVal G.Nodes) {
+	if l.fw != nil {
+		retVal = append(retVal, l.fw.Model()...)
+	}
+	if l.bw != nil {
+		retVal = append(retVal, l.bw.Model()...)
+	}
+	return retVal
+}
+
+// Fwd runs both directions of the layer over the sequence, and merges the results.
+func (l *Bidir) Fwd(a G.Input) G.Result {
+	if err := G.CheckOne(a); err != nil {
+		return wrapErr(l, "checking input: %w", err)
+	}
+	x := a.Node()
+	if x == nil {
+		return wrapErr(l, "expected a *Node. Got %v of %T instead", a, a)
+	}
+
+	if l.fw == nil {
+		if err := l.construct(x); err != nil {
+			return wrapErr(l, "constructing: %w", err)
+		}
+	}
+
+	reversed, err := reverseAlong(x, l.axis)
+	if err != nil {
+		return wrapErr(l, "reversing the input: %w", err)
+	}
+
+	fw := l.fw.Fwd(x)
+	if err = G.CheckOne(fw); err != nil {
+		return wrapErr(l, "forwards: %w", err)
+	}
+	bw := l.bw.Fwd(reversed)
+	if err = G.CheckOne(bw); err != nil {
+		return wrapErr(l, "backwards: %w", err)
+	}
+	fwOut, bwOut := fw.Node(), bw.Node()
+	if fwOut == nil || bwOut == nil {
+		return wrapErr(l, "expected the recurrent layer to return a Result with a Node")
+	}
+
+	// a sequence of results has to be realigned in time
+	l.sequenceOut = bwOut.Dims() == x.Dims()
+	if l.sequenceOut {
+		if bwOut, err = reverseAlong(bwOut, l.axis); err != nil {
+			return wrapErr(l, "reversing the backwards result: %w", err)
+		}
+	}
+
+	var retVal *G.Node
+	switch l.merge {
+	case MergeConcat:
+		retVal, err = G.Concat(fwOut.Dims()-1, fwOut, bwOut)
+	case MergeSum:
+		retVal, err = G.Add(fwOut, bwOut)
+	case MergeMean:
+		var half *G.Node
+		if retVal, err = G.Add(fwOut, bwOut); err != nil {
+			break
+		}
+		if half, err = constOf(retVal, 0.5); err != nil {
+			break
+		}
+		retVal, err = G.HadamardProd(retVal, half)
+	default:
+		err = errors.Errorf("unknown merge mode %d", l.merge)
+	}
+	if err != nil {
+		return wrapErr(l, "merging: %w", err)
+	}
+	return retVal
+}
+
+// construct constructs both copies of the recurrent layer.
+func (l *Bidir) construct(x *G.Node) (err error) {
+	if l.cons == nil {
+		return errors.New("no construction function")
+	}
+	defer func() {
+		if err != nil {
+			l.fw, l.bw = nil, nil
+		}
+	}()
+	var base string
+	fwOpts := append(append([]ConsOpt{}, l.opts...), withNameSuffix(&base, "fw"))
+	bwOpts := append(append([]ConsOpt{}, l.opts...), withNameSuffix(&base, "bw"))
+
+	if l.fw, err = l.cons(x, fwOpts...); err != nil {
+		return errors.Wrap(err, "forwards copy")
+	}
+	if l.bw, err = l.cons(x, bwOpts...); err != nil {
+		return errors.Wrap(err, "backwards copy")
+	}
+	l.name = base
+
+	seq, ok := l.fw.(sequencer)
+	if !ok {
+		return errors.Errorf("%T does not process sequences", l.fw)
+	}
+	if l.axis, ok = seq.timeAxis(); !ok {
+		return errors.Errorf("%v only computes a single time step. It has to be constructed to process whole sequences", l.fw.Name())
+	}
+	return nil
+}
+
+// reverseAlong reverses the order of the slices of x along the given axis.
+func reverseAlong(x *G.Node, axis int) (*G.Node, error) {
+	if axis >= x.Dims() {
+		return nil, errors.Errorf("Unable to reverse %v along axis %d", x.Shape(), axis)
+	}
+	n := x.Shape()[axis]
+	if n == 1 {
+		return x, nil
+	}
+	// slicing drops the axis, so every slice is reshaped to keep it
+	kept := x.Shape().Clone()
+	kept[axis] = 1
+	slices := make(G.Nodes, 0, n)
+	for i := n - 1; i >= 0; i-- {
+		ss := make([]tensor.Slice, axis+1)
+		ss[axis] = G.S(i)
+		s, err := G.Slice(x, ss...)
+		if err != nil {
+			return nil, err
+		}
+		if s, err = G.Reshape(s, kept); err != nil {
+			return nil, err
+		}
+		slices = append(slices, s)
+	}
+	return G.Concat(axis, slices...)
+}
+
+// Forwards returns the forwards copy of the recurrent layer. It is nil until the layer is first forwarded.
+func (l *Bidir) Forwards() Layer { return l.fw }
+
+// Backwards returns the backwards copy of the recurrent layer. It is nil until the layer is first forwarded.
+func (l *Bidir) Backwards() Layer { return l.bw }
+
+// Name returns the name of the layer, which is the name the copies were given before they were suffixed.
+func (l *Bidir) Name() string { return l.name }
+
+// Type will return the hm.Type of the bidirectional layer
+func (l *Bidir) Type() hm.Type { return hm.NewFnType(hm.TypeVariable('a'), hm.TypeVariable('b')) }
+
+// ByName returns the copy with the given name, or a Term within it.
+func (l *Bidir) ByName(name string) Term {
+	for _, c := range []Layer{l.fw, l.bw} {
+		if c == nil {
+			continue
+		}
+		if c.Name() == name {
+			return c
+		}
+		if bn, ok := c.(ByNamer); ok {
+			if t := bn.ByName(name); t != nil {
+				return t
+			}
+		}
+	}
+	return nil
+}
+
+// Runners returns the Runners of both copies.
+func (l *Bidir) Runners() (retVal []Runner) {
+	for _, c := range []Layer{l.fw, l.bw} {
+		if rs, ok := c.(Runnerser); ok {
+			retVal = append(retVal, rs.Runners()...)
+		}
+	}
+	return retVal
+}
+
+// FLOPs returns the FLOPs of both copies.
+func (l *Bidir) FLOPs() (retVal int) {
+	for _, c := range []Layer{l.fw, l.bw} {
+		if f, ok := c.(flopser); ok {
+			retVal += f.FLOPs()
+		}
+	}
+	return retVal
+}
+
+// Describe will describe a bidirectional layer. The time reversals are described as Slices with a step of -1.
+func (l *Bidir) Describe() (*onnx.GraphProto, error) {
+	if l.fw == nil || l.bw == nil {
+		return nil, errors.Errorf("Bidirectional layer %v has not been constructed. Call Fwd before describing it", l.name)
+	}
+	fw, err := l.fw.Describe()
+	if err != nil {
+		return nil, errors.Wrapf(err, "Unable to describe %v", l.fw.Name())
+	}
+	bw, err := l.bw.Describe()
+	if err != nil {
+		return nil, errors.Wrapf(err, "Unable to describe %v", l.bw.Name())
+	}
+	bw = chain(l.reverseFragment("reverse_in"), bw)
+	if l.sequenceOut {
+		bw = chain(bw, l.reverseFragment("reverse_out"))
+	}
+
+	var retVal *onnx.GraphProto
+	switch l.merge {
+	case MergeConcat:
+		retVal = fanout(l.name, "Concat", fw, bw)
+		last := retVal.Node[len(retVal.Node)-1]
+		last.Attribute = append(last.Attribute, attrInt("axis", -1))
+	case MergeSum:
+		retVal = fanout(l.name, "Add", fw, bw)
+	case MergeMean:
+		f := newFragment(l.name, "Bidirectional", l.dtype())
+		half, err := f.scalar(f.prefix+"_half", 0.5)
+		if err != nil {
+			return nil, err
+		}
+		f.apply("Mul", []string{half})
+		retVal = chain(fanout(l.name, "Add", fw, bw), f.graph())
+	default:
+		return nil, errors.Errorf("Unable to describe merge mode %d", l.merge)
+	}
+	retVal.Name = l.name
+	return retVal, nil
+}
+
+// reverseFragment describes the reversal of the time axis.
+func (l *Bidir) reverseFragment(name string) *onnx.GraphProto {
+	f := newFragment("", l.name+"_"+name, l.dtype())
+	starts := f.ints(f.prefix+"_starts", -1)
+	ends := f.ints(f.prefix+"_ends", math.MinInt32)
+	axes := f.ints(f.prefix+"_axes", l.axis)
+	steps := f.ints(f.prefix+"_steps", -1)
+	f.apply("Slice", []string{starts, ends, axes, steps})
+	return f.graph()
+}
+
+func (l *Bidir) dtype() tensor.Dtype {
+	if model := l.Model(); len(model) > 0 {
+		return model[0].Dtype()
+	}
+	return tensor.Float32
+}
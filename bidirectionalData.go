@@ -0,0 +1,69 @@
+package golgi
+
+import (
+	"github.com/pkg/errors"
+	G "gorgonia.org/gorgonia"
+)
+
+var (
+	_ Data   = &BidirData{}
+	_ Dataer = &Bidir{}
+)
+
+// BidirData represents the data of a bidirectional layer. Fw and Bw are the data of the forwards and backwards copies.
+type BidirData struct {
+	Name        string
+	Merge       MergeMode
+	Fw, Bw      Data
+	Axis        int
+	SequenceOut bool
+}
+
+// Make creates a *Bidir in the given graph. If name is empty, the name of the snapshotted layer is used. The copies keep their names.
+func (d *BidirData) Make(g *G.ExprGraph, name string) (Layer, error) {
+	if name == "" {
+		name = d.Name
+	}
+	if d.Fw == nil || d.Bw == nil {
+		return nil, errors.Errorf("Unable to make Bidir %v without both copies", name)
+	}
+	fw, err := d.Fw.Make(g, "")
+	if err != nil {
+		return nil, errors.Wrapf(err, "Unable to make the forwards copy of Bidir %v", name)
+	}
+	bw, err := d.Bw.Make(g, "")
+	if err != nil {
+		return nil, errors.Wrapf(err, "Unable to make the backwards copy of Bidir %v", name)
+	}
+	return &Bidir{
+		merge:       d.Merge,
+		name:        name,
+		fw:          fw,
+		bw:          bw,
+		axis:        d.Axis,
+		sequenceOut: d.SequenceOut,
+	}, nil
+}
+
+// ToData snapshots both copies of the bidirectional layer. The copies must have been constructed.
+func (l *Bidir) ToData() (Data, error) {
+	if l.fw == nil || l.bw == nil {
+		return nil, errors.Errorf("Unable to take a snapshot of Bidir %v. It has not been constructed", l.name)
+	}
+	fw, err := termToData(l.fw)
+	if err != nil {
+		return nil, errors.Wrapf(err, "ToData of Bidir %v (forwards)", l.name)
+	}
+	bw, err := termToData(l.bw)
+	if err != nil {
+		return nil, errors.Wrapf(err, "ToData of Bidir %v (backwards)", l.name)
+	}
+	return &BidirData{
+		Name:        l.name,
+		Merge:       l.merge,
+		Fw:          fw,
+		Bw:          bw,
+		Axis:        l.axis,
+		SequenceOut: l.sequenceOut,
+	}, nil
+}
@@ -0,0 +1,107 @@
+package golgi
+
+import (
+	"bytes"
+	"testing"
+
+	"github.com/stretchr/testify/require"
+	"gorgonia.org/gorgonia"
+	"gorgonia.org/tensor"
+)
+
+func TestBidirectional(t *testing.T) {
+	c := require.New(t)
+	steps, n, features, size := 3, 2, 4, 5
+	backing := tensor.Range(tensor.Float64, 0, steps*n*features).([]float64)
+	for i := range backing {
+		backing[i] = backing[i]/10 - 1
+	}
+	// the same sequence, reversed in time
+	reversed := make([]float64, 0, len(backing))
+	for ts := steps - 1; ts >= 0; ts-- {
+		reversed = append(reversed, backing[ts*n*features:(ts+1)*n*features]...)
+	}
+
+	g := gorgonia.NewGraph()
+	seq := gorgonia.NewTensor(g, tensor.Float64, 3, gorgonia.WithName("seq"), gorgonia.WithShape(steps, n, features),
+		gorgonia.WithValue(tensor.New(tensor.WithShape(steps, n, features), tensor.WithBacking(backing))))
+	rev := gorgonia.NewTensor(g, tensor.Float64, 3, gorgonia.WithName("rev"), gorgonia.WithShape(steps, n, features),
+		gorgonia.WithValue(tensor.New(tensor.WithShape(steps, n, features), tensor.WithBacking(reversed))))
+
+	bi := Bidirectional(ConsLSTM, MergeConcat, WithName("enc"), WithSize(size), AsSequence(TimeMajor), ReturnSequences(true))
+	out := bi.Fwd(seq)
+	c.NoError(gorgonia.CheckOne(out))
+	c.Equal(tensor.Shape{steps, n, 2 * size}, out.Node().Shape())
+	c.Equal("enc", bi.Name())
+
+	model := bi.Model()
+	c.Len(model, 24)
+	c.Equal("enc_fw_i_wx", model[0].Name())
+	c.Equal("enc_bw_i_wx", model[12].Name())
+	c.Equal(bi.Backwards(), bi.ByName("enc_bw"))
+
+	cost, err := gorgonia.Sum(out.Node())
+	c.NoError(err)
+	_, err = gorgonia.Grad(cost, model...)
+	c.NoError(err)
+
+	// the backwards copy fed the reversed sequence
+	bw := bi.Backwards().Fwd(rev)
+	c.NoError(gorgonia.CheckOne(bw))
+	var got, bwSeq gorgonia.Value
+	gorgonia.Read(out.Node(), &got)
+	gorgonia.Read(bw.Node(), &bwSeq)
+	m := gorgonia.NewTapeMachine(g)
+	defer m.Close()
+	c.NoError(m.RunAll())
+
+	gotData := got.Data().([]float64)
+	bwData := bwSeq.Data().([]float64)
+	for ts := 0; ts < steps; ts++ {
+		for i := 0; i < n; i++ {
+			row := gotData[(ts*n+i)*2*size : (ts*n+i+1)*2*size]
+			want := bwData[((steps-1-ts)*n+i)*size : ((steps-1-ts)*n+i+1)*size]
+			c.InDeltaSlice(want, row[size:], 1e-10)
+		}
+	}
+
+	desc, err := bi.Describe()
+	c.NoError(err)
+	c.Equal([]string{"LSTM", "Squeeze", "Slice", "LSTM", "Squeeze", "Slice", "Concat"}, opTypes(desc))
+	checkWellFormed(t, desc)
+
+	// a checkpoint rebuilds both copies
+	var buf bytes.Buffer
+	c.NoError(SaveCheckpoint(&buf, bi))
+	g2 := gorgonia.NewGraph()
+	seq2 := gorgonia.NewTensor(g2, tensor.Float64, 3, gorgonia.WithName("seq"), gorgonia.WithShape(steps, n, features),
+		gorgonia.WithValue(tensor.New(tensor.WithShape(steps, n, features), tensor.WithBacking(append([]float64(nil), backing...)))))
+	loaded, err := LoadCheckpoint(g2, &buf)
+	c.NoError(err)
+	c.Equal("enc", loaded.Name())
+	out2 := loaded.Fwd(seq2)
+	c.NoError(gorgonia.CheckOne(out2))
+	c.Equal(gotData, runValue(t, g2, out2.Node()))
+}
+
+func TestBidirectional_Merge(t *testing.T) {
+	c := require.New(t)
+	g := gorgonia.NewGraph()
+	x := gorgonia.NewTensor(g, tensor.Float32, 3, gorgonia.WithName("x"), gorgonia.WithShape(2, 4, 3), gorgonia.WithInit(gorgonia.GlorotU(1)))
+
+	sum := Bidirectional(ConsLSTM, MergeSum, WithSize(5), AsSequence(BatchMajor))
+	mean := Bidirectional(ConsLSTM, MergeMean, WithSize(5), AsSequence(BatchMajor))
+	for _, bi := range []*Bidir{sum, mean} {
+		out := bi.Fwd(x)
+		c.NoError(gorgonia.CheckOne(out))
+		// the final hidden states are merged
+		c.Equal(tensor.Shape{2, 5}, out.Node().Shape())
+		c.Equal("fw_i_wx", bi.Model()[0].Name())
+		desc, err := bi.Describe()
+		c.NoError(err)
+		checkWellFormed(t, desc)
+	}
+
+	single := Bidirectional(ConsLSTM, MergeSum, WithSize(5))
+	c.Error(gorgonia.CheckOne(single.Fwd(x)))
+}
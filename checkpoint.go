@@ -0,0 +1,73 @@
+package golgi
+
+import (
+	"encoding/gob"
+	"io"
+
+	"github.com/pkg/errors"
+	G "gorgonia.org/gorgonia"
+)
+
+// checkpointVersion is the version of the checkpoint format. It is bumped whenever a Data type changes in an incompatible way.
+const checkpointVersion = 1
+
+func init() {
+	gob.Register(&FCData{})
+	gob.Register(&ConvData{})
+	gob.Register(&MaxPoolData{})
+	gob.Register(&EmbeddingData{})
+	gob.Register(&LayerNormData{})
+	gob.Register(&BatchNormData{})
+	gob.Register(&LSTMData{})
+	gob.Register(&GRUData{})
+	gob.Register(&CompositionData{})
+	gob.Register(&JoinData{})
+	gob.Register(ReshapeData{})
+	gob.Register(DropoutData{})
+	gob.Register(&SkipData{})
+	gob.Register(&BidirData{})
+	gob.Register(&MultiHeadAttentionData{})
+	gob.Register(&EncoderBlockData{})
+	gob.Register(&AvgPoolData{})
+	gob.Register(&GlobalPoolData{})
+	gob.Register(&AdaptiveAvgPoolData{})
+	gob.Register(&ConvTransposeData{})
+}
+
+// checkpoint is what gets written to disk.
+type checkpoint struct {
+	Version int
+	Layer   Data
+}
+
+// SaveCheckpoint writes a snapshot of the weights and configuration of a layer to the writer.
+// The layer has to be a Dataer, and all its weights must have been initialized.
+func SaveCheckpoint(w io.Writer, l Layer) error {
+	dl, ok := l.(Dataer)
+	if !ok {
+		return errors.Errorf("Unable to save a checkpoint of %v. %T does not implement Dataer", l.Name(), l)
+	}
+	data, err := dl.ToData()
+	if err != nil {
+		return errors.Wrapf(err, "Unable to save a checkpoint of %v", l.Name())
+	}
+	if err = gob.NewEncoder(w).Encode(checkpoint{Version: checkpointVersion, Layer: data}); err != nil {
+		return errors.Wrap(err, "Unable to encode checkpoint")
+	}
+	return nil
+}
+
+// LoadCheckpoint reads a checkpoint written by SaveCheckpoint, and rebuilds the layer in the given graph.
+func LoadCheckpoint(g *G.ExprGraph, r io.Reader) (Layer, error) {
+	var ckpt checkpoint
+	if err := gob.NewDecoder(r).Decode(&ckpt); err != nil {
+		return nil, errors.Wrap(err, "Unable to decode checkpoint")
+	}
+	if ckpt.Version != checkpointVersion {
+		return nil, errors.Errorf("Unsupported checkpoint version %d. Expected version %d", ckpt.Version, checkpointVersion)
+	}
+	if ckpt.Layer == nil {
+		return nil, errors.New("Checkpoint does not contain a layer")
+	}
+	return ckpt.Layer.Make(g, "")
+}
@@ -0,0 +1,125 @@
+package golgi
+
+import (
+	"bytes"
+	"testing"
+
+	"github.com/stretchr/testify/require"
+	"gorgonia.org/gorgonia"
+	"gorgonia.org/tensor"
+)
+
+func TestCheckpoint_RoundTrip(t *testing.T) {
+	c := require.New(t)
+	xT := tensor.New(tensor.WithShape(4, 6), tensor.WithBacking(tensor.Range(tensor.Float64, 0, 24)))
+
+	g := gorgonia.NewGraph()
+	x := gorgonia.NewMatrix(g, tensor.Float64, gorgonia.WithName("x"), gorgonia.WithShape(4, 6), gorgonia.WithValue(xT))
+	bias := gorgonia.NewMatrix(g, tensor.Float64, gorgonia.WithName("bias"), gorgonia.WithShape(4, 6), gorgonia.WithInit(gorgonia.GlorotU(1)))
+	nn, err := ComposeSeq(
+		x,
+		L(ConsFC, WithName("l0"), WithSize(6), WithActivation(gorgonia.Tanh)),
+		L(ConsSkip, WithConst(bias)),
+		L(ConsLayerNorm, WithName("norm"), WithSize(5), WithEps(0.001)),
+		L(ConsFC, WithName("l1"), WithSize(3), WithActivation(SoftMaxFn), WithBias(false)),
+	)
+	c.NoError(err)
+	out := nn.Fwd(x)
+	c.NoError(gorgonia.CheckOne(out))
+
+	var buf bytes.Buffer
+	c.NoError(SaveCheckpoint(&buf, nn))
+
+	g2 := gorgonia.NewGraph()
+	x2 := gorgonia.NewMatrix(g2, tensor.Float64, gorgonia.WithName("x"), gorgonia.WithShape(4, 6), gorgonia.WithValue(xT.Clone()))
+	loaded, err := LoadCheckpoint(g2, &buf)
+	c.NoError(err)
+	out2 := loaded.Fwd(x2)
+	c.NoError(gorgonia.CheckOne(out2))
+
+	want, got := nn.Model(), loaded.Model()
+	c.Equal(len(want), len(got))
+	for i := range want {
+		c.Equal(want[i].Name(), got[i].Name())
+		c.Equal(want[i].Value().Data(), got[i].Value().Data(), "%v", want[i].Name())
+		c.True(got[i].Graph() == g2)
+	}
+
+	c.Equal(0.001, loaded.(*Composition).ByName("norm").(*layerNorm).eps)
+	c.Equal(bias.Value().Data(), loaded.(*Composition).ByName("+bias").(*skip).b.Value().Data())
+	c.Equal(runValue(t, g, out.Node()), runValue(t, g2, out2.Node()))
+}
+
+func TestCheckpoint_Conv(t *testing.T) {
+	c := require.New(t)
+	n := 2
+	g := gorgonia.NewGraph()
+	x := gorgonia.NewTensor(g, tensor.Float32, 4, gorgonia.WithName("X"), gorgonia.WithShape(n, 1, 28, 28), gorgonia.WithInit(gorgonia.GlorotU(1)))
+	nn, err := ComposeSeq(
+		x,
+		L(ConsConv, WithName("conv"), WithSize(4, 1), WithKernelShape(tensor.Shape{3, 3}), WithActivation(gorgonia.Sigmoid)),
+		L(ConsMaxPool, WithName("pool"), WithKernelShape(tensor.Shape{2, 2}), WithProbability(0.1)),
+		L(ConsReshape, ToShape(n, 4*14*14)),
+		L(ConsDropout, WithProbability(0.5)),
+		L(ConsFC, WithName("fc"), WithSize(10)),
+	)
+	c.NoError(err)
+	c.NoError(gorgonia.CheckOne(nn.Fwd(x)))
+
+	var buf bytes.Buffer
+	c.NoError(SaveCheckpoint(&buf, nn))
+
+	g2 := gorgonia.NewGraph()
+	x2 := gorgonia.NewTensor(g2, tensor.Float32, 4, gorgonia.WithName("X"), gorgonia.WithShape(n, 1, 28, 28), gorgonia.WithInit(gorgonia.GlorotU(1)))
+	loaded, err := LoadCheckpoint(g2, &buf)
+	c.NoError(err)
+	out := loaded.Fwd(x2)
+	c.NoError(gorgonia.CheckOne(out))
+	c.Equal(tensor.Shape{n, 10}, out.Node().Shape())
+
+	conv := loaded.(*Composition).ByName("conv").(*Conv)
+	c.Equal(tensor.Shape{3, 3}, conv.kernelShape)
+	c.Equal(tensor.Float32, conv.w.Dtype())
+	c.Equal(nn.ByName("conv").(*Conv).w.Value().Data(), conv.w.Value().Data())
+	c.Equal(nn.ByName("conv").(*Conv).b.Value().Data(), conv.b.Value().Data())
+
+	data, err := conv.ToData()
+	c.NoError(err)
+	c.Equal(Sigmoid, data.(*ConvData).Act)
+	c.Equal(0.1, *loaded.(*Composition).ByName("pool").(*MaxPool).dropout)
+}
+
+func TestCheckpoint_Embedding(t *testing.T) {
+	c := require.New(t)
+	g := gorgonia.NewGraph()
+	w := gorgonia.NewMatrix(g, tensor.Float64, gorgonia.WithShape(13, 5), gorgonia.WithName("emb"), gorgonia.WithInit(gorgonia.GlorotN(1)))
+	emb := NewEmbedding(WithWeights(w), WithName("emb"), WithClasses(13), WithOneHotInput())
+
+	var buf bytes.Buffer
+	c.NoError(SaveCheckpoint(&buf, emb))
+	loaded, err := LoadCheckpoint(gorgonia.NewGraph(), &buf)
+	c.NoError(err)
+
+	emb2 := loaded.(*Embedding)
+	c.Equal("emb", emb2.name)
+	c.Equal(onehotindices, emb2.selectFn)
+	c.Equal(13, emb2.classes)
+	c.Equal(5, emb2.dims)
+	c.Equal(w.Value().Data(), emb2.w.Value().Data())
+}
+
+func TestCheckpoint_Unsupported(t *testing.T) {
+	c := require.New(t)
+	var buf bytes.Buffer
+
+	// unconstructed layers cannot be saved
+	nn := Compose(L(ConsFC, WithSize(5)), L(ConsFC, WithSize(3)))
+	c.Error(SaveCheckpoint(&buf, nn))
+
+	// neither can unserializable activation functions
+	g := gorgonia.NewGraph()
+	x := gorgonia.NewMatrix(g, tensor.Float64, gorgonia.WithShape(2, 3), gorgonia.WithInit(gorgonia.GlorotU(1)))
+	fc, err := ConsFC(x, WithSize(5), WithActivation(gorgonia.Exp))
+	c.NoError(err)
+	c.Error(SaveCheckpoint(&buf, fc))
+}
@@ -5,6 +5,7 @@ import (
 
 	"github.com/chewxy/hm"
 	"github.com/pkg/errors"
+	"gorgonia.org/golgi/onnx"
 	G "gorgonia.org/gorgonia"
 	"gorgonia.org/tensor"
 )
@@ -20,7 +21,6 @@ type Composition struct {
 	a, b Term // can be thunk, Layer or *G.Node
 
 	// store returns
-	retVal   G.Result
 	retType  hm.Type
 	retShape tensor.Shape
 }
@@ -56,22 +56,25 @@ func ComposeSeq(layers ...Term) (retVal *Composition, err error) {
 	return l.(*Composition), nil
 }
 
-// Fwd runs the equation forwards
-func (l *Composition) Fwd(a G.Input) (output G.Result) {
+// Fwd runs the equation forwards.
+//
+// The first application constructs the thunked layers. Every application builds a fresh forward path from the given input, which
+// reuses the weights of the constructed layers. This allows a model to be applied to the training data and to the test data alike.
+func (l *Composition) Fwd(a G.Input) (output G.Result) { return l.fwd(a, Apply) }
+
+// fwd runs the equation forwards, applying the terms with the given function.
+func (l *Composition) fwd(a G.Input, apply applyFn) G.Result {
 	if err := G.CheckOne(a); err != nil {
 		return G.Err(errors.Wrapf(err, "Forward of a Composition %v", l.Name()))
 	}
 
-	if l.retVal != nil {
-		return l.retVal
-	}
 	logf("Compose %v and %v", l.a.Name(), a)
 	enterLogScope()
 	defer leaveLogScope()
 	input := a.Node()
 
 	// apply a to input
-	x, err := Apply(l.a, input)
+	x, err := apply(l.a, input)
 	if err != nil {
 		return G.Err(errors.Wrapf(err, "Forward of Composition %v (a)", l.Name()))
 	}
@@ -81,7 +84,7 @@ func (l *Composition) Fwd(a G.Input) (output G.Result) {
 	}
 
 	// apply b to the result
-	y, err := Apply(l.b, x)
+	y, err := apply(l.b, x)
 	if err != nil {
 		return G.Err(errors.Wrapf(err, "Forward of Composition %v (b)", l.Name()))
 	}
@@ -92,10 +95,8 @@ func (l *Composition) Fwd(a G.Input) (output G.Result) {
 		if !ok {
 			return G.Err(errors.Errorf("Error while forwarding Composition where layer is returned. Expected the result of a application to be a Result. Got %v of %T instead", yt.b, yt.b))
 		}
-		l.retVal = retVal
 		return retVal
 	case G.Result:
-		l.retVal = yt
 		return yt
 	default:
 		return G.Err(errors.Errorf("Error while forwarding Composition. Expected the result of a application to be a Result. Got %v of %T instead", y, y))
@@ -106,9 +107,12 @@ func (l *Composition) Fwd(a G.Input) (output G.Result) {
 // Model will return the gorgonia.Nodes associated with this composition
 func (l *Composition) Model() (retVal G.Nodes) {
 	if a, ok := l.a.(Layer); ok {
-		return append(a.Model(), l.b.(Layer).Model()...)
+		retVal = append(retVal, a.Model()...)
+	}
+	if b, ok := l.b.(Layer); ok {
+		retVal = append(retVal, b.Model()...)
 	}
-	return l.b.(Layer).Model()
+	return retVal
 }
 
 // Name will return the name of the composition
@@ -121,7 +125,23 @@ func (l *Composition) Name() string {
 }
 
 // Describe will describe a composition
-func (l *Composition) Describe() { panic("STUB") }
+func (l *Composition) Describe() (*onnx.GraphProto, error) {
+	b, err := describeTerm(l.b)
+	if err != nil {
+		return nil, errors.Wrapf(err, "Unable to describe %v", l.b.Name())
+	}
+	if l.a == nil {
+		return b, nil
+	}
+	if _, ok := l.a.(I); ok {
+		return b, nil
+	}
+	a, err := describeTerm(l.a)
+	if err != nil {
+		return nil, errors.Wrapf(err, "Unable to describe %v", l.a.Name())
+	}
+	return chain(a, b), nil
+}
 
 // ByName returns a Term by name
 func (l *Composition) ByName(name string) Term {
@@ -181,3 +201,11 @@ func (l *Composition) FLOPs() (retVal int) {
 	}
 	return
 }
+
+// Reinit re-initializes the weights of both terms in place.
+func (l *Composition) Reinit() error {
+	if err := reinitTerm(l.a); err != nil {
+		return err
+	}
+	return reinitTerm(l.b)
+}
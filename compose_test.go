@@ -3,6 +3,7 @@ package golgi
 import (
 	"testing"
 
+	"github.com/stretchr/testify/require"
 	"gorgonia.org/gorgonia"
 	"gorgonia.org/tensor"
 )
@@ -33,3 +34,46 @@ func TestCompose(t *testing.T) {
 	t.Logf("%v", y)
 
 }
+
+func TestCompose_Reapply(t *testing.T) {
+	c := require.New(t)
+	g := gorgonia.NewGraph()
+	newInput := func(name string, backing []float64) *gorgonia.Node {
+		return gorgonia.NewMatrix(g, tensor.Float64, gorgonia.WithName(name), gorgonia.WithShape(2, 3), gorgonia.WithValue(tensor.New(tensor.WithShape(2, 3), tensor.WithBacking(backing))))
+	}
+	train := newInput("train", []float64{0.1, -0.2, 0.3, -0.4, 0.5, -0.6})
+	test := newInput("test", []float64{0.6, 0.5, -0.4, 0.3, -0.2, 0.1})
+	same := newInput("same", []float64{0.1, -0.2, 0.3, -0.4, 0.5, -0.6})
+
+	nn, err := ComposeSeq(
+		train,
+		L(ConsFC, WithName("l0"), WithSize(4), WithActivation(gorgonia.Tanh)),
+		Add(I{}, L(ConsFC, WithName("res"), WithSize(4))),
+		L(ConsFC, WithName("l1"), WithSize(2)),
+	)
+	c.NoError(err)
+	out := nn.Fwd(train)
+	c.NoError(gorgonia.CheckOne(out))
+	model := nn.Model()
+	c.Len(model, 6)
+
+	// the later applications build new forward paths over the same weights
+	outTest := nn.Fwd(test)
+	c.NoError(gorgonia.CheckOne(outTest))
+	outSame := nn.Fwd(same)
+	c.NoError(gorgonia.CheckOne(outSame))
+	c.False(out.Node() == outTest.Node())
+	c.Equal(model, nn.Model())
+
+	cost, err := gorgonia.Sum(outTest.Node())
+	c.NoError(err)
+	_, err = gorgonia.Grad(cost, model...)
+	c.NoError(err)
+
+	var v, vTest gorgonia.Value
+	gorgonia.Read(out.Node(), &v)
+	gorgonia.Read(outTest.Node(), &vTest)
+	got := runValue(t, g, outSame.Node())
+	c.Equal(v.Data(), got)
+	c.NotEqual(v.Data(), vTest.Data())
+}
@@ -49,7 +49,6 @@ func WithName(name string) ConsOpt {
 		case *layerNorm:
 			l.name = name
 			return layer, nil
-		case *LSTM:
 		case unnameable:
 			return layer, nil
 		case namesetter:
@@ -88,6 +87,9 @@ func WithBias(withbias bool) ConsOpt {
 		case *FC:
 			l.nobias = !withbias
 			return layer, nil
+		case *Conv:
+			l.nobias = !withbias
+			return layer, nil
 		}
 		return layer, nil
 	}
@@ -121,6 +123,14 @@ func WithSize(size ...int) ConsOpt {
 		case *LSTM:
 			l.size = size[0]
 			return l, nil
+		case *ConvTranspose:
+			l.size = size
+			return l, nil
+		case *AdaptiveAvgPool:
+			if err := l.SetSize(size...); err != nil {
+				return nil, err
+			}
+			return l, nil
 		}
 
 		return nil, errors.Errorf("WithSize Unhandled Layer type: %T", layer)
@@ -175,11 +185,20 @@ func Of(dt tensor.Dtype) ConsOpt {
 	}
 }
 
-// ToShape is a ConsOpt for Reshape only.
+// ToShape is a ConsOpt for Reshape only. A 0 copies the dimension of the input, and a single -1 is inferred from the size of the input.
 func ToShape(shp ...int) ConsOpt {
 	return func(layer Layer) (Layer, error) {
 		switch layer.(type) {
 		case reshape:
+			var inferred int
+			for _, d := range shp {
+				if d == -1 {
+					inferred++
+				}
+				if d < -1 || inferred > 1 {
+					return nil, errors.Errorf("ToShape expects positive dimensions, 0 or a single -1. Got %v", shp)
+				}
+			}
 			return reshape(tensor.Shape(shp)), nil
 		case Pass:
 			return layer, nil
@@ -207,13 +226,16 @@ func WithProbability(prob float64) ConsOpt {
 	}
 }
 
-// WithEps is a ConsOpt for constructing Layer Norms only.
+// WithEps is a ConsOpt for constructing normalization layers (Layer Norms and BatchNorms) only.
 func WithEps(eps float64) ConsOpt {
 	return func(layer Layer) (Layer, error) {
 		switch l := layer.(type) {
 		case *layerNorm:
 			l.eps = eps
 			return l, nil
+		case *BatchNorm:
+			l.eps = eps
+			return l, nil
 		case Pass:
 			return layer, nil
 		}
@@ -243,6 +265,9 @@ func WithWeights(w *G.Node) ConsOpt {
 			l.w = w
 			// l.initialized = true
 			// this cannot be true unless l.oh has been set.
+		case *Conv:
+			l.w = w
+			// the bias is still created when the layer is initialized, unless WithBias(false) is given
 		default:
 			return nil, errors.Errorf("WithWeights does not handle layer of type %T", layer)
 		}
@@ -250,7 +275,35 @@ func WithWeights(w *G.Node) ConsOpt {
 	}
 }
 
-// WithKernelShape sets the kernel shape for convolution layers (Conv, MaxPool)
+// WithInit sets the function used to initialize the weights of a layer (FC, Conv, LSTM, GRU, Embedding or LayerNorm).
+// Biases are always initialized with zeroes. Weights that are given with WithWeights or WithWB are not reinitialized.
+func WithInit(fn G.InitWFn) ConsOpt {
+	return func(layer Layer) (Layer, error) {
+		if fn == nil {
+			return nil, errors.New("WithInit expects an initialization function. Got nil")
+		}
+		switch l := layer.(type) {
+		case *FC:
+			l.initW = fn
+		case *layerNorm:
+			l.initW = fn
+		case *Conv:
+			l.initW = fn
+		case *LSTM:
+			l.initW = fn
+		case *GRU:
+			l.initW = fn
+		case *Embedding:
+			l.initW = fn
+		case Pass:
+		default:
+			return nil, errors.Errorf("WithInit does not handle layer of type %T", layer)
+		}
+		return layer, nil
+	}
+}
+
+// WithKernelShape sets the kernel shape for convolution layers (Conv, ConvTranspose, MaxPool, AvgPool)
 func WithKernelShape(s tensor.Shape) ConsOpt {
 	return func(l Layer) (Layer, error) {
 		switch c := l.(type) {
@@ -261,6 +314,14 @@ func WithKernelShape(s tensor.Shape) ConsOpt {
 		case *MaxPool:
 			c.kernelShape = s
 
+			return c, nil
+		case *AvgPool:
+			c.kernelShape = s
+
+			return c, nil
+		case *ConvTranspose:
+			c.kernelShape = s
+
 			return c, nil
 		}
 
@@ -268,7 +329,7 @@ func WithKernelShape(s tensor.Shape) ConsOpt {
 	}
 }
 
-// WithPad sets the pad  for convolution layers (Conv, MaxPool)
+// WithPad sets the pad  for convolution layers (Conv, ConvTranspose, MaxPool, AvgPool)
 func WithPad(p []int) ConsOpt {
 	return func(l Layer) (Layer, error) {
 		switch c := l.(type) {
@@ -279,6 +340,14 @@ func WithPad(p []int) ConsOpt {
 		case *MaxPool:
 			c.pad = p
 
+			return c, nil
+		case *AvgPool:
+			c.pad = p
+
+			return c, nil
+		case *ConvTranspose:
+			c.pad = p
+
 			return c, nil
 		}
 
@@ -286,7 +355,31 @@ func WithPad(p []int) ConsOpt {
 	}
 }
 
-// WithStride sets the stride for convolution layers (Conv, MaxPool)
+// WithPadding sets the padding scheme of a convolution or a max pooling layer (Conv, MaxPool). Any padding scheme other than
+// ExplicitPadding overrides the pads given with WithPad.
+func WithPadding(p Padding) ConsOpt {
+	return func(l Layer) (Layer, error) {
+		switch c := l.(type) {
+		case *Conv:
+			c.padding = p
+
+			return c, nil
+		case *MaxPool:
+			if p == Causal {
+				return nil, errors.Errorf("MaxPool %v does not support causal padding", c.name)
+			}
+			c.padding = p
+
+			return c, nil
+		case Pass:
+			return l, nil
+		}
+
+		return nil, fmt.Errorf("Setting padding is not supported by this layer")
+	}
+}
+
+// WithStride sets the stride for convolution layers (Conv, ConvTranspose, MaxPool, AvgPool)
 func WithStride(s []int) ConsOpt {
 	return func(l Layer) (Layer, error) {
 		switch c := l.(type) {
@@ -297,6 +390,14 @@ func WithStride(s []int) ConsOpt {
 		case *MaxPool:
 			c.stride = s
 
+			return c, nil
+		case *AvgPool:
+			c.stride = s
+
+			return c, nil
+		case *ConvTranspose:
+			c.stride = s
+
 			return c, nil
 		}
 
@@ -304,13 +405,17 @@ func WithStride(s []int) ConsOpt {
 	}
 }
 
-// WithDilation sets the dilation for convolution layers
+// WithDilation sets the dilation for convolution layers (Conv, ConvTranspose)
 func WithDilation(s []int) ConsOpt {
 	return func(l Layer) (Layer, error) {
 		switch c := l.(type) {
 		case *Conv:
 			c.dilation = s
 
+			return c, nil
+		case *ConvTranspose:
+			c.dilation = s
+
 			return c, nil
 		}
 
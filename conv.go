@@ -6,11 +6,13 @@ import (
 	"fmt"
 
 	"github.com/chewxy/hm"
+	"gorgonia.org/golgi/onnx"
 	"gorgonia.org/gorgonia"
 	"gorgonia.org/tensor"
 )
 
 // ConsConv is a Conv construction function. It takes a gorgonia.Input that has a *gorgonia.Node.
+// The layer has a bias per output channel, unless WithBias(false) is given.
 // Defaults:
 //		activation function: Rectify
 // 		kernel shape: (5,5)
@@ -42,12 +44,33 @@ func ConsConv(in gorgonia.Input, opts ...ConsOpt) (retVal Layer, err error) {
 }
 
 // Init will initialize the fully connected layer
+//
+// The weights are of shape (out channels, in channels / groups, kernel shape...), so a Conv1D has a 3D weight and a Conv3D has a 5D weight.
+// The bias is a vector with one value per output channel.
+//
+// Weights that were given with WithWeights are kept, and the size and kernel shape of the layer are taken from them.
 func (l *Conv) Init(xs ...*gorgonia.Node) (err error) {
 	x := xs[0]
+	if l.w != nil {
+		wshp := l.w.Shape()
+		if wshp.Dims() < 3 {
+			return fmt.Errorf("Expected the weights of Conv %v to be of shape (out channels, in channels, kernel shape...). Got %v instead", l.name, wshp)
+		}
+		l.size = []int{wshp[0], wshp[1] * l.groupCount()}
+		l.kernelShape = tensor.Shape(cloneInts(wshp[2:]))
+	}
+	if err = l.checkConfig(x); err != nil {
+		return err
+	}
 	g := x.Graph()
 	of := x.Dtype()
-	name := l.name + "_w"
-	l.w = gorgonia.NewTensor(g, of, 4, gorgonia.WithShape(l.size[0], l.size[1], l.kernelShape[0], l.kernelShape[1]), gorgonia.WithName(name), gorgonia.WithInit(gorgonia.GlorotN(1.0)))
+	if l.w == nil {
+		shp := append([]int{l.size[0], l.size[1] / l.groupCount()}, l.kernelShape...)
+		l.w = gorgonia.NewTensor(g, of, len(shp), gorgonia.WithShape(shp...), gorgonia.WithName(l.name+"_w"), gorgonia.WithInit(l.weightInit()))
+	}
+	if !l.nobias && l.b == nil {
+		l.b = gorgonia.NewVector(g, of, gorgonia.WithShape(l.size[0]), gorgonia.WithName(l.name+"_b"), gorgonia.WithInit(gorgonia.Zeroes()))
+	}
 
 	l.initialized = true
 
@@ -56,13 +79,18 @@ func (l *Conv) Init(xs ...*gorgonia.Node) (err error) {
 
 // Conv represents a convolution layer
 type Conv struct {
-	w *gorgonia.Node
+	w, b *gorgonia.Node
+
+	initW  gorgonia.InitWFn // nil means the default initialization
+	nobias bool
 
 	name string
 	size []int
 
 	kernelShape           tensor.Shape
 	pad, stride, dilation []int
+	padding               Padding
+	groups                int // 0 and 1 both mean that the channels are not grouped
 
 	// optional config
 	dropout *float64 // nil when shouldn't be applied
@@ -74,13 +102,25 @@ type Conv struct {
 	flops        int
 }
 
+// NewConv creates a new 2D convolution layer. It does not initialize the layer.
 func NewConv(opts ...ConsOpt) (*Conv, error) {
+	return newConv(2, opts...)
+}
+
+// newConv creates a new convolution layer over the given number of spatial dimensions, with the defaults of ConsConv.
+func newConv(dims int, opts ...ConsOpt) (*Conv, error) {
 	l := &Conv{
 		act:         gorgonia.Rectify,
-		kernelShape: tensor.Shape{5, 5},
-		pad:         []int{1, 1},
-		stride:      []int{1, 1},
-		dilation:    []int{1, 1},
+		kernelShape: make(tensor.Shape, dims),
+		pad:         make([]int, dims),
+		stride:      make([]int, dims),
+		dilation:    make([]int, dims),
+	}
+	for i := 0; i < dims; i++ {
+		l.kernelShape[i] = 5
+		l.pad[i] = 1
+		l.stride[i] = 1
+		l.dilation[i] = 1
 	}
 
 	for _, opt := range opts {
@@ -101,6 +141,33 @@ func NewConv(opts ...ConsOpt) (*Conv, error) {
 	return l, nil
 }
 
+// WithGroups is a ConsOpt for constructing convolution layers only. It splits the input channels and the output channels into n
+// groups, and every group of output channels is only computed from its group of input channels. Both the number of input channels
+// and the number of output channels must be divisible by n. A depthwise convolution has as many groups as input channels.
+func WithGroups(n int) ConsOpt {
+	return func(layer Layer) (Layer, error) {
+		if n < 1 {
+			return nil, fmt.Errorf("Expected a positive number of groups. Got %d", n)
+		}
+		switch l := layer.(type) {
+		case *Conv:
+			l.groups = n
+			return l, nil
+		case Pass:
+			return layer, nil
+		}
+		return nil, fmt.Errorf("WithGroups does not handle layer of type %T", layer)
+	}
+}
+
+// groupCount returns the number of groups of channels.
+func (l *Conv) groupCount() int {
+	if l.groups < 1 {
+		return 1
+	}
+	return l.groups
+}
+
 // SetDropout sets the dropout of the layer
 func (l *Conv) SetDropout(d float64) error {
 	l.dropout = &d
@@ -125,11 +192,28 @@ func (l *Conv) SetActivationFn(act ActivationFunction) error {
 	return nil
 }
 
+// weightInit returns the initialization function of the weights.
+func (l *Conv) weightInit() gorgonia.InitWFn {
+	if l.initW == nil {
+		return gorgonia.GlorotN(1.0)
+	}
+	return l.initW
+}
+
+// Reinit re-initializes the weights and the bias in place.
+func (l *Conv) Reinit() error {
+	if err := reinitNode(l.w, l.weightInit()); err != nil {
+		return err
+	}
+	return reinitNode(l.b, gorgonia.Zeroes())
+}
+
 // Model will return the gorgonia.Nodes associated with this convolution layer
 func (l *Conv) Model() gorgonia.Nodes {
-	return gorgonia.Nodes{
-		l.w,
+	if l.b == nil {
+		return gorgonia.Nodes{l.w}
 	}
+	return gorgonia.Nodes{l.w, l.b}
 }
 
 // Fwd runs the equation forwards
@@ -139,20 +223,51 @@ func (l *Conv) Fwd(x gorgonia.Input) gorgonia.Result {
 	}
 
 	xN := x.Node()
+	in := xN.Shape()
 	if !l.initialized {
 		if err := l.Init(xN); err != nil {
 			return wrapErr(l, "Initializing a previously uninitialized Conv layer: %w", err)
 		}
 	}
 
-	c, err := gorgonia.Conv2d(xN, l.w, l.kernelShape, l.pad, l.stride, l.dilation)
-	if err != nil {
-		return wrapErr(l, "applying conv2d %v %v: %w", x.Node().Shape(), l.w.Shape(), err)
+	if err := l.checkConfig(xN); err != nil {
+		return wrapErr(l, "checking input: %w", err)
 	}
 
-	result, err := l.act(c)
+	var (
+		c   *gorgonia.Node
+		err error
+	)
+	pad := l.pad
+	if l.padding != ExplicitPadding {
+		before, after, err := l.padding.pads(in, l.kernelShape, l.pad, l.stride, l.dilation)
+		if err != nil {
+			return wrapErr(l, "computing the pads: %w", err)
+		}
+		if xN, pad, err = padSpatial(xN, before, after, gorgonia.Zeroes(), l.name+"_pad"); err != nil {
+			return wrapErr(l, "padding the input: %w", err)
+		}
+	}
+	if l.groups > 1 {
+		c, err = l.convGroups(xN, pad)
+	} else {
+		c, err = l.convolve(xN, l.w, pad)
+	}
 	if err != nil {
-		return wrapErr(l, "applying activation function: %w", err)
+		return wrapErr(l, "applying convolution %v %v: %w", x.Node().Shape(), l.w.Shape(), err)
+	}
+
+	if l.b != nil {
+		if c, err = l.addBias(c); err != nil {
+			return wrapErr(l, "adding the bias: %w", err)
+		}
+	}
+
+	result := c
+	if l.act != nil {
+		if result, err = l.act(c); err != nil {
+			return wrapErr(l, "applying activation function: %w", err)
+		}
 	}
 
 	if l.dropout != nil {
@@ -164,7 +279,7 @@ func (l *Conv) Fwd(x gorgonia.Input) gorgonia.Result {
 
 	// Side effects are cool
 	if l.computeFLOPs {
-		l.flops = l.doComputeFLOPs(xN.Shape())
+		l.flops = l.doComputeFLOPs(in)
 	}
 
 	logf("%T shape %s: %v", l, l.name, result.Shape())
@@ -172,6 +287,69 @@ func (l *Conv) Fwd(x gorgonia.Input) gorgonia.Result {
 	return result
 }
 
+// convolve convolves x with the weights w, dispatching on the number of spatial dimensions.
+func (l *Conv) convolve(x, w *gorgonia.Node, pad []int) (*gorgonia.Node, error) {
+	switch len(l.kernelShape) {
+	case 1:
+		return l.conv1d(x, w, pad)
+	case 3:
+		return l.conv3d(x, w, pad)
+	}
+	return gorgonia.Conv2d(x, w, l.kernelShape, pad, l.stride, l.dilation)
+}
+
+// convGroups splits the channels of x and the filters of the weights into groups, convolves every group of channels with its
+// group of filters, and concatenates the results along the channels.
+//
+// The results are concatenated along a new axis of groups. The gradient of a concatenation slices along the concatenated axis,
+// which drops the axis when a group has a single channel.
+func (l *Conv) convGroups(x *gorgonia.Node, pad []int) (retVal *gorgonia.Node, err error) {
+	in, out := x.Shape()[1]/l.groups, l.size[0]/l.groups
+	outputs := make(gorgonia.Nodes, 0, l.groups)
+	for g := 0; g < l.groups; g++ {
+		xg, err := sliceAlong(x, 1, g*in, (g+1)*in)
+		if err != nil {
+			return nil, err
+		}
+		wg, err := sliceAlong(l.w, 0, g*out, (g+1)*out)
+		if err != nil {
+			return nil, err
+		}
+		c, err := l.convolve(xg, wg, pad)
+		if err != nil {
+			return nil, fmt.Errorf("group %d: %w", g, err)
+		}
+		shp := c.Shape()
+		if c, err = gorgonia.Reshape(c, append(tensor.Shape{shp[0], 1}, shp[1:]...)); err != nil {
+			return nil, err
+		}
+		outputs = append(outputs, c)
+	}
+	if retVal, err = gorgonia.Concat(1, outputs...); err != nil {
+		return nil, err
+	}
+	shp := retVal.Shape()
+	return gorgonia.Reshape(retVal, append(tensor.Shape{shp[0], shp[1] * shp[2]}, shp[3:]...))
+}
+
+// addBias adds the bias to every position of every output channel. The output is viewed as (N, C, positions), and the bias as
+// (1, C, 1), which is broadcast over the batch and the positions.
+func (l *Conv) addBias(c *gorgonia.Node) (retVal *gorgonia.Node, err error) {
+	shp := c.Shape().Clone()
+	n, ch, positions := shp[0], shp[1], shp[2:].TotalSize()
+	var b, c3 *gorgonia.Node
+	if b, err = gorgonia.Reshape(l.b, tensor.Shape{1, ch, 1}); err != nil {
+		return nil, err
+	}
+	if c3, err = gorgonia.Reshape(c, tensor.Shape{n, ch, positions}); err != nil {
+		return nil, err
+	}
+	if retVal, err = BroadcastAdd(c3, b, nil, []byte{0, 2}); err != nil {
+		return nil, err
+	}
+	return gorgonia.Reshape(retVal, shp)
+}
+
 // Type will return the hm.Type of the convolution layer
 func (l *Conv) Type() hm.Type {
 	return hm.NewFnType(hm.TypeVariable('a'), hm.TypeVariable('b'))
@@ -182,14 +360,57 @@ func (l *Conv) Shape() tensor.Shape {
 	return l.w.Shape()
 }
 
+// OutputShape returns the shape of the output of the layer for an input of the given shape, without applying the layer.
+func (l *Conv) OutputShape(in tensor.Shape) (tensor.Shape, error) {
+	if len(l.size) != 2 {
+		return nil, fmt.Errorf("Expected the size of Conv %v to be (out channels, in channels). Got %v", l.name, l.size)
+	}
+	before, after, err := l.padding.pads(in, l.kernelShape, l.pad, l.stride, l.dilation)
+	if err != nil {
+		return nil, err
+	}
+	return spatialOutputShape(in, l.size[0], l.kernelShape, before, after, l.stride, l.dilation)
+}
+
 // Name will return the name of the convolution layer
 func (l *Conv) Name() string {
 	return l.name
 }
 
 // Describe will describe a convolution layer
-func (l *Conv) Describe() {
-	panic("not implemented")
+func (l *Conv) Describe() (*onnx.GraphProto, error) {
+	if !l.initialized {
+		return nil, fmt.Errorf("Unable to describe Conv %v. It has not been initialized", l.name)
+	}
+	f := newFragment(l.name, "Conv", l.w.Dtype())
+	w, err := f.weight(l.w)
+	if err != nil {
+		return nil, err
+	}
+	inputs := []string{w}
+	if l.b != nil {
+		b, err := f.weight(l.b)
+		if err != nil {
+			return nil, err
+		}
+		inputs = append(inputs, b)
+	}
+	attrs := append([]*onnx.AttributeProto{
+		attrInts("kernel_shape", l.kernelShape...),
+		attrInts("strides", l.stride...),
+		attrInts("dilations", l.dilation...),
+	}, onnxPadding(l.padding, l.kernelShape, l.pad, l.dilation)...)
+	if l.groups > 1 {
+		attrs = append(attrs, attrInt("group", l.groups))
+	}
+	f.apply("Conv", inputs, attrs...)
+	if err = f.activate(l.act); err != nil {
+		return nil, err
+	}
+	if l.dropout != nil {
+		f.dropout(*l.dropout)
+	}
+	return f.graph(), nil
 }
 
 func (l *Conv) FLOPs() int { return l.flops }
@@ -197,12 +418,17 @@ func (l *Conv) FLOPs() int { return l.flops }
 // doComputeFLOPs computes the rough number of floating point operations for this layer.
 //
 // Adapted from: https://stats.stackexchange.com/a/296793
+//
+// The input is of shape (N, C, spatial dims...). The FLOPs are for a single instance of the batch.
 func (l *Conv) doComputeFLOPs(input tensor.Shape) int {
 	shp := l.w.Shape()
-	n := shp[1] * shp[2] * shp[3]
+	n := shp[1] * l.kernelShape.TotalSize()
 	flopsPerInstance := n + 1
-	instancesPerFilter := ((input[1] - shp[2] + 2*l.pad[0]) / l.stride[0]) + 1 // rows
-	instancesPerFilter *= ((input[2] - shp[3] + 2*l.pad[1]) / l.stride[1]) + 1 // multiplying with cols
+	out, err := l.OutputShape(input)
+	if err != nil {
+		return 0
+	}
+	instancesPerFilter := out[2:].TotalSize()
 
 	flopsPerFilter := instancesPerFilter * flopsPerInstance
 	retVal := flopsPerFilter * shp[0] // multiply with number of filters
@@ -219,4 +445,5 @@ var (
 	_ actSetter       = &Conv{}
 	_ dropoutConfiger = &Conv{}
 	_ Term            = &Conv{}
+	_ OutputShaper    = &Conv{}
 )
@@ -4,6 +4,7 @@ package golgi
 
 import (
 	"github.com/chewxy/hm"
+	"gorgonia.org/golgi/onnx"
 	G "gorgonia.org/gorgonia"
 	"gorgonia.org/tensor"
 )
@@ -37,6 +38,6 @@ func (l *Conv) Name() string {
 }
 
 // Describe will describe a convolution layer
-func (l *Conv) Describe() {
+func (l *Conv) Describe() (*onnx.GraphProto, error) {
 	panic("not implemented")
 }
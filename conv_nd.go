@@ -0,0 +1,179 @@
+// +build !cuda
+
+package golgi
+
+import (
+	"fmt"
+
+	"github.com/pkg/errors"
+	G "gorgonia.org/gorgonia"
+	"gorgonia.org/tensor"
+)
+
+// ConsConv1D is a construction function for a 1D convolution layer. It takes a gorgonia.Input that has a *gorgonia.Node of shape (N, C, L).
+// The options are the same as the options of ConsConv, with a single value per option.
+// Defaults:
+//
+//	activation function: Rectify
+//	kernel shape: (5)
+//	pad: (1)
+//	stride: (1)
+//	dilation: (1)
+func ConsConv1D(in G.Input, opts ...ConsOpt) (retVal Layer, err error) {
+	return consConvND(in, 1, opts...)
+}
+
+// ConsConv3D is a construction function for a 3D convolution layer. It takes a gorgonia.Input that has a *gorgonia.Node of shape (N, C, D, H, W).
+// The options are the same as the options of ConsConv, with three values per option.
+// Defaults:
+//
+//	activation function: Rectify
+//	kernel shape: (5,5,5)
+//	pad: (1,1,1)
+//	stride: (1,1,1)
+//	dilation: (1,1,1)
+func ConsConv3D(in G.Input, opts ...ConsOpt) (retVal Layer, err error) {
+	return consConvND(in, 3, opts...)
+}
+
+func consConvND(in G.Input, dims int, opts ...ConsOpt) (retVal Layer, err error) {
+	x := in.Node()
+	if x == nil {
+		return nil, errors.Errorf("ConsConv expects a *Node. Got input %v of  %T instead", in, in)
+	}
+	if x.Dims() != dims+2 {
+		return nil, errors.Errorf("Expected the input of a %dD convolution to have %d dimensions. Got %v instead", dims, dims+2, x.Shape())
+	}
+	l, err := newConv(dims, opts...)
+	if err != nil {
+		return nil, err
+	}
+	if err = l.Init(x); err != nil {
+		return nil, err
+	}
+	return l, nil
+}
+
+// checkConfig checks that the configuration of the layer is consistent with the input.
+func (l *Conv) checkConfig(x *G.Node) error {
+	dims := len(l.kernelShape)
+	if dims < 1 || dims > 3 {
+		return errors.Errorf("Only 1D, 2D and 3D convolutions are supported. Got kernel shape %v", l.kernelShape)
+	}
+	if x.Dims() != dims+2 {
+		return errors.Errorf("Expected the input of a %dD convolution to have %d dimensions. Got %v instead", dims, dims+2, x.Shape())
+	}
+	if len(l.pad) != dims || len(l.stride) != dims || len(l.dilation) != dims {
+		return errors.Errorf("Expected %d values for the pad, stride and dilation. Got %v, %v and %v", dims, l.pad, l.stride, l.dilation)
+	}
+	if len(l.size) != 2 {
+		return errors.Errorf("Expected the size of a convolution to be (out channels, in channels). Got %v", l.size)
+	}
+	if groups := l.groupCount(); l.size[0]%groups != 0 || l.size[1]%groups != 0 {
+		return errors.Errorf("Expected the channels %v of a convolution to be divisible by the number of groups %d", l.size, groups)
+	}
+	if x.Shape()[1] != l.size[1] {
+		return errors.Errorf("Expected an input with %d channels. Got %v instead", l.size[1], x.Shape())
+	}
+	if l.padding == Causal && dims != 1 {
+		return errors.Errorf("Causal padding is only supported by 1D convolutions. Got kernel shape %v", l.kernelShape)
+	}
+	return nil
+}
+
+// conv1d convolves an input of shape (N, C, L) by reshaping it to (N, C, 1, L), and convolving it with a kernel of height 1.
+func (l *Conv) conv1d(x, w *G.Node, pad []int) (retVal *G.Node, err error) {
+	shp, wshp := x.Shape(), w.Shape()
+	var x2, w2 *G.Node
+	if x2, err = G.Reshape(x, tensor.Shape{shp[0], shp[1], 1, shp[2]}); err != nil {
+		return nil, err
+	}
+	if w2, err = G.Reshape(w, tensor.Shape{wshp[0], wshp[1], 1, wshp[2]}); err != nil {
+		return nil, err
+	}
+	kernelShape := tensor.Shape{1, l.kernelShape[0]}
+	stride := []int{1, l.stride[0]}
+	dilation := []int{1, l.dilation[0]}
+	if retVal, err = G.Conv2d(x2, w2, kernelShape, []int{0, pad[0]}, stride, dilation); err != nil {
+		return nil, err
+	}
+	out := retVal.Shape()
+	return G.Reshape(retVal, tensor.Shape{out[0], out[1], out[3]})
+}
+
+// conv3d convolves an input of shape (N, C, D, H, W). Each depth of the kernel is a 2D convolution over the slab of depths it
+// covers, with the depths folded into the batch. The 2D convolutions are then summed.
+func (l *Conv) conv3d(x, weights *G.Node, pad []int) (retVal *G.Node, err error) {
+	shp := x.Shape()
+	n, c, h, w := shp[0], shp[1], shp[3], shp[4]
+	kd, pd, sd, dd := l.kernelShape[0], pad[0], l.stride[0], l.dilation[0]
+
+	depth := convOutputSize(shp[2], kd, pd, sd, dd)
+	if depth <= 0 {
+		return nil, errors.Errorf("the kernel %v is deeper than the input %v", l.kernelShape, shp)
+	}
+
+	// the slabs are sliced with a step of the stride. Their ends are rounded up to a multiple of the stride, as the shape of a
+	// stepped slice is inferred by flooring, so the end of the input is padded further if needed.
+	front, back := pd, pd
+	if extra := (kd-1)*dd + depth*sd - (shp[2] + 2*pd); extra > 0 {
+		back += extra
+	}
+	if front > 0 || back > 0 {
+		zeroes := func(size int) *G.Node {
+			return G.NewTensor(x.Graph(), x.Dtype(), 5, G.WithShape(n, c, size, h, w), G.WithName(fmt.Sprintf("%v_depth_pad%d", l.name, size)), G.WithInit(G.Zeroes()))
+		}
+		inputs := G.Nodes{x}
+		if front > 0 {
+			inputs = append(G.Nodes{zeroes(front)}, inputs...)
+		}
+		if back > 0 {
+			inputs = append(inputs, zeroes(back))
+		}
+		if x, err = G.Concat(2, inputs...); err != nil {
+			return nil, errors.Wrap(err, "padding the depth")
+		}
+	}
+
+	for k := 0; k < kd; k++ {
+		start := k * dd
+		var slab, kernel, y *G.Node
+		if slab, err = G.Slice(x, nil, nil, G.S(start, start+depth*sd, sd)); err != nil {
+			return nil, err
+		}
+		// slicing may drop the depth axis
+		if slab, err = G.Reshape(slab, tensor.Shape{n, c, depth, h, w}); err != nil {
+			return nil, err
+		}
+		if slab, err = G.Transpose(slab, 0, 2, 1, 3, 4); err != nil {
+			return nil, err
+		}
+		if slab, err = G.Reshape(slab, tensor.Shape{n * depth, c, h, w}); err != nil {
+			return nil, err
+		}
+		if kernel, err = G.Slice(weights, nil, nil, G.S(k)); err != nil {
+			return nil, err
+		}
+		if y, err = G.Conv2d(slab, kernel, l.kernelShape[1:], pad[1:], l.stride[1:], l.dilation[1:]); err != nil {
+			return nil, err
+		}
+		if retVal == nil {
+			retVal = y
+			continue
+		}
+		if retVal, err = G.Add(retVal, y); err != nil {
+			return nil, err
+		}
+	}
+
+	out := retVal.Shape()
+	if retVal, err = G.Reshape(retVal, tensor.Shape{n, depth, out[1], out[2], out[3]}); err != nil {
+		return nil, err
+	}
+	return G.Transpose(retVal, 0, 2, 1, 3, 4)
+}
+
+// convOutputSize computes the size of a spatial dimension of the output of a convolution or a pooling.
+func convOutputSize(in, kernel, pad, stride, dilation int) int {
+	return (in+2*pad-dilation*(kernel-1)-1)/stride + 1
+}
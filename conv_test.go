@@ -1,6 +1,7 @@
 package golgi
 
 import (
+	"bytes"
 	"testing"
 
 	"github.com/stretchr/testify/require"
@@ -61,3 +62,373 @@ func TestConvNet(t *testing.T) {
 	c.True(ok)
 	c.NotZero(cost)
 }
+
+// convRef convolves x with w with plain loops, over any number of spatial dimensions.
+func convRef(xs []float64, xshp tensor.Shape, ws []float64, wshp tensor.Shape, pad, stride, dilation []int) ([]float64, tensor.Shape) {
+	dims := len(xshp) - 2
+	outshp := tensor.Shape{xshp[0], wshp[0]}
+	for i := 0; i < dims; i++ {
+		outshp = append(outshp, convOutputSize(xshp[2+i], wshp[2+i], pad[i], stride[i], dilation[i]))
+	}
+	// unravel converts a flat index into coordinates
+	unravel := func(i int, shp tensor.Shape) []int {
+		coords := make([]int, len(shp))
+		for d := len(shp) - 1; d >= 0; d-- {
+			coords[d] = i % shp[d]
+			i /= shp[d]
+		}
+		return coords
+	}
+	ravel := func(coords []int, shp tensor.Shape) int {
+		var retVal int
+		for d := range shp {
+			retVal = retVal*shp[d] + coords[d]
+		}
+		return retVal
+	}
+
+	retVal := make([]float64, outshp.TotalSize())
+	kernel := tensor.Shape(wshp[1:])
+	for o := range retVal {
+		out := unravel(o, outshp)
+		var acc float64
+	kernelLoop:
+		for k := 0; k < kernel.TotalSize(); k++ {
+			kc := unravel(k, kernel) // channel, spatial...
+			in := []int{out[0], kc[0]}
+			for d := 0; d < dims; d++ {
+				at := out[2+d]*stride[d] + kc[1+d]*dilation[d] - pad[d]
+				if at < 0 || at >= xshp[2+d] {
+					continue kernelLoop
+				}
+				in = append(in, at)
+			}
+			acc += xs[ravel(in, xshp)] * ws[ravel(append([]int{out[1]}, kc...), wshp)]
+		}
+		retVal[o] = acc
+	}
+	return retVal, outshp
+}
+
+func TestConvND(t *testing.T) {
+	cases := []struct {
+		name                  string
+		cons                  LayerCons
+		xshp                  tensor.Shape
+		size                  []int
+		kernel                tensor.Shape
+		pad, stride, dilation []int
+	}{
+		{"1D", ConsConv1D, tensor.Shape{2, 3, 9}, []int{4, 3}, tensor.Shape{3}, []int{1}, []int{2}, []int{1}},
+		{"1D dilated", ConsConv1D, tensor.Shape{1, 2, 8}, []int{2, 2}, tensor.Shape{2}, []int{0}, []int{1}, []int{3}},
+		{"2D", ConsConv, tensor.Shape{1, 2, 5, 4}, []int{3, 2}, tensor.Shape{3, 2}, []int{1, 0}, []int{1, 2}, []int{1, 1}},
+		{"3D strided", ConsConv3D, tensor.Shape{1, 1, 6, 3, 3}, []int{2, 1}, tensor.Shape{2, 2, 2}, []int{0, 0, 1}, []int{2, 1, 2}, []int{1, 1, 1}},
+		{"3D", ConsConv3D, tensor.Shape{2, 2, 5, 4, 4}, []int{3, 2}, tensor.Shape{2, 3, 2}, []int{1, 1, 0}, []int{2, 1, 1}, []int{1, 1, 2}},
+	}
+	for _, tc := range cases {
+		t.Run(tc.name, func(t *testing.T) {
+			c := require.New(t)
+			g := gorgonia.NewGraph()
+			xs := make([]float64, tc.xshp.TotalSize())
+			for i := range xs {
+				xs[i] = float64((i*7)%13)/13 - 0.5
+			}
+			x := gorgonia.NewTensor(g, tensor.Float64, tc.xshp.Dims(), gorgonia.WithName("x"), gorgonia.WithShape(tc.xshp...), gorgonia.WithValue(tensor.New(tensor.WithShape(tc.xshp...), tensor.WithBacking(xs))))
+
+			l, err := tc.cons(x, WithName("conv"), WithSize(tc.size...), WithKernelShape(tc.kernel), WithPad(tc.pad), WithStride(tc.stride), WithDilation(tc.dilation), WithActivation(nil), ComputeFLOPs(true))
+			c.NoError(err)
+			conv := l.(*Conv)
+			c.Equal(append(tensor.Shape{tc.size[0], tc.size[1]}, tc.kernel...), conv.w.Shape())
+
+			out := conv.Fwd(x)
+			c.NoError(gorgonia.CheckOne(out))
+			expected, outshp := convRef(xs, tc.xshp, conv.w.Value().Data().([]float64), conv.w.Shape(), tc.pad, tc.stride, tc.dilation)
+			c.Equal(outshp, out.Node().Shape())
+			c.Equal((tc.size[1]*tc.kernel.TotalSize()+1)*outshp[2:].TotalSize()*tc.size[0], conv.FLOPs())
+
+			cost, err := gorgonia.Sum(out.Node())
+			c.NoError(err)
+			_, err = gorgonia.Grad(cost, conv.w, x)
+			c.NoError(err)
+			c.InDeltaSlice(expected, runValue(t, g, out.Node()), 1e-10)
+
+			desc, err := conv.Describe()
+			c.NoError(err)
+			checkWellFormed(t, desc)
+		})
+	}
+
+	c := require.New(t)
+	g := gorgonia.NewGraph()
+	x := gorgonia.NewTensor(g, tensor.Float64, 4, gorgonia.WithShape(1, 1, 4, 4), gorgonia.WithInit(gorgonia.GlorotU(1)))
+	_, err := ConsConv1D(x, WithSize(1, 1))
+	c.Error(err)
+	_, err = ConsConv3D(x, WithSize(1, 1))
+	c.Error(err)
+	_, err = ConsConv(x, WithSize(1, 1), WithKernelShape(tensor.Shape{3}))
+	c.Error(err)
+}
+
+func TestConv_Bias(t *testing.T) {
+	c := require.New(t)
+	xshp := tensor.Shape{2, 2, 4, 4}
+	xs := make([]float64, xshp.TotalSize())
+	for i := range xs {
+		xs[i] = float64((i*5)%11)/11 - 0.5
+	}
+	newInput := func(g *gorgonia.ExprGraph) *gorgonia.Node {
+		return gorgonia.NewTensor(g, tensor.Float64, 4, gorgonia.WithName("x"), gorgonia.WithShape(xshp...), gorgonia.WithValue(tensor.New(tensor.WithShape(xshp...), tensor.WithBacking(append([]float64(nil), xs...)))))
+	}
+	g := gorgonia.NewGraph()
+	x := newInput(g)
+
+	l, err := ConsConv(x, WithName("conv"), WithSize(3, 2), WithKernelShape(tensor.Shape{3, 3}), WithActivation(nil))
+	c.NoError(err)
+	conv := l.(*Conv)
+	c.Len(conv.Model(), 2)
+	c.Equal("conv_b", conv.b.Name())
+	c.Equal(tensor.Shape{3}, conv.b.Shape())
+	bs := []float64{1, -2, 3}
+	c.NoError(gorgonia.Let(conv.b, tensor.New(tensor.WithShape(3), tensor.WithBacking(bs))))
+
+	out := conv.Fwd(x)
+	c.NoError(gorgonia.CheckOne(out))
+	expected, outshp := convRef(xs, xshp, conv.w.Value().Data().([]float64), conv.w.Shape(), conv.pad, conv.stride, conv.dilation)
+	c.Equal(outshp, out.Node().Shape())
+	plane := outshp[2:].TotalSize()
+	for i := range expected {
+		expected[i] += bs[(i/plane)%3]
+	}
+	cost, err := gorgonia.Sum(out.Node())
+	c.NoError(err)
+	grads, err := gorgonia.Grad(cost, conv.b)
+	c.NoError(err)
+	var db gorgonia.Value
+	gorgonia.Read(grads[0], &db)
+	c.InDeltaSlice(expected, runValue(t, g, out.Node()), 1e-10)
+	// every output channel has N×H×W positions
+	c.Equal([]float64{32, 32, 32}, db.Data())
+
+	// the bias round trips through ONNX
+	var buf bytes.Buffer
+	c.NoError(ExportONNX(conv, &buf))
+	g2 := gorgonia.NewGraph()
+	x2 := newInput(g2)
+	imported, err := ImportONNX(g2, &buf)
+	c.NoError(err)
+	model := imported.Model()
+	c.Len(model, 2)
+	c.Equal(bs, model[1].Value().Data())
+	out2 := imported.Fwd(x2)
+	c.NoError(gorgonia.CheckOne(out2))
+	c.InDeltaSlice(expected, runValue(t, g2, out2.Node()), 1e-10)
+
+	// without a bias
+	l, err = ConsConv(x, WithName("nobias"), WithSize(3, 2), WithKernelShape(tensor.Shape{3, 3}), WithBias(false))
+	c.NoError(err)
+	c.Len(l.Model(), 1)
+	desc, err := l.Describe()
+	c.NoError(err)
+	c.Len(desc.Node[0].Input, 2)
+}
+
+func TestConv_WithWeights(t *testing.T) {
+	c := require.New(t)
+	g := gorgonia.NewGraph()
+	x := gorgonia.NewTensor(g, tensor.Float64, 4, gorgonia.WithName("x"), gorgonia.WithShape(1, 2, 5, 5), gorgonia.WithInit(gorgonia.GlorotU(1)))
+	w := gorgonia.NewTensor(g, tensor.Float64, 4, gorgonia.WithName("shared_w"), gorgonia.WithShape(4, 2, 3, 1), gorgonia.WithInit(gorgonia.GlorotU(1)))
+
+	l, err := ConsConv(x, WithName("conv"), WithWeights(w), WithPad([]int{0, 0}))
+	c.NoError(err)
+	conv := l.(*Conv)
+	c.Equal(w, conv.w)
+	c.Equal([]int{4, 2}, conv.size)
+	c.Equal(tensor.Shape{3, 1}, conv.kernelShape)
+	out := conv.Fwd(x)
+	c.NoError(gorgonia.CheckOne(out))
+	c.Equal(tensor.Shape{1, 4, 3, 5}, out.Node().Shape())
+
+	_, err = ConsConv(x, WithWeights(gorgonia.NewMatrix(g, tensor.Float64, gorgonia.WithShape(2, 2))))
+	c.Error(err)
+}
+
+func TestWithInit(t *testing.T) {
+	c := require.New(t)
+	g := gorgonia.NewGraph()
+	img := gorgonia.NewTensor(g, tensor.Float64, 4, gorgonia.WithName("img"), gorgonia.WithShape(1, 1, 4, 4), gorgonia.WithInit(gorgonia.GlorotU(1)))
+	x := gorgonia.NewMatrix(g, tensor.Float64, gorgonia.WithName("x"), gorgonia.WithShape(2, 3), gorgonia.WithInit(gorgonia.GlorotU(1)))
+	seq := gorgonia.NewMatrix(g, tensor.Float64, gorgonia.WithName("seq"), gorgonia.WithShape(4, 3), gorgonia.WithInit(gorgonia.GlorotU(1)))
+	idx := gorgonia.NewVector(g, tensor.Float64, gorgonia.WithName("idx"), gorgonia.WithShape(2), gorgonia.WithInit(gorgonia.Zeroes()))
+
+	conv, err := ConsConv(img, WithName("conv"), WithSize(2, 1), WithKernelShape(tensor.Shape{3, 3}), WithInit(gorgonia.Ones()))
+	c.NoError(err)
+	fc, err := ConsFC(x, WithName("fc"), WithSize(2), WithInit(gorgonia.Ones()))
+	c.NoError(err)
+	lstm, err := ConsLSTM(seq, WithName("lstm"), WithSize(2), WithInit(gorgonia.Ones()))
+	c.NoError(err)
+	gru, err := ConsGRU(seq, WithName("gru"), WithSize(2), WithInit(gorgonia.Ones()))
+	c.NoError(err)
+	norm, err := ConsLayerNorm(x, WithName("norm"), WithSize(2), WithInit(gorgonia.ValuesOf(2.0)))
+	c.NoError(err)
+	emb := NewEmbedding(WithName("emb"), WithClasses(5), WithSize(2), WithInit(gorgonia.Ones()))
+	c.NoError(emb.Init(idx))
+
+	weights := gorgonia.Nodes{conv.(*Conv).w, fc.(*FC).w, norm.(*layerNorm).w, emb.w}
+	for _, gate := range []*lstmGate{&lstm.(*LSTM).input, &lstm.(*LSTM).cell, &gru.(*GRU).reset} {
+		weights = append(weights, gate.wx, gate.wh)
+	}
+	for _, w := range weights {
+		want := 1.0
+		if w == norm.(*layerNorm).w {
+			want = 2.0
+		}
+		for _, v := range w.Value().Data().([]float64) {
+			c.Equal(want, v, "%v", w.Name())
+		}
+	}
+	// biases are still zeroes
+	for _, v := range conv.(*Conv).b.Value().Data().([]float64) {
+		c.Zero(v)
+	}
+
+	_, err = ConsConv(img, WithSize(2, 1), WithInit(nil))
+	c.Error(err)
+	_, err = ConsMaxPool(img, WithInit(gorgonia.Ones()))
+	c.Error(err)
+}
+
+// blockDiagonal expands grouped weights of shape (out, in/groups, kernel...) to full weights of shape (out, in, kernel...) that are
+// zero between the groups, so that a grouped convolution can be checked against convRef.
+func blockDiagonal(ws []float64, wshp tensor.Shape, groups int) ([]float64, tensor.Shape) {
+	out, in, kernel := wshp[0], wshp[1], wshp[2:].TotalSize()
+	full := append(tensor.Shape{out, in * groups}, wshp[2:]...)
+	retVal := make([]float64, full.TotalSize())
+	for o := 0; o < out; o++ {
+		g := o / (out / groups)
+		for i := 0; i < in; i++ {
+			copy(retVal[(o*in*groups+g*in+i)*kernel:], ws[(o*in+i)*kernel:(o*in+i+1)*kernel])
+		}
+	}
+	return retVal, full
+}
+
+func TestConv_Groups(t *testing.T) {
+	cases := []struct {
+		name   string
+		cons   LayerCons
+		xshp   tensor.Shape
+		size   []int
+		groups int
+		kernel tensor.Shape
+	}{
+		{"1D depthwise", ConsConv1D, tensor.Shape{2, 3, 8}, []int{3, 3}, 3, tensor.Shape{3}},
+		{"2D", ConsConv, tensor.Shape{1, 4, 5, 5}, []int{6, 4}, 2, tensor.Shape{3, 3}},
+		{"2D depthwise multiplier", ConsConv, tensor.Shape{2, 2, 4, 4}, []int{4, 2}, 2, tensor.Shape{3, 2}},
+		{"3D", ConsConv3D, tensor.Shape{1, 4, 3, 3, 3}, []int{2, 4}, 2, tensor.Shape{2, 2, 2}},
+	}
+	for _, tc := range cases {
+		t.Run(tc.name, func(t *testing.T) {
+			c := require.New(t)
+			xs := make([]float64, tc.xshp.TotalSize())
+			for i := range xs {
+				xs[i] = float64((i*7)%13)/13 - 0.5
+			}
+			newInput := func(g *gorgonia.ExprGraph) *gorgonia.Node {
+				return gorgonia.NewTensor(g, tensor.Float64, tc.xshp.Dims(), gorgonia.WithName("x"), gorgonia.WithShape(tc.xshp...), gorgonia.WithValue(tensor.New(tensor.WithShape(tc.xshp...), tensor.WithBacking(append([]float64(nil), xs...)))))
+			}
+			g := gorgonia.NewGraph()
+			x := newInput(g)
+
+			l, err := tc.cons(x, WithName("conv"), WithSize(tc.size...), WithGroups(tc.groups), WithKernelShape(tc.kernel), WithPadding(Same), WithActivation(nil), ComputeFLOPs(true))
+			c.NoError(err)
+			conv := l.(*Conv)
+			c.Equal(append(tensor.Shape{tc.size[0], tc.size[1] / tc.groups}, tc.kernel...), conv.w.Shape())
+			c.Len(conv.Model(), 2)
+
+			out := conv.Fwd(x)
+			c.NoError(gorgonia.CheckOne(out))
+			outshp, err := conv.OutputShape(tc.xshp)
+			c.NoError(err)
+			c.Equal(outshp, out.Node().Shape())
+			c.Equal((tc.size[1]/tc.groups*tc.kernel.TotalSize()+1)*outshp[2:].TotalSize()*tc.size[0], conv.FLOPs())
+
+			// Same padding is symmetric for odd kernels, and pads one more at the end for even kernels
+			before, after := make([]int, len(tc.kernel)), make([]int, len(tc.kernel))
+			for i, k := range tc.kernel {
+				before[i], after[i] = (k-1)/2, k/2
+			}
+			padded, pshp := padRef(xs, tc.xshp, before, after, 0)
+			ws, wshp := blockDiagonal(conv.w.Value().Data().([]float64), conv.w.Shape(), tc.groups)
+			expected, _ := convRef(padded, pshp, ws, wshp, make([]int, len(tc.kernel)), conv.stride, conv.dilation)
+			cost, err := gorgonia.Sum(out.Node())
+			c.NoError(err)
+			_, err = gorgonia.Grad(cost, conv.w, x)
+			c.NoError(err)
+			c.InDeltaSlice(expected, runValue(t, g, out.Node()), 1e-10)
+
+			var buf bytes.Buffer
+			c.NoError(ExportONNX(conv, &buf))
+			g2 := gorgonia.NewGraph()
+			x2 := newInput(g2)
+			imported, err := ImportONNX(g2, &buf)
+			c.NoError(err)
+			out2 := imported.Fwd(x2)
+			c.NoError(gorgonia.CheckOne(out2))
+			c.InDeltaSlice(expected, runValue(t, g2, out2.Node()), 1e-10)
+
+			data, err := conv.ToData()
+			c.NoError(err)
+			c.Equal(tc.groups, data.(*ConvData).Groups)
+		})
+	}
+
+	c := require.New(t)
+	g := gorgonia.NewGraph()
+	x := gorgonia.NewTensor(g, tensor.Float64, 4, gorgonia.WithShape(1, 4, 4, 4), gorgonia.WithInit(gorgonia.GlorotU(1)))
+	_, err := ConsConv(x, WithSize(3, 4), WithGroups(2))
+	c.Error(err)
+	_, err = ConsConv(x, WithSize(4, 4), WithGroups(0))
+	c.Error(err)
+	_, err = ConsConv(x, WithSize(4, 2), WithGroups(2))
+	c.Error(err, "the input has 4 channels")
+}
+
+func TestSeparableConv(t *testing.T) {
+	c := require.New(t)
+	g := gorgonia.NewGraph()
+	xshp := tensor.Shape{2, 3, 6, 6}
+	xs := make([]float64, xshp.TotalSize())
+	for i := range xs {
+		xs[i] = float64((i*5)%11)/11 - 0.5
+	}
+	x := gorgonia.NewTensor(g, tensor.Float64, 4, gorgonia.WithName("x"), gorgonia.WithShape(xshp...), gorgonia.WithValue(tensor.New(tensor.WithShape(xshp...), tensor.WithBacking(xs))))
+
+	l, err := ConsSeparableConv(x, WithName("sep"), WithSize(8, 3), WithKernelShape(tensor.Shape{3, 3}), WithPad([]int{1, 1}), WithStride([]int{2, 2}),
+		WithActivation(nil), ComputeFLOPs(true))
+	c.NoError(err)
+	sep := l.(*Composition)
+	depthwise, pointwise := sep.ByName("sep_depthwise").(*Conv), sep.ByName("sep_pointwise").(*Conv)
+	c.Equal(tensor.Shape{3, 1, 3, 3}, depthwise.w.Shape())
+	c.Equal(tensor.Shape{8, 3, 1, 1}, pointwise.w.Shape())
+	var names []string
+	for _, n := range sep.Model() {
+		names = append(names, n.Name())
+	}
+	c.Equal([]string{"sep_depthwise_w", "sep_pointwise_w", "sep_pointwise_b"}, names)
+
+	out := sep.Fwd(x)
+	c.NoError(gorgonia.CheckOne(out))
+	c.Equal(tensor.Shape{2, 8, 3, 3}, out.Node().Shape())
+	// a full 3×3 convolution would take (3×9+1)×9×8 FLOPs per instance
+	c.Equal((9+1)*9*3+(3+1)*9*8, sep.FLOPs())
+
+	ws, wshp := blockDiagonal(depthwise.w.Value().Data().([]float64), depthwise.w.Shape(), 3)
+	hidden, hshp := convRef(xs, xshp, ws, wshp, []int{1, 1}, []int{2, 2}, []int{1, 1})
+	expected, _ := convRef(hidden, hshp, pointwise.w.Value().Data().([]float64), pointwise.w.Shape(), []int{0, 0}, []int{1, 1}, []int{1, 1})
+	c.InDeltaSlice(expected, runValue(t, g, out.Node()), 1e-10)
+
+	_, err = ConsSeparableConv(x, WithSize(8, 3), WithGroups(3))
+	c.Error(err)
+	_, err = ConsSeparableConv(gorgonia.NewMatrix(g, tensor.Float64, gorgonia.WithShape(2, 2)), WithSize(8, 3))
+	c.Error(err)
+}
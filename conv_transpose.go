@@ -0,0 +1,389 @@
+package golgi
+
+import (
+	"fmt"
+
+	"github.com/chewxy/hm"
+	"github.com/pkg/errors"
+	"gorgonia.org/golgi/onnx"
+	G "gorgonia.org/gorgonia"
+	"gorgonia.org/tensor"
+)
+
+var (
+	_ Layer              = &ConvTranspose{}
+	_ namesetter         = &ConvTranspose{}
+	_ actSetter          = &ConvTranspose{}
+	_ computeFLOPsSetter = &ConvTranspose{}
+	_ OutputShaper       = &ConvTranspose{}
+)
+
+// WithOutputPadding is a ConsOpt for constructing transposed convolution layers only. It sets the number of rows and columns that
+// are added to the end of the output, which disambiguates the output shape when the stride is greater than 1.
+func WithOutputPadding(p []int) ConsOpt {
+	return func(layer Layer) (Layer, error) {
+		switch l := layer.(type) {
+		case *ConvTranspose:
+			l.outputPad = p
+			return l, nil
+		case Pass:
+			return layer, nil
+		}
+		return nil, errors.Errorf("WithOutputPadding Unhandled Layer type: %T", layer)
+	}
+}
+
+// ConvTranspose represents a transposed convolution layer, which is the gradient of a convolution with respect to its input.
+// It is used to upsample with learned weights.
+//
+// The output of an input of shape (N, C, H, W) is of shape (N, out channels, H', W') where
+//
+//	H' = (H-1)×stride - 2×pad + dilation×(kernel-1) + output padding + 1
+//
+// The weight is of shape (in channels, out channels, kernel H, kernel W), as per ONNX.
+type ConvTranspose struct {
+	w *G.Node
+
+	name string
+	size []int // (out channels, in channels), as per Conv
+
+	kernelShape                      tensor.Shape
+	pad, stride, dilation, outputPad []int
+
+	act ActivationFunction
+
+	initialized  bool
+	computeFLOPs bool
+	flops        int
+}
+
+// NewConvTranspose creates a new transposed convolution layer. It does not initialize the layer.
+// Defaults:
+//
+//	activation function: Rectify
+//	kernel shape: (2,2)
+//	pad: (0,0)
+//	stride: (2,2)
+//	dilation: (1,1)
+//	output padding: (0,0)
+func NewConvTranspose(opts ...ConsOpt) (*ConvTranspose, error) {
+	l := &ConvTranspose{
+		act:         G.Rectify,
+		kernelShape: tensor.Shape{2, 2},
+		pad:         []int{0, 0},
+		stride:      []int{2, 2},
+		dilation:    []int{1, 1},
+		outputPad:   []int{0, 0},
+	}
+	for _, opt := range opts {
+		var (
+			o   Layer
+			ok  bool
+			err error
+		)
+		if o, err = opt(l); err != nil {
+			return nil, err
+		}
+		if l, ok = o.(*ConvTranspose); !ok {
+			return nil, errors.Errorf("Construction Option returned a non ConvTranspose. Got %T instead", o)
+		}
+	}
+	return l, nil
+}
+
+// ConsConvTranspose is a ConvTranspose construction function. It takes a gorgonia.Input that has a *gorgonia.Node of shape (N, C, H, W).
+// The size is set with WithSize(out channels, in channels).
+func ConsConvTranspose(in G.Input, opts ...ConsOpt) (retVal Layer, err error) {
+	x := in.Node()
+	if x == nil {
+		return nil, errors.Errorf("ConsConvTranspose expects a *Node. Got input %v of  %T instead", in, in)
+	}
+	if x.Dims() != 4 {
+		return nil, errors.Errorf("Expected the input of a transposed convolution to be of shape (N, C, H, W). Got %v instead", x.Shape())
+	}
+	l, err := NewConvTranspose(opts...)
+	if err != nil {
+		return nil, err
+	}
+	if err = l.Init(x); err != nil {
+		return nil, err
+	}
+	return l, nil
+}
+
+// Init will initialize the transposed convolution layer
+func (l *ConvTranspose) Init(xs ...*G.Node) (err error) {
+	x := xs[0]
+	if err = l.checkConfig(x); err != nil {
+		return err
+	}
+	l.w = G.NewTensor(x.Graph(), x.Dtype(), 4, G.WithShape(l.size[1], l.size[0], l.kernelShape[0], l.kernelShape[1]), G.WithName(l.name+"_w"), G.WithInit(G.GlorotN(1.0)))
+	l.initialized = true
+	return nil
+}
+
+// checkConfig checks that the configuration of the layer is consistent with the input.
+func (l *ConvTranspose) checkConfig(x *G.Node) error {
+	if x.Dims() != 4 {
+		return errors.Errorf("Expected the input to be of shape (N, C, H, W). Got %v instead", x.Shape())
+	}
+	if len(l.size) != 2 {
+		return errors.Errorf("Expected the size of a transposed convolution to be (out channels, in channels). Got %v", l.size)
+	}
+	if x.Shape()[1] != l.size[1] {
+		return errors.Errorf("Expected the input to have %d channels. Got %v instead", l.size[1], x.Shape())
+	}
+	if len(l.kernelShape) != 2 || len(l.pad) != 2 || len(l.stride) != 2 || len(l.dilation) != 2 || len(l.outputPad) != 2 {
+		return errors.Errorf("Expected 2 values for the kernel shape, pad, stride, dilation and output padding. Got %v, %v, %v, %v and %v", l.kernelShape, l.pad, l.stride, l.dilation, l.outputPad)
+	}
+	for i := range l.outputPad {
+		if l.outputPad[i] >= l.stride[i] && l.outputPad[i] >= l.dilation[i] {
+			return errors.Errorf("Expected the output padding %v to be smaller than either the stride %v or the dilation %v", l.outputPad, l.stride, l.dilation)
+		}
+	}
+	return nil
+}
+
+// outputSize computes the size of the spatial dimensions of the output.
+func (l *ConvTranspose) outputSize(in tensor.Shape) []int {
+	retVal := make([]int, 2)
+	for i := range retVal {
+		retVal[i] = (in[2+i]-1)*l.stride[i] - 2*l.pad[i] + l.dilation[i]*(l.kernelShape[i]-1) + l.outputPad[i] + 1
+	}
+	return retVal
+}
+
+// OutputShape returns the shape of the output of the layer for an input of the given shape, without applying the layer.
+func (l *ConvTranspose) OutputShape(in tensor.Shape) (tensor.Shape, error) {
+	if in.Dims() != 4 {
+		return nil, errors.Errorf("Expected the input of ConvTranspose %v to be of shape (N, C, H, W). Got %v instead", l.name, in)
+	}
+	if len(l.size) != 2 {
+		return nil, errors.Errorf("Expected the size of ConvTranspose %v to be (out channels, in channels). Got %v", l.name, l.size)
+	}
+	out := l.outputSize(in)
+	if out[0] <= 0 || out[1] <= 0 {
+		return nil, errors.Errorf("The input %v of ConvTranspose %v is too small for its pads %v", in, l.name, l.pad)
+	}
+	return tensor.Shape{in[0], l.size[0], out[0], out[1]}, nil
+}
+
+// Reinit re-initializes the weights in place.
+func (l *ConvTranspose) Reinit() error { return reinitNode(l.w, G.GlorotN(1.0)) }
+
+// Model will return the gorgonia.Nodes associated with this transposed convolution layer
+func (l *ConvTranspose) Model() G.Nodes { return G.Nodes{l.w} }
+
+// Fwd runs the equation forwards.
+//
+// The transposed convolution is computed as a convolution of the input dilated by the stride (i.e. with stride-1 zeroes inserted
+// between its values), padded by dilation×(kernel-1)-pad, with the flipped kernel.
+func (l *ConvTranspose) Fwd(x G.Input) G.Result {
+	if err := G.CheckOne(x); err != nil {
+		return wrapErr(l, "checking input: %w", err)
+	}
+	xN := x.Node()
+	if !l.initialized {
+		if err := l.Init(xN); err != nil {
+			return wrapErr(l, "Initializing a previously uninitialized ConvTranspose layer: %w", err)
+		}
+	}
+	if err := l.checkConfig(xN); err != nil {
+		return wrapErr(l, "checking input: %w", err)
+	}
+
+	retVal := xN
+	var err error
+	for axis := 2; axis < 4; axis++ {
+		i := axis - 2
+		if retVal, err = dilateAlong(retVal, axis, l.stride[i], fmt.Sprintf("%v_dilation%d", l.name, i)); err != nil {
+			return wrapErr(l, "dilating the input: %w", err)
+		}
+		border := l.dilation[i]*(l.kernelShape[i]-1) - l.pad[i]
+		if retVal, err = padAlong(retVal, axis, border, border+l.outputPad[i], fmt.Sprintf("%v_pad%d", l.name, i)); err != nil {
+			return wrapErr(l, "padding the input: %w", err)
+		}
+	}
+
+	// the weight (in, out, kh, kw) is flipped and transposed to (out, in, kh, kw)
+	var kernel *G.Node
+	if kernel, err = G.Transpose(l.w, 1, 0, 2, 3); err != nil {
+		return wrapErr(l, "transposing the weight: %w", err)
+	}
+	for axis := 2; axis < 4; axis++ {
+		if kernel, err = reverseAlong(kernel, axis); err != nil {
+			return wrapErr(l, "flipping the weight: %w", err)
+		}
+	}
+
+	var result *G.Node
+	if result, err = G.Conv2d(retVal, kernel, l.kernelShape, []int{0, 0}, []int{1, 1}, l.dilation); err != nil {
+		return wrapErr(l, "applying conv2d %v %v: %w", retVal.Shape(), kernel.Shape(), err)
+	}
+	if l.act != nil {
+		if result, err = l.act(result); err != nil {
+			return wrapErr(l, "applying activation function: %w", err)
+		}
+	}
+
+	if l.computeFLOPs {
+		l.flops = l.doComputeFLOPs(xN.Shape())
+	}
+	logf("%T shape %s: %v", l, l.name, result.Shape())
+	return result
+}
+
+// dilateAlong inserts factor-1 zeroes between the values of x along the given axis.
+func dilateAlong(x *G.Node, axis, factor int, name string) (retVal *G.Node, err error) {
+	if factor == 1 {
+		return x, nil
+	}
+	shp := x.Shape()
+	n := shp[axis]
+
+	// interleave with zeroes: (..., n, ...) → (..., n, 1, ...) ++ (..., n, factor-1, ...) → (..., n×factor, ...)
+	expanded := append(append(append(tensor.Shape{}, shp[:axis+1]...), 1), shp[axis+1:]...)
+	if retVal, err = G.Reshape(x, expanded); err != nil {
+		return nil, err
+	}
+	zshp := expanded.Clone()
+	zshp[axis+1] = factor - 1
+	zeroes := G.NewTensor(x.Graph(), x.Dtype(), zshp.Dims(), G.WithShape(zshp...), G.WithName(name), G.WithInit(G.Zeroes()))
+	if retVal, err = G.Concat(axis+1, retVal, zeroes); err != nil {
+		return nil, err
+	}
+	interleaved := shp.Clone()
+	interleaved[axis] = n * factor
+	if retVal, err = G.Reshape(retVal, interleaved); err != nil {
+		return nil, err
+	}
+
+	// the zeroes after the last value are dropped
+	return sliceAlong(retVal, axis, 0, (n-1)*factor+1)
+}
+
+// padAlong pads x with zeroes along the given axis. Negative paddings crop x instead.
+func padAlong(x *G.Node, axis, before, after int, name string) (retVal *G.Node, err error) {
+	return padAlongWith(x, axis, before, after, name, G.Zeroes())
+}
+
+// padAlongWith pads x with the values of fill along the given axis. Negative paddings crop x instead.
+func padAlongWith(x *G.Node, axis, before, after int, name string, fill G.InitWFn) (retVal *G.Node, err error) {
+	retVal = x
+	if before < 0 || after < 0 {
+		start, end := 0, x.Shape()[axis]
+		if before < 0 {
+			start, before = -before, 0
+		}
+		if after < 0 {
+			end, after = end+after, 0
+		}
+		if end <= start {
+			return nil, errors.Errorf("unable to crop %v along axis %d", x.Shape(), axis)
+		}
+		if retVal, err = sliceAlong(retVal, axis, start, end); err != nil {
+			return nil, err
+		}
+	}
+	if before == 0 && after == 0 {
+		return retVal, nil
+	}
+
+	filled := func(size int) *G.Node {
+		fshp := retVal.Shape().Clone()
+		fshp[axis] = size
+		return G.NewTensor(x.Graph(), x.Dtype(), fshp.Dims(), G.WithShape(fshp...), G.WithName(fmt.Sprintf("%v_%d", name, size)), G.WithInit(fill))
+	}
+	inputs := G.Nodes{retVal}
+	if before > 0 {
+		inputs = append(G.Nodes{filled(before)}, inputs...)
+	}
+	if after > 0 {
+		inputs = append(inputs, filled(after))
+	}
+	return G.Concat(axis, inputs...)
+}
+
+// sliceAlong slices x along the given axis, keeping the axis.
+func sliceAlong(x *G.Node, axis, start, end int) (retVal *G.Node, err error) {
+	if start == 0 && end == x.Shape()[axis] {
+		return x, nil
+	}
+	ss := make([]tensor.Slice, axis+1)
+	ss[axis] = G.S(start, end)
+	if retVal, err = G.Slice(x, ss...); err != nil {
+		return nil, err
+	}
+	kept := x.Shape().Clone()
+	kept[axis] = end - start
+	return G.Reshape(retVal, kept)
+}
+
+// doComputeFLOPs computes the rough number of floating point operations for this layer.
+//
+// Every value of the input is scattered to a kernel-sized window of every output channel. As with Conv, the FLOPs are for a single
+// instance of the batch.
+func (l *ConvTranspose) doComputeFLOPs(input tensor.Shape) int {
+	shp := l.w.Shape()
+	retVal := 2 * input[1] * input[2] * input[3] * shp[1] * l.kernelShape.TotalSize() // multiply and accumulate
+	if l.act != nil {
+		out := l.outputSize(input)
+		retVal += shp[1] * out[0] * out[1]
+	}
+	return retVal
+}
+
+// Type will return the hm.Type of the transposed convolution layer
+func (l *ConvTranspose) Type() hm.Type {
+	return hm.NewFnType(hm.TypeVariable('a'), hm.TypeVariable('b'))
+}
+
+// Shape will return the tensor.Shape of the transposed convolution layer
+func (l *ConvTranspose) Shape() tensor.Shape { return l.w.Shape() }
+
+// Name will return the name of the transposed convolution layer
+func (l *ConvTranspose) Name() string { return l.name }
+
+// SetName sets the name of the layer
+func (l *ConvTranspose) SetName(n string) error {
+	l.name = n
+	return nil
+}
+
+// SetActivationFn sets the activation function of the layer
+func (l *ConvTranspose) SetActivationFn(act ActivationFunction) error {
+	l.act = act
+	return nil
+}
+
+// SetComputeFLOPs sets whether the FLOPs are computed when the input is forwarded.
+func (l *ConvTranspose) SetComputeFLOPs(toCompute bool) error {
+	l.computeFLOPs = toCompute
+	return nil
+}
+
+// FLOPs returns the FLOPs computed by the last Fwd.
+func (l *ConvTranspose) FLOPs() int { return l.flops }
+
+// Describe will describe a transposed convolution layer
+func (l *ConvTranspose) Describe() (*onnx.GraphProto, error) {
+	if !l.initialized {
+		return nil, errors.Errorf("Unable to describe ConvTranspose %v. It has not been initialized", l.name)
+	}
+	f := newFragment(l.name, "ConvTranspose", l.w.Dtype())
+	w, err := f.weight(l.w)
+	if err != nil {
+		return nil, err
+	}
+	f.apply("ConvTranspose", []string{w},
+		attrInts("kernel_shape", l.kernelShape...),
+		attrInts("pads", onnxPads(l.pad)...),
+		attrInts("strides", l.stride...),
+		attrInts("dilations", l.dilation...),
+		attrInts("output_padding", l.outputPad...),
+	)
+	if err = f.activate(l.act); err != nil {
+		return nil, err
+	}
+	return f.graph(), nil
+}
@@ -0,0 +1,71 @@
+package golgi
+
+import (
+	"github.com/pkg/errors"
+	G "gorgonia.org/gorgonia"
+	"gorgonia.org/tensor"
+)
+
+var (
+	_ Data   = &ConvTransposeData{}
+	_ Dataer = &ConvTranspose{}
+)
+
+// ConvTransposeData represents the data of a transposed convolution layer.
+type ConvTransposeData struct {
+	Name                             string
+	W                                *tensor.Dense
+	Size                             []int
+	KernelShape                      []int
+	Pad, Stride, Dilation, OutputPad []int
+	Act                              Activation
+}
+
+// Make creates a *ConvTranspose in the given graph. If name is empty, the name of the snapshotted layer is used.
+func (d *ConvTransposeData) Make(g *G.ExprGraph, name string) (Layer, error) {
+	if name == "" {
+		name = d.Name
+	}
+	l, err := NewConvTranspose(
+		WithName(name),
+		WithSize(d.Size...),
+		WithKernelShape(tensor.Shape(d.KernelShape)),
+		WithPad(d.Pad),
+		WithStride(d.Stride),
+		WithDilation(d.Dilation),
+		WithOutputPadding(d.OutputPad),
+		WithActivation(ActivationMap(d.Act)),
+	)
+	if err != nil {
+		return nil, errors.Wrapf(err, "Unable to make ConvTranspose %v", name)
+	}
+	l.w = weightFromData(g, d.W, name+"_w")
+	l.initialized = true
+	return l, nil
+}
+
+// ToData snapshots the weights and configuration of the transposed convolution layer.
+func (l *ConvTranspose) ToData() (Data, error) {
+	if l.w == nil {
+		return nil, errors.Errorf("Unable to take a snapshot of ConvTranspose %v. It has not been initialized", l.name)
+	}
+	act, err := activationOf(l.act)
+	if err != nil {
+		return nil, errors.Wrapf(err, "ToData of ConvTranspose %v", l.name)
+	}
+	w, err := snapshot(l.w)
+	if err != nil {
+		return nil, err
+	}
+	return &ConvTransposeData{
+		Name:        l.name,
+		W:           w,
+		Size:        cloneInts(l.size),
+		KernelShape: cloneInts(l.kernelShape),
+		Pad:         cloneInts(l.pad),
+		Stride:      cloneInts(l.stride),
+		Dilation:    cloneInts(l.dilation),
+		OutputPad:   cloneInts(l.outputPad),
+		Act:         act,
+	}, nil
+}
@@ -0,0 +1,155 @@
+package golgi
+
+import (
+	"bytes"
+	"testing"
+
+	"github.com/stretchr/testify/require"
+	"gorgonia.org/gorgonia"
+	"gorgonia.org/tensor"
+)
+
+// convTransposeRef scatters every value of x over a kernel-sized window of the output with plain loops.
+func convTransposeRef(l *ConvTranspose, xs []float64, xshp tensor.Shape, ws []float64) ([]float64, tensor.Shape) {
+	out := l.outputSize(xshp)
+	cin, cout := xshp[1], l.size[0]
+	kh, kw := l.kernelShape[0], l.kernelShape[1]
+	outshp := tensor.Shape{xshp[0], cout, out[0], out[1]}
+	retVal := make([]float64, outshp.TotalSize())
+	for n := 0; n < xshp[0]; n++ {
+		for ci := 0; ci < cin; ci++ {
+			for i := 0; i < xshp[2]; i++ {
+				for j := 0; j < xshp[3]; j++ {
+					x := xs[((n*cin+ci)*xshp[2]+i)*xshp[3]+j]
+					for co := 0; co < cout; co++ {
+						for a := 0; a < kh; a++ {
+							for b := 0; b < kw; b++ {
+								oi := i*l.stride[0] - l.pad[0] + a*l.dilation[0]
+								oj := j*l.stride[1] - l.pad[1] + b*l.dilation[1]
+								if oi < 0 || oi >= out[0] || oj < 0 || oj >= out[1] {
+									continue
+								}
+								w := ws[((ci*cout+co)*kh+a)*kw+b]
+								retVal[((n*cout+co)*out[0]+oi)*out[1]+oj] += x * w
+							}
+						}
+					}
+				}
+			}
+		}
+	}
+	return retVal, outshp
+}
+
+func TestConvTranspose(t *testing.T) {
+	cases := []struct {
+		name                             string
+		xshp                             tensor.Shape
+		kernel                           tensor.Shape
+		pad, stride, dilation, outputPad []int
+	}{
+		{"upsample", tensor.Shape{2, 2, 3, 3}, tensor.Shape{2, 2}, []int{0, 0}, []int{2, 2}, []int{1, 1}, []int{0, 0}},
+		{"padded", tensor.Shape{1, 2, 3, 4}, tensor.Shape{3, 3}, []int{1, 1}, []int{2, 1}, []int{1, 1}, []int{1, 0}},
+		{"cropped", tensor.Shape{1, 2, 4, 3}, tensor.Shape{2, 3}, []int{2, 1}, []int{1, 3}, []int{1, 1}, []int{0, 2}},
+		{"dilated", tensor.Shape{1, 1, 3, 3}, tensor.Shape{2, 2}, []int{1, 0}, []int{2, 2}, []int{2, 3}, []int{1, 0}},
+	}
+	for _, tc := range cases {
+		t.Run(tc.name, func(t *testing.T) {
+			c := require.New(t)
+			g := gorgonia.NewGraph()
+			xs := make([]float64, tc.xshp.TotalSize())
+			for i := range xs {
+				xs[i] = float64((i*7)%13)/13 - 0.5
+			}
+			x := gorgonia.NewTensor(g, tensor.Float64, 4, gorgonia.WithName("x"), gorgonia.WithShape(tc.xshp...), gorgonia.WithValue(tensor.New(tensor.WithShape(tc.xshp...), tensor.WithBacking(xs))))
+
+			l, err := ConsConvTranspose(x, WithName("up"), WithSize(3, tc.xshp[1]), WithKernelShape(tc.kernel), WithPad(tc.pad), WithStride(tc.stride),
+				WithDilation(tc.dilation), WithOutputPadding(tc.outputPad), WithActivation(nil), ComputeFLOPs(true))
+			c.NoError(err)
+			up := l.(*ConvTranspose)
+			c.Equal(tensor.Shape{tc.xshp[1], 3, tc.kernel[0], tc.kernel[1]}, up.w.Shape())
+			ws := up.w.Value().Data().([]float64)
+
+			out := up.Fwd(x)
+			c.NoError(gorgonia.CheckOne(out))
+			expected, outshp := convTransposeRef(up, xs, tc.xshp, ws)
+			c.Equal(outshp, out.Node().Shape())
+			c.Equal(2*tc.xshp[1:].TotalSize()*3*tc.kernel.TotalSize(), up.FLOPs())
+
+			// the cost is a weighted sum of the output, so that every output value has a different gradient
+			rs := make([]float64, outshp.TotalSize())
+			for i := range rs {
+				rs[i] = float64((i*5)%7) - 3
+			}
+			r := gorgonia.NewTensor(g, tensor.Float64, 4, gorgonia.WithName("r"), gorgonia.WithShape(outshp...), gorgonia.WithValue(tensor.New(tensor.WithShape(outshp...), tensor.WithBacking(rs))))
+			weighted, err := gorgonia.HadamardProd(out.Node(), r)
+			c.NoError(err)
+			cost, err := gorgonia.Sum(weighted)
+			c.NoError(err)
+			grads, err := gorgonia.Grad(cost, up.w, x)
+			c.NoError(err)
+			var y, dw, dx gorgonia.Value
+			gorgonia.Read(out.Node(), &y)
+			gorgonia.Read(grads[0], &dw)
+			gorgonia.Read(grads[1], &dx)
+			m := gorgonia.NewTapeMachine(g)
+			c.NoError(m.RunAll())
+			m.Close()
+			c.InDeltaSlice(expected, y.Data(), 1e-10)
+
+			// the reference is linear, so central differences are exact up to rounding
+			refCost := func(xs, ws []float64) float64 {
+				ys, _ := convTransposeRef(up, xs, tc.xshp, ws)
+				var acc float64
+				for i := range ys {
+					acc += ys[i] * rs[i]
+				}
+				return acc
+			}
+			numGrad := func(vs []float64, f func() float64) []float64 {
+				retVal := make([]float64, len(vs))
+				for i := range vs {
+					orig := vs[i]
+					vs[i] = orig + 1e-6
+					plus := f()
+					vs[i] = orig - 1e-6
+					minus := f()
+					vs[i] = orig
+					retVal[i] = (plus - minus) / 2e-6
+				}
+				return retVal
+			}
+			wsCopy := append([]float64(nil), ws...)
+			xsCopy := append([]float64(nil), xs...)
+			c.InDeltaSlice(numGrad(wsCopy, func() float64 { return refCost(xsCopy, wsCopy) }), dw.Data(), 1e-6)
+			c.InDeltaSlice(numGrad(xsCopy, func() float64 { return refCost(xsCopy, wsCopy) }), dx.Data(), 1e-6)
+
+			desc, err := up.Describe()
+			c.NoError(err)
+			c.Equal([]string{"ConvTranspose"}, opTypes(desc))
+			checkWellFormed(t, desc)
+
+			// a checkpoint keeps the weights and configuration
+			var buf bytes.Buffer
+			c.NoError(SaveCheckpoint(&buf, up))
+			g2 := gorgonia.NewGraph()
+			x2 := gorgonia.NewTensor(g2, tensor.Float64, 4, gorgonia.WithName("x"), gorgonia.WithShape(tc.xshp...), gorgonia.WithValue(tensor.New(tensor.WithShape(tc.xshp...), tensor.WithBacking(xs))))
+			loaded, err := LoadCheckpoint(g2, &buf)
+			c.NoError(err)
+			c.Equal(tc.outputPad, loaded.(*ConvTranspose).outputPad)
+			out2 := loaded.Fwd(x2)
+			c.NoError(gorgonia.CheckOne(out2))
+			c.InDeltaSlice(expected, runValue(t, g2, out2.Node()), 1e-10)
+		})
+	}
+
+	c := require.New(t)
+	g := gorgonia.NewGraph()
+	x := gorgonia.NewTensor(g, tensor.Float64, 4, gorgonia.WithShape(1, 2, 3, 3), gorgonia.WithInit(gorgonia.GlorotU(1)))
+	_, err := ConsConvTranspose(x, WithSize(1, 3))
+	c.Error(err)
+	_, err = ConsConvTranspose(x, WithSize(1, 2), WithOutputPadding([]int{2, 0}))
+	c.Error(err)
+	_, err = ConsConvTranspose(gorgonia.NewMatrix(g, tensor.Float64, gorgonia.WithShape(2, 2)), WithSize(1, 2))
+	c.Error(err)
+}
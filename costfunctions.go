@@ -9,6 +9,8 @@ import (
 // The root-mean-square deviation (RMSD) or root-mean-square error (RMSE) is a frequently
 // used measure of the differences between values (sample or population values) predicted
 // by a model or an estimator and the values observed.
+//
+// Note that despite its name, RMS does not take the square root: it is the mean squared error. Use RMSE for the root mean squared error.
 func RMS(yHat, y G.Input) (retVal *G.Node, err error) {
 	if err = G.CheckOne(yHat); err != nil {
 		return nil, errors.Wrap(err, "unable to extract node from yHat")
@@ -32,3 +34,436 @@ func RMS(yHat, y G.Input) (retVal *G.Node, err error) {
 
 	return retVal, nil
 }
+
+// Reduction is how the losses of the samples of a batch are reduced into a cost.
+type Reduction int
+
+const (
+	// ReduceMean reduces the losses of the samples into their mean. This is the default.
+	ReduceMean Reduction = iota
+	// ReduceSum reduces the losses of the samples into their sum.
+	ReduceSum
+	// ReduceNone does not reduce the losses. The cost is a vector of the loss of each sample.
+	ReduceNone
+)
+
+// costEps is used to perturb values before taking their logarithms.
+const costEps = 1e-7
+
+// CostOpt is an option for the cost functions that accept options.
+type CostOpt func(c *costConfig) error
+
+// costConfig is the configuration of a cost function.
+type costConfig struct {
+	reduction Reduction
+	weights   *G.Node
+	smoothing float64
+	delta     float64
+	margin    *float64 // nil for the default margin of the cost function
+	labels    *G.Node
+}
+
+// WithReduction is a CostOpt that sets how the losses of the samples are reduced.
+func WithReduction(r Reduction) CostOpt {
+	return func(c *costConfig) error {
+		if r < ReduceMean || r > ReduceNone {
+			return errors.Errorf("Unknown Reduction %d", r)
+		}
+		c.reduction = r
+		return nil
+	}
+}
+
+// WithSampleWeights is a CostOpt that weighs the loss of each sample. The weights have to be a vector with one weight per sample.
+func WithSampleWeights(w G.Input) CostOpt {
+	return func(c *costConfig) error {
+		if err := G.CheckOne(w); err != nil {
+			return errors.Wrap(err, "Unable to extract node from the sample weights")
+		}
+		c.weights = w.Node()
+		return nil
+	}
+}
+
+// WithLabelSmoothing is a CostOpt for the cross entropies. The targets y become y(1-ε) + ε/K, where K is the number of classes.
+func WithLabelSmoothing(ε float64) CostOpt {
+	return func(c *costConfig) error {
+		if ε < 0 || ε >= 1 {
+			return errors.Errorf("Label smoothing has to be in [0, 1). Got %v", ε)
+		}
+		c.smoothing = ε
+		return nil
+	}
+}
+
+// WithDelta is a CostOpt for Huber. It sets the point where the loss changes from quadratic to linear. The default is 1.
+func WithDelta(δ float64) CostOpt {
+	return func(c *costConfig) error {
+		if δ <= 0 {
+			return errors.Errorf("Delta has to be positive. Got %v", δ)
+		}
+		c.delta = δ
+		return nil
+	}
+}
+
+// WithMargin is a CostOpt for Hinge and CosineEmbedding. The default is 1 for Hinge and 0 for CosineEmbedding.
+func WithMargin(m float64) CostOpt {
+	return func(c *costConfig) error {
+		c.margin = &m
+		return nil
+	}
+}
+
+// WithSimilarity is a CostOpt for CosineEmbedding. The labels have to be a vector with one label per sample: 1 if ŷ and y should
+// be similar, and -1 if they should be dissimilar.
+func WithSimilarity(labels G.Input) CostOpt {
+	return func(c *costConfig) error {
+		if err := G.CheckOne(labels); err != nil {
+			return errors.Wrap(err, "Unable to extract node from the similarity labels")
+		}
+		c.labels = labels.Node()
+		return nil
+	}
+}
+
+// CostFnWithOpts is a cost function that accepts options.
+type CostFnWithOpts func(yHat, y G.Input, opts ...CostOpt) (*G.Node, error)
+
+// WithCostOpts binds the options to a cost function, so it can be used as a CostFn, e.g. in a Trainer.
+func WithCostOpts(fn CostFnWithOpts, opts ...CostOpt) CostFn {
+	return func(yHat, y G.Input) (*G.Node, error) { return fn(yHat, y, opts...) }
+}
+
+var (
+	_ CostFnWithOpts = CrossEntropy
+	_ CostFnWithOpts = BinaryCrossEntropy
+	_ CostFnWithOpts = SparseCategoricalCrossEntropy
+	_ CostFnWithOpts = Huber
+	_ CostFnWithOpts = Hinge
+	_ CostFnWithOpts = KLDivergence
+	_ CostFnWithOpts = CosineEmbedding
+	_ CostFnWithOpts = MAE
+	_ CostFnWithOpts = RMSE
+)
+
+var (
+	ln     = G.Lift1(G.Log)
+	square = G.Lift1(G.Square)
+	sqrt   = G.Lift1(G.Sqrt)
+)
+
+// CrossEntropy is the categorical cross entropy -Σ y log(ŷ). ŷ is expected to be a probability distribution over the last axis
+// (e.g. the output of a softmax), and y is expected to be one-hot encoded.
+func CrossEntropy(yHat, y G.Input, opts ...CostOpt) (retVal *G.Node, err error) {
+	ŷ, t, c, err := prepCost(yHat, y, opts)
+	if err != nil {
+		return nil, errors.Wrap(err, "CrossEntropy")
+	}
+	return c.crossEntropy(ŷ, t)
+}
+
+// SparseCategoricalCrossEntropy is the categorical cross entropy where y is a vector of classes instead of a one-hot matrix.
+//
+// The classes may be qol.Class, or any of the types an Embedding accepts as classes. ŷ is expected to be a (samples, classes) matrix.
+func SparseCategoricalCrossEntropy(yHat, y G.Input, opts ...CostOpt) (retVal *G.Node, err error) {
+	ŷ, t, c, err := prepCost(yHat, y, opts)
+	if err != nil {
+		return nil, errors.Wrap(err, "SparseCategoricalCrossEntropy")
+	}
+	if !ŷ.IsMatrix() {
+		return nil, errors.Errorf("SparseCategoricalCrossEntropy expects ŷ to be a matrix. Got %v instead", ŷ.Shape())
+	}
+	if t, err = oneHot(t, ŷ.Shape()[1], ŷ.Dtype()); err != nil {
+		return nil, errors.Wrap(err, "SparseCategoricalCrossEntropy")
+	}
+	return c.crossEntropy(ŷ, t)
+}
+
+// BinaryCrossEntropy is -(y log(ŷ) + (1-y) log(1-ŷ)), averaged over each sample. ŷ is expected to be a probability (e.g. the output of a sigmoid).
+func BinaryCrossEntropy(yHat, y G.Input, opts ...CostOpt) (retVal *G.Node, err error) {
+	ŷ, t, c, err := prepCost(yHat, y, opts)
+	if err != nil {
+		return nil, errors.Wrap(err, "BinaryCrossEntropy")
+	}
+	if t, err = c.smooth(t, 2); err != nil {
+		return nil, err
+	}
+	one, eps, err := constsOf(ŷ, 1, costEps)
+	if err != nil {
+		return nil, err
+	}
+	losses := neg(add(
+		elmul(t, ln(add(ŷ, eps))),
+		elmul(sub(one, t), ln(add(sub(one, ŷ), eps))),
+	))
+	if err = losses.Err(); err != nil {
+		return nil, errors.Wrap(err, "-(y log(ŷ) + (1-y) log(1-ŷ))")
+	}
+	return c.reduce(losses.Node(), G.Mean)
+}
+
+// Huber is the Huber loss: ½(ŷ-y)² where |ŷ-y| ≤ δ and δ(|ŷ-y| - ½δ) otherwise, averaged over each sample. Use WithDelta to set δ.
+func Huber(yHat, y G.Input, opts ...CostOpt) (retVal *G.Node, err error) {
+	ŷ, t, c, err := prepCost(yHat, y, opts)
+	if err != nil {
+		return nil, errors.Wrap(err, "Huber")
+	}
+	if err = c.noSmoothing("Huber"); err != nil {
+		return nil, err
+	}
+	half, δ, err := constsOf(ŷ, 0.5, c.delta)
+	if err != nil {
+		return nil, err
+	}
+	// the quadratic part is min(|ŷ-y|, δ) = |ŷ-y| - max(0, |ŷ-y| - δ)
+	d := abs(sub(ŷ, t))
+	q := sub(d, rectify(sub(d, δ)))
+	losses := add(
+		elmul(half, square(q)),
+		elmul(δ, sub(d, q)),
+	)
+	if err = losses.Err(); err != nil {
+		return nil, errors.Wrap(err, "Huber")
+	}
+	return c.reduce(losses.Node(), G.Mean)
+}
+
+// Hinge is max(0, m - yŷ), averaged over each sample. y is expected to be -1 or 1. Use WithMargin to set m.
+func Hinge(yHat, y G.Input, opts ...CostOpt) (retVal *G.Node, err error) {
+	ŷ, t, c, err := prepCost(yHat, y, opts)
+	if err != nil {
+		return nil, errors.Wrap(err, "Hinge")
+	}
+	if err = c.noSmoothing("Hinge"); err != nil {
+		return nil, err
+	}
+	m, err := constOf(ŷ, c.marginOr(1))
+	if err != nil {
+		return nil, err
+	}
+	losses := rectify(sub(m, elmul(t, ŷ)))
+	if err = losses.Err(); err != nil {
+		return nil, errors.Wrap(err, "max(0, m - yŷ)")
+	}
+	return c.reduce(losses.Node(), G.Mean)
+}
+
+// KLDivergence is the Kullback-Leibler divergence Σ y (log(y) - log(ŷ)) of ŷ from y. Both are expected to be probability distributions over the last axis.
+func KLDivergence(yHat, y G.Input, opts ...CostOpt) (retVal *G.Node, err error) {
+	ŷ, t, c, err := prepCost(yHat, y, opts)
+	if err != nil {
+		return nil, errors.Wrap(err, "KLDivergence")
+	}
+	if err = c.noSmoothing("KLDivergence"); err != nil {
+		return nil, err
+	}
+	eps, err := constOf(ŷ, costEps)
+	if err != nil {
+		return nil, err
+	}
+	losses := elmul(t, sub(ln(add(t, eps)), ln(add(ŷ, eps))))
+	if err = losses.Err(); err != nil {
+		return nil, errors.Wrap(err, "y (log(y) - log(ŷ))")
+	}
+	return c.reduce(losses.Node(), G.Sum)
+}
+
+// CosineEmbedding is the cosine embedding loss of each pair of samples of ŷ and y:
+//
+//	1 - cos(ŷ, y)          if the pair is labelled 1
+//	max(0, cos(ŷ, y) - m)  if the pair is labelled -1
+//
+// The labels are given with WithSimilarity. Without labels, every pair is labelled 1. Use WithMargin to set m.
+// ŷ and y are expected to be (samples, features) matrices.
+func CosineEmbedding(yHat, y G.Input, opts ...CostOpt) (retVal *G.Node, err error) {
+	ŷ, t, c, err := prepCost(yHat, y, opts)
+	if err != nil {
+		return nil, errors.Wrap(err, "CosineEmbedding")
+	}
+	if err = c.noSmoothing("CosineEmbedding"); err != nil {
+		return nil, err
+	}
+	if !ŷ.IsMatrix() {
+		return nil, errors.Errorf("CosineEmbedding expects a matrix. Got %v instead", ŷ.Shape())
+	}
+	one, eps, err := constsOf(ŷ, 1, costEps)
+	if err != nil {
+		return nil, err
+	}
+	sumRows := G.Lift1(func(a *G.Node) (*G.Node, error) { return G.Sum(a, 1) })
+	dot := sumRows(elmul(ŷ, t))
+	norms := elmul(sqrt(sumRows(square(ŷ))), sqrt(sumRows(square(t))))
+	cos := eldiv(dot, add(norms, eps))
+	losses := sub(one, cos)
+	if c.labels != nil {
+		if err = cos.Err(); err != nil {
+			return nil, errors.Wrap(err, "cos(ŷ, y)")
+		}
+		if !c.labels.Shape().Eq(cos.Node().Shape()) {
+			return nil, errors.Errorf("Expected the similarity labels to be of shape %v. Got %v instead", cos.Node().Shape(), c.labels.Shape())
+		}
+		var half, m *G.Node
+		if half, m, err = constsOf(ŷ, 0.5, c.marginOr(0)); err != nil {
+			return nil, err
+		}
+		// the labels are turned into the masks (1+l)/2 of the similar pairs and (1-l)/2 of the dissimilar pairs
+		similar := elmul(half, add(one, c.labels))
+		dissimilar := elmul(half, sub(one, c.labels))
+		losses = add(elmul(similar, losses), elmul(dissimilar, rectify(sub(cos, m))))
+	}
+	if err = losses.Err(); err != nil {
+		return nil, errors.Wrap(err, "CosineEmbedding")
+	}
+	return c.reduce(losses.Node(), G.Mean)
+}
+
+// MAE is the mean absolute error |ŷ-y|.
+func MAE(yHat, y G.Input, opts ...CostOpt) (retVal *G.Node, err error) {
+	ŷ, t, c, err := prepCost(yHat, y, opts)
+	if err != nil {
+		return nil, errors.Wrap(err, "MAE")
+	}
+	if err = c.noSmoothing("MAE"); err != nil {
+		return nil, err
+	}
+	losses := abs(sub(ŷ, t))
+	if err = losses.Err(); err != nil {
+		return nil, errors.Wrap(err, "|ŷ-y|")
+	}
+	return c.reduce(losses.Node(), G.Mean)
+}
+
+// RMSE is the root mean squared error √(mean((ŷ-y)²)). Unlike RMS, the square root is taken.
+//
+// The square root is taken of the mean over all the samples, weighted if WithSampleWeights is given. As the square root of a sum
+// is not an RMSE, only ReduceMean is supported.
+func RMSE(yHat, y G.Input, opts ...CostOpt) (retVal *G.Node, err error) {
+	ŷ, t, c, err := prepCost(yHat, y, opts)
+	if err != nil {
+		return nil, errors.Wrap(err, "RMSE")
+	}
+	if err = c.noSmoothing("RMSE"); err != nil {
+		return nil, err
+	}
+	if c.reduction != ReduceMean {
+		return nil, errors.New("RMSE only supports ReduceMean")
+	}
+	sq := square(sub(ŷ, t))
+	if err = sq.Err(); err != nil {
+		return nil, errors.Wrap(err, "(ŷ-y)²")
+	}
+	if retVal, err = c.reduce(sq.Node(), G.Mean); err != nil {
+		return nil, err
+	}
+	return G.Sqrt(retVal)
+}
+
+// prepCost checks the inputs of a cost function and applies the options.
+func prepCost(yHat, y G.Input, opts []CostOpt) (ŷ, t *G.Node, c *costConfig, err error) {
+	if err = G.CheckOne(yHat); err != nil {
+		return nil, nil, nil, errors.Wrap(err, "unable to extract node from yHat")
+	}
+	if err = G.CheckOne(y); err != nil {
+		return nil, nil, nil, errors.Wrap(err, "unable to extract node from y")
+	}
+	c = &costConfig{delta: 1}
+	for _, opt := range opts {
+		if err = opt(c); err != nil {
+			return nil, nil, nil, err
+		}
+	}
+	return yHat.Node(), y.Node(), c, nil
+}
+
+// crossEntropy computes -Σ y log(ŷ) over the last axis.
+func (c *costConfig) crossEntropy(ŷ, t *G.Node) (*G.Node, error) {
+	classes := 1
+	if ŷ.Dims() > 0 {
+		classes = ŷ.Shape()[ŷ.Dims()-1]
+	}
+	t, err := c.smooth(t, classes)
+	if err != nil {
+		return nil, err
+	}
+	eps, err := constOf(ŷ, costEps)
+	if err != nil {
+		return nil, err
+	}
+	losses := neg(elmul(t, ln(add(ŷ, eps))))
+	if err = losses.Err(); err != nil {
+		return nil, errors.Wrap(err, "-y log(ŷ)")
+	}
+	return c.reduce(losses.Node(), G.Sum)
+}
+
+// smooth applies label smoothing to the targets: y(1-ε) + ε/K.
+func (c *costConfig) smooth(t *G.Node, classes int) (*G.Node, error) {
+	if c.smoothing == 0 {
+		return t, nil
+	}
+	keep, spread, err := constsOf(t, 1-c.smoothing, c.smoothing/float64(classes))
+	if err != nil {
+		return nil, err
+	}
+	retVal := add(elmul(keep, t), spread)
+	return retVal.Node(), errors.Wrap(retVal.Err(), "y(1-ε) + ε/K")
+}
+
+// marginOr returns the margin given with WithMargin, or the default margin of the cost function.
+func (c *costConfig) marginOr(def float64) float64 {
+	if c.margin == nil {
+		return def
+	}
+	return *c.margin
+}
+
+// noSmoothing returns an error if label smoothing was requested for a cost function that does not support it.
+func (c *costConfig) noSmoothing(cost string) error {
+	if c.smoothing != 0 {
+		return errors.Errorf("%v does not support label smoothing", cost)
+	}
+	return nil
+}
+
+// reduce reduces the elementwise losses into the losses of each sample with perSample, weighs them, and then applies the reduction.
+// The first axis is the axis of the samples.
+func (c *costConfig) reduce(losses *G.Node, perSample func(a *G.Node, along ...int) (*G.Node, error)) (retVal *G.Node, err error) {
+	retVal = losses
+	if losses.Dims() > 1 {
+		axes := make([]int, 0, losses.Dims()-1)
+		for i := 1; i < losses.Dims(); i++ {
+			axes = append(axes, i)
+		}
+		if retVal, err = perSample(losses, axes...); err != nil {
+			return nil, errors.Wrap(err, "Unable to compute the loss of each sample")
+		}
+	}
+	if c.weights != nil {
+		if !c.weights.Shape().Eq(retVal.Shape()) {
+			return nil, errors.Errorf("Expected the sample weights to be of shape %v. Got %v instead", retVal.Shape(), c.weights.Shape())
+		}
+		if retVal, err = G.HadamardProd(retVal, c.weights); err != nil {
+			return nil, errors.Wrap(err, "Unable to weigh the losses")
+		}
+	}
+	switch c.reduction {
+	case ReduceSum:
+		return G.Sum(retVal)
+	case ReduceNone:
+		return retVal, nil
+	default:
+		return G.Mean(retVal)
+	}
+}
+
+// constsOf creates two constants with the same Dtype as a.
+func constsOf(a *G.Node, v0, v1 float64) (c0, c1 *G.Node, err error) {
+	if c0, err = constOf(a, v0); err != nil {
+		return nil, nil, err
+	}
+	if c1, err = constOf(a, v1); err != nil {
+		return nil, nil, err
+	}
+	return c0, c1, nil
+}
@@ -0,0 +1,153 @@
+package golgi
+
+import (
+	"math"
+	"testing"
+
+	"github.com/stretchr/testify/require"
+	"gorgonia.org/gorgonia"
+	"gorgonia.org/qol"
+	"gorgonia.org/tensor"
+)
+
+// evalCost builds a cost on a fresh graph, checks that it is differentiable with regards to ŷ, and returns its value.
+func evalCost(t *testing.T, cost CostFn, yHat, y tensor.Tensor) []float64 {
+	c := require.New(t)
+	g := gorgonia.NewGraph()
+	ŷ := gorgonia.NodeFromAny(g, yHat, gorgonia.WithName("ŷ"))
+	target := gorgonia.NodeFromAny(g, y, gorgonia.WithName("y"))
+	out, err := cost(ŷ, target)
+	c.NoError(err)
+
+	scalar := out
+	if !out.IsScalar() {
+		scalar, err = gorgonia.Sum(out)
+		c.NoError(err)
+	}
+	_, err = gorgonia.Grad(scalar, ŷ)
+	c.NoError(err)
+
+	var v gorgonia.Value
+	gorgonia.Read(out, &v)
+	m := gorgonia.NewTapeMachine(g)
+	defer m.Close()
+	c.NoError(m.RunAll())
+	switch d := v.Data().(type) {
+	case float64:
+		return []float64{d}
+	case []float64:
+		return append([]float64(nil), d...)
+	}
+	t.Fatalf("Unexpected value %v", v)
+	return nil
+}
+
+func TestCostFunctions(t *testing.T) {
+	probs := []float64{0.7, 0.2, 0.1, 0.1, 0.3, 0.6}
+	onehot := []float64{1, 0, 0, 0, 0, 1}
+	mat := func(backing []float64) tensor.Tensor {
+		return tensor.New(tensor.WithShape(2, 3), tensor.WithBacking(append([]float64(nil), backing...)))
+	}
+
+	ce := []float64{-math.Log(0.7 + costEps), -math.Log(0.6 + costEps)}
+	bce := func(p, y float64) float64 {
+		return -(y*math.Log(p+costEps) + (1-y)*math.Log(1-p+costEps))
+	}
+	var bces [2]float64
+	for i := range probs {
+		bces[i/3] += bce(probs[i], onehot[i]) / 3
+	}
+
+	testCases := []struct {
+		name string
+		cost CostFn
+		want []float64
+	}{
+		{"CrossEntropy", WithCostOpts(CrossEntropy), []float64{(ce[0] + ce[1]) / 2}},
+		{"CrossEntropy/Sum", WithCostOpts(CrossEntropy, WithReduction(ReduceSum)), []float64{ce[0] + ce[1]}},
+		{"CrossEntropy/None", WithCostOpts(CrossEntropy, WithReduction(ReduceNone)), ce},
+		{"BinaryCrossEntropy", WithCostOpts(BinaryCrossEntropy, WithReduction(ReduceNone)), bces[:]},
+		{"MAE", WithCostOpts(MAE), []float64{(0.3 + 0.2 + 0.1 + 0.1 + 0.3 + 0.4) / 6}},
+		{"RMSE", WithCostOpts(RMSE), []float64{math.Sqrt((0.09 + 0.04 + 0.01 + 0.01 + 0.09 + 0.16) / 6)}},
+		{"Hinge", WithCostOpts(Hinge, WithReduction(ReduceNone)), []float64{(0.3 + 1 + 1) / 3, (1 + 1 + 0.4) / 3}},
+		{"Huber", WithCostOpts(Huber, WithDelta(0.25), WithReduction(ReduceSum)), []float64{
+			(0.25*(0.3-0.125) + 0.5*0.04 + 0.5*0.01 + 0.5*0.01 + 0.25*(0.3-0.125) + 0.25*(0.4-0.125)) / 3,
+		}},
+		{"KLDivergence", WithCostOpts(KLDivergence, WithReduction(ReduceNone)), []float64{
+			math.Log(1+costEps) - math.Log(0.7+costEps),
+			math.Log(1+costEps) - math.Log(0.6+costEps),
+		}},
+	}
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			got := evalCost(t, tc.cost, mat(probs), mat(onehot))
+			require.InDeltaSlice(t, tc.want, got, 1e-6)
+		})
+	}
+}
+
+func TestCostFunctions_Options(t *testing.T) {
+	c := require.New(t)
+	probs := tensor.New(tensor.WithShape(2, 3), tensor.WithBacking([]float64{0.7, 0.2, 0.1, 0.1, 0.3, 0.6}))
+	onehot := tensor.New(tensor.WithShape(2, 3), tensor.WithBacking([]float64{1, 0, 0, 0, 0, 1}))
+	ce0, ce1 := -math.Log(0.7+costEps), -math.Log(0.6+costEps)
+
+	// sparse labels are the same as one-hot labels
+	labels := tensor.New(tensor.WithShape(2), tensor.WithBacking([]qol.Class{0, 2}))
+	got := evalCost(t, WithCostOpts(SparseCategoricalCrossEntropy, WithReduction(ReduceNone)), probs.Clone().(tensor.Tensor), labels)
+	c.InDeltaSlice([]float64{ce0, ce1}, got, 1e-10)
+
+	// per-sample weights
+	weights := tensor.New(tensor.WithBacking([]float64{2, 0.5}))
+	got = evalCost(t, func(yHat, y gorgonia.Input) (*gorgonia.Node, error) {
+		// the weights have to live in the same graph as the cost
+		w := gorgonia.NodeFromAny(yHat.Node().Graph(), weights, gorgonia.WithName("w"))
+		return CrossEntropy(yHat, y, WithSampleWeights(w), WithReduction(ReduceSum))
+	}, probs.Clone().(tensor.Tensor), onehot.Clone().(tensor.Tensor))
+	c.InDeltaSlice([]float64{2*ce0 + 0.5*ce1}, got, 1e-10)
+
+	// label smoothing: y = y(1-ε) + ε/K
+	ε := 0.3
+	smoothed := func(p []float64, y []float64) (retVal float64) {
+		for i := range p {
+			retVal -= (y[i]*(1-ε) + ε/3) * math.Log(p[i]+costEps)
+		}
+		return retVal
+	}
+	got = evalCost(t, WithCostOpts(CrossEntropy, WithLabelSmoothing(ε), WithReduction(ReduceNone)), probs.Clone().(tensor.Tensor), onehot.Clone().(tensor.Tensor))
+	c.InDeltaSlice([]float64{smoothed([]float64{0.7, 0.2, 0.1}, []float64{1, 0, 0}), smoothed([]float64{0.1, 0.3, 0.6}, []float64{0, 0, 1})}, got, 1e-10)
+
+	// cosine
+	a := tensor.New(tensor.WithShape(2, 2), tensor.WithBacking([]float64{1, 0, 1, 1}))
+	b := tensor.New(tensor.WithShape(2, 2), tensor.WithBacking([]float64{1, 0, 0, 1}))
+	got = evalCost(t, WithCostOpts(CosineEmbedding, WithReduction(ReduceNone)), a, b)
+	c.InDeltaSlice([]float64{0, 1 - 1/math.Sqrt2}, got, 1e-6)
+
+	// cosine with a dissimilar pair: max(0, cos - m)
+	similarity := tensor.New(tensor.WithBacking([]float64{1, -1}))
+	got = evalCost(t, func(yHat, y gorgonia.Input) (*gorgonia.Node, error) {
+		l := gorgonia.NodeFromAny(yHat.Node().Graph(), similarity, gorgonia.WithName("labels"))
+		return CosineEmbedding(yHat, y, WithSimilarity(l), WithMargin(0.5), WithReduction(ReduceNone))
+	}, a.Clone().(tensor.Tensor), b.Clone().(tensor.Tensor))
+	c.InDeltaSlice([]float64{0, 1/math.Sqrt2 - 0.5}, got, 1e-6)
+
+	// invalid options
+	g := gorgonia.NewGraph()
+	x := gorgonia.NewMatrix(g, tensor.Float64, gorgonia.WithShape(2, 3), gorgonia.WithInit(gorgonia.Zeroes()))
+	_, err := MAE(x, x, WithLabelSmoothing(0.1))
+	c.Error(err)
+	_, err = MAE(x, x, WithLabelSmoothing(1))
+	c.Error(err)
+	_, err = Huber(x, x, WithDelta(0))
+	c.Error(err)
+	_, err = MAE(x, x, WithSampleWeights(x))
+	c.Error(err)
+	_, err = CosineEmbedding(x, x, WithSimilarity(x))
+	c.Error(err)
+
+	// the square root of a sum, or of each element, is not an RMSE
+	_, err = RMSE(x, x, WithReduction(ReduceSum))
+	c.Error(err)
+	_, err = RMSE(x, x, WithReduction(ReduceNone))
+	c.Error(err)
+}
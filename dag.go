@@ -0,0 +1,377 @@
+package golgi
+
+import (
+	"fmt"
+	"strings"
+
+	"github.com/pkg/errors"
+	"gorgonia.org/golgi/onnx"
+	G "gorgonia.org/gorgonia"
+)
+
+var (
+	_ Layer     = (*DAG)(nil)
+	_ ByNamer   = (*DAG)(nil)
+	_ Runnerser = (*DAG)(nil)
+)
+
+// DAG is a model whose layers are wired into a directed acyclic graph by name. Unlike a Composition, which has a single input and
+// a single output, a DAG has any number of named inputs and named outputs. This allows models such as U-Nets, siamese networks
+// and multi-task heads to be expressed.
+//
+// The inputs are bound with an *Env when the DAG is applied:
+//
+//	d := NewDAG("left", "right")
+//	d.Add("l", encoder, "left")
+//	d.Add("r", encoder, "right") // the same layer, so the weights are shared
+//	d.AddMerge("diff", diff, "l", "r")
+//	d.Output("diff")
+//	outs, err := d.Apply(NewEnv("left", x).Extend("right", y))
+//
+// A vertex may refer to vertices that are added after it. The references are checked by Validate.
+type DAG struct {
+	inputs   []string
+	vertices []*vertex
+	outputs  []string
+}
+
+// vertex is a named application of a term, or of a merge function, to the named inputs.
+type vertex struct {
+	name   string
+	term   Term // can be thunk or Layer
+	merge  func(G.Nodes) (*G.Node, error)
+	inputs []string
+
+	flops int // the FLOPs of the last application of the vertex
+}
+
+// NewDAG creates a DAG with the given named inputs.
+func NewDAG(inputs ...string) *DAG {
+	return &DAG{inputs: append([]string(nil), inputs...)}
+}
+
+// Add adds a vertex that applies the term to the named inputs. The names refer to the inputs of the DAG or to other vertices.
+//
+// When more than one name is given, the term is applied to a tuple of the nodes, as a gorgonia.Nodes, in the given order. This is
+// the convention of the layers that take several inputs, such as MultiHeadAttention. A term that is used by several vertices shares
+// its weights across them. A thunk created with L is constructed once for every vertex that uses it.
+func (d *DAG) Add(name string, t Term, inputs ...string) error {
+	if t == nil {
+		return errors.Errorf("Unable to add vertex %q to %v without a term", name, d.Name())
+	}
+	return d.add(&vertex{name: name, term: t, inputs: append([]string(nil), inputs...)})
+}
+
+// AddMerge adds a vertex that merges the nodes of the named inputs with the given function. The nodes are passed to the function
+// in the given order.
+func (d *DAG) AddMerge(name string, fn func(G.Nodes) (*G.Node, error), inputs ...string) error {
+	if fn == nil {
+		return errors.Errorf("Unable to add vertex %q to %v without a merge function", name, d.Name())
+	}
+	return d.add(&vertex{name: name, merge: fn, inputs: append([]string(nil), inputs...)})
+}
+
+func (d *DAG) add(v *vertex) error {
+	if v.name == "" {
+		return errors.Errorf("Unable to add an unnamed vertex to %v", d.Name())
+	}
+	if len(v.inputs) == 0 {
+		return errors.Errorf("Expected vertex %q of %v to have at least one input", v.name, d.Name())
+	}
+	if d.defines(v.name) {
+		return errors.Errorf("%v already defines %q", d.Name(), v.name)
+	}
+	d.vertices = append(d.vertices, v)
+	return nil
+}
+
+// Output marks the named vertices or inputs as the outputs of the DAG, in the given order.
+func (d *DAG) Output(names ...string) {
+	d.outputs = append(d.outputs, names...)
+}
+
+// Inputs returns the names of the inputs of the DAG.
+func (d *DAG) Inputs() []string { return d.inputs }
+
+// Outputs returns the names of the outputs of the DAG.
+func (d *DAG) Outputs() []string { return d.outputs }
+
+func (d *DAG) defines(name string) bool {
+	for _, in := range d.inputs {
+		if in == name {
+			return true
+		}
+	}
+	return d.vertex(name) != nil
+}
+
+func (d *DAG) vertex(name string) *vertex {
+	for _, v := range d.vertices {
+		if v.name == name {
+			return v
+		}
+	}
+	return nil
+}
+
+// Validate checks that the DAG is well formed: every name that is referred to is defined exactly once, there is at least one
+// output, and there are no cycles.
+func (d *DAG) Validate() error {
+	_, err := d.sort()
+	return err
+}
+
+// sort returns the vertices in topological order. Vertices that do not depend on each other are kept in the order they were added.
+func (d *DAG) sort() ([]*vertex, error) {
+	seen := make(map[string]struct{})
+	for _, in := range d.inputs {
+		if _, ok := seen[in]; ok {
+			return nil, errors.Errorf("%v has more than one input named %q", d.Name(), in)
+		}
+		seen[in] = struct{}{}
+	}
+	if len(d.outputs) == 0 {
+		return nil, errors.Errorf("%v does not have any outputs", d.Name())
+	}
+	for _, v := range d.vertices {
+		for _, in := range v.inputs {
+			if !d.defines(in) {
+				return nil, errors.Errorf("Vertex %q of %v refers to %q, which is not defined", v.name, d.Name(), in)
+			}
+		}
+	}
+	for _, out := range d.outputs {
+		if !d.defines(out) {
+			return nil, errors.Errorf("The output %q of %v is not defined", out, d.Name())
+		}
+	}
+
+	retVal := make([]*vertex, 0, len(d.vertices))
+	for len(retVal) < len(d.vertices) {
+		progress := false
+		for _, v := range d.vertices {
+			if _, ok := seen[v.name]; ok {
+				continue
+			}
+			ready := true
+			for _, in := range v.inputs {
+				if _, ok := seen[in]; !ok {
+					ready = false
+					break
+				}
+			}
+			if ready {
+				seen[v.name] = struct{}{}
+				retVal = append(retVal, v)
+				progress = true
+			}
+		}
+		if !progress {
+			var cycle []string
+			for _, v := range d.vertices {
+				if _, ok := seen[v.name]; !ok {
+					cycle = append(cycle, v.name)
+				}
+			}
+			return nil, errors.Errorf("%v has a cycle through the vertices %v", d.Name(), cycle)
+		}
+	}
+	return retVal, nil
+}
+
+// Apply applies the DAG to the inputs bound in the environment. It returns the node of every output, by name.
+func (d *DAG) Apply(env *Env) (map[string]*G.Node, error) {
+	order, err := d.sort()
+	if err != nil {
+		return nil, err
+	}
+	if env == nil {
+		return nil, errors.Errorf("Unable to apply %v without an environment", d.Name())
+	}
+	values := make(map[string]*G.Node, len(d.inputs)+len(d.vertices))
+	for _, in := range d.inputs {
+		n, _ := env.ByName(in)
+		if n == nil {
+			return nil, errors.Errorf("The input %q of %v is not bound in %v", in, d.Name(), env.Name())
+		}
+		values[in] = n
+	}
+	for _, v := range order {
+		xs := make(G.Nodes, len(v.inputs))
+		for i, in := range v.inputs {
+			xs[i] = values[in]
+		}
+		if values[v.name], err = v.apply(xs); err != nil {
+			return nil, errors.Wrapf(err, "Forward of %v", d.Name())
+		}
+	}
+	retVal := make(map[string]*G.Node, len(d.outputs))
+	for _, out := range d.outputs {
+		retVal[out] = values[out]
+	}
+	return retVal, nil
+}
+
+func (v *vertex) apply(xs G.Nodes) (*G.Node, error) {
+	if v.merge != nil {
+		retVal, err := v.merge(xs)
+		if err != nil {
+			return nil, errors.Wrapf(err, "Merging the inputs %v of vertex %q", v.inputs, v.name)
+		}
+		return retVal, nil
+	}
+	var in G.Input = xs
+	if len(xs) == 1 {
+		in = xs[0]
+	}
+	var l Layer
+	switch t := v.term.(type) {
+	case Layer:
+		l = t
+	case consThunk:
+		var err error
+		if l, err = t.LayerCons(in, t.Opts...); err != nil {
+			return nil, errors.Wrapf(err, "Unable to construct vertex %q", v.name)
+		}
+		v.term = l
+	default:
+		return nil, errors.Errorf("Unable to apply vertex %q with a term %v of %T", v.name, v.term, v.term)
+	}
+	res := l.Fwd(in)
+	if err := G.CheckOne(res); err != nil {
+		return nil, errors.Wrapf(err, "Applying vertex %q to %v", v.name, v.inputs)
+	}
+	if res.Node() == nil {
+		return nil, errors.Errorf("Expected the result of vertex %q to be a *Node. Got %v instead", v.name, res)
+	}
+	if f, ok := l.(flopser); ok {
+		v.flops = f.FLOPs()
+	}
+	return res.Node(), nil
+}
+
+// Fwd applies the DAG to the input, so that it can be composed with other layers. The input is either a single *Node, for a DAG
+// with a single input, or a tuple of nodes, which are bound to the inputs in order. The result is the output node of a DAG with a
+// single output, or a tuple of the output nodes in order.
+func (d *DAG) Fwd(a G.Input) G.Result {
+	if err := G.CheckOne(a); err != nil {
+		return G.Err(errors.Wrapf(err, "Forward of %v", d.Name()))
+	}
+	ns := a.Nodes()
+	if len(ns) != len(d.inputs) {
+		return G.Err(errors.Errorf("Expected %d inputs for %v. Got %d instead", len(d.inputs), d.Name(), len(ns)))
+	}
+	var env *Env
+	for i, in := range d.inputs {
+		if env == nil {
+			env = NewEnv(in, ns[i])
+			continue
+		}
+		env = env.Extend(in, ns[i])
+	}
+	outs, err := d.Apply(env)
+	if err != nil {
+		return G.Err(err)
+	}
+	if len(d.outputs) == 1 {
+		return outs[d.outputs[0]]
+	}
+	retVal := make(G.Nodes, len(d.outputs))
+	for i, out := range d.outputs {
+		retVal[i] = outs[out]
+	}
+	return retVal
+}
+
+// Model will return the gorgonia.Nodes associated with every vertex. The weights of a layer that is shared by several vertices
+// are only returned once.
+func (d *DAG) Model() (retVal G.Nodes) {
+	seen := make(map[*G.Node]struct{})
+	for _, v := range d.vertices {
+		l, ok := v.term.(Layer)
+		if !ok {
+			continue
+		}
+		for _, n := range l.Model() {
+			if _, ok := seen[n]; ok {
+				continue
+			}
+			seen[n] = struct{}{}
+			retVal = append(retVal, n)
+		}
+	}
+	return retVal
+}
+
+// Name will return the name of the DAG.
+func (d *DAG) Name() string {
+	return fmt.Sprintf("DAG(%v ↦ %v)", strings.Join(d.inputs, ", "), strings.Join(d.outputs, ", "))
+}
+
+// Describe returns an error, as an ONNX fragment has exactly one input and one output.
+func (d *DAG) Describe() (*onnx.GraphProto, error) {
+	return nil, errors.Errorf("Unable to describe %v", d.Name())
+}
+
+// ByName returns the term of the named vertex. If there is no such vertex, the terms of the vertices are searched in order.
+func (d *DAG) ByName(name string) Term {
+	if v := d.vertex(name); v != nil && v.term != nil {
+		return v.term
+	}
+	for _, v := range d.vertices {
+		if bn, ok := v.term.(ByNamer); ok {
+			if t := bn.ByName(name); t != nil {
+				return t
+			}
+		}
+	}
+	return nil
+}
+
+func (d *DAG) Graph() *G.ExprGraph {
+	for _, v := range d.vertices {
+		if gp, ok := v.term.(Grapher); ok {
+			return gp.Graph()
+		}
+	}
+	return nil
+}
+
+// Runners returns the runners of every layer. The runners of a layer that is shared by several vertices are only returned once.
+func (d *DAG) Runners() []Runner {
+	var retVal []Runner
+	seen := make(map[Runner]struct{})
+	for _, v := range d.vertices {
+		f, ok := v.term.(Runnerser)
+		if !ok {
+			continue
+		}
+		for _, r := range f.Runners() {
+			if _, ok := seen[r]; ok {
+				continue
+			}
+			seen[r] = struct{}{}
+			retVal = append(retVal, r)
+		}
+	}
+	return retVal
+}
+
+// FLOPs returns the number of floating point operations of the last application of the DAG. A layer that is shared by several
+// vertices is counted once for every vertex, with the FLOPs of the application of that vertex.
+func (d *DAG) FLOPs() (retVal int) {
+	for _, v := range d.vertices {
+		retVal += v.flops
+	}
+	return
+}
+
+// Reinit re-initializes the weights of every vertex in place.
+func (d *DAG) Reinit() error {
+	for _, v := range d.vertices {
+		if err := reinitTerm(v.term); err != nil {
+			return err
+		}
+	}
+	return nil
+}
@@ -0,0 +1,149 @@
+package golgi
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/require"
+	"gorgonia.org/gorgonia"
+	"gorgonia.org/tensor"
+)
+
+func TestDAG(t *testing.T) {
+	c := require.New(t)
+	g := gorgonia.NewGraph()
+	newInput := func(name string, backing []float64) *gorgonia.Node {
+		return gorgonia.NewMatrix(g, tensor.Float64, gorgonia.WithName(name), gorgonia.WithShape(2, 3), gorgonia.WithValue(tensor.New(tensor.WithShape(2, 3), tensor.WithBacking(backing))))
+	}
+	left := newInput("left", []float64{0.1, -0.2, 0.3, -0.4, 0.5, -0.6})
+	right := newInput("right", []float64{0.6, 0.5, -0.4, 0.3, -0.2, 0.1})
+	encoder, err := ConsFC(left, WithName("enc"), WithSize(4), WithActivation(gorgonia.Tanh), ComputeFLOPs(true))
+	c.NoError(err)
+	diff := func(xs gorgonia.Nodes) (*gorgonia.Node, error) { return gorgonia.Sub(xs[0], xs[1]) }
+
+	// a siamese network with two heads. The vertices are added out of order.
+	d := NewDAG("left", "right")
+	c.NoError(d.AddMerge("diff", diff, "l", "r"))
+	c.NoError(d.Add("l", encoder, "left"))
+	c.NoError(d.Add("r", encoder, "right"))
+	c.NoError(d.Add("score", L(ConsFC, WithName("head"), WithSize(1), WithBias(false), ComputeFLOPs(true)), "diff"))
+	d.Output("score", "l")
+	c.NoError(d.Validate())
+
+	outs, err := d.Apply(NewEnv("left", left).Extend("right", right))
+	c.NoError(err)
+	c.Len(outs, 2)
+	c.Equal(tensor.Shape{2, 1}, outs["score"].Shape())
+	c.Equal(tensor.Shape{2, 4}, outs["l"].Shape())
+
+	// the encoder is shared, so its weights are only returned once
+	head, ok := d.ByName("score").(*FC)
+	c.True(ok, "the thunk is replaced by the constructed layer")
+	c.Equal(head, d.ByName("head"))
+	c.Len(d.Model(), 3)
+	c.Equal(2*encoder.(*FC).FLOPs()+head.FLOPs(), d.FLOPs())
+
+	cost, err := gorgonia.Sum(outs["score"])
+	c.NoError(err)
+	_, err = gorgonia.Grad(cost, d.Model()...)
+	c.NoError(err)
+
+	l2 := encoder.Fwd(left)
+	r2 := encoder.Fwd(right)
+	var lv, rv, hw gorgonia.Value
+	gorgonia.Read(l2.Node(), &lv)
+	gorgonia.Read(r2.Node(), &rv)
+	gorgonia.Read(head.w, &hw)
+	got := runValue(t, g, outs["score"])
+	ls, rs, ws := lv.Data().([]float64), rv.Data().([]float64), hw.Data().([]float64)
+	for i := 0; i < 2; i++ {
+		var expected float64
+		for j := 0; j < 4; j++ {
+			expected += (ls[i*4+j] - rs[i*4+j]) * ws[j]
+		}
+		c.InDelta(expected, got[i], 1e-10)
+	}
+
+	// as a layer, the tuple of inputs is bound in order
+	out := d.Fwd(gorgonia.Nodes{left, right})
+	c.NoError(gorgonia.CheckOne(out))
+	c.Len(out.Nodes(), 2)
+	c.Equal(tensor.Shape{2, 1}, out.Nodes()[0].Shape())
+	c.Error(gorgonia.CheckOne(d.Fwd(left)))
+	_, err = d.Apply(NewEnv("left", left))
+	c.Error(err)
+}
+
+func TestDAG_Validate(t *testing.T) {
+	c := require.New(t)
+	fc := NewFC(WithName("fc"), WithSize(2))
+
+	d := NewDAG("x")
+	c.NoError(d.Add("a", fc, "x"))
+	c.Error(d.Add("a", fc, "x"), "duplicate vertex")
+	c.Error(d.Add("x", fc, "a"), "a vertex cannot shadow an input")
+	c.Error(d.Add("b", fc), "a vertex needs an input")
+	c.Error(d.Add("", fc, "x"))
+	c.Error(d.Validate(), "no outputs")
+	d.Output("a")
+	c.NoError(d.Validate())
+
+	dangling := NewDAG("x")
+	c.NoError(dangling.Add("a", fc, "y"))
+	dangling.Output("a")
+	c.Error(dangling.Validate())
+
+	undefined := NewDAG("x")
+	c.NoError(undefined.Add("a", fc, "x"))
+	undefined.Output("b")
+	c.Error(undefined.Validate())
+
+	cycle := NewDAG("x")
+	c.NoError(cycle.Add("a", fc, "x", "c"))
+	c.NoError(cycle.Add("b", fc, "a"))
+	c.NoError(cycle.Add("c", fc, "b"))
+	c.NoError(cycle.Add("d", fc, "x"))
+	cycle.Output("d")
+	err := cycle.Validate()
+	c.Error(err)
+	c.Contains(err.Error(), "[a b c]")
+
+	c.Error(NewDAG("x", "x").Validate())
+}
+
+func TestDAG_Shared(t *testing.T) {
+	c := require.New(t)
+	g := gorgonia.NewGraph()
+	short := gorgonia.NewMatrix(g, tensor.Float64, gorgonia.WithName("short"), gorgonia.WithShape(2, 3), gorgonia.WithInit(gorgonia.GlorotU(1)))
+	long := gorgonia.NewMatrix(g, tensor.Float64, gorgonia.WithName("long"), gorgonia.WithShape(5, 3), gorgonia.WithInit(gorgonia.GlorotU(1)))
+	fc, err := ConsFC(short, WithName("fc"), WithSize(4), AsBatched(true))
+	c.NoError(err)
+	bn, err := NewBatchNorm(WithName("bn"), ComputeFLOPs(true))
+	c.NoError(err)
+	emb := NewEmbedding(Of(tensor.Float64), WithName("emb"), WithSize(4), WithClasses(3), WithBatchSize(2), AsRunner())
+
+	d := NewDAG("short", "long")
+	c.NoError(d.Add("s", fc, "short"))
+	c.NoError(d.Add("l", fc, "long"))
+	c.NoError(d.Add("sn", bn, "s"))
+	c.NoError(d.Add("ln", bn, "l"))
+	c.NoError(d.Add("e0", emb, "short"))
+	c.NoError(d.Add("e1", emb, "long"))
+	d.Output("sn", "ln")
+
+	// the runners of a shared layer are only returned once
+	c.Len(d.Runners(), 2)
+
+	// each vertex counts the FLOPs of its own application of the shared layer
+	sub := NewDAG("short", "long")
+	c.NoError(sub.Add("s", bn, "short"))
+	c.NoError(sub.Add("l", bn, "long"))
+	sub.Output("s", "l")
+	_, err = sub.Apply(NewEnv("short", short).Extend("long", long))
+	c.NoError(err)
+	c.NoError(gorgonia.CheckOne(bn.Fwd(short)))
+	flopsShort := bn.FLOPs()
+	c.NoError(gorgonia.CheckOne(bn.Fwd(long)))
+	flopsLong := bn.FLOPs()
+	c.NotEqual(flopsShort, flopsLong)
+	c.Equal(flopsShort+flopsLong, sub.FLOPs())
+}
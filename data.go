@@ -0,0 +1,486 @@
+package golgi
+
+import (
+	"github.com/pkg/errors"
+	G "gorgonia.org/gorgonia"
+	"gorgonia.org/tensor"
+)
+
+var (
+	_ Data = &FCData{}
+	_ Data = &ConvData{}
+	_ Data = &MaxPoolData{}
+	_ Data = &EmbeddingData{}
+	_ Data = &LayerNormData{}
+	_ Data = &CompositionData{}
+	_ Data = &JoinData{}
+	_ Data = ReshapeData{}
+	_ Data = DropoutData{}
+	_ Data = &SkipData{}
+
+	_ Dataer = &FC{}
+	_ Dataer = &Conv{}
+	_ Dataer = &MaxPool{}
+	_ Dataer = &Embedding{}
+	_ Dataer = &layerNorm{}
+	_ Dataer = &Composition{}
+	_ Dataer = &Join{}
+	_ Dataer = reshape(nil)
+	_ Dataer = dropout(0)
+	_ Dataer = &skip{}
+)
+
+// FCData represents the data of a fully connected layer. B is nil if the layer has no bias.
+type FCData struct {
+	Name    string
+	W, B    *tensor.Dense
+	Size    int
+	Act     Activation
+	Batched bool
+	NoBias  bool
+}
+
+// Make creates a *FC in the given graph. If name is empty, the name of the snapshotted layer is used.
+func (d *FCData) Make(g *G.ExprGraph, name string) (Layer, error) {
+	if name == "" {
+		name = d.Name
+	}
+	w := weightFromData(g, d.W, name+"_W")
+	b := weightFromData(g, d.B, name+"_B")
+	return NewFC(WithName(name), WithSize(d.Size), WithActivation(ActivationMap(d.Act)), AsBatched(d.Batched), WithBias(!d.NoBias), WithWB(w, b)), nil
+}
+
+// ToData snapshots the weights and configuration of the fully connected layer.
+func (l *FC) ToData() (Data, error) {
+	if l.w == nil {
+		return nil, errors.Errorf("Unable to take a snapshot of FC %v. It has not been initialized", l.name)
+	}
+	act, err := activationOf(l.act)
+	if err != nil {
+		return nil, errors.Wrapf(err, "ToData of FC %v", l.name)
+	}
+	w, err := snapshot(l.w)
+	if err != nil {
+		return nil, err
+	}
+	b, err := snapshot(l.b)
+	if err != nil {
+		return nil, err
+	}
+	return &FCData{
+		Name:    l.name,
+		W:       w,
+		B:       b,
+		Size:    l.size,
+		Act:     act,
+		Batched: l.batched,
+		NoBias:  l.nobias,
+	}, nil
+}
+
+// ConvData represents the data of a convolution layer. B is nil if the layer has no bias. Dropout is nil if the layer has no dropout.
+type ConvData struct {
+	Name                  string
+	W, B                  *tensor.Dense
+	Size                  []int
+	KernelShape           []int
+	Pad, Stride, Dilation []int
+	Padding               Padding
+	Groups                int
+	Act                   Activation
+	Dropout               *float64
+}
+
+// Make creates a *Conv in the given graph. If name is empty, the name of the snapshotted layer is used.
+func (d *ConvData) Make(g *G.ExprGraph, name string) (Layer, error) {
+	if name == "" {
+		name = d.Name
+	}
+	opts := []ConsOpt{
+		WithName(name),
+		WithSize(d.Size...),
+		WithKernelShape(tensor.Shape(d.KernelShape)),
+		WithPad(d.Pad),
+		WithStride(d.Stride),
+		WithDilation(d.Dilation),
+		WithPadding(d.Padding),
+		WithActivation(ActivationMap(d.Act)),
+	}
+	if d.Groups > 1 {
+		opts = append(opts, WithGroups(d.Groups))
+	}
+	if d.Dropout != nil {
+		opts = append(opts, WithProbability(*d.Dropout))
+	}
+	l, err := NewConv(opts...)
+	if err != nil {
+		return nil, errors.Wrapf(err, "Unable to make Conv %v", name)
+	}
+	l.w = weightFromData(g, d.W, name+"_w")
+	l.b = weightFromData(g, d.B, name+"_b")
+	l.nobias = l.b == nil
+	l.initialized = true
+	return l, nil
+}
+
+// ToData snapshots the weights and configuration of the convolution layer.
+func (l *Conv) ToData() (Data, error) {
+	if l.w == nil {
+		return nil, errors.Errorf("Unable to take a snapshot of Conv %v. It has not been initialized", l.name)
+	}
+	act, err := activationOf(l.act)
+	if err != nil {
+		return nil, errors.Wrapf(err, "ToData of Conv %v", l.name)
+	}
+	w, err := snapshot(l.w)
+	if err != nil {
+		return nil, err
+	}
+	b, err := snapshot(l.b)
+	if err != nil {
+		return nil, err
+	}
+	return &ConvData{
+		Name:        l.name,
+		W:           w,
+		B:           b,
+		Size:        cloneInts(l.size),
+		KernelShape: cloneInts(l.kernelShape),
+		Pad:         cloneInts(l.pad),
+		Stride:      cloneInts(l.stride),
+		Dilation:    cloneInts(l.dilation),
+		Padding:     l.padding,
+		Groups:      l.groups,
+		Act:         act,
+		Dropout:     cloneProb(l.dropout),
+	}, nil
+}
+
+// MaxPoolData represents the configuration of a max pooling layer. A max pooling layer has no weights.
+type MaxPoolData struct {
+	Name        string
+	Size        int
+	KernelShape []int
+	Pad, Stride []int
+	Padding     Padding
+	Dropout     *float64
+}
+
+// Make creates a *MaxPool. The graph is unused. If name is empty, the name of the snapshotted layer is used.
+func (d *MaxPoolData) Make(_ *G.ExprGraph, name string) (Layer, error) {
+	if name == "" {
+		name = d.Name
+	}
+	opts := []ConsOpt{
+		WithName(name),
+		WithSize(d.Size),
+		WithKernelShape(tensor.Shape(d.KernelShape)),
+		WithPad(d.Pad),
+		WithStride(d.Stride),
+		WithPadding(d.Padding),
+	}
+	if d.Dropout != nil {
+		opts = append(opts, WithProbability(*d.Dropout))
+	}
+	l, err := NewMaxPool(opts...)
+	if err != nil {
+		return nil, errors.Wrapf(err, "Unable to make MaxPool %v", name)
+	}
+	l.initialized = true
+	return l, nil
+}
+
+// ToData snapshots the configuration of the max pooling layer.
+func (l *MaxPool) ToData() (Data, error) {
+	return &MaxPoolData{
+		Name:        l.name,
+		Size:        l.size,
+		KernelShape: cloneInts(l.kernelShape),
+		Pad:         cloneInts(l.pad),
+		Stride:      cloneInts(l.stride),
+		Padding:     l.padding,
+		Dropout:     cloneProb(l.dropout),
+	}, nil
+}
+
+// EmbeddingData represents the data of an embedding layer.
+type EmbeddingData struct {
+	Name        string
+	W           *tensor.Dense
+	Classes     int
+	Dims        int
+	BatchSize   int
+	OneHotInput bool // the layer was constructed WithOneHotInput()
+	Runner      bool // the layer was constructed AsRunner()
+}
+
+// Make creates a *Embedding in the given graph. If name is empty, the name of the snapshotted layer is used.
+func (d *EmbeddingData) Make(g *G.ExprGraph, name string) (Layer, error) {
+	if name == "" {
+		name = d.Name
+	}
+	w := weightFromData(g, d.W, name)
+	opts := []ConsOpt{
+		WithName(name),
+		WithWeights(w),
+		Of(w.Dtype()),
+		WithClasses(d.Classes),
+		WithSize(d.Dims),
+		WithBatchSize(d.BatchSize),
+	}
+	switch {
+	case d.OneHotInput:
+		opts = append(opts, WithOneHotInput())
+	case d.Runner:
+		opts = append(opts, AsRunner())
+	}
+	return NewEmbedding(opts...), nil
+}
+
+// ToData snapshots the weights and configuration of the embedding layer.
+func (l *Embedding) ToData() (Data, error) {
+	if l.w == nil {
+		return nil, errors.Errorf("Unable to take a snapshot of Embedding %v. It has not been initialized", l.name)
+	}
+	w, err := snapshot(l.w)
+	if err != nil {
+		return nil, err
+	}
+	shp := w.Shape()
+	return &EmbeddingData{
+		Name:        l.name,
+		W:           w,
+		Classes:     shp[0],
+		Dims:        shp[1],
+		BatchSize:   l.bs,
+		OneHotInput: l.selectFn == onehotindices,
+		Runner:      l.selectFn == runnerindices,
+	}, nil
+}
+
+// LayerNormData represents the data of a layer normalization layer.
+type LayerNormData struct {
+	Name string
+	W, B *tensor.Dense
+	Size int
+	Eps  float64
+}
+
+// Make creates a layer normalization layer in the given graph. If name is empty, the name of the snapshotted layer is used.
+func (d *LayerNormData) Make(g *G.ExprGraph, name string) (Layer, error) {
+	if name == "" {
+		name = d.Name
+	}
+	w := weightFromData(g, d.W, name+"_W")
+	b := weightFromData(g, d.B, name+"_B")
+	return MakeLayerNorm(WithName(name), WithSize(d.Size), WithEps(d.Eps), WithWB(w, b)), nil
+}
+
+// ToData snapshots the weights and configuration of the layer normalization layer.
+func (l *layerNorm) ToData() (Data, error) {
+	if l.w == nil {
+		return nil, errors.Errorf("Unable to take a snapshot of layer norm %v. It has not been initialized", l.name)
+	}
+	w, err := snapshot(l.w)
+	if err != nil {
+		return nil, err
+	}
+	b, err := snapshot(l.b)
+	if err != nil {
+		return nil, err
+	}
+	return &LayerNormData{
+		Name: l.name,
+		W:    w,
+		B:    b,
+		Size: l.size,
+		Eps:  l.eps,
+	}, nil
+}
+
+// CompositionData represents the data of a composition. A nil A is the identity.
+type CompositionData struct {
+	A, B Data
+}
+
+// Make creates a *Composition in the given graph. The name is unused; each of the composed layers keep their own names.
+func (d *CompositionData) Make(g *G.ExprGraph, _ string) (Layer, error) {
+	if d.B == nil {
+		return nil, errors.New("Unable to make a Composition without a second term")
+	}
+	var a Term = I{}
+	if d.A != nil {
+		l, err := d.A.Make(g, "")
+		if err != nil {
+			return nil, err
+		}
+		a = l
+	}
+	b, err := d.B.Make(g, "")
+	if err != nil {
+		return nil, err
+	}
+	return Compose(a, b), nil
+}
+
+// ToData snapshots each of the composed layers. All the layers must have been constructed.
+func (l *Composition) ToData() (Data, error) {
+	a, err := termToData(l.a)
+	if err != nil {
+		return nil, errors.Wrapf(err, "ToData of Composition %v (a)", l.Name())
+	}
+	b, err := termToData(l.b)
+	if err != nil {
+		return nil, errors.Wrapf(err, "ToData of Composition %v (b)", l.Name())
+	}
+	return &CompositionData{A: a, B: b}, nil
+}
+
+// JoinData represents the data of a join. A nil term is the identity.
+type JoinData struct {
+	Op    string
+	Axis  int
+	Terms []Data
+}
+
+// Make creates a *Join in the given graph. The name is unused; each of the joined layers keep their own names.
+func (d *JoinData) Make(g *G.ExprGraph, _ string) (Layer, error) {
+	terms := make([]Term, len(d.Terms))
+	for i, td := range d.Terms {
+		terms[i] = I{}
+		if td == nil {
+			continue
+		}
+		l, err := td.Make(g, "")
+		if err != nil {
+			return nil, err
+		}
+		terms[i] = l
+	}
+	for op := addOp; op < mergeOp; op++ {
+		if op.String() == d.Op {
+			retVal := newJoin(op, terms...)
+			retVal.axis = d.Axis
+			return retVal, nil
+		}
+	}
+	return nil, errors.Errorf("Unable to make a Join with op %q", d.Op)
+}
+
+// ToData snapshots each of the joined layers. All the layers must have been constructed. A Join created by Merge cannot be snapshotted.
+func (l *Join) ToData() (Data, error) {
+	if l.op == mergeOp {
+		return nil, errors.Errorf("Unable to take a snapshot of %v. The merge function cannot be snapshotted", l.Name())
+	}
+	retVal := &JoinData{Op: l.op.String(), Axis: l.axis, Terms: make([]Data, len(l.terms))}
+	for i, t := range l.terms {
+		var err error
+		if retVal.Terms[i], err = termToData(t); err != nil {
+			return nil, errors.Wrapf(err, "ToData of Join %v (term %d)", l.Name(), i)
+		}
+	}
+	return retVal, nil
+}
+
+// ReshapeData represents a reshaping layer.
+type ReshapeData struct {
+	To []int
+}
+
+// Make creates a reshaping layer. The graph and name are unused.
+func (d ReshapeData) Make(_ *G.ExprGraph, _ string) (Layer, error) {
+	return reshape(cloneInts(d.To)), nil
+}
+
+// ToData snapshots the shape of the reshaping layer.
+func (l reshape) ToData() (Data, error) { return ReshapeData{To: cloneInts(l)}, nil }
+
+// DropoutData represents a dropout layer.
+type DropoutData struct {
+	Probability float64
+}
+
+// Make creates a dropout layer. The graph and name are unused.
+func (d DropoutData) Make(_ *G.ExprGraph, _ string) (Layer, error) {
+	return dropout(d.Probability), nil
+}
+
+// ToData snapshots the probability of the dropout layer.
+func (l dropout) ToData() (Data, error) { return DropoutData{Probability: float64(l)}, nil }
+
+// SkipData represents the data of a skip layer, which adds a constant to its input.
+type SkipData struct {
+	Name string
+	B    *tensor.Dense
+}
+
+// Make creates a skip layer in the given graph. If name is empty, the name of the snapshotted constant is used.
+func (d *SkipData) Make(g *G.ExprGraph, name string) (Layer, error) {
+	if name == "" {
+		name = d.Name
+	}
+	if d.B == nil {
+		return nil, errors.Errorf("Unable to make skip layer %v without a constant", name)
+	}
+	return &skip{b: weightFromData(g, d.B, name)}, nil
+}
+
+// ToData snapshots the constant of the skip layer.
+func (l *skip) ToData() (Data, error) {
+	if l.b == nil {
+		return nil, errors.New("Unable to take a snapshot of a skip layer without a constant")
+	}
+	b, err := snapshot(l.b)
+	if err != nil {
+		return nil, err
+	}
+	return &SkipData{Name: l.b.Name(), B: b}, nil
+}
+
+// termToData snapshots a term. The identity is represented by a nil Data.
+func termToData(t Term) (Data, error) {
+	switch tt := t.(type) {
+	case nil, I:
+		return nil, nil
+	case Dataer:
+		return tt.ToData()
+	case consThunk:
+		return nil, errors.Errorf("%v has not been constructed. Call Fwd before taking a snapshot", tt.Name())
+	}
+	return nil, errors.Errorf("Unable to take a snapshot of %v of %T", t.Name(), t)
+}
+
+// snapshot clones the value of a weight so that the snapshot does not share memory with the graph.
+func snapshot(n *G.Node) (*tensor.Dense, error) {
+	if n == nil {
+		return nil, nil
+	}
+	v, ok := n.Value().(*tensor.Dense)
+	if !ok {
+		return nil, errors.Errorf("Expected the value of %v to be a *tensor.Dense. Got %v of %T instead", n.Name(), n.Value(), n.Value())
+	}
+	return v.Clone().(*tensor.Dense), nil
+}
+
+// weightFromData creates a weight in the graph that holds a copy of the snapshotted value.
+func weightFromData(g *G.ExprGraph, v *tensor.Dense, name string) *G.Node {
+	if v == nil {
+		return nil
+	}
+	return G.NodeFromAny(g, v.Clone(), G.WithName(name))
+}
+
+func cloneInts(a []int) []int {
+	if a == nil {
+		return nil
+	}
+	return append([]int(nil), a...)
+}
+
+func cloneProb(p *float64) *float64 {
+	if p == nil {
+		return nil
+	}
+	v := *p
+	return &v
+}
@@ -5,6 +5,7 @@ import (
 
 	"github.com/chewxy/hm"
 	"github.com/pkg/errors"
+	"gorgonia.org/golgi/onnx"
 	G "gorgonia.org/gorgonia"
 	"gorgonia.org/tensor"
 )
@@ -34,7 +35,9 @@ func (m *Metadata) Name() string { return m.name }
 func (m *Metadata) Shape() tensor.Shape { return m.shape }
 
 // Describe will describe a metadata
-func (m *Metadata) Describe() {}
+func (m *Metadata) Describe() (*onnx.GraphProto, error) {
+	return nil, errors.New("Metadata is a dummy Layer")
+}
 
 // Model will return the gorgonia.Nodes associated with this metadata
 func (m *Metadata) Model() G.Nodes { return nil }
@@ -154,5 +157,8 @@ func (t *trace) Fwd(x G.Input) G.Result {
 func (t *trace) Name() string        { return t.name }
 func (t *trace) Type() hm.Type       { return nil }
 func (t *trace) Shape() tensor.Shape { return nil }
-func (t *trace) Describe()           {}
 func (t *trace) PassThru()           {}
+
+func (t *trace) Describe() (*onnx.GraphProto, error) {
+	return identityFragment(t.name), nil
+}
@@ -2,6 +2,7 @@ package golgi
 
 import (
 	"github.com/pkg/errors"
+	"gorgonia.org/golgi/onnx"
 	G "gorgonia.org/gorgonia"
 	"gorgonia.org/qol"
 	"gorgonia.org/tensor"
@@ -117,6 +118,9 @@ type Embedding struct {
 	// name
 	name string
 
+	// initW initializes w. nil means the default initialization
+	initW G.InitWFn
+
 	// initialized
 	initialized bool
 
@@ -193,6 +197,15 @@ func (l *Embedding) Fwd(a G.Input) G.Result {
 	default:
 	}
 
+	if l.computeFLOPs {
+		l.flops = 0
+		if useOneHot {
+			// a (rows, classes) × (classes, dims) matmul
+			rows := oh.Shape().TotalSize() / l.classes
+			l.flops = 2 * rows * l.classes * l.dims
+		}
+	}
+
 	if useOneHot {
 		retVal, err := G.Mul(oh, l.w)
 		if err != nil {
@@ -220,10 +233,44 @@ func (l *Embedding) Fwd(a G.Input) G.Result {
 
 func (l *Embedding) Name() string { return l.name }
 
-func (l *Embedding) Describe() {}
+// FLOPs returns the FLOPs computed by the last Fwd. Selecting with a one-hot matrix is a matrix multiplication. Selecting by indices
+// only gathers the rows of the embedding matrix, and is counted as zero FLOPs.
+func (l *Embedding) FLOPs() int { return l.flops }
+
+// Describe will describe an embedding layer.
+//
+// An embedding layer that accepts one-hot inputs is described as a MatMul. Otherwise it is described as a Gather of the classes.
+func (l *Embedding) Describe() (*onnx.GraphProto, error) {
+	if !l.initialized {
+		return nil, errors.Errorf("Unable to describe Embedding %v. It has not been initialized", l.name)
+	}
+	f := newFragment(l.name, "Embedding", l.w.Dtype())
+	w, err := f.weight(l.w)
+	if err != nil {
+		return nil, err
+	}
+	if l.selectFn == onehotindices {
+		f.apply("MatMul", []string{w})
+		return f.graph(), nil
+	}
+	f.g.Input[0].Type = &onnx.TypeProto{TensorType: &onnx.TensorTypeProto{ElemType: onnx.Int64}}
+	f.node("Gather", []string{w, f.input()}, attrInt("axis", 0))
+	return f.graph(), nil
+}
 
 func (l *Embedding) IsInitialized() bool { return l.initialized }
 
+// weightInit returns the initialization function of the embedding matrix.
+func (l *Embedding) weightInit() G.InitWFn {
+	if l.initW == nil {
+		return G.GlorotN(1)
+	}
+	return l.initW
+}
+
+// Reinit re-initializes the embedding matrix in place.
+func (l *Embedding) Reinit() error { return reinitNode(l.w, l.weightInit()) }
+
 // Init initializes the embedding layer.
 func (l *Embedding) Init(xs ...*G.Node) (err error) {
 	x := xs[0]
@@ -231,7 +278,7 @@ func (l *Embedding) Init(xs ...*G.Node) (err error) {
 	of := l.of
 
 	if l.w == nil {
-		l.w = G.NewMatrix(g, of, G.WithShape(l.classes, l.dims), G.WithInit(G.GlorotN(1)), G.WithName(l.name))
+		l.w = G.NewMatrix(g, of, G.WithShape(l.classes, l.dims), G.WithInit(l.weightInit()), G.WithName(l.name))
 	}
 
 	if l.selectFn == runnerindices {
@@ -283,8 +330,21 @@ func (l *Embedding) Run(input G.Input) (err error) {
 
 	oh, _ := l.oh.Value().(*tensor.Dense)
 
+	classes, err := toClasses(vec)
+	if err != nil {
+		return errors.Wrapf(err, "Failed to run Embedding %v", l.name)
+	}
+
+	return G.Let(l.oh, qol.UnsafeToOneHotMatrix(classes, uint(l.classes), oh))
+}
+
+// Runners returns the embedding itself
+func (l *Embedding) Runners() []Runner { return []Runner{l} }
+
+// toClasses converts the data of a value into a slice of qol.Class.
+func toClasses(data interface{}) ([]qol.Class, error) {
 	var classes []qol.Class
-	switch v := vec.(type) {
+	switch v := data.(type) {
 	case []qol.Class:
 		classes = v
 	case []uint:
@@ -307,10 +367,8 @@ func (l *Embedding) Run(input G.Input) (err error) {
 		for i := range classes {
 			classes[i] = qol.Class(v[i])
 		}
+	default:
+		return nil, errors.Errorf("Unable to convert %v of %T into classes", data, data)
 	}
-
-	return G.Let(l.oh, qol.UnsafeToOneHotMatrix(classes, uint(l.classes), oh))
+	return classes, nil
 }
-
-// Runners returns the embedding itself
-func (l *Embedding) Runners() []Runner { return []Runner{l} }
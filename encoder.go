@@ -0,0 +1,313 @@
+package golgi
+
+import (
+	"fmt"
+
+	"github.com/pkg/errors"
+	"gorgonia.org/golgi/onnx"
+	G "gorgonia.org/gorgonia"
+	"gorgonia.org/tensor"
+)
+
+var (
+	_ Layer              = &EncoderBlock{}
+	_ namesetter         = &EncoderBlock{}
+	_ sizeSetter         = &EncoderBlock{}
+	_ dropoutConfiger    = &EncoderBlock{}
+	_ computeFLOPsSetter = &EncoderBlock{}
+
+	_ Layer     = &perPosition{}
+	_ ByNamer   = &perPosition{}
+	_ Runnerser = &perPosition{}
+	_ flopser   = &perPosition{}
+)
+
+// WithFeedForward is a ConsOpt for constructing transformer encoder blocks only. It sets the size of the hidden layer of the feed-forward network.
+func WithFeedForward(size int) ConsOpt {
+	return func(layer Layer) (Layer, error) {
+		switch l := layer.(type) {
+		case *EncoderBlock:
+			if size <= 0 {
+				return nil, errors.Errorf("Expected a positive feed-forward size. Got %d instead", size)
+			}
+			l.ff = size
+			return l, nil
+		case Pass:
+			return layer, nil
+		}
+		return nil, errors.Errorf("WithFeedForward Unhandled Layer type: %T", layer)
+	}
+}
+
+// WithPreNorm is a ConsOpt for constructing transformer encoder blocks only. If set to true, the inputs of the sublayers are normalized
+// instead of the outputs of the residual connections.
+func WithPreNorm(pre bool) ConsOpt {
+	return func(layer Layer) (Layer, error) {
+		switch l := layer.(type) {
+		case *EncoderBlock:
+			l.preNorm = pre
+			return l, nil
+		case Pass:
+			return layer, nil
+		}
+		return nil, errors.Errorf("WithPreNorm Unhandled Layer type: %T", layer)
+	}
+}
+
+// EncoderBlock is a transformer encoder block as per https://arxiv.org/abs/1706.03762. It is a composition of existing layers:
+//
+//	post-norm: x → norm(x + attention(x)) → norm(x + ff(x))
+//	pre-norm:  x → x + attention(norm(x)) → x + ff(norm(x))
+//
+// where ff is a two-layer feed-forward network with a GeLU activation. The outputs of the attention and of the feed-forward network
+// go through dropout before the residual connections.
+//
+// The input is either of shape (N, T, D) or (T, D). The layer normalizations and the feed-forward network are applied to every position.
+//
+// The sublayers are named with the suffixes "_attn", "_norm1", "_ff1", "_ff2" and "_norm2".
+type EncoderBlock struct {
+	*Composition
+
+	name      string
+	size      int // D
+	ff        int
+	heads     int
+	keyDims   int
+	valueDims int
+	mask      *G.Node
+	dropout   *float64
+	preNorm   bool
+
+	computeFLOPs bool
+}
+
+// TransformerEncoderBlock creates a transformer encoder block. The size of the block (the D of the inputs) has to be set with WithSize.
+// The layers of the block are initialized when it is first forwarded.
+// Defaults:
+//
+//	heads: 1
+//	feed-forward size: 4 * D
+//	post-norm, no dropout
+//
+// e.g.
+//
+//	enc, err := TransformerEncoderBlock(WithName("enc"), WithSize(512), WithHeads(8), WithProbability(0.1))
+func TransformerEncoderBlock(opts ...ConsOpt) (*EncoderBlock, error) {
+	l := &EncoderBlock{heads: 1}
+	for _, opt := range opts {
+		var (
+			o   Layer
+			ok  bool
+			err error
+		)
+		if o, err = opt(l); err != nil {
+			return nil, err
+		}
+		if l, ok = o.(*EncoderBlock); !ok {
+			return nil, errors.Errorf("Construction Option returned a non EncoderBlock. Got %T instead", o)
+		}
+	}
+	if l.size <= 0 {
+		return nil, errors.Errorf("Unable to create transformer encoder block %v with size %d", l.name, l.size)
+	}
+	if l.ff == 0 {
+		l.ff = 4 * l.size
+	}
+	if err := l.build(); err != nil {
+		return nil, errors.Wrapf(err, "Unable to build transformer encoder block %v", l.name)
+	}
+	return l, nil
+}
+
+// TransformerEncoder stacks n transformer encoder blocks. All the blocks are created with the same options. The blocks are named
+// by suffixing the name given by the options with "_block0", "_block1" and so on, so that their weights have distinct names.
+func TransformerEncoder(n int, opts ...ConsOpt) (*Composition, error) {
+	if n <= 0 {
+		return nil, errors.Errorf("Expected a positive number of blocks. Got %d instead", n)
+	}
+	blocks := make([]Term, 0, n)
+	for i := 0; i < n; i++ {
+		var base string
+		block, err := TransformerEncoderBlock(append(append([]ConsOpt{}, opts...), withNameSuffix(&base, fmt.Sprintf("block%d", i)))...)
+		if err != nil {
+			return nil, err
+		}
+		blocks = append(blocks, block)
+	}
+	if n == 1 {
+		return Compose(I{}, blocks[0]), nil
+	}
+	return ComposeSeq(blocks...)
+}
+
+// build composes the sublayers of the block.
+func (l *EncoderBlock) build() (err error) {
+	attnOpts := []ConsOpt{WithName(l.name + "_attn"), WithHeads(l.heads), ComputeFLOPs(l.computeFLOPs)}
+	if l.keyDims > 0 {
+		attnOpts = append(attnOpts, WithKeyValueDims(l.keyDims, l.valueDims))
+	}
+	if l.mask != nil {
+		attnOpts = append(attnOpts, WithMask(l.mask))
+	}
+	if l.dropout != nil {
+		attnOpts = append(attnOpts, WithProbability(*l.dropout))
+	}
+	attn, err := NewMultiHeadAttention(attnOpts...)
+	if err != nil {
+		return err
+	}
+
+	ff1 := NewFC(WithName(l.name+"_ff1"), WithSize(l.ff), WithActivation(GeLUFn), AsBatched(true), ComputeFLOPs(l.computeFLOPs))
+	ff2 := NewFC(WithName(l.name+"_ff2"), WithSize(l.size), AsBatched(true), ComputeFLOPs(l.computeFLOPs))
+	norm1 := NewLayerNorm(WithName(l.name+"_norm1"), WithSize(l.size), ComputeFLOPs(l.computeFLOPs))
+	norm2 := NewLayerNorm(WithName(l.name+"_norm2"), WithSize(l.size), ComputeFLOPs(l.computeFLOPs))
+	return l.compose(attn, ff1, ff2, norm1, norm2)
+}
+
+// compose wires the sublayers of the block into its composition.
+func (l *EncoderBlock) compose(attn, ff1, ff2, norm1, norm2 Layer) (err error) {
+	var ff Term
+	if ff, err = ComposeSeq(ff1, ff2); err != nil {
+		return err
+	}
+	ff = &perPosition{Layer: ff.(Layer)}
+	var a Term = attn
+	if l.dropout != nil {
+		a = Compose(attn, dropout(*l.dropout))
+		ff = Compose(ff, dropout(*l.dropout))
+	}
+
+	n1 := &perPosition{Layer: norm1}
+	n2 := &perPosition{Layer: norm2}
+	if l.preNorm {
+		l.Composition = Compose(Add(I{}, Compose(n1, a)), Add(I{}, Compose(n2, ff)))
+		return nil
+	}
+	l.Composition, err = ComposeSeq(Add(I{}, a), n1, Add(I{}, ff), n2)
+	return err
+}
+
+// Name returns the name of the block.
+func (l *EncoderBlock) Name() string { return l.name }
+
+// SetName sets the name of the block.
+func (l *EncoderBlock) SetName(n string) error {
+	l.name = n
+	return nil
+}
+
+// SetSize sets the size of the block, which is the size of the last dimension of its inputs.
+func (l *EncoderBlock) SetSize(size int) error {
+	l.size = size
+	return nil
+}
+
+// SetDropout sets the dropout applied to the attention weights and to the outputs of the sublayers.
+func (l *EncoderBlock) SetDropout(prob float64) error {
+	l.dropout = &prob
+	return nil
+}
+
+// SetComputeFLOPs sets whether the FLOPs of the sublayers are computed when the input is forwarded.
+func (l *EncoderBlock) SetComputeFLOPs(toCompute bool) error {
+	l.computeFLOPs = toCompute
+	return nil
+}
+
+// perPosition applies a layer that works on matrices to every position of a batch of sequences. Inputs of shape (N, T, D) are
+// reshaped to (N×T, D) and back. Matrices are passed through as is.
+type perPosition struct {
+	Layer
+
+	// the shape and dtype of the last input. Only used for describing the layer
+	inShape tensor.Shape
+	of      tensor.Dtype
+}
+
+// Fwd runs the equation forwards.
+func (l *perPosition) Fwd(a G.Input) G.Result {
+	if err := G.CheckOne(a); err != nil {
+		return G.Err(errors.Wrapf(err, "Forward of %v", l.Name()))
+	}
+	x := a.Node()
+	l.inShape, l.of = x.Shape().Clone(), x.Dtype()
+	if x.Dims() != 3 {
+		return l.Layer.Fwd(x)
+	}
+
+	n, t, d := l.inShape[0], l.inShape[1], l.inShape[2]
+	flat, err := G.Reshape(x, tensor.Shape{n * t, d})
+	if err != nil {
+		return G.Err(errors.Wrapf(err, "Forward of %v - Unable to flatten %v", l.Name(), l.inShape))
+	}
+	res := l.Layer.Fwd(flat)
+	if err = G.CheckOne(res); err != nil {
+		return G.Err(errors.Wrapf(err, "Forward of %v", l.Name()))
+	}
+	out := res.Node()
+	return G.LiftResult(G.Reshape(out, tensor.Shape{n, t, out.Shape()[1]}))
+}
+
+// Name returns the name of the wrapped layer.
+func (l *perPosition) Name() string { return fmt.Sprintf("PerPosition(%v)", l.Layer.Name()) }
+
+// Describe describes the wrapped layer between the Reshapes. The (N, T) of the output are read from the shape of the input, so the
+// description is not tied to the batch size and the length of the last input.
+func (l *perPosition) Describe() (*onnx.GraphProto, error) {
+	inner, err := l.Layer.Describe()
+	if err != nil {
+		return nil, err
+	}
+	if l.inShape.Dims() != 3 {
+		return inner, nil
+	}
+	of := l.dtype()
+	flatten := newFragment("", "Flatten", of)
+	x := flatten.input()
+	flatten.apply("Shape", nil)
+	nt := flatten.apply("Slice", []string{flatten.ints(flatten.prefix+"_starts", 0), flatten.ints(flatten.prefix+"_ends", 2)})
+	flatten.node("Reshape", []string{x, flatten.ints(flatten.prefix+"_shape", -1, l.inShape[2])})
+
+	unflatten := newFragment("", "Unflatten", of)
+	y := unflatten.input()
+	shape := unflatten.node("Concat", []string{nt, unflatten.ints(unflatten.prefix+"_features", -1)}, attrInt("axis", 0))
+	unflatten.node("Reshape", []string{y, shape})
+	return chain(chain(flatten.graph(), inner), unflatten.graph()), nil
+}
+
+// dtype returns the dtype of the weights of the wrapped layer, or of the last input if the wrapped layer has no weights.
+func (l *perPosition) dtype() tensor.Dtype {
+	for _, n := range l.Layer.Model() {
+		if n != nil {
+			return n.Dtype()
+		}
+	}
+	return l.of
+}
+
+// ByName returns the wrapped layer, or a Term within it.
+func (l *perPosition) ByName(name string) Term {
+	if l.Layer.Name() == name {
+		return l.Layer
+	}
+	if bn, ok := l.Layer.(ByNamer); ok {
+		return bn.ByName(name)
+	}
+	return nil
+}
+
+// Runners returns the Runners of the wrapped layer.
+func (l *perPosition) Runners() []Runner {
+	if rs, ok := l.Layer.(Runnerser); ok {
+		return rs.Runners()
+	}
+	return nil
+}
+
+// FLOPs returns the FLOPs of the wrapped layer.
+func (l *perPosition) FLOPs() int {
+	if f, ok := l.Layer.(flopser); ok {
+		return f.FLOPs()
+	}
+	return 0
+}
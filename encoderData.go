@@ -0,0 +1,108 @@
+package golgi
+
+import (
+	"github.com/pkg/errors"
+	G "gorgonia.org/gorgonia"
+)
+
+var (
+	_ Data   = &EncoderBlockData{}
+	_ Dataer = &EncoderBlock{}
+)
+
+// EncoderBlockData represents the data of a transformer encoder block. The data of the sublayers are kept separately.
+type EncoderBlockData struct {
+	Name         string
+	Size         int
+	FF           int
+	Dropout      *float64
+	PreNorm      bool
+	Attn         *MultiHeadAttentionData
+	FF1, FF2     *FCData
+	Norm1, Norm2 *LayerNormData
+}
+
+// Make creates a *EncoderBlock in the given graph. If name is empty, the name of the snapshotted block is used.
+func (d *EncoderBlockData) Make(g *G.ExprGraph, name string) (Layer, error) {
+	if name == "" {
+		name = d.Name
+	}
+	if d.Attn == nil || d.FF1 == nil || d.FF2 == nil || d.Norm1 == nil || d.Norm2 == nil {
+		return nil, errors.Errorf("Unable to make transformer encoder block %v without all its sublayers", name)
+	}
+	attn, err := d.Attn.Make(g, name+"_attn")
+	if err != nil {
+		return nil, err
+	}
+	ff1, err := d.FF1.Make(g, name+"_ff1")
+	if err != nil {
+		return nil, err
+	}
+	ff2, err := d.FF2.Make(g, name+"_ff2")
+	if err != nil {
+		return nil, err
+	}
+	norm1, err := d.Norm1.Make(g, name+"_norm1")
+	if err != nil {
+		return nil, err
+	}
+	norm2, err := d.Norm2.Make(g, name+"_norm2")
+	if err != nil {
+		return nil, err
+	}
+	mha := attn.(*MultiHeadAttention)
+	l := &EncoderBlock{
+		name:      name,
+		size:      d.Size,
+		ff:        d.FF,
+		heads:     mha.heads,
+		keyDims:   mha.keyDims,
+		valueDims: mha.valueDims,
+		mask:      mha.mask,
+		dropout:   cloneProb(d.Dropout),
+		preNorm:   d.PreNorm,
+	}
+	if err = l.compose(attn, ff1, ff2, norm1, norm2); err != nil {
+		return nil, errors.Wrapf(err, "Unable to make transformer encoder block %v", name)
+	}
+	return l, nil
+}
+
+// ToData snapshots the sublayers and configuration of the transformer encoder block. The block must have been forwarded.
+func (l *EncoderBlock) ToData() (Data, error) {
+	retVal := &EncoderBlockData{
+		Name:    l.name,
+		Size:    l.size,
+		FF:      l.ff,
+		Dropout: cloneProb(l.dropout),
+		PreNorm: l.preNorm,
+	}
+	for _, p := range []struct {
+		suffix string
+		data   interface{}
+	}{
+		{"_attn", &retVal.Attn},
+		{"_ff1", &retVal.FF1},
+		{"_ff2", &retVal.FF2},
+		{"_norm1", &retVal.Norm1},
+		{"_norm2", &retVal.Norm2},
+	} {
+		data, err := termToData(l.ByName(l.name + p.suffix))
+		if err != nil {
+			return nil, errors.Wrapf(err, "ToData of transformer encoder block %v", l.name)
+		}
+		var ok bool
+		switch ptr := p.data.(type) {
+		case **MultiHeadAttentionData:
+			*ptr, ok = data.(*MultiHeadAttentionData)
+		case **FCData:
+			*ptr, ok = data.(*FCData)
+		case **LayerNormData:
+			*ptr, ok = data.(*LayerNormData)
+		}
+		if !ok {
+			return nil, errors.Errorf("Unexpected data %T for %v%v of transformer encoder block %v", data, l.name, p.suffix, l.name)
+		}
+	}
+	return retVal, nil
+}
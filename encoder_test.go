@@ -0,0 +1,122 @@
+package golgi
+
+import (
+	"bytes"
+	"testing"
+
+	"github.com/stretchr/testify/require"
+	"gorgonia.org/gorgonia"
+	"gorgonia.org/tensor"
+)
+
+func TestTransformerEncoderBlock(t *testing.T) {
+	for _, pre := range []bool{false, true} {
+		c := require.New(t)
+		g := gorgonia.NewGraph()
+		x := gorgonia.NewTensor(g, tensor.Float64, 3, gorgonia.WithName("x"), gorgonia.WithShape(2, 3, 4), gorgonia.WithInit(gorgonia.GlorotU(1)))
+
+		enc, err := TransformerEncoderBlock(WithName("enc"), WithSize(4), WithHeads(2), WithFeedForward(8), WithPreNorm(pre), WithProbability(0.1), ComputeFLOPs(true))
+		c.NoError(err)
+		c.Equal("enc", enc.Name())
+
+		out := enc.Fwd(x)
+		c.NoError(gorgonia.CheckOne(out))
+		c.Equal(tensor.Shape{2, 3, 4}, out.Node().Shape())
+		// attention: 4 × (W, B), norms: 2 × (W, B), feed-forward: 2 × (W, B)
+		c.Len(enc.Model(), 16)
+		c.NotZero(enc.FLOPs())
+		c.IsType(&MultiHeadAttention{}, enc.ByName("enc_attn"))
+		c.IsType(&FC{}, enc.ByName("enc_ff1"))
+		c.Equal(tensor.Shape{4, 8}, enc.ByName("enc_ff1_W").(*gorgonia.Node).Shape())
+
+		cost, err := gorgonia.Sum(out.Node())
+		c.NoError(err)
+		_, err = gorgonia.Grad(cost, enc.Model()...)
+		c.NoError(err)
+		m := gorgonia.NewTapeMachine(g)
+		c.NoError(m.RunAll())
+		m.Close()
+
+		desc, err := enc.Describe()
+		c.NoError(err)
+		ops := opTypes(desc)
+		c.Contains(ops, "Softmax")
+		c.Contains(ops, "LayerNormalization")
+		checkWellFormed(t, desc)
+	}
+}
+
+func TestPerPosition_Describe(t *testing.T) {
+	c := require.New(t)
+	g := gorgonia.NewGraph()
+	x := gorgonia.NewTensor(g, tensor.Float32, 3, gorgonia.WithName("x"), gorgonia.WithShape(5, 7, 4), gorgonia.WithInit(gorgonia.GlorotU(1)))
+	fc, err := ConsFC(gorgonia.NewMatrix(g, tensor.Float32, gorgonia.WithShape(35, 4)), WithName("fc"), WithSize(6))
+	c.NoError(err)
+	l := &perPosition{Layer: fc}
+	out := l.Fwd(x)
+	c.NoError(gorgonia.CheckOne(out))
+	c.Equal(tensor.Shape{5, 7, 6}, out.Node().Shape())
+
+	desc, err := l.Describe()
+	c.NoError(err)
+	checkWellFormed(t, desc)
+	ops := opTypes(desc)
+	c.Equal([]string{"Shape", "Slice", "Reshape"}, ops[:3])
+	c.Equal([]string{"Concat", "Reshape"}, ops[len(ops)-2:])
+
+	// the batch size and the length are not baked into the description
+	concat := desc.Node[len(desc.Node)-2]
+	c.Equal(desc.Node[1].Output[0], concat.Input[0])
+	for _, init := range desc.Initializer {
+		if len(init.Int64Data) > 0 {
+			c.NotContains(init.Int64Data, int64(5), init.Name)
+			c.NotContains(init.Int64Data, int64(7), init.Name)
+		}
+	}
+}
+
+func TestTransformerEncoder(t *testing.T) {
+	c := require.New(t)
+	g := gorgonia.NewGraph()
+	x := gorgonia.NewMatrix(g, tensor.Float64, gorgonia.WithName("x"), gorgonia.WithShape(3, 4), gorgonia.WithInit(gorgonia.GlorotU(1)))
+
+	enc, err := TransformerEncoder(3, WithName("enc"), WithSize(4), WithHeads(2))
+	c.NoError(err)
+	out := enc.Fwd(x)
+	c.NoError(gorgonia.CheckOne(out))
+	c.Equal(tensor.Shape{3, 4}, out.Node().Shape())
+
+	model := enc.Model()
+	c.Len(model, 3*16)
+	names := make(map[string]struct{})
+	for _, w := range model {
+		names[w.Name()] = struct{}{}
+	}
+	c.Len(names, len(model))
+	c.NotNil(enc.ByName("enc_block2"))
+	c.NotNil(enc.ByName("enc_block0_ff2"))
+
+	// a checkpoint rebuilds every block
+	var buf bytes.Buffer
+	c.NoError(SaveCheckpoint(&buf, enc))
+	g2 := gorgonia.NewGraph()
+	x2 := gorgonia.NewMatrix(g2, tensor.Float64, gorgonia.WithName("x"), gorgonia.WithShape(3, 4), gorgonia.WithValue(x.Value().(*tensor.Dense).Clone()))
+	loaded, err := LoadCheckpoint(g2, &buf)
+	c.NoError(err)
+	c.IsType(&EncoderBlock{}, loaded.(*Composition).ByName("enc_block1"))
+	out2 := loaded.Fwd(x2)
+	c.NoError(gorgonia.CheckOne(out2))
+	c.Equal(len(model), len(loaded.Model()))
+	c.InDeltaSlice(runValue(t, g, out.Node()), runValue(t, g2, out2.Node()), 1e-10)
+
+	single, err := TransformerEncoder(1, WithSize(4))
+	c.NoError(err)
+	c.NotNil(single.ByName("block0"))
+
+	_, err = TransformerEncoder(0, WithSize(4))
+	c.Error(err)
+	_, err = TransformerEncoderBlock(WithHeads(2))
+	c.Error(err)
+	_, err = TransformerEncoderBlock(WithSize(4), WithFeedForward(-1))
+	c.Error(err)
+}
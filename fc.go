@@ -3,6 +3,7 @@ package golgi
 import (
 	"github.com/chewxy/hm"
 	"github.com/pkg/errors"
+	"gorgonia.org/golgi/onnx"
 	G "gorgonia.org/gorgonia"
 	"gorgonia.org/tensor"
 )
@@ -11,11 +12,16 @@ var (
 	_ ByNamer = &FC{}
 )
 
-// WithWB is a FC specific construction option used to initialize a FC.
+// WithWB is a FC specific construction option used to initialize a FC. Layer norms are also supported.
 func WithWB(w, b *G.Node) ConsOpt {
 	return func(layer Layer) (Layer, error) {
-		fc, ok := layer.(*FC)
-		if !ok {
+		var fc *FC
+		switch l := layer.(type) {
+		case *FC:
+			fc = l
+		case *layerNorm:
+			fc = &l.FC
+		default:
 			return layer, errors.Errorf("Expected a *FC. Got %v of %T instead", layer, layer)
 		}
 		fc.w = w
@@ -29,8 +35,9 @@ func WithWB(w, b *G.Node) ConsOpt {
 //
 // If batched is set to true, then the first dimension is assumed to be the batch dimension
 type FC struct {
-	w, b *G.Node
-	act  ActivationFunction
+	w, b  *G.Node
+	act   ActivationFunction
+	initW G.InitWFn // nil means the default initialization
 
 	name string
 
@@ -144,8 +151,33 @@ func (l *FC) Name() string {
 }
 
 // Describe will describe a fully connected layer
-func (l *FC) Describe() {
-	panic("STUB")
+func (l *FC) Describe() (*onnx.GraphProto, error) {
+	if !l.initialized {
+		return nil, errors.Errorf("Unable to describe FC %v. It has not been initialized", l.name)
+	}
+	f := newFragment(l.name, "FC", l.w.Dtype())
+	if err := l.describe(f); err != nil {
+		return nil, err
+	}
+	return f.graph(), nil
+}
+
+// describe adds the nodes of a fully connected layer to the fragment.
+func (l *FC) describe(f *fragment) error {
+	w, err := f.weight(l.w)
+	if err != nil {
+		return err
+	}
+	if l.b == nil {
+		f.apply("MatMul", []string{w})
+	} else {
+		b, err := f.weight(l.b)
+		if err != nil {
+			return err
+		}
+		f.apply("Gemm", []string{w, b})
+	}
+	return f.activate(l.act)
 }
 
 // IsInitialized returns true if it has been initialized. This allows lazy initialization to be supported
@@ -183,6 +215,22 @@ func (l *FC) SetAct(act ActivationFunction) error { l.act = act; return nil }
 // SetComputeFLOPs will set the `computeFLOPs` param. If true then the FLOPs will be computed when the input is forwarded.
 func (l *FC) SetComputeFLOPs(toCompute bool) error { l.computeFLOPs = toCompute; return nil }
 
+// weightInit returns the initialization function of the weights.
+func (l *FC) weightInit() G.InitWFn {
+	if l.initW == nil {
+		return G.GlorotU(1)
+	}
+	return l.initW
+}
+
+// Reinit re-initializes the weights and the bias in place.
+func (l *FC) Reinit() error {
+	if err := reinitNode(l.w, l.weightInit()); err != nil {
+		return err
+	}
+	return reinitNode(l.b, G.Zeroes())
+}
+
 // Init will initialize the fully connected layer
 func (l *FC) Init(xs ...*G.Node) (err error) {
 	x := xs[0]
@@ -196,7 +244,7 @@ func (l *FC) Init(xs ...*G.Node) (err error) {
 	}
 
 	xshp := X.Shape()
-	l.w = G.NewMatrix(g, of, G.WithShape(xshp[1], l.size), G.WithInit(G.GlorotU(1)), G.WithName(l.name+"_W"))
+	l.w = G.NewMatrix(g, of, G.WithShape(xshp[1], l.size), G.WithInit(l.weightInit()), G.WithName(l.name+"_W"))
 	switch {
 	case l.batched && !l.nobias:
 		l.b = G.NewMatrix(g, of, G.WithShape(1, l.size), G.WithInit(G.Zeroes()), G.WithName(l.name+"_B"))
@@ -2,7 +2,9 @@ package golgi
 
 import (
 	"github.com/pkg/errors"
+	"gorgonia.org/golgi/onnx"
 	G "gorgonia.org/gorgonia"
+	"gorgonia.org/tensor"
 )
 
 // ActivationFunction represents an activation function
@@ -26,6 +28,11 @@ type Data interface {
 	Make(g *G.ExprGraph, name string) (Layer, error)
 }
 
+// Dataer is any layer that is able to snapshot its weights and configuration into a Data.
+type Dataer interface {
+	ToData() (Data, error)
+}
+
 // Runner is a kind of layer that requires outside-the-graph manipulation.
 type Runner interface {
 	Run(a G.Input) error
@@ -41,6 +48,16 @@ type Runnerser interface {
 	Runners() []Runner
 }
 
+// postStepper is a Runner that has to be run after the step of the solver, rather than before the machine is run.
+type postStepper interface {
+	postStep() error
+}
+
+// OutputShaper is any layer that is able to compute the shape of its output from the shape of its input, without applying the layer.
+type OutputShaper interface {
+	OutputShape(in tensor.Shape) (tensor.Shape, error)
+}
+
 // Layer represents a neural network layer.
 // λ
 type Layer interface {
@@ -57,24 +74,79 @@ type Layer interface {
 
 	// Serialization stuff
 
-	// Describe returns the protobuf definition of a Layer that conforms to the ONNX standard
-	Describe() // some protobuf things TODO
+	// Describe returns the protobuf definition of a Layer that conforms to the ONNX standard.
+	//
+	// The returned graph is a fragment: it has exactly one input and one output, and its initializers hold the weights of the layer.
+	Describe() (*onnx.GraphProto, error)
+}
+
+// Reiniter is any layer whose weights can be re-initialized in place. See Reinit.
+type Reiniter interface {
+	Reinit() error
 }
 
 type flopser interface {
 	FLOPs() int
 }
 
-// Redefine redefines a layer with the given construction options. This is useful for re-initializing layers
+// Redefine redefines a layer with the given construction options, and returns the redefined layer. This is useful for
+// re-initializing layers: Redefine(l, WithInit(fn)) followed by Reinit re-initializes the weights of l with fn.
+//
+// If an option fails, the original layer is returned with the error.
 func Redefine(l Layer, opts ...ConsOpt) (retVal Layer, err error) {
+	retVal = l
 	for _, opt := range opts {
-		if l, err := opt(l); err != nil {
+		if retVal, err = opt(retVal); err != nil {
 			return l, err
 		}
 	}
-	return l, nil
+	return retVal, nil
 }
 
+// Reinit re-initializes the weights of a layer in place, with the initializers the weights were created with. Compositions, joins
+// and DAGs re-initialize every layer they are made of. The graph is left alone, so this is a cheap way to restart training from new
+// random weights.
+//
+// Layers without weights, and layers that have not been initialized yet, are left alone. A layer with weights must implement
+// Reiniter.
+func Reinit(l Layer) error {
+	if r, ok := l.(Reiniter); ok {
+		return r.Reinit()
+	}
+	if len(l.Model()) == 0 {
+		return nil
+	}
+	return errors.Errorf("Unable to re-initialize %v of %T", l.Name(), l)
+}
+
+// reinitTerm re-initializes a term. Thunks have not been constructed, so they have no weights.
+func reinitTerm(t Term) error {
+	if l, ok := t.(Layer); ok {
+		return Reinit(l)
+	}
+	return nil
+}
+
+// reinitNode fills the value of a weight with new values from the initialization function. A nil node is left alone.
+func reinitNode(n *G.Node, fn G.InitWFn) error {
+	if n == nil {
+		return nil
+	}
+	v, ok := n.Value().(tensor.Tensor)
+	if !ok {
+		return errors.Errorf("Expected the value of %v to be a tensor. Got %v of %T instead", n.Name(), n.Value(), n.Value())
+	}
+	shp := n.Shape()
+	if err := tensor.Copy(v, tensor.New(tensor.WithShape(shp...), tensor.WithBacking(fn(n.Dtype(), shp...)))); err != nil {
+		return errors.Wrapf(err, "Unable to re-initialize %v", n.Name())
+	}
+	return nil
+}
+
+// applyFn has the signature of Apply. A Composition or a Join applies its terms with an applyFn, which allows Summary to observe
+// every application.
+type applyFn func(a, b Term) (Term, error)
+
 // Apply will apply two terms and return the resulting term
 // Apply(a, b) has the semantics of a(b).
 func Apply(a, b Term) (Term, error) {
@@ -0,0 +1,80 @@
+package golgi
+
+import (
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/require"
+	"gorgonia.org/gorgonia"
+	"gorgonia.org/tensor"
+)
+
+func TestRedefine(t *testing.T) {
+	c := require.New(t)
+	fc := NewFC(WithName("a"), WithSize(3))
+
+	l, err := Redefine(fc, WithName("b"), WithSize(4))
+	c.NoError(err)
+	c.Equal("b", l.Name())
+	c.Equal(4, l.(*FC).size)
+
+	l, err = Redefine(fc, WithName("c"), WithInit(nil))
+	c.Error(err)
+	c.True(l == Layer(fc))
+}
+
+func TestReinit(t *testing.T) {
+	c := require.New(t)
+	g := gorgonia.NewGraph()
+	x := gorgonia.NewMatrix(g, tensor.Float64, gorgonia.WithName("x"), gorgonia.WithShape(2, 3), gorgonia.WithInit(gorgonia.GlorotU(1)))
+	nn, err := ComposeSeq(
+		x,
+		L(ConsFC, WithName("l0"), WithSize(4), WithActivation(gorgonia.Tanh)),
+		Add(I{}, L(ConsFC, WithName("res"), WithSize(4))),
+		L(ConsLayerNorm, WithName("norm"), WithSize(4)),
+		L(ConsLSTM, WithName("lstm"), WithSize(2)),
+	)
+	c.NoError(err)
+	out := nn.Fwd(x)
+	c.NoError(gorgonia.CheckOne(out))
+	before := runValue(t, g, out.Node())
+
+	model := nn.Model()
+	snapshots := make([][]float64, len(model))
+	for i, n := range model {
+		snapshots[i] = append([]float64(nil), n.Value().Data().([]float64)...)
+		// perturb every weight, including those that are initialized to constants
+		for j := range n.Value().Data().([]float64) {
+			n.Value().Data().([]float64)[j] += 1
+		}
+	}
+
+	// a different initializer for the nested layer
+	res := nn.ByName("res")
+	_, err = Redefine(res.(Layer), WithInit(gorgonia.ValuesOf(0.25)))
+	c.NoError(err)
+	c.NoError(Reinit(nn))
+
+	c.Equal(model, nn.Model(), "the weights are re-initialized in place")
+	for i, n := range model {
+		vals := n.Value().Data().([]float64)
+		switch {
+		case n.Name() == "res_W":
+			for _, v := range vals {
+				c.Equal(0.25, v)
+			}
+		case n.Name() == "norm_W", strings.HasSuffix(strings.ToLower(n.Name()), "_b"):
+			// constant initializers give back the same values
+			c.Equal(snapshots[i], vals, n.Name())
+		default:
+			c.NotEqual(snapshots[i], vals, n.Name())
+		}
+	}
+	c.NotEqual(before, runValue(t, g, out.Node()))
+
+	// thunks and layers without weights have nothing to re-initialize
+	c.NoError(Reinit(Compose(I{}, L(ConsFC, WithSize(2)))))
+	flat, err := ConsFlatten(nil)
+	c.NoError(err)
+	c.NoError(Reinit(flat))
+}
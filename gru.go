@@ -0,0 +1,286 @@
+package golgi
+
+import (
+	"github.com/chewxy/hm"
+	"github.com/pkg/errors"
+	"gorgonia.org/golgi/onnx"
+	G "gorgonia.org/gorgonia"
+	"gorgonia.org/tensor"
+)
+
+var (
+	_ Layer              = &GRU{}
+	_ namesetter         = &GRU{}
+	_ sizeSetter         = &GRU{}
+	_ computeFLOPsSetter = &GRU{}
+)
+
+// GRU represents a gated recurrent unit as per https://arxiv.org/abs/1406.1078
+//
+// The hidden state is computed as such:
+//
+//	r  = σ(xWr + hUr + br)
+//	z  = σ(xWz + hUz + bz)
+//	ĥ  = tanh(xWh + (r⊙h)Uh + bh)
+//	h' = (1-z)⊙ĥ + z⊙h
+type GRU struct {
+	name string
+
+	g *G.ExprGraph
+
+	reset     lstmGate
+	update    lstmGate
+	candidate lstmGate
+
+	size        int       // for construction
+	initW       G.InitWFn // for construction. nil means the default initialization
+	initialized bool
+	dummyHidden *G.Node
+
+	computeFLOPs bool
+	flops        int
+}
+
+// FromGRUData will initialize a new GRU model
+func FromGRUData(g *G.ExprGraph, layer *GRUData, name string) *GRU {
+	retVal, err := layer.Make(g, name)
+	if err != nil {
+		panic(err)
+	}
+	return retVal.(*GRU)
+}
+
+// ConsGRU is a GRU construction function. It takes a gorgonia.Input that has a *gorgonia.Node.
+func ConsGRU(in G.Input, opts ...ConsOpt) (retVal Layer, err error) {
+	x := in.Node()
+	if x == nil {
+		return nil, errors.Errorf("GRU expects a *Node. Got input %v of  %T instead", in, in)
+	}
+
+	if !x.IsMatrix() {
+		return nil, errors.Errorf("Expected the input of a GRU to be a matrix. Got %v instead", x.Shape())
+	}
+
+	l := &GRU{}
+	for _, opt := range opts {
+		var (
+			o  Layer
+			ok bool
+		)
+
+		if o, err = opt(l); err != nil {
+			return nil, err
+		}
+
+		if l, ok = o.(*GRU); !ok {
+			return nil, errors.Errorf("Construction Option returned a non GRU. Got %T instead", o)
+		}
+	}
+
+	if err = l.Init(x); err != nil {
+		return nil, err
+	}
+	return l, nil
+}
+
+// Init will initialize the GRU
+func (l *GRU) Init(xs ...*G.Node) (err error) {
+	if len(xs) != 1 {
+		return errors.Errorf("Tried to initialize a GRU with %d input nodes. Expected 1 only.", len(xs))
+	}
+	if l.size <= 0 {
+		return errors.Errorf("Unable to initialize GRU %v with size %d", l.name, l.size)
+	}
+	x := xs[0]
+	g := x.Graph()
+	of := x.Dtype()
+	inner := x.Shape()[1]
+
+	l.g = g
+	l.reset.init(g, of, inner, l.size, l.name+"_r", G.Sigmoid, l.initW)
+	l.update.init(g, of, inner, l.size, l.name+"_z", G.Sigmoid, l.initW)
+	l.candidate.init(g, of, inner, l.size, l.name+"_h", G.Tanh, l.initW)
+
+	l.dummyHidden = G.NewMatrix(g, of, G.WithShape(1, l.size), G.WithName(l.name+"dummyHidden"), G.WithInit(G.Zeroes()))
+	l.initialized = true
+	return nil
+}
+
+// Reinit re-initializes the weights of every gate in place.
+func (l *GRU) Reinit() error {
+	for _, gate := range []*lstmGate{&l.reset, &l.update, &l.candidate} {
+		if err := gate.reinit(l.initW); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// Model will return the gorgonia.Nodes associated with this GRU
+func (l *GRU) Model() G.Nodes {
+	return G.Nodes{
+		l.reset.wx, l.reset.wh, l.reset.b,
+		l.update.wx, l.update.wh, l.update.b,
+		l.candidate.wx, l.candidate.wh, l.candidate.b,
+	}
+}
+
+// Fwd runs the equation forwards.
+//
+// The input is either a single *Node, in which case the previous hidden state is all zeroes, or a tuple of [x, prevHidden].
+// The Result is a tuple, organized as such: [x, hidden]. The Node() of the Result is the hidden state.
+//
+// e.g.
+//
+//	out := gru.Fwd(x)
+//	hidden := out.Nodes()[1]
+func (l *GRU) Fwd(x G.Input) G.Result {
+	var (
+		inputVector *G.Node
+		prevHidden  *G.Node
+
+		err error
+	)
+
+	if err = G.CheckOne(x); err != nil {
+		return G.Err(err)
+	}
+
+	// the result of a previous layer, such as another GRU, carries its state in Nodes(). Only its output is the input.
+	ns := x.Nodes()
+	if n := x.Node(); n != nil {
+		ns = G.Nodes{n}
+	}
+	switch len(ns) {
+	case 0:
+		return G.Err(errors.New("input value does not contain any nodes"))
+	case 1:
+		inputVector = ns[0]
+	case 2:
+		inputVector = ns[0]
+		prevHidden = ns[1]
+	default:
+		return G.Err(errors.Errorf("invalid number of nodes, expected 1 or 2 and received %d", len(ns)))
+	}
+
+	if !l.initialized {
+		if err = l.Init(inputVector); err != nil {
+			return G.Err(errors.Wrapf(err, "Lazy initialization of GRU %v", l.name))
+		}
+	}
+	if prevHidden == nil {
+		prevHidden = l.dummyHidden
+	}
+
+	var resetGate, updateGate *G.Node
+	if resetGate, err = l.reset.activate(inputVector, prevHidden); err != nil {
+		return G.Err(errors.Wrap(err, "Unable to activate the reset gate"))
+	}
+	if updateGate, err = l.update.activate(inputVector, prevHidden); err != nil {
+		return G.Err(errors.Wrap(err, "Unable to activate the update gate"))
+	}
+
+	var resetHidden *G.Node
+	if resetHidden, err = BroadcastHadamardProd(resetGate, prevHidden, nil, []byte{0}); err != nil {
+		return G.Err(err)
+	}
+
+	var candidate *G.Node
+	if candidate, err = l.candidate.activate(inputVector, resetHidden); err != nil {
+		return G.Err(errors.Wrap(err, "Unable to activate the candidate gate"))
+	}
+
+	// h' = (1-z)⊙ĥ + z⊙h
+	var one, keep, write, retain, hidden *G.Node
+	if one, err = constOf(updateGate, 1); err != nil {
+		return G.Err(err)
+	}
+	if keep, err = G.Sub(one, updateGate); err != nil {
+		return G.Err(err)
+	}
+	if write, err = G.HadamardProd(keep, candidate); err != nil {
+		return G.Err(err)
+	}
+	if retain, err = BroadcastHadamardProd(updateGate, prevHidden, nil, []byte{0}); err != nil {
+		return G.Err(err)
+	}
+	if hidden, err = G.Add(write, retain); err != nil {
+		return G.Err(err)
+	}
+
+	if l.computeFLOPs {
+		l.flops = l.doComputeFLOPs(inputVector.Shape())
+	}
+
+	result := makeGRUIO(inputVector, hidden, nil)
+	result.out = hidden
+	return &result
+}
+
+// Type will return the hm.Type of the GRU
+func (l *GRU) Type() hm.Type { return hm.NewFnType(hm.TypeVariable('a'), hm.TypeVariable('b')) }
+
+// Shape will return the tensor.Shape of the GRU
+func (l *GRU) Shape() tensor.Shape { return l.reset.b.Shape() }
+
+// Name will return the name of the GRU
+func (l *GRU) Name() string { return l.name }
+
+// SetName will set the name of the GRU
+func (l *GRU) SetName(a string) error {
+	l.name = a
+	return nil
+}
+
+// SetSize sets the size of the hidden state of the GRU
+func (l *GRU) SetSize(size int) error {
+	l.size = size
+	return nil
+}
+
+// SetComputeFLOPs sets whether the FLOPs are computed when the input is forwarded.
+func (l *GRU) SetComputeFLOPs(toCompute bool) error {
+	l.computeFLOPs = toCompute
+	return nil
+}
+
+// FLOPs returns the FLOPs computed by the last Fwd.
+func (l *GRU) FLOPs() int { return l.flops }
+
+// doComputeFLOPs computes the rough number of floating point operations of a single time step.
+func (l *GRU) doComputeFLOPs(input tensor.Shape) int {
+	n := input[0]
+	retVal := l.reset.flops(n) + l.update.flops(n) + l.candidate.flops(n)
+	// r⊙h, 1-z, (1-z)⊙ĥ, z⊙h and the final addition
+	return retVal + 5*n*l.size
+}
+
+// Describe will describe a GRU.
+//
+// As the *GRU computes a single time step, it is described as an ONNX GRU over a sequence of length 1, starting from a zero state.
+func (l *GRU) Describe() (*onnx.GraphProto, error) {
+	if !l.initialized {
+		return nil, errors.Errorf("Unable to describe GRU %v. It has not been initialized", l.name)
+	}
+	f := newFragment(l.name, "GRU", l.reset.wx.Dtype())
+
+	// ONNX orders the gates as update, reset, hidden.
+	w, r, b, err := describeGates(f, &l.update, &l.reset, &l.candidate)
+	if err != nil {
+		return nil, err
+	}
+
+	axes := f.ints(f.prefix+"_axes", 0)
+	seq := f.apply("Unsqueeze", []string{axes})
+	hidden := f.prefix + "_Y_h"
+	f.g.Node = append(f.g.Node, &onnx.NodeProto{
+		Name:      f.prefix + "_GRU",
+		OpType:    "GRU",
+		Input:     []string{seq, w, r, b},
+		Output:    []string{"", hidden},
+		Attribute: []*onnx.AttributeProto{attrInt("hidden_size", l.size)},
+	})
+	f.last = hidden
+	f.apply("Squeeze", []string{axes})
+	return f.graph(), nil
+}
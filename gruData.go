@@ -0,0 +1,115 @@
+package golgi
+
+import (
+	"github.com/pkg/errors"
+	G "gorgonia.org/gorgonia"
+	"gorgonia.org/tensor"
+)
+
+var (
+	_ Data   = &GRUData{}
+	_ Dataer = &GRU{}
+)
+
+// GRUGateData represents the weights of a single gate of a GRU.
+type GRUGateData struct {
+	Wx, Wh, B *tensor.Dense
+	Act       Activation
+}
+
+// GRUData represents the data of a GRU.
+type GRUData struct {
+	Name                     string
+	Size                     int
+	Reset, Update, Candidate GRUGateData
+}
+
+// Data snapshots the weights and configuration of the GRU. The weights are copied, so further training does not affect the snapshot.
+//
+// Gate activation functions that cannot be serialized are recorded as the default activation of the gate.
+func (l *GRU) Data() (*GRUData, error) {
+	if !l.initialized {
+		return nil, errors.Errorf("Unable to take a snapshot of GRU %v. It has not been initialized", l.name)
+	}
+	retVal := &GRUData{
+		Name: l.name,
+		Size: l.size,
+	}
+	for _, gate := range []struct {
+		from *lstmGate
+		into *GRUGateData
+		def  Activation
+	}{
+		{&l.reset, &retVal.Reset, Sigmoid},
+		{&l.update, &retVal.Update, Sigmoid},
+		{&l.candidate, &retVal.Candidate, Tanh},
+	} {
+		var err error
+		if gate.into.Wx, err = snapshot(gate.from.wx); err != nil {
+			return nil, err
+		}
+		if gate.into.Wh, err = snapshot(gate.from.wh); err != nil {
+			return nil, err
+		}
+		if gate.into.B, err = snapshot(gate.from.b); err != nil {
+			return nil, err
+		}
+		gate.into.Act = gateActivation(gate.from.act, gate.def)
+	}
+	return retVal, nil
+}
+
+// ToData snapshots the weights and configuration of the GRU.
+func (l *GRU) ToData() (Data, error) {
+	retVal, err := l.Data()
+	if err != nil {
+		return nil, err
+	}
+	for _, gate := range []*lstmGate{&l.reset, &l.update, &l.candidate} {
+		if _, err := activationOf(gate.act); err != nil {
+			return nil, errors.Wrapf(err, "ToData of GRU %v", l.name)
+		}
+	}
+	return retVal, nil
+}
+
+// Make creates a *GRU in the given graph. If name is empty, the name of the snapshotted layer is used.
+//
+// The weights are named the same way as a GRU that is initialized by ConsGRU.
+func (d *GRUData) Make(g *G.ExprGraph, name string) (Layer, error) {
+	if name == "" {
+		name = d.Name
+	}
+	if d.Size <= 0 {
+		return nil, errors.Errorf("GRUData %v has an invalid size %d", d.Name, d.Size)
+	}
+	retVal := &GRU{
+		name: name,
+		g:    g,
+		size: d.Size,
+	}
+	for _, gate := range []struct {
+		from         *GRUGateData
+		into         *lstmGate
+		gate, suffix string
+	}{
+		{&d.Reset, &retVal.reset, "reset", "_r"},
+		{&d.Update, &retVal.update, "update", "_z"},
+		{&d.Candidate, &retVal.candidate, "candidate", "_h"},
+	} {
+		if gate.from.Wx == nil || gate.from.Wh == nil || gate.from.B == nil {
+			return nil, errors.Errorf("GRUData %v is missing the weights of the %v gate", d.Name, gate.gate)
+		}
+		*gate.into = makeLSTMGate(
+			weightFromData(g, gate.from.Wx, name+gate.suffix+"_wx"),
+			weightFromData(g, gate.from.Wh, name+gate.suffix+"_wh"),
+			weightFromData(g, gate.from.B, name+gate.suffix+"_b"),
+		)
+		gate.into.act = ActivationMap(gate.from.Act)
+	}
+
+	of := retVal.reset.wx.Dtype()
+	retVal.dummyHidden = G.NewMatrix(g, of, G.WithShape(1, d.Size), G.WithName(name+"dummyHidden"), G.WithInit(G.Zeroes()))
+	retVal.initialized = true
+	return retVal, nil
+}
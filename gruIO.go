@@ -0,0 +1,68 @@
+package golgi
+
+import G "gorgonia.org/gorgonia"
+
+// Ensure that gruIO matches both gorgonia.Input and gorgonia.Result interfaces
+var (
+	_ G.Input  = &gruIO{}
+	_ G.Result = &gruIO{}
+)
+
+// makeGRUIO will return a new gruIO
+func makeGRUIO(x, prevHidden *G.Node, err error) (l gruIO) {
+	l.x = x
+	l.prevHidden = prevHidden
+	l.err = err
+	return l
+}
+
+// gruIO represents a GRU input/output value
+type gruIO struct {
+	x          *G.Node
+	prevHidden *G.Node
+
+	// out is the output of a GRU: the hidden state
+	out *G.Node
+
+	err error
+}
+
+// Node will return the output of the GRU. It is nil for a gruIO that is used as an input.
+func (l *gruIO) Node() *G.Node { return l.out }
+
+// Name returns the name of the output, which allows a gruIO to be used as a Term.
+func (l *gruIO) Name() string {
+	if l.out == nil {
+		return "gruIO"
+	}
+	return l.out.Name()
+}
+
+// Nodes will return the nodes associated with the GRU input
+func (l *gruIO) Nodes() (ns G.Nodes) {
+	if l.err != nil {
+		return
+	}
+	return G.Nodes{l.x, l.prevHidden}
+}
+
+// Err will return any error associated with the GRU input
+func (l *gruIO) Err() error { return l.err }
+
+// Mk makes a new Input, given the xs. This is useful for replacing values in the tuple
+//
+// CAVEAT: the replacements depends on the length of xs
+//
+//	1: replace x
+//	2: replace x, prevHidden in this order
+//	other: no replacement. l is returned
+func (l *gruIO) Mk(xs ...G.Input) G.Input {
+	switch len(xs) {
+	case 1:
+		return &gruIO{x: xs[0].Node(), prevHidden: l.prevHidden}
+	case 2:
+		return &gruIO{x: xs[0].Node(), prevHidden: xs[1].Node()}
+	default:
+		return l
+	}
+}
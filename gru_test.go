@@ -0,0 +1,125 @@
+package golgi
+
+import (
+	"bytes"
+	"math"
+	"testing"
+
+	"github.com/stretchr/testify/require"
+	"gorgonia.org/gorgonia"
+	"gorgonia.org/tensor"
+)
+
+// gruRef computes a single step of a GRU with plain loops.
+func gruRef(l *GRU, x []float64, n, inner int, h []float64) []float64 {
+	size := l.size
+	gate := func(w *lstmGate, x, h []float64, act func(float64) float64) []float64 {
+		wx := w.wx.Value().Data().([]float64)
+		wh := w.wh.Value().Data().([]float64)
+		b := w.b.Value().Data().([]float64)
+		retVal := make([]float64, n*size)
+		for i := 0; i < n; i++ {
+			for j := 0; j < size; j++ {
+				acc := b[j]
+				for k := 0; k < inner; k++ {
+					acc += x[i*inner+k] * wx[k*size+j]
+				}
+				for k := 0; k < size; k++ {
+					acc += h[i*size+k] * wh[k*size+j]
+				}
+				retVal[i*size+j] = act(acc)
+			}
+		}
+		return retVal
+	}
+	r := gate(&l.reset, x, h, sigmoidRef)
+	z := gate(&l.update, x, h, sigmoidRef)
+	rh := make([]float64, len(h))
+	for i := range h {
+		rh[i] = r[i] * h[i]
+	}
+	ĥ := gate(&l.candidate, x, rh, math.Tanh)
+	retVal := make([]float64, len(h))
+	for i := range h {
+		retVal[i] = (1-z[i])*ĥ[i] + z[i]*h[i]
+	}
+	return retVal
+}
+
+func TestGRU(t *testing.T) {
+	c := require.New(t)
+	xs := []float64{0.1, -0.2, 0.3, 0.4, 0.5, -0.6}
+	hs := []float64{0.5, -0.5, 0.25, 0, 1, -1, 0.1, 0.2}
+
+	g := gorgonia.NewGraph()
+	x := gorgonia.NewMatrix(g, tensor.Float64, gorgonia.WithName("x"), gorgonia.WithShape(2, 3), gorgonia.WithValue(tensor.New(tensor.WithShape(2, 3), tensor.WithBacking(xs))))
+	h := gorgonia.NewMatrix(g, tensor.Float64, gorgonia.WithName("h"), gorgonia.WithShape(2, 4), gorgonia.WithValue(tensor.New(tensor.WithShape(2, 4), tensor.WithBacking(hs))))
+	l, err := ConsGRU(x, WithName("gru"), WithSize(4), ComputeFLOPs(true))
+	c.NoError(err)
+	gru := l.(*GRU)
+	c.Len(gru.Model(), 9)
+	c.Equal("gru_z_wh", gru.Model()[4].Name())
+
+	first := gru.Fwd(x)
+	c.NoError(gorgonia.CheckOne(first))
+	c.Len(first.Nodes(), 2)
+	c.Equal(tensor.Shape{2, 4}, first.Nodes()[1].Shape())
+	c.NotZero(gru.FLOPs())
+
+	second := gru.Fwd(&gruIO{x: x, prevHidden: h})
+	c.NoError(gorgonia.CheckOne(second))
+	cost, err := gorgonia.Sum(second.Nodes()[1])
+	c.NoError(err)
+	_, err = gorgonia.Grad(cost, gru.Model()...)
+	c.NoError(err)
+
+	var v1, v2 gorgonia.Value
+	gorgonia.Read(first.Nodes()[1], &v1)
+	gorgonia.Read(second.Nodes()[1], &v2)
+	m := gorgonia.NewTapeMachine(g)
+	defer m.Close()
+	c.NoError(m.RunAll())
+	c.InDeltaSlice(gruRef(gru, xs, 2, 3, make([]float64, 8)), v1.Data(), 1e-10)
+	c.InDeltaSlice(gruRef(gru, xs, 2, 3, hs), v2.Data(), 1e-10)
+
+	// persistence
+	var buf bytes.Buffer
+	c.NoError(SaveCheckpoint(&buf, gru))
+	g2 := gorgonia.NewGraph()
+	x2 := gorgonia.NewMatrix(g2, tensor.Float64, gorgonia.WithName("x"), gorgonia.WithShape(2, 3), gorgonia.WithValue(tensor.New(tensor.WithShape(2, 3), tensor.WithBacking(xs))))
+	loaded, err := LoadCheckpoint(g2, &buf)
+	c.NoError(err)
+	for i, w := range gru.Model() {
+		c.Equal(w.Name(), loaded.Model()[i].Name())
+	}
+	c.InDeltaSlice(v1.Data(), runValue(t, g2, loaded.Fwd(x2).Nodes()[1]), 1e-10)
+
+	desc, err := gru.Describe()
+	c.NoError(err)
+	c.Equal([]string{"Unsqueeze", "GRU", "Squeeze"}, opTypes(desc))
+	checkWellFormed(t, desc)
+
+	_, err = ConsGRU(gorgonia.NewVector(g, tensor.Float64, gorgonia.WithShape(3)), WithSize(4))
+	c.Error(err)
+	_, err = new(GRUData).Make(g, "gru")
+	c.Error(err)
+}
+
+func TestGRU_Stacked(t *testing.T) {
+	c := require.New(t)
+	g := gorgonia.NewGraph()
+	x := gorgonia.NewMatrix(g, tensor.Float64, gorgonia.WithName("x"), gorgonia.WithShape(2, 4), gorgonia.WithInit(gorgonia.GlorotU(1)))
+	nn, err := ComposeSeq(
+		x,
+		L(ConsGRU, WithName("g1"), WithSize(6)),
+		L(ConsGRU, WithName("g2"), WithSize(5)),
+	)
+	c.NoError(err)
+	out := nn.Fwd(x)
+	c.NoError(gorgonia.CheckOne(out))
+	c.Equal(tensor.Shape{2, 5}, out.Node().Shape())
+
+	// the second GRU reads the hidden state of the first one, not the original input
+	g2 := nn.ByName("g2").(*GRU)
+	c.Equal(tensor.Shape{6, 5}, g2.reset.wx.Shape())
+}
@@ -1,90 +1,311 @@
 package golgi
 
 import (
+	"fmt"
+	"strings"
+
 	"github.com/pkg/errors"
+	"gorgonia.org/golgi/onnx"
 	G "gorgonia.org/gorgonia"
+	"gorgonia.org/tensor"
 )
 
 var (
-	_ Layer = (*Join)(nil)
+	_ Layer     = (*Join)(nil)
+	_ ByNamer   = (*Join)(nil)
+	_ Runnerser = (*Join)(nil)
 )
 
 type joinOp int
 
 const (
-	composeOp joinOp = iota
-	addOp
+	addOp joinOp = iota
 	elMulOp
+	subOp
+	maxOp
+	meanOp
+	concatOp
+	mergeOp
 )
 
-// Join joins are generalized compositions.
+func (op joinOp) String() string {
+	switch op {
+	case addOp:
+		return "Add"
+	case elMulOp:
+		return "Mul"
+	case subOp:
+		return "Sub"
+	case maxOp:
+		return "Max"
+	case meanOp:
+		return "Mean"
+	case concatOp:
+		return "Concat"
+	case mergeOp:
+		return "Merge"
+	}
+	return "UNKNOWN JOIN"
+}
+
+// Join joins are generalized compositions. The same input is fanned out to every term, and the results of the terms are merged.
 type Join struct {
-	Composition
-	op joinOp
+	terms []Term // can be thunk, Layer or I
+	op    joinOp
+
+	axis  int                            // the axis of a concatenation
+	merge func(G.Nodes) (*G.Node, error) // the merge function of Merge
 }
 
 // Add adds the results of two layers/terms.
-func Add(a, b Term) *Join {
-	return &Join{
-		Composition: Composition{
-			a: a,
-			b: b,
-		},
-		op: addOp,
+func Add(a, b Term) *Join { return newJoin(addOp, a, b) }
+
+// HadamardProd performs a elementwise multiplicatoin on the results of two layers/terms.
+func HadamardProd(a, b Term) *Join { return newJoin(elMulOp, a, b) }
+
+// Sub subtracts the results of the rest of the terms from the result of the first term.
+func Sub(terms ...Term) *Join { return newJoin(subOp, terms...) }
+
+// Max takes the elementwise maximum of the results of the terms.
+func Max(terms ...Term) *Join { return newJoin(maxOp, terms...) }
+
+// Mean takes the elementwise mean of the results of the terms.
+func Mean(terms ...Term) *Join { return newJoin(meanOp, terms...) }
+
+// Concat concatenates the results of the terms along the given axis. This is the merge of the towers of an Inception-style block.
+func Concat(axis int, terms ...Term) *Join {
+	retVal := newJoin(concatOp, terms...)
+	retVal.axis = axis
+	return retVal
+}
+
+// Merge merges the results of the terms with the given function. The results are passed to the function in the order of the terms.
+//
+// A Join created by Merge cannot be described nor snapshotted, as the merge function is opaque.
+func Merge(fn func(G.Nodes) (*G.Node, error), terms ...Term) *Join {
+	retVal := newJoin(mergeOp, terms...)
+	retVal.merge = fn
+	return retVal
+}
+
+func newJoin(op joinOp, terms ...Term) *Join {
+	retVal := &Join{op: op, terms: make([]Term, len(terms))}
+	for i, t := range terms {
+		if _, ok := t.(G.Input); ok {
+			t = I{}
+		}
+		retVal.terms[i] = t
 	}
+	return retVal
 }
 
-// HadamardProd performs a elementwise multiplicatoin on the results of two layers/terms.
-func HadamardProd(a, b Term) *Join {
-	return &Join{
-		Composition: Composition{
-			a: a,
-			b: b,
-		},
-		op: elMulOp,
+// Terms returns the terms of the join, in order.
+func (l *Join) Terms() []Term { return l.terms }
+
+// Name will return the name of the join.
+func (l *Join) Name() string {
+	names := make([]string, len(l.terms))
+	for i, t := range l.terms {
+		names[i] = "x"
+		if t != nil {
+			names[i] = t.Name()
+		}
 	}
+	if l.op == concatOp {
+		return fmt.Sprintf("%v[%d](%v)", l.op, l.axis, strings.Join(names, ", "))
+	}
+	return fmt.Sprintf("%v(%v)", l.op, strings.Join(names, ", "))
 }
 
-// Fwd runs the equation forwards.
-func (l *Join) Fwd(a G.Input) (output G.Result) {
-	if l.op == composeOp {
-		return l.Composition.Fwd(a)
+// Model will return the gorgonia.Nodes associated with every term of the join.
+func (l *Join) Model() (retVal G.Nodes) {
+	for _, t := range l.terms {
+		if lt, ok := t.(Layer); ok {
+			retVal = append(retVal, lt.Model()...)
+		}
 	}
+	return retVal
+}
 
-	if err := G.CheckOne(a); err != nil {
-		return G.Err(errors.Wrapf(err, "Forward of a Join %v", l.Name()))
+// ByName returns a Term by name. The terms are searched in order.
+func (l *Join) ByName(name string) Term {
+	for _, t := range l.terms {
+		if t != nil && t.Name() == name {
+			return t
+		}
+	}
+	for _, t := range l.terms {
+		if bn, ok := t.(ByNamer); ok {
+			if found := bn.ByName(name); found != nil {
+				return found
+			}
+		}
 	}
+	return nil
+}
 
-	if l.retVal != nil {
-		return l.retVal
+func (l *Join) Graph() *G.ExprGraph {
+	for _, t := range l.terms {
+		if gp, ok := t.(Grapher); ok {
+			return gp.Graph()
+		}
 	}
-	input := a.Node()
+	return nil
+}
 
-	x, err := Apply(l.a, input)
-	if err != nil {
-		return G.Err(errors.Wrapf(err, "Forward of Join %v - Applying %v to %v failed", l.Name(), l.a, input.Name()))
+func (l *Join) Runners() []Runner {
+	var retVal []Runner
+	for _, t := range l.terms {
+		if f, ok := t.(Runnerser); ok {
+			retVal = append(retVal, f.Runners()...)
+		}
 	}
-	xn, ok := x.(*G.Node)
-	if !ok {
-		return G.Err(errors.Errorf("Expected the result of applying %v to %v to return a *Node. Got %v of %T instead", l.a, input.Name(), x, x))
+	return retVal
+}
+
+func (l *Join) FLOPs() (retVal int) {
+	for _, t := range l.terms {
+		if f, ok := t.(flopser); ok {
+			retVal += f.FLOPs()
+		}
 	}
+	return
+}
 
-	y, err := Apply(l.b, input)
-	if err != nil {
-		return G.Err(errors.Wrapf(err, "Forward of Join %v - Applying %v to %v failed", l.Name(), l.b, input.Name()))
+// Reinit re-initializes the weights of every term in place.
+func (l *Join) Reinit() error {
+	for _, t := range l.terms {
+		if err := reinitTerm(t); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// Describe will describe a join. All the branches are applied to the same input.
+func (l *Join) Describe() (*onnx.GraphProto, error) {
+	if len(l.terms) == 0 {
+		return nil, errors.Errorf("Unable to describe %v without any terms", l.Name())
 	}
-	yn, ok := y.(*G.Node)
-	if !ok {
-		return G.Err(errors.Errorf("Expected the result of applying %v to %v to return a *Node. Got %v of %T instead", l.a, input.Name(), y, y))
+	branches := make([]*onnx.GraphProto, len(l.terms))
+	for i, t := range l.terms {
+		desc, err := describeTerm(t)
+		if err != nil {
+			return nil, errors.Wrapf(err, "Unable to describe %v", t.Name())
+		}
+		branches[i] = desc
 	}
+	if len(branches) == 1 && l.op != concatOp {
+		return branches[0], nil
+	}
+	switch l.op {
+	case addOp, elMulOp, subOp:
+		// the ONNX ops are binary, so the branches are folded from the left
+		retVal := fanout("", l.op.String(), branches[0], branches[1])
+		for _, b := range branches[2:] {
+			retVal = fanout("", l.op.String(), retVal, b)
+		}
+		return retVal, nil
+	case maxOp, meanOp:
+		return fanout("", l.op.String(), branches...), nil
+	case concatOp:
+		retVal := fanout("", "Concat", branches...)
+		last := retVal.Node[len(retVal.Node)-1]
+		last.Attribute = append(last.Attribute, attrInt("axis", l.axis))
+		return retVal, nil
+	}
+	return nil, errors.Errorf("Unable to describe Join with op %v", l.op)
+}
 
-	// perform the op
+// Fwd runs the equation forwards.
+//
+// As with a Composition, every application applies the terms to the given input anew, reusing the weights of the constructed layers.
+func (l *Join) Fwd(a G.Input) (output G.Result) { return l.fwd(a, Apply) }
 
+// fwd runs the equation forwards, applying the terms with the given function.
+func (l *Join) fwd(a G.Input, apply applyFn) G.Result {
+	if err := G.CheckOne(a); err != nil {
+		return G.Err(errors.Wrapf(err, "Forward of a Join %v", l.Name()))
+	}
+	if len(l.terms) == 0 {
+		return G.Err(errors.Errorf("Forward of Join %v - a Join needs at least one term", l.Name()))
+	}
+	input := a.Node()
+
+	xs := make(G.Nodes, len(l.terms))
+	for i, t := range l.terms {
+		x, err := apply(t, input)
+		if err != nil {
+			return G.Err(errors.Wrapf(err, "Forward of Join %v - Applying %v to %v failed", l.Name(), t, input.Name()))
+		}
+		if tg, ok := x.(tag); ok {
+			l.terms[i], _ = tg.a.(Layer)
+			x = tg.b
+		}
+		xn, ok := x.(*G.Node)
+		if !ok {
+			return G.Err(errors.Errorf("Expected the result of applying %v to %v to return a *Node. Got %v of %T instead", t, input.Name(), x, x))
+		}
+		xs[i] = xn
+	}
+	return l.join(xs)
+}
+
+// join merges the results of the terms with the op of the join.
+func (l *Join) join(xs G.Nodes) G.Result {
 	switch l.op {
 	case addOp:
-		return G.LiftResult(G.Add(xn, yn))
+		return G.LiftResult(foldNodes(G.Add, xs))
 	case elMulOp:
-		return G.LiftResult(G.HadamardProd(xn, yn))
+		return G.LiftResult(foldNodes(G.HadamardProd, xs))
+	case subOp:
+		return G.LiftResult(foldNodes(G.Sub, xs))
+	case maxOp:
+		return G.LiftResult(reduceStacked(G.Max, xs))
+	case meanOp:
+		return G.LiftResult(reduceStacked(G.Mean, xs))
+	case concatOp:
+		if len(xs) == 1 {
+			return xs[0]
+		}
+		return G.LiftResult(G.Concat(l.axis, xs...))
+	case mergeOp:
+		if l.merge == nil {
+			return G.Err(errors.Errorf("Forward of Join %v - no merge function was given", l.Name()))
+		}
+		return G.LiftResult(l.merge(xs))
+	}
+	return G.Err(errors.Errorf("Forward of Join %v - unknown op %d", l.Name(), l.op))
+}
+
+// foldNodes folds the nodes from the left with the given binary function.
+func foldNodes(fn func(a, b *G.Node) (*G.Node, error), xs G.Nodes) (retVal *G.Node, err error) {
+	retVal = xs[0]
+	for _, x := range xs[1:] {
+		if retVal, err = fn(retVal, x); err != nil {
+			return nil, err
+		}
+	}
+	return retVal, nil
+}
+
+// reduceStacked stacks the nodes along a new leading axis, and reduces the stack along that axis with the given function.
+func reduceStacked(fn func(x *G.Node, along ...int) (*G.Node, error), xs G.Nodes) (*G.Node, error) {
+	if len(xs) == 1 {
+		return xs[0], nil
+	}
+	stacked := make(G.Nodes, len(xs))
+	for i, x := range xs {
+		// every result gets a leading axis of size 1, so that the results can be concatenated into a stack
+		shp := append(tensor.Shape{1}, x.Shape()...)
+		var err error
+		if stacked[i], err = G.Reshape(x, shp); err != nil {
+			return nil, err
+		}
+	}
+	s, err := G.Concat(0, stacked...)
+	if err != nil {
+		return nil, err
 	}
-	panic("Unreachable")
+	return fn(s, 0)
 }
@@ -0,0 +1,165 @@
+package golgi
+
+import (
+	"bytes"
+	"math"
+	"testing"
+
+	"github.com/stretchr/testify/require"
+	"gorgonia.org/gorgonia"
+	"gorgonia.org/tensor"
+)
+
+func TestJoin(t *testing.T) {
+	xT := tensor.New(tensor.WithShape(2, 3), tensor.WithBacking([]float64{0.1, -0.2, 0.3, -0.4, 0.5, -0.6}))
+	merge := func(xs gorgonia.Nodes) (*gorgonia.Node, error) {
+		ab, err := gorgonia.Add(xs[0], xs[1])
+		if err != nil {
+			return nil, err
+		}
+		return gorgonia.HadamardProd(ab, xs[2])
+	}
+	cases := []struct {
+		name     string
+		join     func(a, b, c Term) *Join
+		shape    tensor.Shape
+		expected func(a, b, c float64) float64
+	}{
+		{"Add", func(a, b, c Term) *Join { return Add(a, b) }, tensor.Shape{2, 3}, func(a, b, c float64) float64 { return a + b }},
+		{"Sub", func(a, b, c Term) *Join { return Sub(a, b, c) }, tensor.Shape{2, 3}, func(a, b, c float64) float64 { return a - b - c }},
+		{"Max", func(a, b, c Term) *Join { return Max(a, b, c) }, tensor.Shape{2, 3}, func(a, b, c float64) float64 { return math.Max(math.Max(a, b), c) }},
+		{"Mean", func(a, b, c Term) *Join { return Mean(a, b, c) }, tensor.Shape{2, 3}, func(a, b, c float64) float64 { return (a + b + c) / 3 }},
+		{"Merge", func(a, b, c Term) *Join { return Merge(merge, a, b, c) }, tensor.Shape{2, 3}, func(a, b, c float64) float64 { return (a + b) * c }},
+	}
+	for _, tc := range cases {
+		t.Run(tc.name, func(t *testing.T) {
+			c := require.New(t)
+			g := gorgonia.NewGraph()
+			x := gorgonia.NewMatrix(g, tensor.Float64, gorgonia.WithName("x"), gorgonia.WithShape(2, 3), gorgonia.WithValue(xT.Clone()))
+
+			// the last term is a thunk, which is constructed when the join is applied
+			a, err := ConsFC(x, WithName("a"), WithSize(3), ComputeFLOPs(true))
+			c.NoError(err)
+			b, err := ConsFC(x, WithName("b"), WithSize(3), ComputeFLOPs(true))
+			c.NoError(err)
+			j := tc.join(a, b, L(ConsFC, WithName("c"), WithSize(3), ComputeFLOPs(true)))
+			out := j.Fwd(x)
+			c.NoError(gorgonia.CheckOne(out))
+			c.Equal(tc.shape, out.Node().Shape())
+
+			layers := []*FC{a.(*FC), b.(*FC)}
+			if tc.name != "Add" {
+				third, ok := j.ByName("c").(*FC)
+				c.True(ok, "the thunk is replaced by the constructed layer")
+				layers = append(layers, third)
+			}
+			c.Len(j.Model(), 2*len(layers))
+			c.Equal(len(layers)*a.(*FC).FLOPs(), j.FLOPs())
+
+			// each branch is applied again to read its result
+			var outs []*gorgonia.Node
+			for _, l := range layers {
+				o := l.Fwd(x)
+				c.NoError(gorgonia.CheckOne(o))
+				outs = append(outs, o.Node())
+			}
+			vals := make([]gorgonia.Value, len(outs))
+			for i, o := range outs {
+				gorgonia.Read(o, &vals[i])
+			}
+			cost, err := gorgonia.Sum(out.Node())
+			c.NoError(err)
+			_, err = gorgonia.Grad(cost, j.Model()...)
+			c.NoError(err)
+			got := runValue(t, g, out.Node())
+
+			branch := func(i, at int) float64 {
+				if i >= len(vals) {
+					return 0
+				}
+				return vals[i].Data().([]float64)[at]
+			}
+			expected := make([]float64, len(got))
+			for i := range expected {
+				expected[i] = tc.expected(branch(0, i), branch(1, i), branch(2, i))
+			}
+			c.InDeltaSlice(expected, got, 1e-10)
+		})
+	}
+}
+
+func TestJoin_Concat(t *testing.T) {
+	c := require.New(t)
+	xT := tensor.New(tensor.WithShape(2, 3), tensor.WithBacking([]float64{0.1, -0.2, 0.3, -0.4, 0.5, -0.6}))
+	g := gorgonia.NewGraph()
+	x := gorgonia.NewMatrix(g, tensor.Float64, gorgonia.WithName("x"), gorgonia.WithShape(2, 3), gorgonia.WithValue(xT.Clone()))
+
+	// a multi-tower model: a tower of two layers, a single layer, and the input itself
+	j := Concat(1,
+		Compose(L(ConsFC, WithName("t0"), WithSize(4), WithActivation(gorgonia.Tanh)), L(ConsFC, WithName("t1"), WithSize(2))),
+		L(ConsFC, WithName("u"), WithSize(5)),
+		x,
+	)
+	out := j.Fwd(x)
+	c.NoError(gorgonia.CheckOne(out))
+	c.Equal(tensor.Shape{2, 10}, out.Node().Shape())
+	c.Len(j.Model(), 6)
+	c.NotNil(j.ByName("t1"))
+	c.NotNil(j.ByName("u"))
+	c.Nil(j.ByName("v"))
+	got := runValue(t, g, out.Node())
+	c.Equal(xT.Data().([]float64)[:3], got[7:10])
+
+	desc, err := j.Describe()
+	c.NoError(err)
+	c.Equal([]string{"Gemm", "Tanh", "Gemm", "Gemm", "Identity", "Concat"}, opTypes(desc))
+	checkWellFormed(t, desc)
+	c.Equal(int64(1), desc.Node[len(desc.Node)-1].Attr("axis").I)
+	c.Equal(desc.Input[0].Name, desc.Node[len(desc.Node)-2].Input[0])
+
+	var buf bytes.Buffer
+	c.NoError(SaveCheckpoint(&buf, j))
+	g2 := gorgonia.NewGraph()
+	x2 := gorgonia.NewMatrix(g2, tensor.Float64, gorgonia.WithName("x"), gorgonia.WithShape(2, 3), gorgonia.WithValue(xT.Clone()))
+	loaded, err := LoadCheckpoint(g2, &buf)
+	c.NoError(err)
+	out2 := loaded.Fwd(x2)
+	c.NoError(gorgonia.CheckOne(out2))
+	c.InDeltaSlice(got, runValue(t, g2, out2.Node()), 1e-10)
+
+	_, err = Merge(nil, x).ToData()
+	c.Error(err)
+	c.Error(gorgonia.CheckOne(Merge(nil, x).Fwd(x)))
+}
+
+func TestJoin_Reapply(t *testing.T) {
+	c := require.New(t)
+	g := gorgonia.NewGraph()
+	newInput := func(name string, backing []float64) *gorgonia.Node {
+		return gorgonia.NewMatrix(g, tensor.Float64, gorgonia.WithName(name), gorgonia.WithShape(2, 3), gorgonia.WithValue(tensor.New(tensor.WithShape(2, 3), tensor.WithBacking(backing))))
+	}
+	train := newInput("train", []float64{0.1, -0.2, 0.3, -0.4, 0.5, -0.6})
+	test := newInput("test", []float64{0.6, 0.5, -0.4, 0.3, -0.2, 0.1})
+	same := newInput("same", []float64{0.1, -0.2, 0.3, -0.4, 0.5, -0.6})
+
+	j := Concat(1, L(ConsFC, WithName("a"), WithSize(4), WithActivation(gorgonia.Tanh)), L(ConsFC, WithName("b"), WithSize(2)), I{})
+	out := j.Fwd(train)
+	c.NoError(gorgonia.CheckOne(out))
+	model := j.Model()
+	c.Len(model, 4)
+
+	// the later applications build new forward paths over the same weights
+	outTest := j.Fwd(test)
+	c.NoError(gorgonia.CheckOne(outTest))
+	outSame := j.Fwd(same)
+	c.NoError(gorgonia.CheckOne(outSame))
+	c.False(out.Node() == outTest.Node())
+	c.Equal(model, j.Model())
+
+	var v, vTest gorgonia.Value
+	gorgonia.Read(out.Node(), &v)
+	gorgonia.Read(outTest.Node(), &vTest)
+	got := runValue(t, g, outSame.Node())
+	c.Equal(v.Data(), got)
+	c.NotEqual(v.Data(), vTest.Data())
+}
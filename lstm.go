@@ -3,10 +3,59 @@ package golgi
 import (
 	"github.com/chewxy/hm"
 	"github.com/pkg/errors"
+	"gorgonia.org/golgi/onnx"
 	G "gorgonia.org/gorgonia"
 	"gorgonia.org/tensor"
 )
 
+var (
+	_ computeFLOPsSetter = &LSTM{}
+)
+
+// SeqLayout is the layout of a sequence that is fed into a recurrent layer.
+type SeqLayout int
+
+const (
+	// TimeMajor sequences are of shape (T, N, F)
+	TimeMajor SeqLayout = iota
+	// BatchMajor sequences are of shape (N, T, F)
+	BatchMajor
+)
+
+// AsSequence is a ConsOpt for LSTMs only. It makes the LSTM unroll over a whole sequence of the given layout, instead of
+// computing a single time step.
+func AsSequence(layout SeqLayout) ConsOpt {
+	return func(layer Layer) (Layer, error) {
+		switch l := layer.(type) {
+		case *LSTM:
+			if layout != TimeMajor && layout != BatchMajor {
+				return nil, errors.Errorf("Unknown sequence layout %d", layout)
+			}
+			l.sequence = true
+			l.layout = layout
+			return l, nil
+		case Pass:
+			return layer, nil
+		}
+		return nil, errors.Errorf("AsSequence Unhandled Layer type: %T", layer)
+	}
+}
+
+// ReturnSequences is a ConsOpt for LSTMs constructed AsSequence. If all is true, the Node of the result is the hidden state
+// of every time step, in the same layout as the input. Otherwise it is the final hidden state.
+func ReturnSequences(all bool) ConsOpt {
+	return func(layer Layer) (Layer, error) {
+		switch l := layer.(type) {
+		case *LSTM:
+			l.returnSequences = all
+			return l, nil
+		case Pass:
+			return layer, nil
+		}
+		return nil, errors.Errorf("ReturnSequences Unhandled Layer type: %T", layer)
+	}
+}
+
 // LSTM represents an LSTM RNN
 type LSTM struct {
 	name string
@@ -18,10 +67,19 @@ type LSTM struct {
 	output lstmGate
 	cell   lstmGate
 
-	size        int // for construction
+	size        int       // for construction
+	initW       G.InitWFn // for construction. nil means the default initialization
 	initialized bool
 	dummyCell   *G.Node
 	dummyHidden *G.Node
+
+	// sequence mode
+	sequence        bool
+	layout          SeqLayout
+	returnSequences bool
+
+	computeFLOPs bool
+	flops        int
 }
 
 // FromLSTMData will initialize a new LSTM model
@@ -41,12 +99,6 @@ func ConsLSTM(in G.Input, opts ...ConsOpt) (retVal Layer, err error) {
 		return nil, errors.Errorf("LSTM expects a *Node. Got input %v of  %T instead", in, in)
 	}
 
-	// TODO: Ensure shape is being set correctly
-	inshape := x.Shape()
-	if inshape.Dims() > 2 || inshape.Dims() == 0 {
-		return nil, errors.Errorf("Expected shape is either a vector or a matrix")
-	}
-
 	l := &LSTM{}
 	for _, opt := range opts {
 		var (
@@ -64,6 +116,14 @@ func ConsLSTM(in G.Input, opts ...ConsOpt) (retVal Layer, err error) {
 		}
 	}
 
+	inshape := x.Shape()
+	switch {
+	case l.sequence && inshape.Dims() != 3:
+		return nil, errors.Errorf("Expected the input of a sequence LSTM to be a 3-tensor. Got %v instead", inshape)
+	case !l.sequence && inshape.Dims() != 2:
+		return nil, errors.Errorf("Expected the input of a LSTM to be a matrix. Got %v instead", inshape)
+	}
+
 	if err = l.Init(x); err != nil {
 		return
 	}
@@ -73,6 +133,16 @@ func ConsLSTM(in G.Input, opts ...ConsOpt) (retVal Layer, err error) {
 	return retVal, nil
 }
 
+// Reinit re-initializes the weights of every gate in place.
+func (l *LSTM) Reinit() error {
+	for _, gate := range []*lstmGate{&l.input, &l.forget, &l.output, &l.cell} {
+		if err := gate.reinit(l.initW); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
 // Model will return the gorgonia.Nodes associated with this LSTM
 func (l *LSTM) Model() G.Nodes {
 	return G.Nodes{
@@ -91,13 +161,21 @@ func (l *LSTM) Model() G.Nodes {
 // The lstmIO type is not exported. Instead, to query the *Node of the gorgonia.Input or gorgonia.Result,
 // use the Nodes() method.
 //
-// The Result will always be organized as such: [previousHidden, previousCell]
+// The Result will always be organized as such: [x, hidden, cell]
 //
 // e.g.
-// 	out := lstm.Fwd(x)
-// 	outNodes := out.Nodes()
-//	prevHidden := outNodes[0]
-//	prevCell := outNodes[1]
+//
+//	out := lstm.Fwd(x)
+//	outNodes := out.Nodes()
+//	prevHidden := outNodes[1]
+//	prevCell := outNodes[2]
+//
+// The Node() of the Result is the hidden state, so that a LSTM may be composed with other layers.
+//
+// The input may either be a single *Node, in which case the initial states are zeroes, or a tuple of [x, prevHidden, prevCell].
+// If the LSTM was constructed AsSequence, x is a whole sequence, and is unrolled over all its time steps. The hidden and cell states
+// of the Result are then the states after the final time step, and if the LSTM was constructed with ReturnSequences(true),
+// the Node() of the Result is the hidden state of every time step.
 func (l *LSTM) Fwd(x G.Input) G.Result {
 	var (
 		inputVector *G.Node
@@ -111,74 +189,162 @@ func (l *LSTM) Fwd(x G.Input) G.Result {
 		return G.Err(err)
 	}
 
+	// the result of a previous layer, such as another LSTM, carries its states in Nodes(). Only its output is the input.
 	ns := x.Nodes()
+	if n := x.Node(); n != nil {
+		ns = G.Nodes{n}
+	}
 	switch len(ns) {
 	case 0:
 		err = errors.New("input value does not contain any nodes")
 		return G.Err(err)
 	case 1:
 		inputVector = ns[0]
-		prevHidden = l.dummyHidden
-		prevCell = l.dummyCell
-	case 2:
-		err = errors.Errorf("invalid number of nodes, expected %d and received %d", 3, 2)
-		return G.Err(err)
 	case 3:
 		inputVector = ns[0]
 		prevHidden = ns[1]
 		prevCell = ns[2]
+	default:
+		err = errors.Errorf("invalid number of nodes, expected %d and received %d", 3, len(ns))
+		return G.Err(err)
+	}
+
+	if !l.initialized {
+		if err = l.Init(inputVector); err != nil {
+			return G.Err(errors.Wrapf(err, "Lazy initialization of LSTM %v", l.name))
+		}
+	}
+	if prevHidden == nil {
+		prevHidden = l.dummyHidden
+	}
+	if prevCell == nil {
+		prevCell = l.dummyCell
+	}
+	if l.computeFLOPs {
+		l.flops = l.doComputeFLOPs(inputVector.Shape())
+	}
+
+	if l.sequence {
+		return l.unroll(inputVector, prevHidden, prevCell)
+	}
+
+	hidden, cell, err := l.step(inputVector, prevHidden, prevCell)
+	if err != nil {
+		return G.Err(err)
 	}
+	result := makeLSTMIO(inputVector, hidden, cell, nil)
+	result.out = hidden
+	return &result
+}
 
+// step computes a single time step.
+func (l *LSTM) step(inputVector, prevHidden, prevCell *G.Node) (hidden, cell *G.Node, err error) {
 	var inputGate *G.Node
 	if inputGate, err = l.input.activate(inputVector, prevHidden); err != nil {
-		return G.Err(err)
+		return nil, nil, err
 	}
 
 	var forgetGate *G.Node
 	if forgetGate, err = l.forget.activate(inputVector, prevHidden); err != nil {
-		return G.Err(err)
+		return nil, nil, err
 	}
 
 	var outputGate *G.Node
 	if outputGate, err = l.output.activate(inputVector, prevHidden); err != nil {
-		return G.Err(err)
+		return nil, nil, err
 	}
 
 	var cellWrite *G.Node
 	if cellWrite, err = l.cell.activate(inputVector, prevHidden); err != nil {
-		return G.Err(err)
+		return nil, nil, err
 	}
 
 	// Perform cell activations
 	var retain *G.Node
 	if retain, err = BroadcastHadamardProd(forgetGate, prevCell, nil, []byte{0}); err != nil {
-		return G.Err(err)
+		return nil, nil, err
 	}
 
 	var write *G.Node
 	if write, err = BroadcastHadamardProd(inputGate, cellWrite, nil, []byte{0}); err != nil {
-		return G.Err(err)
+		return nil, nil, err
 	}
 
-	var cell *G.Node
 	if cell, err = G.Add(retain, write); err != nil {
-		return G.Err(err)
+		return nil, nil, err
 	}
 
 	var tahnCell *G.Node
 	if tahnCell, err = G.Tanh(cell); err != nil {
-		return G.Err(err)
+		return nil, nil, err
 	}
 
-	var hidden *G.Node
 	if hidden, err = BroadcastHadamardProd(outputGate, tahnCell, nil, []byte{0}); err != nil {
-		return G.Err(err)
+		return nil, nil, err
 	}
+	return hidden, cell, nil
+}
 
-	result := makeLSTMIO(inputVector, hidden, cell, nil)
+// unroll runs the LSTM over every time step of a sequence. The gate weights are shared by all the time steps.
+func (l *LSTM) unroll(seq, hidden, cell *G.Node) G.Result {
+	shp := seq.Shape()
+	if shp.Dims() != 3 {
+		return G.Err(errors.Errorf("Expected the sequence fed into LSTM %v to be a 3-tensor. Got %v instead", l.name, shp))
+	}
+	timeAxis, _ := l.timeAxis()
+	steps := shp[timeAxis]
+	if steps == 0 {
+		return G.Err(errors.Errorf("LSTM %v was fed an empty sequence", l.name))
+	}
+
+	var err error
+	var hiddens G.Nodes
+	for t := 0; t < steps; t++ {
+		var x *G.Node
+		if l.layout == BatchMajor {
+			x, err = G.Slice(seq, nil, G.S(t))
+		} else {
+			x, err = G.Slice(seq, G.S(t))
+		}
+		if err != nil {
+			return G.Err(errors.Wrapf(err, "Unable to slice time step %d of the sequence", t))
+		}
+		if hidden, cell, err = l.step(x, hidden, cell); err != nil {
+			return G.Err(errors.Wrapf(err, "Time step %d of LSTM %v", t, l.name))
+		}
+		if l.returnSequences {
+			// (N, H) → (1, N, H) or (N, 1, H)
+			hshp := hidden.Shape()
+			expanded := tensor.Shape{1, hshp[0], hshp[1]}
+			if l.layout == BatchMajor {
+				expanded = tensor.Shape{hshp[0], 1, hshp[1]}
+			}
+			var h *G.Node
+			if h, err = G.Reshape(hidden, expanded); err != nil {
+				return G.Err(errors.Wrapf(err, "Unable to reshape the hidden state of time step %d", t))
+			}
+			hiddens = append(hiddens, h)
+		}
+	}
+
+	result := makeLSTMIO(seq, hidden, cell, nil)
+	result.out = hidden
+	if l.returnSequences {
+		if result.out, err = G.Concat(timeAxis, hiddens...); err != nil {
+			return G.Err(errors.Wrap(err, "Unable to concatenate the hidden states"))
+		}
+	}
 	return &result
 }
 
+// timeAxis returns the axis of time of the sequences. It returns false if the LSTM is not constructed AsSequence.
+func (l *LSTM) timeAxis() (int, bool) {
+	if l.layout == BatchMajor {
+		return 1, l.sequence
+	}
+	return 0, l.sequence
+}
+
 // Type will return the hm.Type of the LSTM
 func (l *LSTM) Type() hm.Type { return hm.NewFnType(hm.TypeVariable('a'), hm.TypeVariable('b')) }
 
@@ -188,8 +354,155 @@ func (l *LSTM) Shape() tensor.Shape { return l.input.b.Shape() }
 // Name will return the name of the LSTM
 func (l *LSTM) Name() string { return l.name }
 
-// Describe will describe a LSTM
-func (l *LSTM) Describe() { panic("not implemented") }
+// Describe will describe a LSTM.
+//
+// As the *LSTM computes a single time step, it is described as an ONNX LSTM over a sequence of length 1, starting from zero states.
+// Only the hidden state is returned.
+func (l *LSTM) Describe() (*onnx.GraphProto, error) {
+	if l.input.wx == nil {
+		return nil, errors.Errorf("Unable to describe LSTM %v. It has not been initialized", l.name)
+	}
+	f := newFragment(l.name, "LSTM", l.input.wx.Dtype())
+
+	// ONNX orders the gates as input, output, forget, cell.
+	w, r, b, err := describeGates(f, &l.input, &l.output, &l.forget, &l.cell)
+	if err != nil {
+		return nil, err
+	}
+
+	if l.sequence {
+		return l.describeSequence(f, w, r, b)
+	}
+
+	axes := f.ints(f.prefix+"_axes", 0)
+	seq := f.apply("Unsqueeze", []string{axes})
+	hidden := f.prefix + "_Y_h"
+	f.g.Node = append(f.g.Node, &onnx.NodeProto{
+		Name:      f.prefix + "_LSTM",
+		OpType:    "LSTM",
+		Input:     []string{seq, w, r, b},
+		Output:    []string{"", hidden},
+		Attribute: []*onnx.AttributeProto{attrInt("hidden_size", l.size)},
+	})
+	f.last = hidden
+	f.apply("Squeeze", []string{axes})
+	return f.graph(), nil
+}
+
+// describeSequence describes a LSTM constructed AsSequence as an ONNX LSTM over the whole sequence.
+// The direction axis that ONNX adds to the outputs is squeezed away.
+func (l *LSTM) describeSequence(f *fragment, w, r, b string) (*onnx.GraphProto, error) {
+	attrs := []*onnx.AttributeProto{attrInt("hidden_size", l.size)}
+	// Y is (T, 1, N, H) and Y_h is (1, N, H). With layout = 1, Y is (N, T, 1, H) and Y_h is (N, 1, H).
+	yAxis, hAxis := 1, 0
+	if l.layout == BatchMajor {
+		attrs = append(attrs, attrInt("layout", 1))
+		yAxis, hAxis = 2, 1
+	}
+
+	y, hidden := f.prefix+"_Y", f.prefix+"_Y_h"
+	out, axis := hidden, hAxis
+	if l.returnSequences {
+		out, axis = y, yAxis
+	}
+	f.g.Node = append(f.g.Node, &onnx.NodeProto{
+		Name:      f.prefix + "_LSTM",
+		OpType:    "LSTM",
+		Input:     []string{f.last, w, r, b},
+		Output:    []string{y, hidden},
+		Attribute: attrs,
+	})
+	f.last = out
+	axes := f.ints(f.prefix+"_axes", axis)
+	f.apply("Squeeze", []string{axes})
+	return f.graph(), nil
+}
+
+// describeGates packs the weights of the gates, in the given order, into the W, R and B initializers of an ONNX recurrent op, and
+// returns their names.
+func describeGates(f *fragment, gates ...*lstmGate) (w, r, b string, err error) {
+	var ws, rs, bs []tensor.Tensor
+	for _, gate := range gates {
+		wx, err := lstmONNXWeight(gate.wx)
+		if err != nil {
+			return "", "", "", err
+		}
+		wh, err := lstmONNXWeight(gate.wh)
+		if err != nil {
+			return "", "", "", err
+		}
+		ws = append(ws, wx)
+		rs = append(rs, wh)
+		bs = append(bs, gate.b.Value().(tensor.Tensor))
+	}
+
+	W, err := tensor.Concat(0, ws[0], ws[1:]...)
+	if err != nil {
+		return "", "", "", errors.Wrap(err, "Unable to concatenate the input weights")
+	}
+	R, err := tensor.Concat(0, rs[0], rs[1:]...)
+	if err != nil {
+		return "", "", "", errors.Wrap(err, "Unable to concatenate the recurrence weights")
+	}
+	Wb, err := tensor.Concat(1, bs[0], bs[1:]...)
+	if err != nil {
+		return "", "", "", errors.Wrap(err, "Unable to concatenate the biases")
+	}
+	// the recurrence biases are all zero: golgi only has one bias per gate
+	Rb := tensor.New(tensor.WithShape(Wb.Shape().Clone()...), tensor.Of(Wb.Dtype()))
+	B, err := tensor.Concat(1, Wb, Rb)
+	if err != nil {
+		return "", "", "", errors.Wrap(err, "Unable to concatenate the biases")
+	}
+	if err = W.Reshape(append([]int{1}, W.Shape()...)...); err != nil {
+		return "", "", "", err
+	}
+	if err = R.Reshape(append([]int{1}, R.Shape()...)...); err != nil {
+		return "", "", "", err
+	}
+
+	if w, err = f.value(f.prefix+"_W", W); err != nil {
+		return "", "", "", err
+	}
+	if r, err = f.value(f.prefix+"_R", R); err != nil {
+		return "", "", "", err
+	}
+	if b, err = f.value(f.prefix+"_B", B); err != nil {
+		return "", "", "", err
+	}
+	return w, r, b, nil
+}
+
+// lstmONNXWeight transposes a (inner, size) weight matrix into the (size, inner) layout ONNX expects.
+func lstmONNXWeight(n *G.Node) (tensor.Tensor, error) {
+	v, ok := n.Value().(tensor.Tensor)
+	if !ok {
+		return nil, errors.Errorf("Expected %v to have a tensor value. Got %T instead", n.Name(), n.Value())
+	}
+	return tensor.Transpose(v)
+}
+
+// SetComputeFLOPs sets whether the FLOPs are computed when the input is forwarded.
+func (l *LSTM) SetComputeFLOPs(toCompute bool) error {
+	l.computeFLOPs = toCompute
+	return nil
+}
+
+// FLOPs returns the FLOPs computed by the last Fwd.
+func (l *LSTM) FLOPs() int { return l.flops }
+
+// doComputeFLOPs computes the rough number of floating point operations of the application to the input. In sequence mode, every
+// time step is counted.
+func (l *LSTM) doComputeFLOPs(input tensor.Shape) int {
+	n, steps := input[0], 1
+	if timeAxis, ok := l.timeAxis(); ok && input.Dims() == 3 {
+		n, steps = input[1-timeAxis], input[timeAxis]
+	}
+	retVal := l.input.flops(n) + l.forget.flops(n) + l.output.flops(n) + l.cell.flops(n)
+	// f⊙c, i⊙ĉ, the addition of both, tanh(c) and o⊙tanh(c)
+	retVal += 5 * n * l.input.wx.Shape()[1]
+	return steps * retVal
+}
 
 // SetName will set the name of a fully connected layer
 func (l *LSTM) SetName(a string) error {
@@ -205,18 +518,22 @@ func (l *LSTM) Init(xs ...*G.Node) (err error) {
 	x := xs[0]
 	g := x.Graph()
 	of := x.Dtype()
-	X := x
-	inner := X.Shape()[1]
+	if x.Dims() < 2 {
+		return errors.Errorf("Expected the input of LSTM %v to be at least a matrix. Got %v instead", l.name, x.Shape())
+	}
+	// the features are always the last axis, whether x is a single time step or a sequence
+	inner := x.Shape()[x.Dims()-1]
 
 	// initialize input gate
-	l.input.init(g, of, inner, l.size, l.name+"_i", G.Sigmoid)
-	l.forget.init(g, of, inner, l.size, l.name+"_f", G.Sigmoid)
-	l.output.init(g, of, inner, l.size, l.name+"_o", G.Sigmoid)
-	l.cell.init(g, of, inner, l.size, l.name+"_c", G.Tanh)
+	l.input.init(g, of, inner, l.size, l.name+"_i", G.Sigmoid, l.initW)
+	l.forget.init(g, of, inner, l.size, l.name+"_f", G.Sigmoid, l.initW)
+	l.output.init(g, of, inner, l.size, l.name+"_o", G.Sigmoid, l.initW)
+	l.cell.init(g, of, inner, l.size, l.name+"_c", G.Tanh, l.initW)
 
 	// initialize dummyPrev and dummyCell
 	l.dummyHidden = G.NewMatrix(g, of, G.WithShape(1, l.size), G.WithName(l.name+"dummyHidden"), G.WithInit(G.Zeroes()))
 	l.dummyCell = G.NewMatrix(g, of, G.WithShape(1, l.size), G.WithName(l.name+"dummySize"), G.WithInit(G.Zeroes()))
+	l.g = g
 	l.initialized = true
 	return nil
 }
@@ -1,43 +1,280 @@
 package golgi
 
 import (
+	proto "github.com/gogo/protobuf/proto"
+	"github.com/pkg/errors"
+	"gorgonia.org/golgi/onnx"
 	G "gorgonia.org/gorgonia"
+	"gorgonia.org/tensor"
 )
 
-// LSTMData represents a basic LSTM layer
+var (
+	_ Data   = &LSTMData{}
+	_ Dataer = &LSTM{}
+)
+
+// LSTMData represents the data of a LSTM.
 type LSTMData struct {
-	inputGateWeight       G.Value
-	inputGateHiddenWeight G.Value
-	inputBias             G.Value
+	Name string
+	Size int
+
+	InputGateWeight       *tensor.Dense
+	InputGateHiddenWeight *tensor.Dense
+	InputBias             *tensor.Dense
+	InputAct              Activation
 
-	forgetGateWeight       G.Value
-	forgetGateHiddenWeight G.Value
-	forgetBias             G.Value
+	ForgetGateWeight       *tensor.Dense
+	ForgetGateHiddenWeight *tensor.Dense
+	ForgetBias             *tensor.Dense
+	ForgetAct              Activation
 
-	outputGateWeight       G.Value
-	outputGateHiddenWeight G.Value
-	outputBias             G.Value
+	OutputGateWeight       *tensor.Dense
+	OutputGateHiddenWeight *tensor.Dense
+	OutputBias             *tensor.Dense
+	OutputAct              Activation
 
-	cellGateWeight       G.Value
-	cellGateHiddenWeight G.Value
-	cellBias             G.Value
+	CellGateWeight       *tensor.Dense
+	CellGateHiddenWeight *tensor.Dense
+	CellBias             *tensor.Dense
+	CellAct              Activation
+
+	Sequence        bool
+	Layout          SeqLayout
+	ReturnSequences bool
 }
 
-func (l *LSTMData) makeGate(g *G.ExprGraph, name string) lstmGate {
-	return makeLSTMGate(
-		G.NodeFromAny(g, l.inputGateWeight, G.WithName("wx"+name)),
-		G.NodeFromAny(g, l.inputGateHiddenWeight, G.WithName("wh_"+name)),
-		G.NodeFromAny(g, l.inputBias, G.WithName("b_"+name)),
+// Data snapshots the weights and configuration of the LSTM. The weights are copied, so further training does not affect the snapshot.
+//
+// Gate activation functions that cannot be serialized are recorded as the default activation of the gate.
+func (l *LSTM) Data() (*LSTMData, error) {
+	if l.input.wx == nil {
+		return nil, errors.Errorf("Unable to take a snapshot of LSTM %v. It has not been initialized", l.name)
+	}
+	retVal := &LSTMData{
+		Name: l.name,
+		Size: l.size,
+
+		InputAct:  gateActivation(l.input.act, Sigmoid),
+		ForgetAct: gateActivation(l.forget.act, Sigmoid),
+		OutputAct: gateActivation(l.output.act, Sigmoid),
+		CellAct:   gateActivation(l.cell.act, Tanh),
+
+		Sequence:        l.sequence,
+		Layout:          l.layout,
+		ReturnSequences: l.returnSequences,
+	}
+	vals := retVal.values()
+	for i, gate := range []*lstmGate{&l.input, &l.forget, &l.output, &l.cell} {
+		for j, n := range []*G.Node{gate.wx, gate.wh, gate.b} {
+			var err error
+			if *vals[i*3+j], err = snapshot(n); err != nil {
+				return nil, err
+			}
+		}
+	}
+	if retVal.Size == 0 && retVal.InputBias != nil {
+		shp := retVal.InputBias.Shape()
+		retVal.Size = shp[len(shp)-1]
+	}
+	return retVal, nil
+}
+
+// ToData snapshots the weights and configuration of the LSTM.
+func (l *LSTM) ToData() (Data, error) {
+	retVal, err := l.Data()
+	if err != nil {
+		return nil, err
+	}
+	for i, gate := range []*lstmGate{&l.input, &l.forget, &l.output, &l.cell} {
+		if _, err := activationOf(gate.act); err != nil {
+			return nil, errors.Wrapf(err, "ToData of the %v gate of LSTM %v", lstmGateNames[i], l.name)
+		}
+	}
+	return retVal, nil
+}
+
+func (l *LSTMData) makeGate(g *G.ExprGraph, name string, wx, wh, b *tensor.Dense, act Activation) lstmGate {
+	retVal := makeLSTMGate(
+		weightFromData(g, wx, name+"_wx"),
+		weightFromData(g, wh, name+"_wh"),
+		weightFromData(g, b, name+"_b"),
 	)
+	retVal.act = ActivationMap(act)
+	return retVal
 }
 
+// Make creates a *LSTM in the given graph. If name is empty, the name of the snapshotted layer is used.
+//
+// The weights are named the same way as a LSTM that is initialized by ConsLSTM.
 func (l *LSTMData) Make(g *G.ExprGraph, name string) (Layer, error) {
+	if err := l.check(); err != nil {
+		return nil, err
+	}
+	if name == "" {
+		name = l.Name
+	}
 	var retVal LSTM
 	retVal.g = g
 	retVal.name = name
-	retVal.input = l.makeGate(g, "_input_"+name)
-	retVal.forget = l.makeGate(g, "_forget_"+name)
-	retVal.output = l.makeGate(g, "_output_"+name)
-	retVal.cell = l.makeGate(g, "_cell_"+name)
+	retVal.size = l.Size
+	retVal.sequence = l.Sequence
+	retVal.layout = l.Layout
+	retVal.returnSequences = l.ReturnSequences
+	retVal.input = l.makeGate(g, name+"_i", l.InputGateWeight, l.InputGateHiddenWeight, l.InputBias, l.InputAct)
+	retVal.forget = l.makeGate(g, name+"_f", l.ForgetGateWeight, l.ForgetGateHiddenWeight, l.ForgetBias, l.ForgetAct)
+	retVal.output = l.makeGate(g, name+"_o", l.OutputGateWeight, l.OutputGateHiddenWeight, l.OutputBias, l.OutputAct)
+	retVal.cell = l.makeGate(g, name+"_c", l.CellGateWeight, l.CellGateHiddenWeight, l.CellBias, l.CellAct)
+
+	of := retVal.input.wx.Dtype()
+	retVal.dummyHidden = G.NewMatrix(g, of, G.WithShape(1, l.Size), G.WithName(name+"dummyHidden"), G.WithInit(G.Zeroes()))
+	retVal.dummyCell = G.NewMatrix(g, of, G.WithShape(1, l.Size), G.WithName(name+"dummySize"), G.WithInit(G.Zeroes()))
+	retVal.initialized = true
 	return &retVal, nil
 }
+
+// check checks that all the weights are present.
+func (l *LSTMData) check() error {
+	for i, v := range l.values() {
+		if *v == nil {
+			return errors.Errorf("LSTMData %v is missing the %v of the %v gate", l.Name, lstmWeightKinds[i%3], lstmGateNames[i/3])
+		}
+	}
+	if l.Size <= 0 {
+		return errors.Errorf("LSTMData %v has an invalid size %d", l.Name, l.Size)
+	}
+	return nil
+}
+
+// values returns pointers to the weights, ordered by gate (input, forget, output, cell), then by kind (wx, wh, b).
+func (l *LSTMData) values() []**tensor.Dense {
+	return []**tensor.Dense{
+		&l.InputGateWeight, &l.InputGateHiddenWeight, &l.InputBias,
+		&l.ForgetGateWeight, &l.ForgetGateHiddenWeight, &l.ForgetBias,
+		&l.OutputGateWeight, &l.OutputGateHiddenWeight, &l.OutputBias,
+		&l.CellGateWeight, &l.CellGateHiddenWeight, &l.CellBias,
+	}
+}
+
+// acts returns pointers to the gate activations, ordered by gate.
+func (l *LSTMData) acts() []*Activation {
+	return []*Activation{&l.InputAct, &l.ForgetAct, &l.OutputAct, &l.CellAct}
+}
+
+var (
+	lstmGateNames   = [...]string{"input", "forget", "output", "cell"}
+	lstmWeightKinds = [...]string{"input weight", "hidden weight", "bias"}
+)
+
+// Marshal encodes the LSTMData as a protobuf message. The weights are encoded as ONNX TensorProtos.
+func (l *LSTMData) Marshal() ([]byte, error) {
+	if err := l.check(); err != nil {
+		return nil, err
+	}
+	msg := &lstmDataProto{
+		Name:            l.Name,
+		Size:            int64(l.Size),
+		Sequence:        l.Sequence,
+		Layout:          int64(l.Layout),
+		ReturnSequences: l.ReturnSequences,
+	}
+	vals := l.values()
+	for i, act := range l.acts() {
+		name, err := act.MarshalText()
+		if err != nil {
+			return nil, err
+		}
+		gate := &lstmGateProto{Act: string(name)}
+		if gate.Wx, err = valueToTensorProto("wx", *vals[i*3]); err != nil {
+			return nil, err
+		}
+		if gate.Wh, err = valueToTensorProto("wh", *vals[i*3+1]); err != nil {
+			return nil, err
+		}
+		if gate.B, err = valueToTensorProto("b", *vals[i*3+2]); err != nil {
+			return nil, err
+		}
+		msg.Gates = append(msg.Gates, gate)
+	}
+	return proto.Marshal(msg)
+}
+
+// Unmarshal decodes a protobuf message written by Marshal.
+func (l *LSTMData) Unmarshal(buf []byte) error {
+	var msg lstmDataProto
+	if err := proto.Unmarshal(buf, &msg); err != nil {
+		return errors.Wrap(err, "Unable to unmarshal LSTMData")
+	}
+	if len(msg.Gates) != len(lstmGateNames) {
+		return errors.Errorf("Expected %d gates in LSTMData. Got %d", len(lstmGateNames), len(msg.Gates))
+	}
+	var retVal LSTMData
+	retVal.Name = msg.Name
+	retVal.Size = int(msg.Size)
+	retVal.Sequence = msg.Sequence
+	retVal.Layout = SeqLayout(msg.Layout)
+	retVal.ReturnSequences = msg.ReturnSequences
+	vals := retVal.values()
+	acts := retVal.acts()
+	for i, gate := range msg.Gates {
+		for j, t := range []*onnx.TensorProto{gate.Wx, gate.Wh, gate.B} {
+			if t == nil {
+				return errors.Errorf("LSTMData %v is missing the %v of the %v gate", msg.Name, lstmWeightKinds[j], lstmGateNames[i])
+			}
+			v, err := tensorProtoToValue(t)
+			if err != nil {
+				return err
+			}
+			*vals[i*3+j] = v
+		}
+		if err := acts[i].UnmarshalText([]byte(gate.Act)); err != nil {
+			return err
+		}
+	}
+	*l = retVal
+	return nil
+}
+
+// GobEncode implements gob.GobEncoder. The protobuf encoding is used.
+func (l *LSTMData) GobEncode() ([]byte, error) { return l.Marshal() }
+
+// GobDecode implements gob.GobDecoder.
+func (l *LSTMData) GobDecode(buf []byte) error { return l.Unmarshal(buf) }
+
+// lstmDataProto is the protobuf message of a LSTMData.
+type lstmDataProto struct {
+	Name  string           `protobuf:"bytes,1,opt,name=name,proto3" json:"name,omitempty"`
+	Size  int64            `protobuf:"varint,2,opt,name=size,proto3" json:"size,omitempty"`
+	Gates []*lstmGateProto `protobuf:"bytes,3,rep,name=gates,proto3" json:"gates,omitempty"`
+
+	Sequence        bool  `protobuf:"varint,4,opt,name=sequence,proto3" json:"sequence,omitempty"`
+	Layout          int64 `protobuf:"varint,5,opt,name=layout,proto3" json:"layout,omitempty"`
+	ReturnSequences bool  `protobuf:"varint,6,opt,name=return_sequences,json=returnSequences,proto3" json:"return_sequences,omitempty"`
+}
+
+func (m *lstmDataProto) Reset()         { *m = lstmDataProto{} }
+func (m *lstmDataProto) String() string { return proto.CompactTextString(m) }
+func (*lstmDataProto) ProtoMessage()    {}
+
+// lstmGateProto is the protobuf message of a single gate of a LSTMData.
+type lstmGateProto struct {
+	Wx  *onnx.TensorProto `protobuf:"bytes,1,opt,name=wx,proto3" json:"wx,omitempty"`
+	Wh  *onnx.TensorProto `protobuf:"bytes,2,opt,name=wh,proto3" json:"wh,omitempty"`
+	B   *onnx.TensorProto `protobuf:"bytes,3,opt,name=b,proto3" json:"b,omitempty"`
+	Act string            `protobuf:"bytes,4,opt,name=act,proto3" json:"act,omitempty"`
+}
+
+func (m *lstmGateProto) Reset()         { *m = lstmGateProto{} }
+func (m *lstmGateProto) String() string { return proto.CompactTextString(m) }
+func (*lstmGateProto) ProtoMessage()    {}
+
+// gateActivation returns the Activation of a gate's activation function, or def if it cannot be serialized.
+func gateActivation(fn ActivationFunction, def Activation) Activation {
+	if fn == nil {
+		return def
+	}
+	act, err := activationOf(fn)
+	if err != nil {
+		return def
+	}
+	return act
+}
@@ -0,0 +1,83 @@
+package golgi
+
+import (
+	"bytes"
+	"encoding/gob"
+	"testing"
+
+	"github.com/stretchr/testify/require"
+	"gorgonia.org/gorgonia"
+	"gorgonia.org/tensor"
+)
+
+func TestLSTMData_RoundTrip(t *testing.T) {
+	c := require.New(t)
+	xT := tensor.New(tensor.WithShape(2, 3), tensor.WithBacking([]float64{0.1, -0.2, 0.3, 0.4, 0.5, -0.6}))
+	yT := tensor.New(tensor.WithShape(2, 4), tensor.WithBacking([]float64{1, 0, 0, 1, 0, 1, 1, 0}))
+
+	g := gorgonia.NewGraph()
+	x := gorgonia.NewMatrix(g, tensor.Float64, gorgonia.WithName("x"), gorgonia.WithShape(2, 3), gorgonia.WithValue(xT))
+	y := gorgonia.NewMatrix(g, tensor.Float64, gorgonia.WithName("y"), gorgonia.WithShape(2, 4), gorgonia.WithValue(yT))
+	l, err := ConsLSTM(x, WithSize(4))
+	c.NoError(err)
+	lstm := l.(*LSTM)
+
+	hidden := lstm.Fwd(x).Nodes()[1]
+	cost := gorgonia.Must(RMS(hidden, y))
+	_, err = gorgonia.Grad(cost, lstm.Model()...)
+	c.NoError(err)
+
+	var out gorgonia.Value
+	gorgonia.Read(hidden, &out)
+	m := gorgonia.NewTapeMachine(g, gorgonia.BindDualValues(lstm.Model()...))
+	defer m.Close()
+	solver := gorgonia.NewVanillaSolver(gorgonia.WithLearnRate(0.1))
+	for i := 0; i < 5; i++ {
+		c.NoError(m.RunAll())
+		c.NoError(solver.Step(gorgonia.NodesToValueGrads(lstm.Model())))
+		m.Reset()
+	}
+	c.NoError(m.RunAll())
+	want := append([]float64(nil), out.Data().([]float64)...)
+
+	// gob
+	snap, err := lstm.Data()
+	c.NoError(err)
+	var buf bytes.Buffer
+	c.NoError(gob.NewEncoder(&buf).Encode(snap))
+	var data LSTMData
+	c.NoError(gob.NewDecoder(&buf).Decode(&data))
+	c.Equal(4, data.Size)
+	c.Equal(Tanh, data.CellAct)
+
+	g2 := gorgonia.NewGraph()
+	x2 := gorgonia.NewMatrix(g2, tensor.Float64, gorgonia.WithName("x"), gorgonia.WithShape(2, 3), gorgonia.WithValue(xT.Clone()))
+	loaded := FromLSTMData(g2, &data, "")
+	for i, w := range lstm.Model() {
+		got := loaded.Model()[i]
+		c.Equal(w.Name(), got.Name())
+		c.Equal(w.Value().Data(), got.Value().Data(), "%v", w.Name())
+	}
+	c.Equal(want, runValue(t, g2, loaded.Fwd(x2).Nodes()[1]))
+
+	// protobuf
+	pb, err := snap.Marshal()
+	c.NoError(err)
+	var data2 LSTMData
+	c.NoError(data2.Unmarshal(pb))
+	g3 := gorgonia.NewGraph()
+	x3 := gorgonia.NewMatrix(g3, tensor.Float64, gorgonia.WithName("x"), gorgonia.WithShape(2, 3), gorgonia.WithValue(xT.Clone()))
+	loaded2, err := data2.Make(g3, "restored")
+	c.NoError(err)
+	c.Equal("restored_f_wx", loaded2.Model()[3].Name())
+	c.Equal(want, runValue(t, g3, loaded2.Fwd(x3).Nodes()[1]))
+}
+
+func TestLSTMData_Missing(t *testing.T) {
+	c := require.New(t)
+	var data LSTMData
+	_, err := data.Make(gorgonia.NewGraph(), "lstm")
+	c.Error(err)
+	_, err = data.Marshal()
+	c.Error(err)
+}
@@ -20,13 +20,34 @@ type lstmGate struct {
 	act ActivationFunction
 }
 
-func (w *lstmGate) init(g *G.ExprGraph, of tensor.Dtype, inner, size int, name string, act ActivationFunction) {
-	w.wh = G.NewMatrix(g, of, G.WithShape(size, size), G.WithName(name+"_wh"), G.WithInit(G.GlorotU(1)))
-	w.wx = G.NewMatrix(g, of, G.WithShape(inner, size), G.WithName(name+"_wx"), G.WithInit(G.GlorotU(1)))
+// gateInit returns the initialization function of the weights of a gate.
+func gateInit(initW G.InitWFn) G.InitWFn {
+	if initW == nil {
+		return G.GlorotU(1)
+	}
+	return initW
+}
+
+// init creates the weights of the gate. The weights are initialized with initW, or with GlorotU(1) if initW is nil. The bias is all zeroes.
+func (w *lstmGate) init(g *G.ExprGraph, of tensor.Dtype, inner, size int, name string, act ActivationFunction, initW G.InitWFn) {
+	initW = gateInit(initW)
+	w.wh = G.NewMatrix(g, of, G.WithShape(size, size), G.WithName(name+"_wh"), G.WithInit(initW))
+	w.wx = G.NewMatrix(g, of, G.WithShape(inner, size), G.WithName(name+"_wx"), G.WithInit(initW))
 	w.b = G.NewMatrix(g, of, G.WithShape(1, size), G.WithName(name+"_b"), G.WithInit(G.Zeroes()))
 	w.act = act
 }
 
+// reinit re-initializes the weights of the gate in place.
+func (w *lstmGate) reinit(initW G.InitWFn) error {
+	initW = gateInit(initW)
+	for _, n := range []*G.Node{w.wh, w.wx} {
+		if err := reinitNode(n, initW); err != nil {
+			return err
+		}
+	}
+	return reinitNode(w.b, G.Zeroes())
+}
+
 // activate activates the gate.
 //
 // some metainformation
@@ -69,3 +90,13 @@ func (w *lstmGate) activate(inputVector, prevHidden *G.Node) (gate *G.Node, err
 	// Return gate with activation func performed on it
 	return w.act(gate)
 }
+
+// flops computes the rough number of floating point operations needed to activate the gate for a batch of n inputs.
+//
+// Each matrix multiplication of a (n, k) matrix by a (k, size) matrix is counted as 2·n·k·size operations.
+// Both additions and the activation are counted as one operation per element.
+func (w *lstmGate) flops(n int) int {
+	wx := w.wx.Shape()
+	inner, size := wx[0], wx[1]
+	return 2*n*inner*size + 2*n*size*size + 3*n*size
+}
@@ -24,11 +24,22 @@ type lstmIO struct {
 	prevHidden *G.Node
 	prevCell   *G.Node
 
+	// out is the output of a LSTM: the hidden state, or the hidden states of a whole sequence
+	out *G.Node
+
 	err error
 }
 
-// Node will return the node associated with the LSTM input
-func (l *lstmIO) Node() *G.Node { return nil }
+// Node will return the output of the LSTM. It is nil for an lstmIO that is used as an input.
+func (l *lstmIO) Node() *G.Node { return l.out }
+
+// Name returns the name of the output, which allows an lstmIO to be used as a Term.
+func (l *lstmIO) Name() string {
+	if l.out == nil {
+		return "lstmIO"
+	}
+	return l.out.Name()
+}
 
 // Nodes will return the nodes associated with the LSTM input
 func (l *lstmIO) Nodes() (ns G.Nodes) {
@@ -0,0 +1,142 @@
+package golgi
+
+import (
+	"bytes"
+	"testing"
+
+	"github.com/stretchr/testify/require"
+	"gorgonia.org/gorgonia"
+	"gorgonia.org/tensor"
+)
+
+func TestLSTM_Sequence(t *testing.T) {
+	c := require.New(t)
+	steps, n, features, size := 3, 2, 4, 5
+	backing := tensor.Range(tensor.Float64, 0, steps*n*features).([]float64)
+	for i := range backing {
+		backing[i] = backing[i]/10 - 1
+	}
+
+	g := gorgonia.NewGraph()
+	seq := gorgonia.NewTensor(g, tensor.Float64, 3, gorgonia.WithName("seq"), gorgonia.WithShape(steps, n, features),
+		gorgonia.WithValue(tensor.New(tensor.WithShape(steps, n, features), tensor.WithBacking(backing))))
+	l, err := ConsLSTM(seq, WithName("lstm"), WithSize(size), AsSequence(TimeMajor), ReturnSequences(true))
+	c.NoError(err)
+	lstm := l.(*LSTM)
+
+	out := lstm.Fwd(seq)
+	c.NoError(gorgonia.CheckOne(out))
+	c.Equal(tensor.Shape{steps, n, size}, out.Node().Shape())
+	c.Equal(tensor.Shape{n, size}, out.Nodes()[1].Shape())
+	c.Equal(tensor.Shape{n, size}, out.Nodes()[2].Shape())
+
+	// the same LSTM, one time step at a time
+	stepper := *lstm
+	stepper.sequence = false
+	var hidden, cell *gorgonia.Node
+	var manual gorgonia.Nodes
+	for ts := 0; ts < steps; ts++ {
+		x := gorgonia.Must(gorgonia.Slice(seq, gorgonia.S(ts)))
+		var res gorgonia.Result
+		if hidden == nil {
+			res = stepper.Fwd(x)
+		} else {
+			res = stepper.Fwd(gorgonia.Nodes{x, hidden, cell})
+		}
+		c.NoError(gorgonia.CheckOne(res))
+		hidden, cell = res.Nodes()[1], res.Nodes()[2]
+		manual = append(manual, hidden)
+	}
+
+	var all, final, finalCell, want, wantCell gorgonia.Value
+	gorgonia.Read(out.Node(), &all)
+	gorgonia.Read(out.Nodes()[1], &final)
+	gorgonia.Read(out.Nodes()[2], &finalCell)
+	wants := make([]gorgonia.Value, steps)
+	for i := range manual {
+		gorgonia.Read(manual[i], &wants[i])
+	}
+	gorgonia.Read(hidden, &want)
+	gorgonia.Read(cell, &wantCell)
+	m := gorgonia.NewTapeMachine(g)
+	defer m.Close()
+	c.NoError(m.RunAll())
+
+	c.InDeltaSlice(want.Data(), final.Data(), 1e-10)
+	c.InDeltaSlice(wantCell.Data(), finalCell.Data(), 1e-10)
+	allData := all.Data().([]float64)
+	for i, w := range wants {
+		c.InDeltaSlice(w.Data(), allData[i*n*size:(i+1)*n*size], 1e-10)
+	}
+
+	desc, err := lstm.Describe()
+	c.NoError(err)
+	c.Equal([]string{"LSTM", "Squeeze"}, opTypes(desc))
+	checkWellFormed(t, desc)
+
+	// the sequence mode is persisted
+	var buf bytes.Buffer
+	c.NoError(SaveCheckpoint(&buf, lstm))
+	loaded, err := LoadCheckpoint(gorgonia.NewGraph(), &buf)
+	c.NoError(err)
+	c.True(loaded.(*LSTM).sequence)
+	c.True(loaded.(*LSTM).returnSequences)
+}
+
+func TestLSTM_ComposeSeq(t *testing.T) {
+	c := require.New(t)
+	n, steps, features := 4, 6, 3
+	g := gorgonia.NewGraph()
+	x := gorgonia.NewTensor(g, tensor.Float32, 3, gorgonia.WithName("x"), gorgonia.WithShape(n, steps, features), gorgonia.WithInit(gorgonia.GlorotU(1)))
+	nn, err := ComposeSeq(
+		x,
+		L(ConsLSTM, WithName("encoder"), WithSize(8), AsSequence(BatchMajor)),
+		L(ConsFC, WithName("head"), WithSize(2), AsBatched(true)),
+	)
+	c.NoError(err)
+	out := nn.Fwd(x)
+	c.NoError(gorgonia.CheckOne(out))
+	c.Equal(tensor.Shape{n, 2}, out.Node().Shape())
+	c.Len(nn.Model(), 12+2)
+
+	// initial states
+	lstm, ok := nn.ByName("encoder").(*LSTM)
+	c.True(ok)
+	h0 := gorgonia.NewMatrix(g, tensor.Float32, gorgonia.WithName("h0"), gorgonia.WithShape(n, 8), gorgonia.WithInit(gorgonia.Zeroes()))
+	c0 := gorgonia.NewMatrix(g, tensor.Float32, gorgonia.WithName("c0"), gorgonia.WithShape(n, 8), gorgonia.WithInit(gorgonia.Zeroes()))
+	res := lstm.Fwd(gorgonia.Nodes{x, h0, c0})
+	c.NoError(gorgonia.CheckOne(res))
+	c.Equal(tensor.Shape{n, 8}, res.Node().Shape())
+
+	m := gorgonia.NewTapeMachine(g)
+	defer m.Close()
+	c.NoError(m.RunAll())
+
+	_, err = ConsLSTM(x, WithSize(8))
+	c.Error(err)
+}
+
+func TestLSTM_Stacked(t *testing.T) {
+	c := require.New(t)
+	n, steps, features := 2, 5, 4
+	g := gorgonia.NewGraph()
+	x := gorgonia.NewTensor(g, tensor.Float64, 3, gorgonia.WithName("x"), gorgonia.WithShape(n, steps, features), gorgonia.WithInit(gorgonia.GlorotU(1)))
+	nn, err := ComposeSeq(
+		x,
+		L(ConsLSTM, WithName("l1"), WithSize(6), AsSequence(BatchMajor), ReturnSequences(true)),
+		L(ConsLSTM, WithName("l2"), WithSize(5), AsSequence(BatchMajor)),
+	)
+	c.NoError(err)
+	out := nn.Fwd(x)
+	c.NoError(gorgonia.CheckOne(out))
+	c.Equal(tensor.Shape{n, 5}, out.Node().Shape())
+
+	// the second LSTM reads the hidden states of the first one, not the original input
+	l2 := nn.ByName("l2").(*LSTM)
+	c.Equal(tensor.Shape{6, 5}, l2.input.wx.Shape())
+	c.Equal(tensor.Shape{5, 5}, l2.input.wh.Shape())
+
+	m := gorgonia.NewTapeMachine(g)
+	defer m.Close()
+	c.NoError(m.RunAll())
+}
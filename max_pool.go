@@ -6,6 +6,7 @@ import (
 	"fmt"
 
 	"github.com/chewxy/hm"
+	"gorgonia.org/golgi/onnx"
 	"gorgonia.org/gorgonia"
 	"gorgonia.org/tensor"
 )
@@ -53,6 +54,7 @@ type MaxPool struct {
 
 	kernelShape tensor.Shape
 	pad, stride []int
+	padding     Padding
 
 	// optional config
 	dropout *float64 // nil when shouldn't be applied
@@ -117,7 +119,19 @@ func (l *MaxPool) Fwd(x gorgonia.Input) gorgonia.Result {
 		return gorgonia.Err(fmt.Errorf("Fwd of MaxPool %v: %w", l.name, err))
 	}
 
-	result, err := gorgonia.MaxPool2D(x.Node(), l.kernelShape, l.pad, l.stride)
+	xN := x.Node()
+	pad := l.pad
+	if l.padding != ExplicitPadding {
+		before, after, err := l.padding.pads(xN.Shape(), l.kernelShape, l.pad, l.stride, []int{1, 1})
+		if err != nil {
+			return wrapErr(l, "computing the pads: %w", err)
+		}
+		if xN, pad, err = padSpatial(xN, before, after, lowest(xN.Dtype()), l.name+"_pad"); err != nil {
+			return wrapErr(l, "padding the input: %w", err)
+		}
+	}
+
+	result, err := gorgonia.MaxPool2D(xN, l.kernelShape, pad, l.stride)
 	if err != nil {
 		return wrapErr(l, "applying max pool to %v: %w", x.Node().Shape(), err)
 	}
@@ -129,6 +143,10 @@ func (l *MaxPool) Fwd(x gorgonia.Input) gorgonia.Result {
 		}
 	}
 
+	if l.computeFLOPs {
+		l.flops = l.doComputeFLOPs(x.Node().Shape())
+	}
+
 	logf("%T shape %s: %v", l, l.name, result.Shape())
 
 	return result
@@ -139,14 +157,33 @@ func (l *MaxPool) Type() hm.Type {
 	return hm.NewFnType(hm.TypeVariable('a'), hm.TypeVariable('b'))
 }
 
+// OutputShape returns the shape of the output of the layer for an input of the given shape, without applying the layer.
+func (l *MaxPool) OutputShape(in tensor.Shape) (tensor.Shape, error) {
+	ones := []int{1, 1}
+	before, after, err := l.padding.pads(in, l.kernelShape, l.pad, l.stride, ones)
+	if err != nil {
+		return nil, err
+	}
+	return spatialOutputShape(in, in[1], l.kernelShape, before, after, l.stride, ones)
+}
+
 // Name will return the name of the MaxPoololution layer
 func (l *MaxPool) Name() string {
 	return l.name
 }
 
 // Describe will describe a MaxPoololution layer
-func (l *MaxPool) Describe() {
-	panic("not implemented")
+func (l *MaxPool) Describe() (*onnx.GraphProto, error) {
+	f := newFragment(l.name, "MaxPool", tensor.Float64)
+	attrs := append([]*onnx.AttributeProto{
+		attrInts("kernel_shape", l.kernelShape...),
+		attrInts("strides", l.stride...),
+	}, onnxPadding(l.padding, l.kernelShape, l.pad, []int{1, 1})...)
+	f.apply("MaxPool", nil, attrs...)
+	if l.dropout != nil {
+		f.dropout(*l.dropout)
+	}
+	return f.graph(), nil
 }
 
 func (l *MaxPool) SetComputeFLOPs(toCompute bool) error {
@@ -166,7 +203,9 @@ func (l *MaxPool) doComputeFLOPs(input tensor.Shape) int {
 }
 
 var (
-	_ sizeSetter      = &MaxPool{}
-	_ namesetter      = &MaxPool{}
-	_ dropoutConfiger = &MaxPool{}
+	_ sizeSetter         = &MaxPool{}
+	_ namesetter         = &MaxPool{}
+	_ dropoutConfiger    = &MaxPool{}
+	_ computeFLOPsSetter = &MaxPool{}
+	_ OutputShaper       = &MaxPool{}
 )
@@ -2,6 +2,7 @@ package golgi
 
 import (
 	"github.com/pkg/errors"
+	"gorgonia.org/golgi/onnx"
 	G "gorgonia.org/gorgonia"
 	"gorgonia.org/tensor"
 )
@@ -94,7 +95,14 @@ func (l *layerNorm) Fwd(a G.Input) G.Result {
 		}
 	}
 
+	// layer norms created with preset weights have not been through Init
 	var err error
+	if l.epsNode == nil {
+		if l.epsNode, err = l.makeEps(x.Dtype()); err != nil {
+			return G.Err(err)
+		}
+	}
+
 	var μ, xmμ, σ2, sd, newX *G.Node
 	if μ, err = G.KeepDims(x, false, func(x *G.Node) (*G.Node, error) { return G.Mean(x, last) }); err != nil {
 		return G.Err(errors.Wrapf(err, "Unable to find mean of %dth dimension of %v", last, x))
@@ -129,6 +137,39 @@ func (l *layerNorm) Fwd(a G.Input) G.Result {
 	return l.FC.Fwd(newX)
 }
 
+// Describe will describe a layer normalization layer. It is described as a LayerNormalization without scaling, followed by the fully connected layer.
+func (l *layerNorm) Describe() (*onnx.GraphProto, error) {
+	if !l.initialized {
+		return nil, errors.Errorf("Unable to describe LayerNorm %v. It has not been initialized", l.name)
+	}
+	f := newFragment(l.name, "LayerNorm", l.w.Dtype())
+	features := l.w.Shape()[0]
+	var ones G.Value
+	switch l.w.Dtype() {
+	case tensor.Float32:
+		backing := make([]float32, features)
+		for i := range backing {
+			backing[i] = 1
+		}
+		ones = tensor.New(tensor.WithShape(features), tensor.WithBacking(backing))
+	default:
+		backing := make([]float64, features)
+		for i := range backing {
+			backing[i] = 1
+		}
+		ones = tensor.New(tensor.WithShape(features), tensor.WithBacking(backing))
+	}
+	scale, err := f.value(f.prefix+"_scale", ones)
+	if err != nil {
+		return nil, err
+	}
+	f.apply("LayerNormalization", []string{scale}, attrInt("axis", -1), attrFloat("epsilon", l.eps))
+	if err = l.FC.describe(f); err != nil {
+		return nil, err
+	}
+	return f.graph(), nil
+}
+
 func MakeLayerNorm(opts ...ConsOpt) Layer {
 	l := &layerNorm{
 		eps: 1e-5,
@@ -165,15 +206,10 @@ func (l *layerNorm) Init(xs ...*G.Node) (err error) {
 	}
 	xshp := X.Shape()
 
-	switch of {
-	case tensor.Float32:
-		l.epsNode = G.NewConstant(float32(l.eps))
-	case tensor.Float64:
-		l.epsNode = G.NewConstant(l.eps)
-	default:
-		return errors.New("Layer Norm only supports Float32 or Float64")
+	if l.epsNode, err = l.makeEps(of); err != nil {
+		return err
 	}
-	l.w = G.NewMatrix(g, of, G.WithShape(xshp[1], l.size), G.WithInit(G.Ones()), G.WithName(l.name+"_W"))
+	l.w = G.NewMatrix(g, of, G.WithShape(xshp[1], l.size), G.WithInit(l.weightInit()), G.WithName(l.name+"_W"))
 	l.b = G.NewMatrix(g, of, G.WithShape(1, l.size), G.WithInit(G.Zeroes()), G.WithName(l.name+"_B"))
 	l.initialized = true
 	if l.computeFLOPs {
@@ -183,6 +219,33 @@ func (l *layerNorm) Init(xs ...*G.Node) (err error) {
 	return nil
 }
 
+// weightInit returns the initialization function of the weights. Unlike a FC, the weights are all ones by default.
+func (l *layerNorm) weightInit() G.InitWFn {
+	if l.initW == nil {
+		return G.Ones()
+	}
+	return l.initW
+}
+
+// Reinit re-initializes the weights and the bias in place.
+func (l *layerNorm) Reinit() error {
+	if err := reinitNode(l.w, l.weightInit()); err != nil {
+		return err
+	}
+	return reinitNode(l.b, G.Zeroes())
+}
+
+// makeEps makes the constant used to perturb the variance.
+func (l *layerNorm) makeEps(of tensor.Dtype) (*G.Node, error) {
+	switch of {
+	case tensor.Float32:
+		return G.NewConstant(float32(l.eps)), nil
+	case tensor.Float64:
+		return G.NewConstant(l.eps), nil
+	}
+	return nil, errors.New("Layer Norm only supports Float32 or Float64")
+}
+
 func (l *layerNorm) SetComputeFLOPs(toCompute bool) error {
 	l.computeFLOPs = toCompute
 	l.FC.computeFLOPs = toCompute
@@ -0,0 +1,78 @@
+package golgi
+
+import (
+	"fmt"
+	"hash"
+	"hash/fnv"
+
+	"github.com/chewxy/hm"
+	"github.com/pkg/errors"
+	G "gorgonia.org/gorgonia"
+	"gorgonia.org/qol"
+	"gorgonia.org/tensor"
+)
+
+// oneHotOp is an Op that turns a vector of classes into a one-hot matrix of shape (len(classes), classes).
+//
+// The input may be a vector of qol.Class, or of any of the types that an Embedding accepts when it is run.
+// The op is not differentiable: the classes are labels, not parameters.
+type oneHotOp struct {
+	classes int
+	of      tensor.Dtype
+}
+
+// oneHot creates a node that is the one-hot matrix of the classes in a.
+func oneHot(a *G.Node, classes int, of tensor.Dtype) (*G.Node, error) {
+	if !a.IsVector() {
+		return nil, errors.Errorf("Expected the classes to be a vector. Got %v instead", a.Shape())
+	}
+	return G.ApplyOp(oneHotOp{classes: classes, of: of}, a)
+}
+
+func (op oneHotOp) Arity() int { return 1 }
+
+func (op oneHotOp) Type() hm.Type {
+	return hm.NewFnType(hm.TypeVariable('a'), G.TensorType{Dims: 2, Of: op.of})
+}
+
+func (op oneHotOp) InferShape(ds ...G.DimSizer) (tensor.Shape, error) {
+	n, err := ds[0].DimSize(0)
+	if err != nil {
+		return nil, err
+	}
+	return tensor.Shape{n, op.classes}, nil
+}
+
+func (op oneHotOp) Do(vs ...G.Value) (G.Value, error) {
+	classes, err := toClasses(vs[0].Data())
+	if err != nil {
+		return nil, errors.Wrap(err, "OneHot")
+	}
+	for _, c := range classes {
+		if int(c) >= op.classes {
+			return nil, errors.Errorf("OneHot: class %d is out of range. There are only %d classes", c, op.classes)
+		}
+	}
+	return qol.ToOneHotMatrix(classes, uint(op.classes), op.of), nil
+}
+
+// DiffWRT returns false: the classes are not differentiable.
+func (op oneHotOp) DiffWRT(inputs int) []bool { return make([]bool, inputs) }
+
+// SymDiff is never called, as oneHotOp is not differentiable with regards to any of its inputs.
+func (op oneHotOp) SymDiff(inputs G.Nodes, output, grad *G.Node) (G.Nodes, error) {
+	return nil, errors.New("OneHot is not differentiable")
+}
+
+func (op oneHotOp) ReturnsPtr() bool     { return false }
+func (op oneHotOp) CallsExtern() bool    { return false }
+func (op oneHotOp) OverwritesInput() int { return -1 }
+func (op oneHotOp) String() string       { return fmt.Sprintf("OneHot(%d, %v)", op.classes, op.of) }
+
+func (op oneHotOp) WriteHash(h hash.Hash) { fmt.Fprint(h, op.String()) }
+
+func (op oneHotOp) Hashcode() uint32 {
+	h := fnv.New32a()
+	op.WriteHash(h)
+	return h.Sum32()
+}
@@ -0,0 +1,530 @@
+package golgi
+
+import (
+	"encoding/binary"
+	"fmt"
+	"io"
+	"math"
+	"reflect"
+	"runtime"
+
+	"github.com/pkg/errors"
+	"gorgonia.org/golgi/onnx"
+	G "gorgonia.org/gorgonia"
+	"gorgonia.org/tensor"
+)
+
+// ExportONNX writes the layer as a complete ONNX model, with the current values of the weights as the initializers of the graph.
+//
+// The layer has to have been constructed (i.e. Fwd has been called on it) before it may be exported.
+func ExportONNX(l Layer, w io.Writer) error {
+	g, err := l.Describe()
+	if err != nil {
+		return errors.Wrapf(err, "Unable to describe %v", l.Name())
+	}
+	if g.Name == "" {
+		g.Name = l.Name()
+	}
+
+	elemType := onnx.Float
+	if model := l.Model(); len(model) > 0 && model[0] != nil {
+		if elemType, err = onnxDtype(model[0].Dtype()); err != nil {
+			return err
+		}
+	}
+	for _, vi := range append(g.Input, g.Output...) {
+		if vi.Type == nil {
+			vi.Type = &onnx.TypeProto{TensorType: &onnx.TensorTypeProto{ElemType: elemType}}
+		}
+	}
+
+	m := &onnx.ModelProto{
+		IrVersion:    onnx.IRVersion,
+		OpsetImport:  []*onnx.OperatorSetIdProto{{Version: onnx.OpsetVersion}},
+		ProducerName: "golgi",
+		Graph:        g,
+	}
+	buf, err := onnx.Marshal(m)
+	if err != nil {
+		return errors.Wrap(err, "Unable to marshal ONNX model")
+	}
+	_, err = w.Write(buf)
+	return err
+}
+
+// fragment is a builder for a *onnx.GraphProto that describes a single layer.
+//
+// A fragment always has exactly one input and one output. The output is the value produced by the last node added.
+type fragment struct {
+	g      *onnx.GraphProto
+	prefix string
+	last   string
+	of     tensor.Dtype
+}
+
+// newFragment creates a new fragment. `name` is the name of the layer. If the layer is unnamed, `def` is used as the prefix of the values instead.
+func newFragment(name, def string, of tensor.Dtype) *fragment {
+	prefix := name
+	if prefix == "" {
+		prefix = def
+	}
+	in := prefix + "_x"
+	return &fragment{
+		g: &onnx.GraphProto{
+			Name:  name,
+			Input: []*onnx.ValueInfoProto{{Name: in}},
+		},
+		prefix: prefix,
+		last:   in,
+		of:     of,
+	}
+}
+
+// input returns the name of the input of the fragment.
+func (f *fragment) input() string { return f.g.Input[0].Name }
+
+// node adds a node to the fragment and returns the name of the value produced by the node.
+func (f *fragment) node(op string, inputs []string, attrs ...*onnx.AttributeProto) string {
+	out := fmt.Sprintf("%s_%s%d", f.prefix, op, len(f.g.Node))
+	f.g.Node = append(f.g.Node, &onnx.NodeProto{
+		Name:      out,
+		OpType:    op,
+		Input:     inputs,
+		Output:    []string{out},
+		Attribute: attrs,
+	})
+	f.last = out
+	return out
+}
+
+// apply adds a node that takes the last produced value as its first input.
+func (f *fragment) apply(op string, others []string, attrs ...*onnx.AttributeProto) string {
+	return f.node(op, append([]string{f.last}, others...), attrs...)
+}
+
+// weight adds the value of a *Node as an initializer.
+func (f *fragment) weight(n *G.Node) (string, error) {
+	if n == nil {
+		return "", errors.New("Cannot describe a nil weight")
+	}
+	if n.Value() == nil {
+		return "", errors.Errorf("Weight %v has no value", n.Name())
+	}
+	return f.value(n.Name(), n.Value())
+}
+
+// value adds a value as an initializer.
+func (f *fragment) value(name string, v G.Value) (string, error) {
+	t, err := valueToTensorProto(name, v)
+	if err != nil {
+		return "", err
+	}
+	f.g.Initializer = append(f.g.Initializer, t)
+	return name, nil
+}
+
+// ints adds a int64 initializer.
+func (f *fragment) ints(name string, vs ...int) string {
+	t := &onnx.TensorProto{Name: name, DataType: onnx.Int64, Dims: []int64{int64(len(vs))}}
+	for _, v := range vs {
+		t.Int64Data = append(t.Int64Data, int64(v))
+	}
+	f.g.Initializer = append(f.g.Initializer, t)
+	return name
+}
+
+// scalar adds a scalar initializer of the fragment's dtype.
+func (f *fragment) scalar(name string, v float64) (string, error) {
+	switch f.of {
+	case tensor.Float32:
+		return f.value(name, G.NewF32(float32(v)))
+	case tensor.Float64:
+		return f.value(name, G.NewF64(v))
+	}
+	return "", errors.Errorf("Unable to describe a scalar of %v", f.of)
+}
+
+// activate adds the nodes representing the activation function.
+func (f *fragment) activate(act ActivationFunction) error {
+	a, err := activationOf(act)
+	if err != nil {
+		return errors.Wrap(err, "Activation function cannot be described in ONNX")
+	}
+	switch a {
+	case Identity:
+	case Sigmoid:
+		f.apply("Sigmoid", nil)
+	case Tanh:
+		f.apply("Tanh", nil)
+	case ReLU:
+		f.apply("Relu", nil)
+	case SoftMax:
+		f.apply("Softmax", nil)
+	case LogSoftMax:
+		f.apply("LogSoftmax", nil)
+	case LeakyReLU:
+		f.apply("LeakyRelu", nil, attrFloat("alpha", 0.01))
+	case ELU:
+		f.apply("Elu", nil)
+	case SELU:
+		f.apply("Selu", nil)
+	case Softplus:
+		f.apply("Softplus", nil)
+	case Softsign:
+		f.apply("Softsign", nil)
+	case HardSigmoid:
+		f.apply("HardSigmoid", nil, attrFloat("alpha", 1.0/6.0), attrFloat("beta", 0.5))
+	case HardSwish:
+		f.apply("HardSwish", nil)
+	case Swish:
+		x := f.last
+		s := f.node("Sigmoid", []string{x})
+		f.node("Mul", []string{x, s})
+	case Mish:
+		// Mish is only an operator from opset 18 onwards
+		x := f.last
+		sp := f.node("Softplus", []string{x})
+		t := f.node("Tanh", []string{sp})
+		f.node("Mul", []string{x, t})
+	case Cube:
+		three, err := f.scalar(f.prefix+"_three", 3)
+		if err != nil {
+			return err
+		}
+		f.apply("Pow", []string{three})
+	case GeLU:
+		// 0.5x(1 + tanh(√(2/π)(x + 0.044715x³)))
+		consts := make([]string, 0, 4)
+		for _, c := range []struct {
+			name string
+			v    float64
+		}{{"_half", 0.5}, {"_magic", 0.044715}, {"_one", 1}, {"_sqrt2Overπ", 0.7978845608028654}} {
+			n, err := f.scalar(f.prefix+c.name, c.v)
+			if err != nil {
+				return err
+			}
+			consts = append(consts, n)
+		}
+		half, magic, one, sqrt2Overπ := consts[0], consts[1], consts[2], consts[3]
+		x := f.last
+		three, err := f.scalar(f.prefix+"_three", 3)
+		if err != nil {
+			return err
+		}
+		cube := f.node("Pow", []string{x, three})
+		inner := f.node("Mul", []string{magic, cube})
+		inner = f.node("Add", []string{x, inner})
+		inner = f.node("Mul", []string{sqrt2Overπ, inner})
+		inner = f.node("Tanh", []string{inner})
+		inner = f.node("Add", []string{one, inner})
+		hx := f.node("Mul", []string{half, x})
+		f.node("Mul", []string{hx, inner})
+	default:
+		return errors.Errorf("Activation function %v (%v) cannot be described in ONNX", a, runtimeFuncName(act))
+	}
+	return nil
+}
+
+// dropout adds a Dropout node.
+func (f *fragment) dropout(prob float64) {
+	ratio := &onnx.TensorProto{Name: f.prefix + fmt.Sprintf("_ratio%d", len(f.g.Node)), DataType: onnx.Float, FloatData: []float32{float32(prob)}}
+	f.g.Initializer = append(f.g.Initializer, ratio)
+	f.apply("Dropout", []string{ratio.Name})
+}
+
+// graph finishes the fragment and returns the *onnx.GraphProto.
+func (f *fragment) graph() *onnx.GraphProto {
+	f.g.Output = []*onnx.ValueInfoProto{{Name: f.last}}
+	return f.g
+}
+
+// identityFragment describes a layer that does nothing to its input.
+func identityFragment(name string) *onnx.GraphProto {
+	f := newFragment(name, "I", tensor.Float64)
+	f.apply("Identity", nil)
+	return f.graph()
+}
+
+// describeTerm describes a Term.
+func describeTerm(t Term) (*onnx.GraphProto, error) {
+	switch tt := t.(type) {
+	case Layer:
+		return tt.Describe()
+	case I:
+		return identityFragment(""), nil
+	case consThunk:
+		return nil, errors.Errorf("%v has not been constructed. Call Fwd before describing it", tt.Name())
+	}
+	return nil, errors.Errorf("Unable to describe %v of %T", t, t)
+}
+
+// chain describes the composition b(a(x)). The output of `a` becomes the input of `b`.
+func chain(a, b *onnx.GraphProto) *onnx.GraphProto {
+	b = uniquify(a, b)
+	rename(b, b.Input[0].Name, a.Output[0].Name)
+	return &onnx.GraphProto{
+		Node:        append(append([]*onnx.NodeProto{}, a.Node...), b.Node...),
+		Initializer: append(append([]*onnx.TensorProto{}, a.Initializer...), b.Initializer...),
+		Input:       a.Input,
+		Output:      b.Output,
+	}
+}
+
+// fanout describes the application of every branch to the same input. The results are merged with the given op, which takes the
+// outputs of all the branches as its inputs.
+func fanout(name, op string, branches ...*onnx.GraphProto) *onnx.GraphProto {
+	a := branches[0]
+	retVal := &onnx.GraphProto{
+		Name:        name,
+		Node:        append([]*onnx.NodeProto{}, a.Node...),
+		Initializer: append([]*onnx.TensorProto{}, a.Initializer...),
+		Input:       a.Input,
+	}
+	outs := []string{a.Output[0].Name}
+	for _, b := range branches[1:] {
+		b = uniquify(retVal, b)
+		rename(b, b.Input[0].Name, a.Input[0].Name)
+		retVal.Node = append(retVal.Node, b.Node...)
+		retVal.Initializer = append(retVal.Initializer, b.Initializer...)
+		outs = append(outs, b.Output[0].Name)
+	}
+	prefix := name
+	if prefix == "" {
+		prefix = op
+	}
+	out := uniqueName(prefix+"_"+op, definedNames(retVal))
+	retVal.Node = append(retVal.Node, &onnx.NodeProto{
+		Name:   out,
+		OpType: op,
+		Input:  outs,
+		Output: []string{out},
+	})
+	retVal.Output = []*onnx.ValueInfoProto{{Name: out}}
+	return retVal
+}
+
+// definedNames returns the set of names that are defined in a graph.
+func definedNames(g *onnx.GraphProto) map[string]struct{} {
+	retVal := make(map[string]struct{})
+	for _, in := range g.Input {
+		retVal[in.Name] = struct{}{}
+	}
+	for _, n := range g.Node {
+		for _, out := range n.Output {
+			retVal[out] = struct{}{}
+		}
+	}
+	for _, init := range g.Initializer {
+		retVal[init.Name] = struct{}{}
+	}
+	return retVal
+}
+
+func uniqueName(name string, taken map[string]struct{}) string {
+	if _, ok := taken[name]; !ok {
+		return name
+	}
+	for i := 1; ; i++ {
+		candidate := fmt.Sprintf("%s_%d", name, i)
+		if _, ok := taken[candidate]; !ok {
+			return candidate
+		}
+	}
+}
+
+// uniquify renames all the names in `b` that clash with the names defined in `a`. The input of `b` is left alone, as it will be replaced.
+func uniquify(a, b *onnx.GraphProto) *onnx.GraphProto {
+	inA := definedNames(a)
+	inB := definedNames(b)
+	taken := make(map[string]struct{}, len(inA)+len(inB))
+	for k := range inA {
+		taken[k] = struct{}{}
+	}
+	for k := range inB {
+		taken[k] = struct{}{}
+	}
+	in := b.Input[0].Name
+	for name := range inB {
+		if name == in {
+			continue
+		}
+		if _, ok := inA[name]; !ok {
+			continue
+		}
+		newName := uniqueName(name, taken)
+		taken[newName] = struct{}{}
+		rename(b, name, newName)
+	}
+	return b
+}
+
+// rename renames all references of a value in a graph.
+func rename(g *onnx.GraphProto, from, to string) {
+	for _, vi := range g.Input {
+		if vi.Name == from {
+			vi.Name = to
+		}
+	}
+	for _, vi := range g.Output {
+		if vi.Name == from {
+			vi.Name = to
+		}
+	}
+	for _, n := range g.Node {
+		if n.Name == from {
+			n.Name = to
+		}
+		for i := range n.Input {
+			if n.Input[i] == from {
+				n.Input[i] = to
+			}
+		}
+		for i := range n.Output {
+			if n.Output[i] == from {
+				n.Output[i] = to
+			}
+		}
+	}
+	for _, init := range g.Initializer {
+		if init.Name == from {
+			init.Name = to
+		}
+	}
+}
+
+func runtimeFuncName(fn interface{}) string {
+	return runtime.FuncForPC(reflect.ValueOf(fn).Pointer()).Name()
+}
+
+func onnxDtype(dt tensor.Dtype) (onnx.DataType, error) {
+	switch dt {
+	case tensor.Float32:
+		return onnx.Float, nil
+	case tensor.Float64:
+		return onnx.Double, nil
+	case tensor.Int, tensor.Int64:
+		return onnx.Int64, nil
+	case tensor.Int32:
+		return onnx.Int32, nil
+	case tensor.Bool:
+		return onnx.Bool, nil
+	}
+	return onnx.Undefined, errors.Errorf("Dtype %v has no ONNX equivalent", dt)
+}
+
+func valueToTensorProto(name string, v G.Value) (*onnx.TensorProto, error) {
+	retVal := &onnx.TensorProto{Name: name}
+	if !v.Shape().IsScalar() {
+		for _, d := range v.Shape() {
+			retVal.Dims = append(retVal.Dims, int64(d))
+		}
+	}
+	switch data := v.Data().(type) {
+	case []float32:
+		retVal.DataType = onnx.Float
+		retVal.FloatData = append(retVal.FloatData, data...)
+	case float32:
+		retVal.DataType = onnx.Float
+		retVal.FloatData = []float32{data}
+	case []float64:
+		retVal.DataType = onnx.Double
+		retVal.DoubleData = append(retVal.DoubleData, data...)
+	case float64:
+		retVal.DataType = onnx.Double
+		retVal.DoubleData = []float64{data}
+	case []int:
+		retVal.DataType = onnx.Int64
+		for _, d := range data {
+			retVal.Int64Data = append(retVal.Int64Data, int64(d))
+		}
+	case []int64:
+		retVal.DataType = onnx.Int64
+		retVal.Int64Data = append(retVal.Int64Data, data...)
+	default:
+		return nil, errors.Errorf("Unable to convert %v of %T to an ONNX tensor", name, data)
+	}
+	return retVal, nil
+}
+
+// tensorProtoToValue converts an ONNX tensor into a *tensor.Dense.
+func tensorProtoToValue(t *onnx.TensorProto) (*tensor.Dense, error) {
+	shp := make(tensor.Shape, 0, len(t.Dims))
+	size := 1
+	for _, d := range t.Dims {
+		shp = append(shp, int(d))
+		size *= int(d)
+	}
+	var backing interface{}
+	switch t.DataType {
+	case onnx.Float:
+		data := t.FloatData
+		if len(t.RawData) > 0 {
+			data = make([]float32, len(t.RawData)/4)
+			for i := range data {
+				data[i] = math.Float32frombits(binary.LittleEndian.Uint32(t.RawData[i*4:]))
+			}
+		}
+		backing = data
+	case onnx.Double:
+		data := t.DoubleData
+		if len(t.RawData) > 0 {
+			data = make([]float64, len(t.RawData)/8)
+			for i := range data {
+				data[i] = math.Float64frombits(binary.LittleEndian.Uint64(t.RawData[i*8:]))
+			}
+		}
+		backing = data
+	case onnx.Int64:
+		data := t.Int64Data
+		if len(t.RawData) > 0 {
+			data = make([]int64, len(t.RawData)/8)
+			for i := range data {
+				data[i] = int64(binary.LittleEndian.Uint64(t.RawData[i*8:]))
+			}
+		}
+		backing = data
+	case onnx.Int32:
+		data := t.Int32Data
+		if len(t.RawData) > 0 {
+			data = make([]int32, len(t.RawData)/4)
+			for i := range data {
+				data[i] = int32(binary.LittleEndian.Uint32(t.RawData[i*4:]))
+			}
+		}
+		backing = data
+	default:
+		return nil, errors.Errorf("ONNX tensor %v has an unsupported data type %d", t.Name, t.DataType)
+	}
+	if l := reflect.ValueOf(backing).Len(); l != size {
+		return nil, errors.Errorf("ONNX tensor %v has shape %v but %d elements", t.Name, shp, l)
+	}
+	if len(shp) == 0 {
+		return tensor.New(tensor.FromScalar(reflect.ValueOf(backing).Index(0).Interface())), nil
+	}
+	return tensor.New(tensor.WithShape(shp...), tensor.WithBacking(backing)), nil
+}
+
+func attrInts(name string, vs ...int) *onnx.AttributeProto {
+	retVal := &onnx.AttributeProto{Name: name, Type: onnx.AttributeInts}
+	for _, v := range vs {
+		retVal.Ints = append(retVal.Ints, int64(v))
+	}
+	return retVal
+}
+
+func attrInt(name string, v int) *onnx.AttributeProto {
+	return &onnx.AttributeProto{Name: name, Type: onnx.AttributeInt, I: int64(v)}
+}
+
+func attrFloat(name string, v float64) *onnx.AttributeProto {
+	return &onnx.AttributeProto{Name: name, Type: onnx.AttributeFloat, F: float32(v)}
+}
+
+func attrString(name, v string) *onnx.AttributeProto {
+	return &onnx.AttributeProto{Name: name, Type: onnx.AttributeString, S: []byte(v)}
+}
+
+// onnxPads converts golgi's symmetric pads into ONNX's (begin..., end...) pads.
+func onnxPads(pad []int) []int {
+	return append(append([]int{}, pad...), pad...)
+}
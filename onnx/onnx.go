@@ -0,0 +1,231 @@
+// Package onnx provides the subset of the ONNX intermediate representation (https://github.com/onnx/onnx/blob/master/onnx/onnx.proto3)
+// that golgi uses to describe, export and import layers.
+//
+// The field numbers are those of the upstream onnx.proto3, so the messages are wire compatible with every ONNX runtime.
+// The `oneof` fields of the upstream definitions (TypeProto.value and TensorShapeProto.Dimension.value) are represented
+// as plain optional fields, which is wire compatible.
+package onnx
+
+import (
+	proto "github.com/gogo/protobuf/proto"
+)
+
+// IRVersion is the version of the ONNX IR that this package writes.
+const IRVersion = 8
+
+// OpsetVersion is the version of the default ("ai.onnx") operator set that this package writes.
+const OpsetVersion = 17
+
+// AttributeType is the type of an attribute.
+type AttributeType int32
+
+const (
+	AttributeUndefined AttributeType = iota
+	AttributeFloat
+	AttributeInt
+	AttributeString
+	AttributeTensor
+	AttributeGraph
+	AttributeFloats
+	AttributeInts
+	AttributeStrings
+	AttributeTensors
+	AttributeGraphs
+)
+
+// DataType is the element type of a tensor.
+type DataType int32
+
+const (
+	Undefined DataType = iota
+	Float
+	Uint8
+	Int8
+	Uint16
+	Int16
+	Int32
+	Int64
+	String
+	Bool
+	Float16
+	Double
+	Uint32
+	Uint64
+	Complex64
+	Complex128
+	BFloat16
+)
+
+// AttributeProto is a named attribute of a node.
+type AttributeProto struct {
+	Name      string         `protobuf:"bytes,1,opt,name=name,proto3" json:"name,omitempty"`
+	DocString string         `protobuf:"bytes,13,opt,name=doc_string,json=docString,proto3" json:"doc_string,omitempty"`
+	Type      AttributeType  `protobuf:"varint,20,opt,name=type,proto3" json:"type,omitempty"`
+	F         float32        `protobuf:"fixed32,2,opt,name=f,proto3" json:"f,omitempty"`
+	I         int64          `protobuf:"varint,3,opt,name=i,proto3" json:"i,omitempty"`
+	S         []byte         `protobuf:"bytes,4,opt,name=s,proto3" json:"s,omitempty"`
+	T         *TensorProto   `protobuf:"bytes,5,opt,name=t,proto3" json:"t,omitempty"`
+	G         *GraphProto    `protobuf:"bytes,6,opt,name=g,proto3" json:"g,omitempty"`
+	Floats    []float32      `protobuf:"fixed32,7,rep,packed,name=floats,proto3" json:"floats,omitempty"`
+	Ints      []int64        `protobuf:"varint,8,rep,packed,name=ints,proto3" json:"ints,omitempty"`
+	Strings   [][]byte       `protobuf:"bytes,9,rep,name=strings,proto3" json:"strings,omitempty"`
+	Tensors   []*TensorProto `protobuf:"bytes,10,rep,name=tensors,proto3" json:"tensors,omitempty"`
+	Graphs    []*GraphProto  `protobuf:"bytes,11,rep,name=graphs,proto3" json:"graphs,omitempty"`
+}
+
+func (m *AttributeProto) Reset()         { *m = AttributeProto{} }
+func (m *AttributeProto) String() string { return proto.CompactTextString(m) }
+func (*AttributeProto) ProtoMessage()    {}
+
+// ValueInfoProto describes a value (an input or an output) of a graph.
+type ValueInfoProto struct {
+	Name      string     `protobuf:"bytes,1,opt,name=name,proto3" json:"name,omitempty"`
+	Type      *TypeProto `protobuf:"bytes,2,opt,name=type,proto3" json:"type,omitempty"`
+	DocString string     `protobuf:"bytes,3,opt,name=doc_string,json=docString,proto3" json:"doc_string,omitempty"`
+}
+
+func (m *ValueInfoProto) Reset()         { *m = ValueInfoProto{} }
+func (m *ValueInfoProto) String() string { return proto.CompactTextString(m) }
+func (*ValueInfoProto) ProtoMessage()    {}
+
+// NodeProto is a single computation in a graph.
+type NodeProto struct {
+	Input     []string          `protobuf:"bytes,1,rep,name=input,proto3" json:"input,omitempty"`
+	Output    []string          `protobuf:"bytes,2,rep,name=output,proto3" json:"output,omitempty"`
+	Name      string            `protobuf:"bytes,3,opt,name=name,proto3" json:"name,omitempty"`
+	OpType    string            `protobuf:"bytes,4,opt,name=op_type,json=opType,proto3" json:"op_type,omitempty"`
+	Domain    string            `protobuf:"bytes,7,opt,name=domain,proto3" json:"domain,omitempty"`
+	Attribute []*AttributeProto `protobuf:"bytes,5,rep,name=attribute,proto3" json:"attribute,omitempty"`
+	DocString string            `protobuf:"bytes,6,opt,name=doc_string,json=docString,proto3" json:"doc_string,omitempty"`
+}
+
+func (m *NodeProto) Reset()         { *m = NodeProto{} }
+func (m *NodeProto) String() string { return proto.CompactTextString(m) }
+func (*NodeProto) ProtoMessage()    {}
+
+// Attr returns the attribute of the given name. nil is returned if no such attribute exists.
+func (m *NodeProto) Attr(name string) *AttributeProto {
+	for _, a := range m.Attribute {
+		if a.Name == name {
+			return a
+		}
+	}
+	return nil
+}
+
+// ModelProto is the top level container of an ONNX model.
+type ModelProto struct {
+	IrVersion       int64                     `protobuf:"varint,1,opt,name=ir_version,json=irVersion,proto3" json:"ir_version,omitempty"`
+	OpsetImport     []*OperatorSetIdProto     `protobuf:"bytes,8,rep,name=opset_import,json=opsetImport,proto3" json:"opset_import,omitempty"`
+	ProducerName    string                    `protobuf:"bytes,2,opt,name=producer_name,json=producerName,proto3" json:"producer_name,omitempty"`
+	ProducerVersion string                    `protobuf:"bytes,3,opt,name=producer_version,json=producerVersion,proto3" json:"producer_version,omitempty"`
+	Domain          string                    `protobuf:"bytes,4,opt,name=domain,proto3" json:"domain,omitempty"`
+	ModelVersion    int64                     `protobuf:"varint,5,opt,name=model_version,json=modelVersion,proto3" json:"model_version,omitempty"`
+	DocString       string                    `protobuf:"bytes,6,opt,name=doc_string,json=docString,proto3" json:"doc_string,omitempty"`
+	Graph           *GraphProto               `protobuf:"bytes,7,opt,name=graph,proto3" json:"graph,omitempty"`
+	MetadataProps   []*StringStringEntryProto `protobuf:"bytes,14,rep,name=metadata_props,json=metadataProps,proto3" json:"metadata_props,omitempty"`
+}
+
+func (m *ModelProto) Reset()         { *m = ModelProto{} }
+func (m *ModelProto) String() string { return proto.CompactTextString(m) }
+func (*ModelProto) ProtoMessage()    {}
+
+// StringStringEntryProto is a key-value pair.
+type StringStringEntryProto struct {
+	Key   string `protobuf:"bytes,1,opt,name=key,proto3" json:"key,omitempty"`
+	Value string `protobuf:"bytes,2,opt,name=value,proto3" json:"value,omitempty"`
+}
+
+func (m *StringStringEntryProto) Reset()         { *m = StringStringEntryProto{} }
+func (m *StringStringEntryProto) String() string { return proto.CompactTextString(m) }
+func (*StringStringEntryProto) ProtoMessage()    {}
+
+// GraphProto is a list of nodes that form a directed acyclic graph, along with the graph's inputs, outputs and initializers.
+type GraphProto struct {
+	Node        []*NodeProto      `protobuf:"bytes,1,rep,name=node,proto3" json:"node,omitempty"`
+	Name        string            `protobuf:"bytes,2,opt,name=name,proto3" json:"name,omitempty"`
+	Initializer []*TensorProto    `protobuf:"bytes,5,rep,name=initializer,proto3" json:"initializer,omitempty"`
+	DocString   string            `protobuf:"bytes,10,opt,name=doc_string,json=docString,proto3" json:"doc_string,omitempty"`
+	Input       []*ValueInfoProto `protobuf:"bytes,11,rep,name=input,proto3" json:"input,omitempty"`
+	Output      []*ValueInfoProto `protobuf:"bytes,12,rep,name=output,proto3" json:"output,omitempty"`
+	ValueInfo   []*ValueInfoProto `protobuf:"bytes,13,rep,name=value_info,json=valueInfo,proto3" json:"value_info,omitempty"`
+}
+
+func (m *GraphProto) Reset()         { *m = GraphProto{} }
+func (m *GraphProto) String() string { return proto.CompactTextString(m) }
+func (*GraphProto) ProtoMessage()    {}
+
+// TensorProto is a serialized tensor value.
+type TensorProto struct {
+	Dims       []int64   `protobuf:"varint,1,rep,packed,name=dims,proto3" json:"dims,omitempty"`
+	DataType   DataType  `protobuf:"varint,2,opt,name=data_type,json=dataType,proto3" json:"data_type,omitempty"`
+	FloatData  []float32 `protobuf:"fixed32,4,rep,packed,name=float_data,json=floatData,proto3" json:"float_data,omitempty"`
+	Int32Data  []int32   `protobuf:"varint,5,rep,packed,name=int32_data,json=int32Data,proto3" json:"int32_data,omitempty"`
+	StringData [][]byte  `protobuf:"bytes,6,rep,name=string_data,json=stringData,proto3" json:"string_data,omitempty"`
+	Int64Data  []int64   `protobuf:"varint,7,rep,packed,name=int64_data,json=int64Data,proto3" json:"int64_data,omitempty"`
+	Name       string    `protobuf:"bytes,8,opt,name=name,proto3" json:"name,omitempty"`
+	DocString  string    `protobuf:"bytes,12,opt,name=doc_string,json=docString,proto3" json:"doc_string,omitempty"`
+	RawData    []byte    `protobuf:"bytes,9,opt,name=raw_data,json=rawData,proto3" json:"raw_data,omitempty"`
+	DoubleData []float64 `protobuf:"fixed64,10,rep,packed,name=double_data,json=doubleData,proto3" json:"double_data,omitempty"`
+	Uint64Data []uint64  `protobuf:"varint,11,rep,packed,name=uint64_data,json=uint64Data,proto3" json:"uint64_data,omitempty"`
+}
+
+func (m *TensorProto) Reset()         { *m = TensorProto{} }
+func (m *TensorProto) String() string { return proto.CompactTextString(m) }
+func (*TensorProto) ProtoMessage()    {}
+
+// TensorShapeProto is the shape of a tensor. A dimension may be a concrete value or a symbolic parameter.
+type TensorShapeProto struct {
+	Dim []*Dimension `protobuf:"bytes,1,rep,name=dim,proto3" json:"dim,omitempty"`
+}
+
+func (m *TensorShapeProto) Reset()         { *m = TensorShapeProto{} }
+func (m *TensorShapeProto) String() string { return proto.CompactTextString(m) }
+func (*TensorShapeProto) ProtoMessage()    {}
+
+// Dimension is a dimension of a TensorShapeProto. Either DimValue or DimParam is set.
+type Dimension struct {
+	DimValue   int64  `protobuf:"varint,1,opt,name=dim_value,json=dimValue,proto3" json:"dim_value,omitempty"`
+	DimParam   string `protobuf:"bytes,2,opt,name=dim_param,json=dimParam,proto3" json:"dim_param,omitempty"`
+	Denotation string `protobuf:"bytes,3,opt,name=denotation,proto3" json:"denotation,omitempty"`
+}
+
+func (m *Dimension) Reset()         { *m = Dimension{} }
+func (m *Dimension) String() string { return proto.CompactTextString(m) }
+func (*Dimension) ProtoMessage()    {}
+
+// TypeProto is the type of a value. Only tensor types are supported.
+type TypeProto struct {
+	TensorType *TensorTypeProto `protobuf:"bytes,1,opt,name=tensor_type,json=tensorType,proto3" json:"tensor_type,omitempty"`
+	Denotation string           `protobuf:"bytes,6,opt,name=denotation,proto3" json:"denotation,omitempty"`
+}
+
+func (m *TypeProto) Reset()         { *m = TypeProto{} }
+func (m *TypeProto) String() string { return proto.CompactTextString(m) }
+func (*TypeProto) ProtoMessage()    {}
+
+// TensorTypeProto is the type of a tensor value.
+type TensorTypeProto struct {
+	ElemType DataType          `protobuf:"varint,1,opt,name=elem_type,json=elemType,proto3" json:"elem_type,omitempty"`
+	Shape    *TensorShapeProto `protobuf:"bytes,2,opt,name=shape,proto3" json:"shape,omitempty"`
+}
+
+func (m *TensorTypeProto) Reset()         { *m = TensorTypeProto{} }
+func (m *TensorTypeProto) String() string { return proto.CompactTextString(m) }
+func (*TensorTypeProto) ProtoMessage()    {}
+
+// OperatorSetIdProto identifies an operator set.
+type OperatorSetIdProto struct {
+	Domain  string `protobuf:"bytes,1,opt,name=domain,proto3" json:"domain,omitempty"`
+	Version int64  `protobuf:"varint,2,opt,name=version,proto3" json:"version,omitempty"`
+}
+
+func (m *OperatorSetIdProto) Reset()         { *m = OperatorSetIdProto{} }
+func (m *OperatorSetIdProto) String() string { return proto.CompactTextString(m) }
+func (*OperatorSetIdProto) ProtoMessage()    {}
+
+// Marshal encodes a message into its wire format.
+func Marshal(m proto.Message) ([]byte, error) { return proto.Marshal(m) }
+
+// Unmarshal decodes the wire format into a message.
+func Unmarshal(buf []byte, m proto.Message) error { return proto.Unmarshal(buf, m) }
@@ -0,0 +1,845 @@
+package golgi
+
+import (
+	"io"
+	"io/ioutil"
+
+	"github.com/pkg/errors"
+	"gorgonia.org/golgi/onnx"
+	G "gorgonia.org/gorgonia"
+	"gorgonia.org/tensor"
+)
+
+// ImportONNX reads an ONNX model and reconstructs it as a *Composition in the given graph.
+//
+// The initializers of the model are loaded as the weights of the layers, so calling Model() on the returned layer returns the imported weights.
+// The model must have exactly one input and one output. The following operators are supported:
+//
+//	Gemm, MatMul (optionally followed by an Add)	→ *FC
+//	Conv						→ *Conv
+//	MaxPool						→ *MaxPool
+//	Reshape, Flatten				→ Reshape
+//	Dropout						→ Dropout
+//	LayerNormalization				→ LayerNorm
+//	Gather						→ *Embedding
+//	LSTM (as written by ExportONNX)			→ *LSTM
+//	Add, Mul					→ *Join
+//	Relu, Sigmoid, Tanh, Softmax			→ the activation function of the preceding *FC or *Conv
+//	Identity					→ nothing
+func ImportONNX(g *G.ExprGraph, r io.Reader) (Layer, error) {
+	buf, err := ioutil.ReadAll(r)
+	if err != nil {
+		return nil, errors.Wrap(err, "Unable to read ONNX model")
+	}
+	var m onnx.ModelProto
+	if err = onnx.Unmarshal(buf, &m); err != nil {
+		return nil, errors.Wrap(err, "Unable to unmarshal ONNX model")
+	}
+	if m.Graph == nil {
+		return nil, errors.New("ONNX model has no graph")
+	}
+
+	im := newImporter(g, m.Graph)
+	var inputs []string
+	for _, in := range m.Graph.Input {
+		if _, ok := im.inits[in.Name]; !ok {
+			inputs = append(inputs, in.Name)
+		}
+	}
+	if len(inputs) != 1 {
+		return nil, errors.Errorf("Expected the ONNX graph to have exactly one input. Got %v", inputs)
+	}
+	if len(m.Graph.Output) != 1 {
+		return nil, errors.Errorf("Expected the ONNX graph to have exactly one output. Got %d", len(m.Graph.Output))
+	}
+
+	t, err := im.build(m.Graph.Output[0].Name, inputs[0])
+	if err != nil {
+		return nil, err
+	}
+	switch tt := t.(type) {
+	case *Composition:
+		return tt, nil
+	case Layer:
+		return Compose(I{}, tt), nil
+	}
+	return Compose(I{}, id{}), nil
+}
+
+// importer reconstructs golgi layers from an ONNX graph.
+type importer struct {
+	g     *G.ExprGraph
+	inits map[string]*onnx.TensorProto
+
+	// producers maps a value to the node that produces it
+	producers map[string]*onnx.NodeProto
+	// consumers counts the number of nodes that use a value
+	consumers map[string]int
+
+	// weights caches the *Node created for each initializer, so that shared initializers are shared weights
+	weights map[string]*G.Node
+}
+
+func newImporter(g *G.ExprGraph, graph *onnx.GraphProto) *importer {
+	im := &importer{
+		g:         g,
+		inits:     make(map[string]*onnx.TensorProto),
+		producers: make(map[string]*onnx.NodeProto),
+		consumers: make(map[string]int),
+		weights:   make(map[string]*G.Node),
+	}
+	for _, init := range graph.Initializer {
+		im.inits[init.Name] = init
+	}
+	for _, n := range graph.Node {
+		for _, out := range n.Output {
+			if out != "" {
+				im.producers[out] = n
+			}
+		}
+		for _, in := range n.Input {
+			im.consumers[in]++
+		}
+	}
+	for _, out := range graph.Output {
+		im.consumers[out.Name]++
+	}
+	return im
+}
+
+// build builds a term that maps the value `stop` to the value `v`.
+func (im *importer) build(v, stop string) (Term, error) {
+	if v == stop {
+		return I{}, nil
+	}
+	n, ok := im.producers[v]
+	if !ok {
+		return nil, errors.Errorf("ONNX value %q is not produced by any node", v)
+	}
+
+	switch n.OpType {
+	case "Identity":
+		return im.build(n.Input[0], stop)
+	case "Relu", "Sigmoid", "Tanh", "Softmax":
+		return im.activation(n, stop)
+	case "Add", "Mul":
+		data := im.dataInputs(n)
+		switch {
+		case len(data) == 2:
+			return im.join(n, stop)
+		case len(data) == 1 && n.OpType == "Add":
+			if l, in, ok, err := im.matMulAdd(n); ok || err != nil {
+				if err != nil {
+					return nil, err
+				}
+				return im.after(l, in, stop)
+			}
+			b, err := im.weight(im.other(n, data[0]))
+			if err != nil {
+				return nil, err
+			}
+			return im.after(&skip{b: b}, data[0], stop)
+		}
+		return nil, errors.Errorf("Unable to import %v node %q with inputs %v", n.OpType, n.Name, n.Input)
+	}
+
+	l, in, err := im.layer(n)
+	if err != nil {
+		return nil, err
+	}
+	return im.after(l, in, stop)
+}
+
+// after builds the term that maps `stop` to `in`, and composes it with `l`.
+func (im *importer) after(l Layer, in, stop string) (Term, error) {
+	prev, err := im.build(in, stop)
+	if err != nil {
+		return nil, err
+	}
+	if _, ok := prev.(I); ok {
+		return l, nil
+	}
+	return Compose(prev, l), nil
+}
+
+// layer converts a node into a Layer. The name of the value that is the input of the layer is also returned.
+func (im *importer) layer(n *onnx.NodeProto) (retVal Layer, in string, err error) {
+	name := n.Name
+	if name == "" {
+		name = n.Output[0]
+	}
+	switch n.OpType {
+	case "Gemm":
+		if l, in, ok, err := im.layerNormGemm(n); ok || err != nil {
+			return l, in, err
+		}
+		return im.gemm(n, name)
+	case "MatMul":
+		w, err := im.weight(n.Input[1])
+		if err != nil {
+			return nil, "", err
+		}
+		return NewFC(WithName(name), WithSize(w.Shape()[1]), WithWeights(w), WithBias(false), AsBatched(true)), n.Input[0], nil
+	case "Conv":
+		return im.conv(n, name)
+	case "MaxPool":
+		return im.maxPool(n, name)
+	case "Reshape":
+		shp, err := im.ints(n.Input[1])
+		if err != nil {
+			return nil, "", err
+		}
+		return reshape(shp), n.Input[0], nil
+	case "Flatten":
+		axis := 1
+		if a := n.Attr("axis"); a != nil {
+			axis = int(a.I)
+		}
+		switch axis {
+		case 0:
+			return reshape{1, -1}, n.Input[0], nil
+		case 1:
+			return reshape{0, -1}, n.Input[0], nil
+		}
+		return nil, "", errors.Errorf("Unable to import Flatten %q with axis %d", n.Name, axis)
+	case "Dropout":
+		prob := 0.5
+		if a := n.Attr("ratio"); a != nil {
+			prob = float64(a.F)
+		}
+		if len(n.Input) > 1 && n.Input[1] != "" {
+			ratio, err := im.value(n.Input[1])
+			if err != nil {
+				return nil, "", err
+			}
+			switch r := ratio.Data().(type) {
+			case float32:
+				prob = float64(r)
+			case float64:
+				prob = r
+			}
+		}
+		return dropout(prob), n.Input[0], nil
+	case "LayerNormalization":
+		return im.layerNorm(n, name)
+	case "Gather":
+		return im.gather(n, name)
+	case "Squeeze":
+		return im.lstm(n)
+	}
+	return nil, "", errors.Errorf("ONNX operator %v (node %q) is not supported", n.OpType, n.Name)
+}
+
+// dataInputs returns the inputs of a node that are not initializers.
+func (im *importer) dataInputs(n *onnx.NodeProto) (retVal []string) {
+	for _, in := range n.Input {
+		if _, ok := im.inits[in]; !ok && in != "" {
+			retVal = append(retVal, in)
+		}
+	}
+	return retVal
+}
+
+// other returns the input of a binary node that is not `in`.
+func (im *importer) other(n *onnx.NodeProto, in string) string {
+	if n.Input[0] == in {
+		return n.Input[1]
+	}
+	return n.Input[0]
+}
+
+// value returns the value of an initializer.
+func (im *importer) value(name string) (*tensor.Dense, error) {
+	init, ok := im.inits[name]
+	if !ok {
+		return nil, errors.Errorf("ONNX value %q is not an initializer", name)
+	}
+	return tensorProtoToValue(init)
+}
+
+// weight returns the *Node holding the value of an initializer.
+func (im *importer) weight(name string) (*G.Node, error) { return im.weightAs(name, "", nil) }
+
+// weightAs returns the *Node holding a copy of the value of an initializer, transformed by fn. The nodes are cached by the name of the
+// initializer and the kind of the transformation, so that an initializer shared by several ONNX nodes is imported as a shared weight.
+// The value of the initializer itself is left untouched.
+func (im *importer) weightAs(name, kind string, fn func(*tensor.Dense) error) (*G.Node, error) {
+	key := name + kind
+	if w, ok := im.weights[key]; ok {
+		return w, nil
+	}
+	v, err := im.value(name)
+	if err != nil {
+		return nil, err
+	}
+	v = v.Clone().(*tensor.Dense)
+	if fn != nil {
+		if err = fn(v); err != nil {
+			return nil, errors.Wrapf(err, "Unable to import ONNX value %q", name)
+		}
+	}
+	w := G.NodeFromAny(im.g, v, G.WithName(name))
+	im.weights[key] = w
+	return w, nil
+}
+
+// ints returns the value of an integer initializer as a []int.
+func (im *importer) ints(name string) ([]int, error) {
+	v, err := im.value(name)
+	if err != nil {
+		return nil, err
+	}
+	var retVal []int
+	switch data := v.Data().(type) {
+	case []int64:
+		for _, d := range data {
+			retVal = append(retVal, int(d))
+		}
+	case []int32:
+		for _, d := range data {
+			retVal = append(retVal, int(d))
+		}
+	default:
+		return nil, errors.Errorf("Expected ONNX value %q to be integers. Got %T instead", name, data)
+	}
+	return retVal, nil
+}
+
+// asRow reshapes a vector bias into a (1, n) matrix, which is what the layers of this package use.
+func (im *importer) asRow(name string) (*G.Node, error) {
+	v, err := im.value(name)
+	if err != nil {
+		return nil, err
+	}
+	if v.Dims() != 1 {
+		return im.weight(name)
+	}
+	return im.weightAs(name, "/row", func(v *tensor.Dense) error { return v.Reshape(1, v.Shape()[0]) })
+}
+
+func (im *importer) gemm(n *onnx.NodeProto, name string) (Layer, string, error) {
+	for _, attr := range []string{"alpha", "beta"} {
+		if a := n.Attr(attr); a != nil && a.F != 1 {
+			return nil, "", errors.Errorf("Unable to import Gemm %q with %v = %v", n.Name, attr, a.F)
+		}
+	}
+	if a := n.Attr("transA"); a != nil && a.I != 0 {
+		return nil, "", errors.Errorf("Unable to import Gemm %q with transA", n.Name)
+	}
+	var w *G.Node
+	var err error
+	if a := n.Attr("transB"); a != nil && a.I != 0 {
+		w, err = im.weightAs(n.Input[1], "/T", func(v *tensor.Dense) error {
+			if err := v.T(); err != nil {
+				return err
+			}
+			return v.Transpose()
+		})
+	} else {
+		w, err = im.weight(n.Input[1])
+	}
+	if err != nil {
+		return nil, "", err
+	}
+	if len(n.Input) < 3 || n.Input[2] == "" {
+		return NewFC(WithName(name), WithSize(w.Shape()[1]), WithWeights(w), WithBias(false), AsBatched(true)), n.Input[0], nil
+	}
+	b, err := im.asRow(n.Input[2])
+	if err != nil {
+		return nil, "", err
+	}
+	return NewFC(WithName(name), WithSize(w.Shape()[1]), WithWB(w, b), AsBatched(true)), n.Input[0], nil
+}
+
+// matMulAdd imports a MatMul followed by an Add of a bias as a *FC. ok is false if the Add does not follow a MatMul.
+func (im *importer) matMulAdd(add *onnx.NodeProto) (l Layer, in string, ok bool, err error) {
+	data := im.dataInputs(add)[0]
+	mm, isProduced := im.producers[data]
+	if !isProduced || mm.OpType != "MatMul" || im.consumers[data] != 1 {
+		return nil, "", false, nil
+	}
+	if _, isInit := im.inits[mm.Input[1]]; !isInit {
+		return nil, "", false, nil
+	}
+	w, err := im.weight(mm.Input[1])
+	if err != nil {
+		return nil, "", true, err
+	}
+	b, err := im.asRow(im.other(add, data))
+	if err != nil {
+		return nil, "", true, err
+	}
+	name := mm.Name
+	if name == "" {
+		name = data
+	}
+	return NewFC(WithName(name), WithSize(w.Shape()[1]), WithWB(w, b), AsBatched(true)), mm.Input[0], true, nil
+}
+
+// symmetricPads converts ONNX's (begin..., end...) pads into golgi's symmetric pads.
+func symmetricPads(n *onnx.NodeProto, dims int) ([]int, error) {
+	if a := n.Attr("auto_pad"); a != nil && string(a.S) != "NOTSET" && string(a.S) != "" {
+		return nil, errors.Errorf("Unable to import %v %q with auto_pad %s", n.OpType, n.Name, a.S)
+	}
+	retVal := make([]int, dims)
+	a := n.Attr("pads")
+	if a == nil {
+		return retVal, nil
+	}
+	for i := range retVal {
+		if a.Ints[i] != a.Ints[i+dims] {
+			return nil, errors.Errorf("Unable to import %v %q with asymmetric pads %v", n.OpType, n.Name, a.Ints)
+		}
+		retVal[i] = int(a.Ints[i])
+	}
+	return retVal, nil
+}
+
+// importPadding converts ONNX's auto_pad and (begin..., end...) pads into a golgi padding scheme and symmetric pads. Pads that
+// only pad the start of a 1D input by the span of the kernel are causal.
+func importPadding(n *onnx.NodeProto, kernelShape, dilation []int) ([]int, Padding, error) {
+	dims := len(kernelShape)
+	if a := n.Attr("auto_pad"); a != nil {
+		switch string(a.S) {
+		case "SAME_UPPER":
+			return make([]int, dims), Same, nil
+		case "VALID":
+			return make([]int, dims), Valid, nil
+		}
+	}
+	if a := n.Attr("pads"); a != nil && dims == 1 && len(a.Ints) == 2 && a.Ints[1] == 0 && a.Ints[0] > 0 && int(a.Ints[0]) == dilation[0]*(kernelShape[0]-1) {
+		return make([]int, dims), Causal, nil
+	}
+	pad, err := symmetricPads(n, dims)
+	return pad, ExplicitPadding, err
+}
+
+// attrIntsOr returns the named attribute as a []int, or the default if the attribute does not exist.
+func attrIntsOr(n *onnx.NodeProto, name string, def []int) []int {
+	a := n.Attr(name)
+	if a == nil {
+		return def
+	}
+	retVal := make([]int, len(a.Ints))
+	for i, v := range a.Ints {
+		retVal[i] = int(v)
+	}
+	return retVal
+}
+
+func (im *importer) conv(n *onnx.NodeProto, name string) (Layer, string, error) {
+	groups := 1
+	if a := n.Attr("group"); a != nil {
+		groups = int(a.I)
+	}
+	w, err := im.weight(n.Input[1])
+	if err != nil {
+		return nil, "", err
+	}
+	wshp := w.Shape()
+	dims := wshp.Dims() - 2
+	if dims < 1 || dims > 3 {
+		return nil, "", errors.Errorf("Unable to import Conv %q: only 1D, 2D and 3D convolutions are supported", n.Name)
+	}
+	ones := make([]int, dims)
+	for i := range ones {
+		ones[i] = 1
+	}
+	kernelShape := cloneInts(wshp[2:])
+	dilation := attrIntsOr(n, "dilations", ones)
+	pad, padding, err := importPadding(n, kernelShape, dilation)
+	if err != nil {
+		return nil, "", err
+	}
+	l, err := newConv(dims,
+		WithName(name),
+		WithSize(wshp[0], wshp[1]*groups),
+		WithGroups(groups),
+		WithKernelShape(tensor.Shape(kernelShape)),
+		WithPad(pad),
+		WithPadding(padding),
+		WithStride(attrIntsOr(n, "strides", ones)),
+		WithDilation(dilation),
+	)
+	if err != nil {
+		return nil, "", err
+	}
+	l.w = w
+	if len(n.Input) > 2 && n.Input[2] != "" {
+		if l.b, err = im.weight(n.Input[2]); err != nil {
+			return nil, "", err
+		}
+	}
+	l.nobias = l.b == nil
+	l.act = nil
+	l.initialized = true
+	return l, n.Input[0], nil
+}
+
+func (im *importer) maxPool(n *onnx.NodeProto, name string) (Layer, string, error) {
+	if a := n.Attr("ceil_mode"); a != nil && a.I != 0 {
+		return nil, "", errors.Errorf("Unable to import MaxPool %q with ceil_mode", n.Name)
+	}
+	for _, d := range attrIntsOr(n, "dilations", nil) {
+		if d != 1 {
+			return nil, "", errors.Errorf("Unable to import MaxPool %q with dilations", n.Name)
+		}
+	}
+	ks := attrIntsOr(n, "kernel_shape", nil)
+	if len(ks) != 2 {
+		return nil, "", errors.Errorf("Unable to import MaxPool %q: only 2D pooling is supported", n.Name)
+	}
+	pad, padding, err := importPadding(n, ks, []int{1, 1})
+	if err != nil {
+		return nil, "", err
+	}
+	l, err := NewMaxPool(
+		WithName(name),
+		WithKernelShape(tensor.Shape(ks)),
+		WithPad(pad),
+		WithPadding(padding),
+		WithStride(attrIntsOr(n, "strides", []int{1, 1})),
+	)
+	if err != nil {
+		return nil, "", err
+	}
+	return l, n.Input[0], nil
+}
+
+// layerNormGemm imports a LayerNormalization with a unit scale and no bias that is followed by a Gemm as a single *layerNorm, which
+// is how (*layerNorm).Describe writes a layer norm. ok is false if the Gemm does not follow such a LayerNormalization.
+func (im *importer) layerNormGemm(gemm *onnx.NodeProto) (l Layer, in string, ok bool, err error) {
+	n, isProduced := im.producers[gemm.Input[0]]
+	if !isProduced || n.OpType != "LayerNormalization" || im.consumers[gemm.Input[0]] != 1 || len(gemm.Input) < 3 || gemm.Input[2] == "" {
+		return nil, "", false, nil
+	}
+	if len(n.Input) > 2 && n.Input[2] != "" {
+		return nil, "", false, nil
+	}
+	if _, isInit := im.inits[n.Input[1]]; !isInit {
+		return nil, "", false, nil
+	}
+	scale, err := im.value(n.Input[1])
+	if err != nil {
+		return nil, "", true, err
+	}
+	if !isOnes(scale) {
+		return nil, "", false, nil
+	}
+	eps, err := layerNormEps(n)
+	if err != nil {
+		return nil, "", true, err
+	}
+
+	name := n.Name
+	if name == "" {
+		name = n.Output[0]
+	}
+	fc, _, err := im.gemm(gemm, name)
+	if err != nil {
+		return nil, "", true, err
+	}
+	w, b := fc.(*FC).w, fc.(*FC).b
+	return MakeLayerNorm(WithName(name), WithSize(w.Shape()[1]), WithEps(eps), WithWB(w, b)), n.Input[0], true, nil
+}
+
+// isOnes returns true if every element of the value is 1.
+func isOnes(v *tensor.Dense) bool {
+	switch data := v.Data().(type) {
+	case []float32:
+		for _, d := range data {
+			if d != 1 {
+				return false
+			}
+		}
+		return true
+	case []float64:
+		for _, d := range data {
+			if d != 1 {
+				return false
+			}
+		}
+		return true
+	}
+	return false
+}
+
+// layerNormEps returns the epsilon of a LayerNormalization. Only the normalization over the last axis is supported.
+func layerNormEps(n *onnx.NodeProto) (float64, error) {
+	if a := n.Attr("axis"); a != nil && a.I != -1 {
+		return 0, errors.Errorf("Unable to import LayerNormalization %q with axis %d. Only the last axis is supported", n.Name, a.I)
+	}
+	eps := 1e-5
+	if a := n.Attr("epsilon"); a != nil {
+		eps = float64(a.F)
+	}
+	return eps, nil
+}
+
+func (im *importer) layerNorm(n *onnx.NodeProto, name string) (Layer, string, error) {
+	eps, err := layerNormEps(n)
+	if err != nil {
+		return nil, "", err
+	}
+	scale, err := im.value(n.Input[1])
+	if err != nil {
+		return nil, "", err
+	}
+	features := scale.Shape().TotalSize()
+
+	// the scale is elementwise, which is the same as multiplying with a diagonal matrix
+	var w, b *tensor.Dense
+	switch data := scale.Data().(type) {
+	case []float32:
+		diag := make([]float32, features*features)
+		for i, s := range data {
+			diag[i*features+i] = s
+		}
+		w = tensor.New(tensor.WithShape(features, features), tensor.WithBacking(diag))
+		b = tensor.New(tensor.WithShape(1, features), tensor.Of(tensor.Float32))
+	case []float64:
+		diag := make([]float64, features*features)
+		for i, s := range data {
+			diag[i*features+i] = s
+		}
+		w = tensor.New(tensor.WithShape(features, features), tensor.WithBacking(diag))
+		b = tensor.New(tensor.WithShape(1, features), tensor.Of(tensor.Float64))
+	default:
+		return nil, "", errors.Errorf("Unable to import LayerNormalization %q of %T", n.Name, data)
+	}
+	if len(n.Input) > 2 && n.Input[2] != "" {
+		if b, err = im.value(n.Input[2]); err != nil {
+			return nil, "", err
+		}
+		if err = b.Reshape(1, features); err != nil {
+			return nil, "", err
+		}
+	}
+	W := G.NodeFromAny(im.g, w, G.WithName(name+"_W"))
+	B := G.NodeFromAny(im.g, b, G.WithName(name+"_B"))
+	return MakeLayerNorm(WithName(name), WithSize(features), WithEps(eps), WithWB(W, B)), n.Input[0], nil
+}
+
+func (im *importer) gather(n *onnx.NodeProto, name string) (Layer, string, error) {
+	if a := n.Attr("axis"); a != nil && a.I != 0 {
+		return nil, "", errors.Errorf("Unable to import Gather %q with axis %d", n.Name, a.I)
+	}
+	w, err := im.weight(n.Input[0])
+	if err != nil {
+		return nil, "", err
+	}
+	if w.Shape().Dims() != 2 {
+		return nil, "", errors.Errorf("Unable to import Gather %q: expected the data to be a matrix. Got %v", n.Name, w.Shape())
+	}
+	return NewEmbedding(WithName(name), WithWeights(w), WithClasses(w.Shape()[0]), WithSize(w.Shape()[1])), n.Input[1], nil
+}
+
+// lstm imports the Unsqueeze → LSTM → Squeeze pattern written by (*LSTM).Describe as a *LSTM.
+func (im *importer) lstm(squeeze *onnx.NodeProto) (Layer, string, error) {
+	n, ok := im.producers[squeeze.Input[0]]
+	if !ok || n.OpType != "LSTM" || len(n.Output) < 2 || n.Output[1] != squeeze.Input[0] {
+		return nil, "", errors.Errorf("Unable to import Squeeze %q: only the final hidden state of a LSTM may be squeezed", squeeze.Name)
+	}
+	unsqueeze, ok := im.producers[n.Input[0]]
+	if !ok || unsqueeze.OpType != "Unsqueeze" {
+		return nil, "", errors.Errorf("Unable to import LSTM %q: only a LSTM over a single time step is supported", n.Name)
+	}
+	for _, in := range n.Input[4:] {
+		if in != "" {
+			return nil, "", errors.Errorf("Unable to import LSTM %q: sequence lengths, initial states and peepholes are not supported", n.Name)
+		}
+	}
+	if a := n.Attr("direction"); a != nil && string(a.S) != "forward" {
+		return nil, "", errors.Errorf("Unable to import LSTM %q with direction %s", n.Name, a.S)
+	}
+	if n.Attr("activations") != nil || n.Attr("clip") != nil || n.Attr("input_forget") != nil {
+		return nil, "", errors.Errorf("Unable to import LSTM %q: only the default LSTM is supported", n.Name)
+	}
+
+	W, err := im.value(n.Input[1])
+	if err != nil {
+		return nil, "", err
+	}
+	R, err := im.value(n.Input[2])
+	if err != nil {
+		return nil, "", err
+	}
+	size := W.Shape()[1] / 4
+	inner := W.Shape()[2]
+	if err = W.Reshape(4*size, inner); err != nil {
+		return nil, "", err
+	}
+	if err = R.Reshape(4*size, size); err != nil {
+		return nil, "", err
+	}
+	var B *tensor.Dense
+	if len(n.Input) > 3 && n.Input[3] != "" {
+		if B, err = im.value(n.Input[3]); err != nil {
+			return nil, "", err
+		}
+	} else {
+		B = tensor.New(tensor.WithShape(1, 8*size), tensor.Of(W.Dtype()))
+	}
+	if err = B.Reshape(2, 4*size); err != nil {
+		return nil, "", err
+	}
+	bs, err := B.Sum(0)
+	if err != nil {
+		return nil, "", err
+	}
+
+	name := n.Name
+	l := &LSTM{name: name, g: im.g, size: size}
+	gates := []*lstmGate{&l.input, &l.output, &l.forget, &l.cell}
+	suffixes := []string{"_i", "_o", "_f", "_c"}
+	for i, gate := range gates {
+		s := G.S(i*size, (i+1)*size)
+		wx, err := lstmGateWeight(W, s)
+		if err != nil {
+			return nil, "", err
+		}
+		wh, err := lstmGateWeight(R, s)
+		if err != nil {
+			return nil, "", err
+		}
+		bv, err := bs.Slice(s)
+		if err != nil {
+			return nil, "", err
+		}
+		b := tensor.Materialize(bv).(*tensor.Dense)
+		if err = b.Reshape(1, size); err != nil {
+			return nil, "", err
+		}
+		*gate = makeLSTMGate(
+			G.NodeFromAny(im.g, wx, G.WithName(name+suffixes[i]+"_wx")),
+			G.NodeFromAny(im.g, wh, G.WithName(name+suffixes[i]+"_wh")),
+			G.NodeFromAny(im.g, b, G.WithName(name+suffixes[i]+"_b")),
+		)
+		gate.act = G.Sigmoid
+	}
+	l.cell.act = G.Tanh
+	of := W.Dtype()
+	l.dummyHidden = G.NewMatrix(im.g, of, G.WithShape(1, size), G.WithName(name+"dummyHidden"), G.WithInit(G.Zeroes()))
+	l.dummyCell = G.NewMatrix(im.g, of, G.WithShape(1, size), G.WithName(name+"dummySize"), G.WithInit(G.Zeroes()))
+	l.initialized = true
+	return l, unsqueeze.Input[0], nil
+}
+
+// lstmGateWeight slices the rows of a ONNX LSTM weight, and transposes them into the (inner, size) layout used by lstmGate.
+func lstmGateWeight(w *tensor.Dense, s tensor.Slice) (*tensor.Dense, error) {
+	v, err := w.Slice(s)
+	if err != nil {
+		return nil, err
+	}
+	retVal, err := tensor.Transpose(tensor.Materialize(v))
+	if err != nil {
+		return nil, err
+	}
+	return retVal.(*tensor.Dense), nil
+}
+
+// activation folds an activation into the *FC or *Conv that precedes it.
+func (im *importer) activation(n *onnx.NodeProto, stop string) (Term, error) {
+	var act ActivationFunction
+	switch n.OpType {
+	case "Relu":
+		act = G.Rectify
+	case "Sigmoid":
+		act = G.Sigmoid
+	case "Tanh":
+		act = G.Tanh
+	case "Softmax":
+		act = SoftMaxFn
+	}
+	in := n.Input[0]
+	if im.consumers[in] != 1 {
+		return nil, errors.Errorf("Unable to import %v %q: its input %q is used elsewhere", n.OpType, n.Name, in)
+	}
+	prev, err := im.build(in, stop)
+	if err != nil {
+		return nil, err
+	}
+	last := prev
+	if c, ok := prev.(*Composition); ok {
+		last = c.b
+	}
+	// SoftMaxFn is a softmax on the last axis.
+	if a := n.Attr("axis"); n.OpType == "Softmax" && a != nil && a.I != -1 {
+		if _, ok := last.(*FC); !ok || a.I != 1 {
+			return nil, errors.Errorf("Unable to import Softmax %q on axis %d", n.Name, a.I)
+		}
+	}
+	switch l := last.(type) {
+	case *FC:
+		if l.act == nil {
+			l.act = act
+			return prev, nil
+		}
+	case *Conv:
+		if l.act == nil {
+			l.act = act
+			return prev, nil
+		}
+	}
+	return nil, errors.Errorf("Unable to import %v %q: an activation must follow a Gemm, MatMul or Conv", n.OpType, n.Name)
+}
+
+// join imports a binary node with two computed inputs as a *Join of two branches that start from the same value.
+func (im *importer) join(n *onnx.NodeProto, stop string) (Term, error) {
+	a, b := n.Input[0], n.Input[1]
+	fork, err := im.fork(a, b)
+	if err != nil {
+		return nil, errors.Wrapf(err, "Unable to import %v %q", n.OpType, n.Name)
+	}
+	ta, err := im.build(a, fork)
+	if err != nil {
+		return nil, err
+	}
+	tb, err := im.build(b, fork)
+	if err != nil {
+		return nil, err
+	}
+	var j *Join
+	switch n.OpType {
+	case "Add":
+		j = Add(ta, tb)
+	case "Mul":
+		j = HadamardProd(ta, tb)
+	}
+	return im.after(j, fork, stop)
+}
+
+// fork finds the nearest value that both `a` and `b` depend on.
+func (im *importer) fork(a, b string) (string, error) {
+	ancestors := make(map[string]struct{})
+	queue := []string{a}
+	for len(queue) > 0 {
+		v := queue[0]
+		queue = queue[1:]
+		if _, ok := ancestors[v]; ok {
+			continue
+		}
+		ancestors[v] = struct{}{}
+		if n, ok := im.producers[v]; ok {
+			queue = append(queue, im.dataInputs(n)...)
+		}
+	}
+
+	seen := make(map[string]struct{})
+	queue = []string{b}
+	for len(queue) > 0 {
+		v := queue[0]
+		queue = queue[1:]
+		if _, ok := ancestors[v]; ok {
+			return v, nil
+		}
+		if _, ok := seen[v]; ok {
+			continue
+		}
+		seen[v] = struct{}{}
+		if n, ok := im.producers[v]; ok {
+			queue = append(queue, im.dataInputs(n)...)
+		}
+	}
+	return "", errors.Errorf("%q and %q do not share an input", a, b)
+}
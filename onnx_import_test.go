@@ -0,0 +1,238 @@
+package golgi
+
+import (
+	"bytes"
+	"testing"
+
+	"github.com/stretchr/testify/require"
+	"gorgonia.org/golgi/onnx"
+	"gorgonia.org/gorgonia"
+	"gorgonia.org/tensor"
+)
+
+// runValue runs the graph and returns the value of the node.
+func runValue(t *testing.T, g *gorgonia.ExprGraph, n *gorgonia.Node) []float64 {
+	var v gorgonia.Value
+	gorgonia.Read(n, &v)
+	m := gorgonia.NewTapeMachine(g)
+	defer m.Close()
+	if err := m.RunAll(); err != nil {
+		t.Fatal(err)
+	}
+	return append([]float64(nil), v.Data().([]float64)...)
+}
+
+func TestImportONNX_RoundTrip(t *testing.T) {
+	c := require.New(t)
+	xT := tensor.New(tensor.WithShape(4, 6), tensor.WithBacking(tensor.Range(tensor.Float64, 0, 24)))
+
+	g := gorgonia.NewGraph()
+	x := gorgonia.NewMatrix(g, tensor.Float64, gorgonia.WithName("x"), gorgonia.WithShape(4, 6), gorgonia.WithValue(xT))
+	residual, err := ConsFC(x, WithName("res"), WithSize(6), WithActivation(gorgonia.Tanh))
+	c.NoError(err)
+	nn, err := ComposeSeq(
+		x,
+		L(ConsFC, WithName("l0"), WithSize(6), WithActivation(gorgonia.Sigmoid)),
+		Add(I{}, residual),
+		L(ConsLayerNorm, WithName("norm"), WithSize(5)),
+		L(ConsFC, WithName("l1"), WithSize(3), WithActivation(SoftMaxFn), WithBias(false)),
+	)
+	c.NoError(err)
+	out := nn.Fwd(x)
+	c.NoError(gorgonia.CheckOne(out))
+
+	var buf bytes.Buffer
+	c.NoError(ExportONNX(nn, &buf))
+
+	g2 := gorgonia.NewGraph()
+	x2 := gorgonia.NewMatrix(g2, tensor.Float64, gorgonia.WithName("x"), gorgonia.WithShape(4, 6), gorgonia.WithValue(xT.Clone()))
+	imported, err := ImportONNX(g2, &buf)
+	c.NoError(err)
+	_, ok := imported.(*Composition)
+	c.True(ok)
+	c.Equal(len(nn.Model()), len(imported.Model()), "the layer norm is imported as a single layer")
+	out2 := imported.Fwd(x2)
+	c.NoError(gorgonia.CheckOne(out2))
+
+	// the imported weights are the exported weights
+	model := make(map[string]gorgonia.Value)
+	for _, w := range imported.Model() {
+		model[w.Name()] = w.Value()
+	}
+	for _, w := range nn.Model() {
+		v, ok := model[w.Name()]
+		c.True(ok, "%v was not imported", w.Name())
+		c.Equal(w.Value().Data(), v.Data())
+	}
+
+	want := runValue(t, g, out.Node())
+	got := runValue(t, g2, out2.Node())
+	c.InDeltaSlice(want, got, 1e-10)
+}
+
+func TestImportONNX_LSTM(t *testing.T) {
+	c := require.New(t)
+	g := gorgonia.NewGraph()
+	x := gorgonia.NewMatrix(g, tensor.Float64, gorgonia.WithName("x"), gorgonia.WithShape(2, 3), gorgonia.WithInit(gorgonia.GlorotU(1)))
+	l, err := ConsLSTM(x, WithSize(4))
+	c.NoError(err)
+
+	var buf bytes.Buffer
+	c.NoError(ExportONNX(l, &buf))
+
+	imported, err := ImportONNX(gorgonia.NewGraph(), &buf)
+	c.NoError(err)
+	lstm, ok := imported.(*Composition).b.(*LSTM)
+	c.True(ok)
+	c.Equal(4, lstm.size)
+
+	want := l.(*LSTM).Model()
+	got := lstm.Model()
+	c.Equal(len(want), len(got))
+	for i := range want {
+		c.Equal(want[i].Shape(), got[i].Shape(), "%v", want[i].Name())
+		c.Equal(want[i].Value().Data(), got[i].Value().Data(), "%v", want[i].Name())
+	}
+}
+
+func TestImportONNX_Reshape(t *testing.T) {
+	c := require.New(t)
+
+	// the shape of a typical exported classifier head: Reshape → Gemm with a transposed weight → Relu
+	wT := &onnx.TensorProto{Name: "w", DataType: onnx.Float, Dims: []int64{3, 8}, FloatData: make([]float32, 24)}
+	for i := range wT.FloatData {
+		wT.FloatData[i] = float32(i)
+	}
+	b := &onnx.TensorProto{Name: "b", DataType: onnx.Float, Dims: []int64{3}, FloatData: []float32{1, 2, 3}}
+	shp := &onnx.TensorProto{Name: "shape", DataType: onnx.Int64, Dims: []int64{2}, Int64Data: []int64{5, 8}}
+	m := &onnx.ModelProto{
+		IrVersion: onnx.IRVersion,
+		Graph: &onnx.GraphProto{
+			Node: []*onnx.NodeProto{
+				{OpType: "Reshape", Input: []string{"x", "shape"}, Output: []string{"flat"}},
+				{Name: "head", OpType: "Gemm", Input: []string{"flat", "w", "b"}, Output: []string{"gemm"}, Attribute: []*onnx.AttributeProto{attrInt("transB", 1)}},
+				{OpType: "Relu", Input: []string{"gemm"}, Output: []string{"y"}},
+			},
+			Initializer: []*onnx.TensorProto{wT, b, shp},
+			Input:       []*onnx.ValueInfoProto{{Name: "x"}},
+			Output:      []*onnx.ValueInfoProto{{Name: "y"}},
+		},
+	}
+	buf, err := onnx.Marshal(m)
+	c.NoError(err)
+
+	g := gorgonia.NewGraph()
+	x := gorgonia.NewTensor(g, tensor.Float32, 4, gorgonia.WithName("x"), gorgonia.WithShape(5, 2, 2, 2), gorgonia.WithInit(gorgonia.GlorotU(1)))
+	nn, err := ImportONNX(g, bytes.NewReader(buf))
+	c.NoError(err)
+	out := nn.Fwd(x)
+	c.NoError(gorgonia.CheckOne(out))
+	c.Equal(tensor.Shape{5, 3}, out.Node().Shape())
+
+	head := nn.(*Composition).ByName("head").(*FC)
+	c.Equal(tensor.Shape{8, 3}, head.w.Shape())
+	c.Equal(tensor.Shape{1, 3}, head.b.Shape())
+	c.NotNil(head.act)
+
+	// copied and inferred dimensions are resolved when the layer is applied
+	shp.Int64Data = []int64{0, -1}
+	buf, err = onnx.Marshal(m)
+	c.NoError(err)
+	nn, err = ImportONNX(g, bytes.NewReader(buf))
+	c.NoError(err)
+	out = nn.Fwd(x)
+	c.NoError(gorgonia.CheckOne(out))
+	c.Equal(tensor.Shape{5, 3}, out.Node().Shape())
+}
+
+func TestImportONNX_SharedWeights(t *testing.T) {
+	c := require.New(t)
+
+	// two Gemms share a transposed weight and a bias
+	wT := &onnx.TensorProto{Name: "w", DataType: onnx.Float, Dims: []int64{3, 3}, FloatData: []float32{1, 2, 3, 4, 5, 6, 7, 8, 9}}
+	b := &onnx.TensorProto{Name: "b", DataType: onnx.Float, Dims: []int64{3}, FloatData: []float32{1, 2, 3}}
+	trans := []*onnx.AttributeProto{attrInt("transB", 1)}
+	m := &onnx.ModelProto{
+		IrVersion: onnx.IRVersion,
+		Graph: &onnx.GraphProto{
+			Node: []*onnx.NodeProto{
+				{Name: "g1", OpType: "Gemm", Input: []string{"x", "w", "b"}, Output: []string{"h"}, Attribute: trans},
+				{Name: "g2", OpType: "Gemm", Input: []string{"h", "w", "b"}, Output: []string{"y"}, Attribute: trans},
+			},
+			Initializer: []*onnx.TensorProto{wT, b},
+			Input:       []*onnx.ValueInfoProto{{Name: "x"}},
+			Output:      []*onnx.ValueInfoProto{{Name: "y"}},
+		},
+	}
+	buf, err := onnx.Marshal(m)
+	c.NoError(err)
+
+	g := gorgonia.NewGraph()
+	x := gorgonia.NewMatrix(g, tensor.Float32, gorgonia.WithName("x"), gorgonia.WithShape(2, 3), gorgonia.WithInit(gorgonia.GlorotU(1)))
+	nn, err := ImportONNX(g, bytes.NewReader(buf))
+	c.NoError(err)
+	c.NoError(gorgonia.CheckOne(nn.Fwd(x)))
+
+	g1 := nn.(*Composition).ByName("g1").(*FC)
+	g2 := nn.(*Composition).ByName("g2").(*FC)
+	c.True(g1.w == g2.w, "the weight is tied")
+	c.True(g1.b == g2.b, "the bias is tied")
+	// the weight is transposed exactly once
+	c.Equal([]float32{1, 4, 7, 2, 5, 8, 3, 6, 9}, g1.w.Value().Data())
+}
+
+func TestImportONNX_Flatten(t *testing.T) {
+	c := require.New(t)
+
+	// the shape of a typical exported classifier head: Flatten → Gemm with a transposed weight → Relu
+	wT := &onnx.TensorProto{Name: "w", DataType: onnx.Float, Dims: []int64{3, 8}, FloatData: make([]float32, 24)}
+	for i := range wT.FloatData {
+		wT.FloatData[i] = float32(i)
+	}
+	b := &onnx.TensorProto{Name: "b", DataType: onnx.Float, Dims: []int64{3}, FloatData: []float32{1, 2, 3}}
+	m := &onnx.ModelProto{
+		IrVersion: onnx.IRVersion,
+		Graph: &onnx.GraphProto{
+			Node: []*onnx.NodeProto{
+				{OpType: "Flatten", Input: []string{"x"}, Output: []string{"flat"}},
+				{Name: "head", OpType: "Gemm", Input: []string{"flat", "w", "b"}, Output: []string{"gemm"}, Attribute: []*onnx.AttributeProto{attrInt("transB", 1)}},
+				{OpType: "Relu", Input: []string{"gemm"}, Output: []string{"y"}},
+			},
+			Initializer: []*onnx.TensorProto{wT, b},
+			Input:       []*onnx.ValueInfoProto{{Name: "x"}},
+			Output:      []*onnx.ValueInfoProto{{Name: "y"}},
+		},
+	}
+	buf, err := onnx.Marshal(m)
+	c.NoError(err)
+
+	g := gorgonia.NewGraph()
+	x := gorgonia.NewTensor(g, tensor.Float32, 4, gorgonia.WithName("x"), gorgonia.WithShape(5, 2, 2, 2), gorgonia.WithInit(gorgonia.GlorotU(1)))
+	nn, err := ImportONNX(g, bytes.NewReader(buf))
+	c.NoError(err)
+	out := nn.Fwd(x)
+	c.NoError(gorgonia.CheckOne(out))
+	c.Equal(tensor.Shape{5, 3}, out.Node().Shape())
+
+	head := nn.(*Composition).ByName("head").(*FC)
+	c.Equal(tensor.Shape{8, 3}, head.w.Shape())
+	c.Equal(tensor.Shape{1, 3}, head.b.Shape())
+	c.NotNil(head.act)
+}
+
+func TestImportONNX_Unsupported(t *testing.T) {
+	c := require.New(t)
+	m := &onnx.ModelProto{
+		IrVersion: onnx.IRVersion,
+		Graph: &onnx.GraphProto{
+			Node:   []*onnx.NodeProto{{Name: "erf", OpType: "Erf", Input: []string{"x"}, Output: []string{"y"}}},
+			Input:  []*onnx.ValueInfoProto{{Name: "x"}},
+			Output: []*onnx.ValueInfoProto{{Name: "y"}},
+		},
+	}
+	buf, err := onnx.Marshal(m)
+	c.NoError(err)
+	_, err = ImportONNX(gorgonia.NewGraph(), bytes.NewReader(buf))
+	c.Error(err)
+	c.Contains(err.Error(), "Erf")
+}
@@ -0,0 +1,177 @@
+package golgi
+
+import (
+	"bytes"
+	"testing"
+
+	"github.com/stretchr/testify/require"
+	"gorgonia.org/golgi/onnx"
+	"gorgonia.org/gorgonia"
+	"gorgonia.org/tensor"
+)
+
+// checkWellFormed checks that every input of every node is defined before it is used.
+func checkWellFormed(t *testing.T, g *onnx.GraphProto) {
+	defined := map[string]struct{}{"": {}}
+	for _, in := range g.Input {
+		defined[in.Name] = struct{}{}
+	}
+	for _, init := range g.Initializer {
+		if _, ok := defined[init.Name]; ok {
+			t.Errorf("%v is defined twice", init.Name)
+		}
+		defined[init.Name] = struct{}{}
+	}
+	for _, n := range g.Node {
+		for _, in := range n.Input {
+			if _, ok := defined[in]; !ok {
+				t.Errorf("Node %v (%v) uses undefined value %q", n.Name, n.OpType, in)
+			}
+		}
+		for _, out := range n.Output {
+			if out == "" {
+				continue
+			}
+			if _, ok := defined[out]; ok {
+				t.Errorf("%v is defined twice", out)
+			}
+			defined[out] = struct{}{}
+		}
+	}
+	for _, out := range g.Output {
+		if _, ok := defined[out.Name]; !ok {
+			t.Errorf("Output %q is undefined", out.Name)
+		}
+	}
+}
+
+func opTypes(g *onnx.GraphProto) []string {
+	retVal := make([]string, 0, len(g.Node))
+	for _, n := range g.Node {
+		retVal = append(retVal, n.OpType)
+	}
+	return retVal
+}
+
+func TestExportONNX(t *testing.T) {
+	c := require.New(t)
+	n := 10
+	g := gorgonia.NewGraph()
+	x := gorgonia.NewTensor(g, tensor.Float64, 4, gorgonia.WithName("X"), gorgonia.WithShape(n, 1, 28, 28), gorgonia.WithInit(gorgonia.GlorotU(1)))
+	nn, err := ComposeSeq(
+		x,
+		L(ConsConv, WithName("conv"), WithSize(4, 1), WithKernelShape(tensor.Shape{3, 3})),
+		L(ConsBatchNorm, WithName("bn")),
+		L(ConsMaxPool, WithName("pool"), WithKernelShape(tensor.Shape{2, 2})),
+		L(ConsReshape, ToShape(n, 4*14*14)),
+		L(ConsDropout, WithProbability(0.5)),
+		L(ConsFC, WithSize(50), WithName("l0"), WithActivation(gorgonia.Tanh)),
+		L(ConsLayerNorm, WithSize(20), WithName("Norm"), WithEps(0.001)),
+		L(ConsFC, WithSize(10), WithName("l1"), WithActivation(SoftMaxFn), WithBias(false)),
+	)
+	c.NoError(err)
+	c.NoError(gorgonia.CheckOne(nn.Fwd(x)))
+
+	var buf bytes.Buffer
+	c.NoError(ExportONNX(nn, &buf))
+
+	var m onnx.ModelProto
+	c.NoError(onnx.Unmarshal(buf.Bytes(), &m))
+	c.Equal(int64(onnx.IRVersion), m.IrVersion)
+	c.Equal(int64(onnx.OpsetVersion), m.OpsetImport[0].Version)
+
+	c.Equal([]string{"Conv", "Relu", "BatchNormalization", "MaxPool", "Reshape", "Dropout", "Gemm", "Tanh", "LayerNormalization", "Gemm", "MatMul", "Softmax"}, opTypes(m.Graph))
+	c.Len(m.Graph.Input, 1)
+	c.Len(m.Graph.Output, 1)
+	c.Equal(onnx.Double, m.Graph.Input[0].Type.TensorType.ElemType)
+	checkWellFormed(t, m.Graph)
+
+	// every weight is an initializer, with the trained values
+	inits := make(map[string]*onnx.TensorProto)
+	for _, init := range m.Graph.Initializer {
+		inits[init.Name] = init
+	}
+	for _, w := range nn.Model() {
+		init, ok := inits[w.Name()]
+		c.True(ok, "%v is not an initializer", w.Name())
+		c.Equal(w.Value().Data(), init.DoubleData)
+	}
+}
+
+func TestDescribe_Join(t *testing.T) {
+	c := require.New(t)
+	g := gorgonia.NewGraph()
+	x := gorgonia.NewMatrix(g, tensor.Float32, gorgonia.WithName("x"), gorgonia.WithShape(4, 3), gorgonia.WithInit(gorgonia.GlorotU(1)))
+
+	// two unnamed FCs to check that the names are made unique
+	a, err := ConsFC(x, WithSize(5))
+	c.NoError(err)
+	b, err := ConsFC(x, WithSize(5))
+	c.NoError(err)
+	j := Add(a, b)
+	c.NoError(gorgonia.CheckOne(j.Fwd(x)))
+
+	desc, err := j.Describe()
+	c.NoError(err)
+	c.Equal([]string{"Gemm", "Gemm", "Add"}, opTypes(desc))
+	c.Len(desc.Initializer, 4)
+	checkWellFormed(t, desc)
+	c.Equal(desc.Input[0].Name, desc.Node[0].Input[0])
+	c.Equal(desc.Input[0].Name, desc.Node[1].Input[0])
+}
+
+func TestDescribe_LSTM(t *testing.T) {
+	c := require.New(t)
+	g := gorgonia.NewGraph()
+	x := gorgonia.NewMatrix(g, tensor.Float64, gorgonia.WithName("x"), gorgonia.WithShape(2, 3), gorgonia.WithInit(gorgonia.GlorotU(1)))
+	l, err := ConsLSTM(x, WithSize(4))
+	c.NoError(err)
+
+	desc, err := l.Describe()
+	c.NoError(err)
+	c.Equal([]string{"Unsqueeze", "LSTM", "Squeeze"}, opTypes(desc))
+	checkWellFormed(t, desc)
+
+	shapes := make(map[string][]int64)
+	for _, init := range desc.Initializer {
+		shapes[init.Name] = init.Dims
+	}
+	c.Equal([]int64{1, 16, 3}, shapes["LSTM_W"])
+	c.Equal([]int64{1, 16, 4}, shapes["LSTM_R"])
+	c.Equal([]int64{1, 32}, shapes["LSTM_B"])
+}
+
+func TestDescribe_Embedding(t *testing.T) {
+	c := require.New(t)
+	g := gorgonia.NewGraph()
+	w := gorgonia.NewMatrix(g, tensor.Float64, gorgonia.WithShape(13, 5), gorgonia.WithName("embW"), gorgonia.WithInit(gorgonia.GlorotN(1)))
+	emb := NewEmbedding(WithWeights(w), WithName("emb"), WithClasses(13))
+
+	desc, err := emb.Describe()
+	c.NoError(err)
+	c.Equal([]string{"Gather"}, opTypes(desc))
+	c.Equal([]string{"embW", desc.Input[0].Name}, desc.Node[0].Input)
+	c.Equal(onnx.Int64, desc.Input[0].Type.TensorType.ElemType)
+}
+
+func TestDescribe_Unconstructed(t *testing.T) {
+	c := require.New(t)
+	nn := Compose(L(ConsFC, WithSize(5)), L(ConsFC, WithSize(3)))
+	_, err := nn.Describe()
+	c.Error(err)
+
+	// the recurrent layers are not initialized until they are constructed with an input
+	_, err = (&LSTM{name: "lstm"}).Describe()
+	c.Error(err)
+	_, err = (&GRU{name: "gru"}).Describe()
+	c.Error(err)
+
+	// nor are the layers that are created without an input
+	conv, err := NewConv(WithSize(3))
+	c.NoError(err)
+	for _, l := range []Layer{NewFC(WithName("fc"), WithSize(3)), conv, NewLayerNorm(WithName("norm"), WithSize(3)), NewEmbedding(WithName("emb"), WithSize(3), WithClasses(4))} {
+		_, err = l.Describe()
+		c.Error(err, l.Name())
+	}
+	c.Error(ExportONNX(Compose(NewFC(WithName("a"), WithSize(3)), NewFC(WithName("b"), WithSize(2))), new(bytes.Buffer)))
+}
@@ -0,0 +1,130 @@
+package golgi
+
+import (
+	"math"
+
+	"github.com/pkg/errors"
+	"gorgonia.org/golgi/onnx"
+	G "gorgonia.org/gorgonia"
+	"gorgonia.org/tensor"
+)
+
+// Padding is a padding scheme of a convolution or a pooling layer. Unlike the pads given with WithPad, the pads of a padding
+// scheme are computed from the shape of the input, the kernel shape, the stride and the dilation every time the layer is applied.
+type Padding byte
+
+const (
+	// ExplicitPadding uses the pads given with WithPad. This is the default.
+	ExplicitPadding Padding = iota
+	// Same pads the input so that the output has ceil(in/stride) positions along every spatial axis. When the total padding of an
+	// axis is odd, the extra value is padded at the end, as ONNX's SAME_UPPER.
+	Same
+	// Valid does not pad the input. Only the positions where the kernel fits entirely within the input are computed.
+	Valid
+	// Causal pads the start of the input only, so that an output never depends on the inputs that come after it. Causal padding is
+	// only supported by 1D convolutions.
+	Causal
+)
+
+func (p Padding) String() string {
+	switch p {
+	case ExplicitPadding:
+		return "Explicit"
+	case Same:
+		return "Same"
+	case Valid:
+		return "Valid"
+	case Causal:
+		return "Causal"
+	}
+	return "UNKNOWN PADDING"
+}
+
+// pads computes the padding before and after every spatial axis of an input of shape (N, C, spatial...).
+func (p Padding) pads(in tensor.Shape, kernelShape tensor.Shape, pad, stride, dilation []int) (before, after []int, err error) {
+	dims := len(kernelShape)
+	if in.Dims() != dims+2 {
+		return nil, nil, errors.Errorf("Expected an input with %d dimensions. Got %v instead", dims+2, in)
+	}
+	before, after = make([]int, dims), make([]int, dims)
+	for i, k := range kernelShape {
+		span := dilation[i]*(k-1) + 1
+		switch p {
+		case ExplicitPadding:
+			before[i], after[i] = pad[i], pad[i]
+		case Valid:
+		case Same:
+			out := (in[2+i] + stride[i] - 1) / stride[i]
+			total := (out-1)*stride[i] + span - in[2+i]
+			if total > 0 {
+				before[i] = total / 2
+				after[i] = total - before[i]
+			}
+		case Causal:
+			if dims != 1 {
+				return nil, nil, errors.Errorf("Causal padding is only supported by 1D convolutions. Got kernel shape %v", kernelShape)
+			}
+			before[i] = span - 1
+		default:
+			return nil, nil, errors.Errorf("Unknown padding %v", p)
+		}
+	}
+	return before, after, nil
+}
+
+// spatialOutputShape computes the shape of the output of a convolution or a pooling with the given pads. The input is of shape
+// (N, C, spatial...), and the output is of shape (N, channels, spatial...).
+func spatialOutputShape(in tensor.Shape, channels int, kernelShape tensor.Shape, before, after, stride, dilation []int) (tensor.Shape, error) {
+	retVal := tensor.Shape{in[0], channels}
+	for i, k := range kernelShape {
+		padded, span := in[2+i]+before[i]+after[i], dilation[i]*(k-1)+1
+		if padded < span {
+			return nil, errors.Errorf("The kernel %v does not fit in the input %v", kernelShape, in)
+		}
+		retVal = append(retVal, (padded-span)/stride[i]+1)
+	}
+	return retVal, nil
+}
+
+// padSpatial pads the spatial axes of x with the values of fill, so that the remaining padding of every axis is symmetric.
+// It returns the padded input and the remaining symmetric pads.
+func padSpatial(x *G.Node, before, after []int, fill G.InitWFn, name string) (retVal *G.Node, pad []int, err error) {
+	retVal = x
+	pad = make([]int, len(before))
+	for i := range before {
+		pad[i] = before[i]
+		if after[i] < pad[i] {
+			pad[i] = after[i]
+		}
+		if retVal, err = padAlongWith(retVal, 2+i, before[i]-pad[i], after[i]-pad[i], name, fill); err != nil {
+			return nil, nil, err
+		}
+	}
+	return retVal, pad, nil
+}
+
+// lowest returns an initialization function that fills a tensor with the lowest finite value of its Dtype. Max pooling pads
+// with it, so that a padded value is never the maximum of a window.
+func lowest(dt tensor.Dtype) G.InitWFn {
+	if dt == tensor.Float32 {
+		return G.ValuesOf(float32(-math.MaxFloat32))
+	}
+	return G.ValuesOf(-math.MaxFloat64)
+}
+
+// onnxPadding describes a padding scheme as ONNX attributes. Causal padding is described with explicit pads.
+func onnxPadding(p Padding, kernelShape tensor.Shape, pad, dilation []int) []*onnx.AttributeProto {
+	switch p {
+	case Same:
+		return []*onnx.AttributeProto{attrString("auto_pad", "SAME_UPPER")}
+	case Valid:
+		return []*onnx.AttributeProto{attrString("auto_pad", "VALID")}
+	case Causal:
+		pads := make([]int, 2*len(kernelShape))
+		for i, k := range kernelShape {
+			pads[i] = dilation[i] * (k - 1)
+		}
+		return []*onnx.AttributeProto{attrInts("pads", pads...)}
+	}
+	return []*onnx.AttributeProto{attrInts("pads", onnxPads(pad)...)}
+}
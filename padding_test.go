@@ -0,0 +1,217 @@
+package golgi
+
+import (
+	"bytes"
+	"math"
+	"testing"
+
+	"github.com/stretchr/testify/require"
+	"gorgonia.org/gorgonia"
+	"gorgonia.org/tensor"
+)
+
+// padRef pads the spatial axes of x with the given value.
+func padRef(xs []float64, xshp tensor.Shape, before, after []int, fill float64) ([]float64, tensor.Shape) {
+	shp := xshp.Clone()
+	for i := range before {
+		shp[2+i] += before[i] + after[i]
+	}
+	retVal := make([]float64, shp.TotalSize())
+	for i := range retVal {
+		retVal[i] = fill
+	}
+	coords := make([]int, len(xshp))
+	for i, x := range xs {
+		rem := i
+		for d := len(xshp) - 1; d >= 0; d-- {
+			coords[d] = rem % xshp[d]
+			rem /= xshp[d]
+		}
+		var at int
+		for d := range shp {
+			c := coords[d]
+			if d >= 2 {
+				c += before[d-2]
+			}
+			at = at*shp[d] + c
+		}
+		retVal[at] = x
+	}
+	return retVal, shp
+}
+
+func TestWithPadding_Conv(t *testing.T) {
+	cases := []struct {
+		name             string
+		cons             LayerCons
+		padding          Padding
+		xshp             tensor.Shape
+		kernel           tensor.Shape
+		stride, dilation []int
+		before, after    []int
+		out              tensor.Shape
+	}{
+		{"1D same", ConsConv1D, Same, tensor.Shape{1, 2, 7}, tensor.Shape{4}, []int{1}, []int{1}, []int{1}, []int{2}, tensor.Shape{1, 3, 7}},
+		{"1D causal", ConsConv1D, Causal, tensor.Shape{2, 2, 6}, tensor.Shape{3}, []int{1}, []int{2}, []int{4}, []int{0}, tensor.Shape{2, 3, 6}},
+		{"1D causal strided", ConsConv1D, Causal, tensor.Shape{1, 2, 7}, tensor.Shape{2}, []int{2}, []int{1}, []int{1}, []int{0}, tensor.Shape{1, 3, 4}},
+		{"2D same strided", ConsConv, Same, tensor.Shape{1, 2, 5, 6}, tensor.Shape{3, 2}, []int{2, 2}, []int{1, 1}, []int{1, 0}, []int{1, 0}, tensor.Shape{1, 3, 3, 3}},
+		{"2D same even", ConsConv, Same, tensor.Shape{1, 1, 4, 4}, tensor.Shape{2, 4}, []int{1, 1}, []int{1, 1}, []int{0, 1}, []int{1, 2}, tensor.Shape{1, 3, 4, 4}},
+		{"2D valid", ConsConv, Valid, tensor.Shape{1, 2, 5, 5}, tensor.Shape{3, 3}, []int{1, 2}, []int{1, 1}, []int{0, 0}, []int{0, 0}, tensor.Shape{1, 3, 3, 2}},
+		{"3D same", ConsConv3D, Same, tensor.Shape{1, 1, 4, 3, 3}, tensor.Shape{2, 3, 3}, []int{1, 1, 1}, []int{1, 1, 1}, []int{0, 1, 1}, []int{1, 1, 1}, tensor.Shape{1, 3, 4, 3, 3}},
+	}
+	for _, tc := range cases {
+		t.Run(tc.name, func(t *testing.T) {
+			c := require.New(t)
+			xs := make([]float64, tc.xshp.TotalSize())
+			for i := range xs {
+				xs[i] = float64((i*7)%13)/13 - 0.5
+			}
+			newInput := func(g *gorgonia.ExprGraph) *gorgonia.Node {
+				return gorgonia.NewTensor(g, tensor.Float64, tc.xshp.Dims(), gorgonia.WithName("x"), gorgonia.WithShape(tc.xshp...), gorgonia.WithValue(tensor.New(tensor.WithShape(tc.xshp...), tensor.WithBacking(append([]float64(nil), xs...)))))
+			}
+			g := gorgonia.NewGraph()
+			x := newInput(g)
+
+			// the pads given with WithPad are ignored
+			l, err := tc.cons(x, WithName("conv"), WithSize(3, tc.xshp[1]), WithKernelShape(tc.kernel), WithPad(make([]int, len(tc.kernel))), WithPadding(tc.padding),
+				WithStride(tc.stride), WithDilation(tc.dilation), WithActivation(nil), WithBias(false), ComputeFLOPs(true))
+			c.NoError(err)
+			conv := l.(*Conv)
+			shp, err := conv.OutputShape(tc.xshp)
+			c.NoError(err)
+			c.Equal(tc.out, shp)
+
+			out := conv.Fwd(x)
+			c.NoError(gorgonia.CheckOne(out))
+			c.Equal(tc.out, out.Node().Shape())
+			c.Equal((tc.xshp[1]*tc.kernel.TotalSize()+1)*tc.out[2:].TotalSize()*3, conv.FLOPs())
+
+			padded, pshp := padRef(xs, tc.xshp, tc.before, tc.after, 0)
+			expected, eshp := convRef(padded, pshp, conv.w.Value().Data().([]float64), conv.w.Shape(), make([]int, len(tc.kernel)), tc.stride, tc.dilation)
+			c.Equal(tc.out, eshp)
+			cost, err := gorgonia.Sum(out.Node())
+			c.NoError(err)
+			_, err = gorgonia.Grad(cost, conv.w)
+			c.NoError(err)
+			c.InDeltaSlice(expected, runValue(t, g, out.Node()), 1e-10)
+
+			// the padding survives an ONNX round trip
+			var buf bytes.Buffer
+			c.NoError(ExportONNX(conv, &buf))
+			g2 := gorgonia.NewGraph()
+			x2 := newInput(g2)
+			imported, err := ImportONNX(g2, &buf)
+			c.NoError(err)
+			out2 := imported.Fwd(x2)
+			c.NoError(gorgonia.CheckOne(out2))
+			c.InDeltaSlice(expected, runValue(t, g2, out2.Node()), 1e-10)
+		})
+	}
+
+	c := require.New(t)
+	g := gorgonia.NewGraph()
+	x := gorgonia.NewTensor(g, tensor.Float64, 4, gorgonia.WithShape(1, 1, 4, 4), gorgonia.WithInit(gorgonia.GlorotU(1)))
+	_, err := ConsConv(x, WithSize(1, 1), WithPadding(Causal))
+	c.Error(err)
+	_, err = NewMaxPool(WithPadding(Causal))
+	c.Error(err)
+	_, err = NewAvgPool(WithPadding(Same))
+	c.Error(err)
+}
+
+func TestWithPadding_MaxPool(t *testing.T) {
+	c := require.New(t)
+	g := gorgonia.NewGraph()
+	xs := []float64{
+		-1, -2, -3,
+		-4, -5, -6,
+		-7, -8, -9,
+	}
+	x := gorgonia.NewTensor(g, tensor.Float64, 4, gorgonia.WithName("x"), gorgonia.WithShape(1, 1, 3, 3), gorgonia.WithValue(tensor.New(tensor.WithShape(1, 1, 3, 3), tensor.WithBacking(xs))))
+
+	l, err := ConsMaxPool(x, WithName("pool"), WithKernelShape(tensor.Shape{2, 2}), WithStride([]int{1, 1}), WithPadding(Same))
+	c.NoError(err)
+	pool := l.(*MaxPool)
+	shp, err := pool.OutputShape(x.Shape())
+	c.NoError(err)
+	c.Equal(tensor.Shape{1, 1, 3, 3}, shp)
+
+	out := pool.Fwd(x)
+	c.NoError(gorgonia.CheckOne(out))
+	c.Equal(shp, out.Node().Shape())
+	cost, err := gorgonia.Sum(out.Node())
+	c.NoError(err)
+	_, err = gorgonia.Grad(cost, x)
+	c.NoError(err)
+
+	// the padded values never win, even though every input is negative
+	padded, _ := padRef(xs, tensor.Shape{1, 1, 3, 3}, []int{0, 0}, []int{1, 1}, math.Inf(-1))
+	var expected []float64
+	for i := 0; i < 3; i++ {
+		for j := 0; j < 3; j++ {
+			expected = append(expected, math.Max(math.Max(padded[i*4+j], padded[i*4+j+1]), math.Max(padded[(i+1)*4+j], padded[(i+1)*4+j+1])))
+		}
+	}
+	c.InDeltaSlice(expected, runValue(t, g, out.Node()), 1e-10)
+
+	desc, err := pool.Describe()
+	c.NoError(err)
+	checkWellFormed(t, desc)
+	c.Equal("SAME_UPPER", string(desc.Node[0].Attr("auto_pad").S))
+
+	data, err := pool.ToData()
+	c.NoError(err)
+	loaded, err := data.Make(nil, "")
+	c.NoError(err)
+	c.Equal(Same, loaded.(*MaxPool).padding)
+}
+
+func TestOutputShape(t *testing.T) {
+	c := require.New(t)
+	bs := 2
+	g := gorgonia.NewGraph()
+	x := gorgonia.NewTensor(g, tensor.Float64, 4, gorgonia.WithName("x"), gorgonia.WithShape(bs, 1, 28, 28), gorgonia.WithInit(gorgonia.GlorotU(1)))
+
+	conv, err := NewConv(WithName("conv"), WithSize(4, 1), WithKernelShape(tensor.Shape{3, 3}))
+	c.NoError(err)
+	pool, err := NewMaxPool(WithName("pool"))
+	c.NoError(err)
+
+	// the reshape before the FC is derived from the output shapes rather than computed by hand
+	shp := x.Shape()
+	for _, l := range []OutputShaper{conv, pool} {
+		shp, err = l.OutputShape(shp)
+		c.NoError(err)
+	}
+	c.Equal(tensor.Shape{bs, 4, 14, 14}, shp)
+	nn, err := ComposeSeq(x, conv, pool, L(ConsReshape, ToShape(bs, shp[1:].TotalSize())), L(ConsFC, WithName("fc"), WithSize(10)))
+	c.NoError(err)
+	out := nn.Fwd(x)
+	c.NoError(gorgonia.CheckOne(out))
+	c.Equal(tensor.Shape{bs, 10}, out.Node().Shape())
+
+	up, err := NewConvTranspose(WithName("up"), WithSize(2, 3), WithKernelShape(tensor.Shape{3, 3}), WithPad([]int{1, 1}), WithOutputPadding([]int{1, 1}))
+	c.NoError(err)
+	avg, err := NewAvgPool(WithName("avg"), WithKernelShape(tensor.Shape{3, 3}), WithPad([]int{1, 1}), WithStride([]int{2, 2}))
+	c.NoError(err)
+	in := tensor.Shape{1, 3, 7, 5}
+	img := gorgonia.NewTensor(g, tensor.Float64, 4, gorgonia.WithName("img"), gorgonia.WithShape(in...), gorgonia.WithInit(gorgonia.GlorotU(1)))
+	adaptive, err := ConsAdaptiveAvgPool(img, WithSize(3))
+	c.NoError(err)
+	global, err := ConsGlobalMaxPool(img)
+	c.NoError(err)
+	for _, l := range []Layer{up, avg, adaptive, global} {
+		shp, err := l.(OutputShaper).OutputShape(in)
+		c.NoError(err)
+		out := l.Fwd(img)
+		c.NoError(gorgonia.CheckOne(out))
+		c.Equal(out.Node().Shape(), shp, "%T", l)
+	}
+
+	_, err = conv.OutputShape(tensor.Shape{2, 1, 28})
+	c.Error(err)
+	_, err = pool.OutputShape(tensor.Shape{2, 4, 1, 1})
+	c.Error(err)
+	_, err = adaptive.(OutputShaper).OutputShape(tensor.Shape{1, 3, 2, 2})
+	c.Error(err)
+}
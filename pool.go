@@ -0,0 +1,615 @@
+package golgi
+
+import (
+	"fmt"
+
+	"github.com/chewxy/hm"
+	"github.com/pkg/errors"
+	"gorgonia.org/golgi/onnx"
+	G "gorgonia.org/gorgonia"
+	"gorgonia.org/tensor"
+)
+
+var (
+	_ Layer              = &AvgPool{}
+	_ namesetter         = &AvgPool{}
+	_ dropoutConfiger    = &AvgPool{}
+	_ computeFLOPsSetter = &AvgPool{}
+	_ OutputShaper       = &AvgPool{}
+
+	_ Layer              = &GlobalPool{}
+	_ namesetter         = &GlobalPool{}
+	_ computeFLOPsSetter = &GlobalPool{}
+	_ OutputShaper       = &GlobalPool{}
+
+	_ Layer              = &AdaptiveAvgPool{}
+	_ namesetter         = &AdaptiveAvgPool{}
+	_ computeFLOPsSetter = &AdaptiveAvgPool{}
+	_ OutputShaper       = &AdaptiveAvgPool{}
+)
+
+// AvgPool represents an average pooling layer. Padded values are counted as zeroes in the averages.
+type AvgPool struct {
+	name string
+
+	kernelShape tensor.Shape
+	pad, stride []int
+
+	// optional config
+	dropout *float64 // nil when shouldn't be applied
+
+	computeFLOPs bool
+	flops        int
+}
+
+// NewAvgPool creates a new average pooling layer.
+// Defaults:
+//
+//	kernel shape: (2,2)
+//	pad: (0,0)
+//	stride: (2,2)
+func NewAvgPool(opts ...ConsOpt) (*AvgPool, error) {
+	l := &AvgPool{
+		kernelShape: tensor.Shape{2, 2},
+		pad:         []int{0, 0},
+		stride:      []int{2, 2},
+	}
+	for _, opt := range opts {
+		var (
+			o   Layer
+			ok  bool
+			err error
+		)
+		if o, err = opt(l); err != nil {
+			return nil, err
+		}
+		if l, ok = o.(*AvgPool); !ok {
+			return nil, errors.Errorf("Construction Option returned a non AvgPool. Got %T instead", o)
+		}
+	}
+	return l, nil
+}
+
+// ConsAvgPool is an AvgPool construction function. It takes a gorgonia.Input that has a *gorgonia.Node of shape (N, C, H, W).
+func ConsAvgPool(in G.Input, opts ...ConsOpt) (retVal Layer, err error) {
+	if err = checkPoolInput(in); err != nil {
+		return nil, errors.Wrap(err, "ConsAvgPool")
+	}
+	return NewAvgPool(opts...)
+}
+
+// Model returns nil. An average pooling layer has no weights.
+func (l *AvgPool) Model() G.Nodes { return nil }
+
+// Fwd runs the equation forwards
+func (l *AvgPool) Fwd(x G.Input) G.Result {
+	if err := G.CheckOne(x); err != nil {
+		return wrapErr(l, "checking input: %w", err)
+	}
+	if err := checkPoolInput(x); err != nil {
+		return wrapErr(l, "checking input: %w", err)
+	}
+	result, err := avgPool2D(x.Node(), l.kernelShape, l.pad, l.stride)
+	if err != nil {
+		return wrapErr(l, "applying average pool to %v: %w", x.Node().Shape(), err)
+	}
+	if l.dropout != nil {
+		if result, err = G.Dropout(result, *l.dropout); err != nil {
+			return wrapErr(l, "applying dropout: %w", err)
+		}
+	}
+	if l.computeFLOPs {
+		l.flops = avgPoolFLOPs(result.Shape(), l.kernelShape, l.dropout != nil)
+	}
+	logf("%T shape %s: %v", l, l.name, result.Shape())
+	return result
+}
+
+// OutputShape returns the shape of the output of the layer for an input of the given shape, without applying the layer.
+func (l *AvgPool) OutputShape(in tensor.Shape) (tensor.Shape, error) {
+	if in.Dims() != 4 {
+		return nil, errors.Errorf("expected the input to be of shape (N, C, H, W). Got %v instead", in)
+	}
+	return spatialOutputShape(in, in[1], l.kernelShape, l.pad, l.pad, l.stride, []int{1, 1})
+}
+
+// Type will return the hm.Type of the average pooling layer
+func (l *AvgPool) Type() hm.Type { return hm.NewFnType(hm.TypeVariable('a'), hm.TypeVariable('b')) }
+
+// Name will return the name of the average pooling layer
+func (l *AvgPool) Name() string { return l.name }
+
+// SetName sets the name of the layer
+func (l *AvgPool) SetName(n string) error {
+	l.name = n
+	return nil
+}
+
+// SetDropout sets the dropout of the layer
+func (l *AvgPool) SetDropout(d float64) error {
+	l.dropout = &d
+	return nil
+}
+
+// SetComputeFLOPs sets whether the FLOPs are computed when the input is forwarded.
+func (l *AvgPool) SetComputeFLOPs(toCompute bool) error {
+	l.computeFLOPs = toCompute
+	return nil
+}
+
+// FLOPs returns the FLOPs computed by the last Fwd.
+func (l *AvgPool) FLOPs() int { return l.flops }
+
+// Describe will describe an average pooling layer
+func (l *AvgPool) Describe() (*onnx.GraphProto, error) {
+	f := newFragment(l.name, "AvgPool", tensor.Float64)
+	f.apply("AveragePool", nil,
+		attrInts("kernel_shape", l.kernelShape...),
+		attrInts("pads", onnxPads(l.pad)...),
+		attrInts("strides", l.stride...),
+		attrInt("count_include_pad", 1),
+	)
+	if l.dropout != nil {
+		f.dropout(*l.dropout)
+	}
+	return f.graph(), nil
+}
+
+// GlobalPool represents a global pooling layer. It collapses the spatial dimensions of an input of shape (N, C, H, W), returning a
+// matrix of shape (N, C).
+type GlobalPool struct {
+	name string
+	max  bool // average pooling otherwise
+
+	computeFLOPs bool
+	flops        int
+}
+
+// ConsGlobalAvgPool is a construction function for a global average pooling layer. It takes a gorgonia.Input that has a
+// *gorgonia.Node of shape (N, C, H, W).
+func ConsGlobalAvgPool(in G.Input, opts ...ConsOpt) (retVal Layer, err error) {
+	return consGlobalPool(in, false, opts...)
+}
+
+// ConsGlobalMaxPool is a construction function for a global max pooling layer. It takes a gorgonia.Input that has a
+// *gorgonia.Node of shape (N, C, H, W).
+func ConsGlobalMaxPool(in G.Input, opts ...ConsOpt) (retVal Layer, err error) {
+	return consGlobalPool(in, true, opts...)
+}
+
+func consGlobalPool(in G.Input, max bool, opts ...ConsOpt) (retVal Layer, err error) {
+	if err = checkPoolInput(in); err != nil {
+		return nil, errors.Wrap(err, "ConsGlobalPool")
+	}
+	l := &GlobalPool{max: max}
+	for _, opt := range opts {
+		var (
+			o  Layer
+			ok bool
+		)
+		if o, err = opt(l); err != nil {
+			return nil, err
+		}
+		if l, ok = o.(*GlobalPool); !ok {
+			return nil, errors.Errorf("Construction Option returned a non GlobalPool. Got %T instead", o)
+		}
+	}
+	return l, nil
+}
+
+// Model returns nil. A global pooling layer has no weights.
+func (l *GlobalPool) Model() G.Nodes { return nil }
+
+// Fwd runs the equation forwards
+func (l *GlobalPool) Fwd(x G.Input) G.Result {
+	if err := G.CheckOne(x); err != nil {
+		return wrapErr(l, "checking input: %w", err)
+	}
+	if err := checkPoolInput(x); err != nil {
+		return wrapErr(l, "checking input: %w", err)
+	}
+	var (
+		result *G.Node
+		err    error
+	)
+	if l.max {
+		result, err = G.Max(x.Node(), 2, 3)
+	} else {
+		result, err = G.Mean(x.Node(), 2, 3)
+	}
+	if err != nil {
+		return wrapErr(l, "pooling %v: %w", x.Node().Shape(), err)
+	}
+	if l.computeFLOPs {
+		l.flops = l.doComputeFLOPs(x.Node().Shape())
+	}
+	logf("%T shape %s: %v", l, l.name, result.Shape())
+	return result
+}
+
+func (l *GlobalPool) doComputeFLOPs(input tensor.Shape) int {
+	if l.max {
+		return input.TotalSize() // comparisons
+	}
+	return input.TotalSize() + input[0]*input[1] // sums, then a division per channel
+}
+
+// OutputShape returns the shape of the output of the layer for an input of the given shape, without applying the layer.
+func (l *GlobalPool) OutputShape(in tensor.Shape) (tensor.Shape, error) {
+	if in.Dims() != 4 {
+		return nil, errors.Errorf("expected the input to be of shape (N, C, H, W). Got %v instead", in)
+	}
+	return tensor.Shape{in[0], in[1]}, nil
+}
+
+// Type will return the hm.Type of the global pooling layer
+func (l *GlobalPool) Type() hm.Type { return hm.NewFnType(hm.TypeVariable('a'), hm.TypeVariable('b')) }
+
+// Name will return the name of the global pooling layer
+func (l *GlobalPool) Name() string { return l.name }
+
+// SetName sets the name of the layer
+func (l *GlobalPool) SetName(n string) error {
+	l.name = n
+	return nil
+}
+
+// SetComputeFLOPs sets whether the FLOPs are computed when the input is forwarded.
+func (l *GlobalPool) SetComputeFLOPs(toCompute bool) error {
+	l.computeFLOPs = toCompute
+	return nil
+}
+
+// FLOPs returns the FLOPs computed by the last Fwd.
+func (l *GlobalPool) FLOPs() int { return l.flops }
+
+// Describe will describe a global pooling layer. The pooled (N, C, 1, 1) result is flattened to (N, C).
+func (l *GlobalPool) Describe() (*onnx.GraphProto, error) {
+	op := "GlobalAveragePool"
+	if l.max {
+		op = "GlobalMaxPool"
+	}
+	f := newFragment(l.name, op, tensor.Float64)
+	f.apply(op, nil)
+	f.apply("Flatten", nil, attrInt("axis", 1))
+	return f.graph(), nil
+}
+
+// AdaptiveAvgPool represents an average pooling layer with a fixed output size. The windows are derived from the shape of the input,
+// so inputs of different spatial sizes are pooled to the same size.
+//
+// Along each axis, the i-th output averages the input from floor(i·in/out) up to ceil((i+1)·in/out). When the input size is not
+// divisible by the output size, the windows may differ in size and overlap.
+type AdaptiveAvgPool struct {
+	name string
+	size []int // (H, W) of the output
+
+	// derived from the last input
+	windows     [2][][2]int  // the [start, end) windows of each axis
+	kernelShape tensor.Shape // nil if the windows cannot be expressed as a kernel and a stride
+	stride      []int
+	of          tensor.Dtype
+
+	computeFLOPs bool
+	flops        int
+}
+
+// ConsAdaptiveAvgPool is an AdaptiveAvgPool construction function. It takes a gorgonia.Input that has a *gorgonia.Node of shape
+// (N, C, H, W). The output size is set with WithSize(h, w), or WithSize(s) for a square output.
+func ConsAdaptiveAvgPool(in G.Input, opts ...ConsOpt) (retVal Layer, err error) {
+	if err = checkPoolInput(in); err != nil {
+		return nil, errors.Wrap(err, "ConsAdaptiveAvgPool")
+	}
+	l := &AdaptiveAvgPool{}
+	for _, opt := range opts {
+		var (
+			o  Layer
+			ok bool
+		)
+		if o, err = opt(l); err != nil {
+			return nil, err
+		}
+		if l, ok = o.(*AdaptiveAvgPool); !ok {
+			return nil, errors.Errorf("Construction Option returned a non AdaptiveAvgPool. Got %T instead", o)
+		}
+	}
+	if len(l.size) == 0 {
+		return nil, errors.Errorf("AdaptiveAvgPool %v requires an output size", l.name)
+	}
+	return l, nil
+}
+
+// SetSize sets the size of the output of the layer. It accepts either a single size for a square output, or (H, W).
+func (l *AdaptiveAvgPool) SetSize(size ...int) error {
+	switch len(size) {
+	case 1:
+		size = []int{size[0], size[0]}
+	case 2:
+	default:
+		return errors.Errorf("Expected the output size of AdaptiveAvgPool %v to be (H, W). Got %v instead", l.name, size)
+	}
+	if size[0] <= 0 || size[1] <= 0 {
+		return errors.Errorf("Expected a positive output size. Got %v instead", size)
+	}
+	l.size = size
+	return nil
+}
+
+// Model returns nil. An adaptive average pooling layer has no weights.
+func (l *AdaptiveAvgPool) Model() G.Nodes { return nil }
+
+// Fwd runs the equation forwards
+func (l *AdaptiveAvgPool) Fwd(x G.Input) G.Result {
+	if err := G.CheckOne(x); err != nil {
+		return wrapErr(l, "checking input: %w", err)
+	}
+	if err := checkPoolInput(x); err != nil {
+		return wrapErr(l, "checking input: %w", err)
+	}
+	shp := x.Node().Shape()
+	l.kernelShape, l.stride = make(tensor.Shape, 2), make([]int, 2)
+	l.of = x.Node().Dtype()
+	uniform := true
+	for i, in := range shp[2:] {
+		if in < l.size[i] {
+			return wrapErr(l, "unable to pool %v to %v", shp, l.size)
+		}
+		l.windows[i] = adaptiveWindows(in, l.size[i])
+		var ok bool
+		l.kernelShape[i], l.stride[i], ok = uniformWindows(l.windows[i])
+		uniform = uniform && ok
+	}
+
+	var result *G.Node
+	var err error
+	if uniform {
+		result, err = avgPool2D(x.Node(), l.kernelShape, []int{0, 0}, l.stride)
+	} else {
+		l.kernelShape, l.stride = nil, nil
+		result, err = l.poolWindows(x.Node())
+	}
+	if err != nil {
+		return wrapErr(l, "applying average pool to %v: %w", shp, err)
+	}
+	if l.computeFLOPs {
+		// a sum over every window, and a division
+		l.flops = shp[0] * shp[1] * windowsSize(l.windows[0]) * windowsSize(l.windows[1])
+	}
+	logf("%T shape %s: %v", l, l.name, result.Shape())
+	return result
+}
+
+// poolWindows averages the (N, C, H, W) input over windows that cannot be expressed as a kernel and a stride. Each axis is pooled by
+// a multiplication with a matrix that averages its windows.
+func (l *AdaptiveAvgPool) poolWindows(x *G.Node) (retVal *G.Node, err error) {
+	shp := x.Shape()
+	n, c, h, w := shp[0], shp[1], shp[2], shp[3]
+	oh, ow := l.size[0], l.size[1]
+	var ph, pw *tensor.Dense
+	if ph, err = windowsMatrix(l.windows[0], h, l.of); err != nil {
+		return nil, err
+	}
+	if pw, err = windowsMatrix(l.windows[1], w, l.of); err != nil {
+		return nil, err
+	}
+	phT, err := tensor.Transpose(ph)
+	if err != nil {
+		return nil, err
+	}
+	pwT, err := tensor.Transpose(pw)
+	if err != nil {
+		return nil, err
+	}
+
+	// the width is pooled first, then the height is moved to the last axis and pooled.
+	if retVal, err = G.Reshape(x, tensor.Shape{n * c * h, w}); err != nil {
+		return nil, err
+	}
+	if retVal, err = G.Mul(retVal, G.NodeFromAny(x.Graph(), pwT, G.WithName(l.name+"_pw"))); err != nil {
+		return nil, err
+	}
+	if retVal, err = G.Reshape(retVal, tensor.Shape{n * c, h, ow}); err != nil {
+		return nil, err
+	}
+	if retVal, err = G.Transpose(retVal, 0, 2, 1); err != nil {
+		return nil, err
+	}
+	if retVal, err = G.Reshape(retVal, tensor.Shape{n * c * ow, h}); err != nil {
+		return nil, err
+	}
+	if retVal, err = G.Mul(retVal, G.NodeFromAny(x.Graph(), phT, G.WithName(l.name+"_ph"))); err != nil {
+		return nil, err
+	}
+	if retVal, err = G.Reshape(retVal, tensor.Shape{n, c, ow, oh}); err != nil {
+		return nil, err
+	}
+	return G.Transpose(retVal, 0, 1, 3, 2)
+}
+
+// adaptiveWindows returns the [start, end) windows that pool an axis of the given size to the given number of outputs.
+func adaptiveWindows(in, out int) [][2]int {
+	retVal := make([][2]int, out)
+	for i := range retVal {
+		retVal[i] = [2]int{i * in / out, ((i+1)*in + out - 1) / out}
+	}
+	return retVal
+}
+
+// uniformWindows returns the kernel size and the stride of the windows. ok is false if the windows differ in size or are not evenly
+// spaced.
+func uniformWindows(windows [][2]int) (kernel, stride int, ok bool) {
+	kernel = windows[0][1] - windows[0][0]
+	stride = kernel
+	if len(windows) > 1 {
+		stride = windows[1][0] - windows[0][0]
+	}
+	for i, w := range windows {
+		if w[1]-w[0] != kernel || w[0] != i*stride {
+			return 0, 0, false
+		}
+	}
+	return kernel, stride, true
+}
+
+// windowsSize returns the total size of the windows.
+func windowsSize(windows [][2]int) (retVal int) {
+	for _, w := range windows {
+		retVal += w[1] - w[0]
+	}
+	return retVal
+}
+
+// windowsMatrix returns the (out, in) matrix whose rows average the windows.
+func windowsMatrix(windows [][2]int, in int, of tensor.Dtype) (*tensor.Dense, error) {
+	retVal := tensor.New(tensor.WithShape(len(windows), in), tensor.Of(of))
+	for i, w := range windows {
+		v := 1 / float64(w[1]-w[0])
+		for j := w[0]; j < w[1]; j++ {
+			var err error
+			switch of {
+			case tensor.Float64:
+				err = retVal.SetAt(v, i, j)
+			case tensor.Float32:
+				err = retVal.SetAt(float32(v), i, j)
+			default:
+				return nil, errors.Errorf("Unable to average windows of %v", of)
+			}
+			if err != nil {
+				return nil, err
+			}
+		}
+	}
+	return retVal, nil
+}
+
+// OutputShape returns the shape of the output of the layer for an input of the given shape, without applying the layer.
+func (l *AdaptiveAvgPool) OutputShape(in tensor.Shape) (tensor.Shape, error) {
+	if in.Dims() != 4 {
+		return nil, errors.Errorf("expected the input to be of shape (N, C, H, W). Got %v instead", in)
+	}
+	if in[2] < l.size[0] || in[3] < l.size[1] {
+		return nil, errors.Errorf("unable to pool %v to %v", in, l.size)
+	}
+	return tensor.Shape{in[0], in[1], l.size[0], l.size[1]}, nil
+}
+
+// Type will return the hm.Type of the adaptive average pooling layer
+func (l *AdaptiveAvgPool) Type() hm.Type {
+	return hm.NewFnType(hm.TypeVariable('a'), hm.TypeVariable('b'))
+}
+
+// Name will return the name of the adaptive average pooling layer
+func (l *AdaptiveAvgPool) Name() string { return l.name }
+
+// SetName sets the name of the layer
+func (l *AdaptiveAvgPool) SetName(n string) error {
+	l.name = n
+	return nil
+}
+
+// SetComputeFLOPs sets whether the FLOPs are computed when the input is forwarded.
+func (l *AdaptiveAvgPool) SetComputeFLOPs(toCompute bool) error {
+	l.computeFLOPs = toCompute
+	return nil
+}
+
+// FLOPs returns the FLOPs computed by the last Fwd.
+func (l *AdaptiveAvgPool) FLOPs() int { return l.flops }
+
+// Describe will describe an adaptive average pooling layer with the windows derived from the last input. Windows that can be
+// expressed as a kernel and a stride are described as an AveragePool. Otherwise, each axis is pooled by a MatMul.
+func (l *AdaptiveAvgPool) Describe() (*onnx.GraphProto, error) {
+	if l.windows[0] == nil {
+		return nil, errors.Errorf("Unable to describe AdaptiveAvgPool %v. Call Fwd before describing it", l.name)
+	}
+	f := newFragment(l.name, "AdaptiveAvgPool", l.of)
+	if l.kernelShape != nil {
+		f.apply("AveragePool", nil,
+			attrInts("kernel_shape", l.kernelShape...),
+			attrInts("strides", l.stride...),
+		)
+		return f.graph(), nil
+	}
+
+	// (N, C, H, W) × (W, ow) is pooled along the width, then (oh, H) × (N, C, H, ow) is pooled along the height.
+	ph, err := windowsMatrix(l.windows[0], l.windows[0][len(l.windows[0])-1][1], l.of)
+	if err != nil {
+		return nil, err
+	}
+	pw, err := windowsMatrix(l.windows[1], l.windows[1][len(l.windows[1])-1][1], l.of)
+	if err != nil {
+		return nil, err
+	}
+	pwT, err := tensor.Transpose(pw)
+	if err != nil {
+		return nil, err
+	}
+	var h, w string
+	if h, err = f.value(f.prefix+"_ph", ph); err != nil {
+		return nil, err
+	}
+	if w, err = f.value(f.prefix+"_pw", pwT); err != nil {
+		return nil, err
+	}
+	f.apply("MatMul", []string{w})
+	f.node("MatMul", []string{h, f.last})
+	return f.graph(), nil
+}
+
+// checkPoolInput checks that the input is a *Node of shape (N, C, H, W).
+func checkPoolInput(in G.Input) error {
+	x := in.Node()
+	if x == nil {
+		return errors.Errorf("expected a *Node. Got input %v of %T instead", in, in)
+	}
+	if x.Dims() != 4 {
+		return errors.Errorf("expected the input to be of shape (N, C, H, W). Got %v instead", x.Shape())
+	}
+	return nil
+}
+
+// avgPool2D averages the (N, C, H, W) input over windows of the given kernel shape.
+//
+// Each channel is convolved separately with a kernel of 1/(kh×kw), so the padded values count as zeroes.
+func avgPool2D(x *G.Node, kernelShape tensor.Shape, pad, stride []int) (retVal *G.Node, err error) {
+	if len(kernelShape) != 2 {
+		return nil, errors.Errorf("expected a 2D kernel. Got %v instead", kernelShape)
+	}
+	shp := x.Shape()
+	n, c := shp[0], shp[1]
+	if retVal, err = G.Reshape(x, tensor.Shape{n * c, 1, shp[2], shp[3]}); err != nil {
+		return nil, err
+	}
+
+	kernel := tensor.New(tensor.Of(x.Dtype()), tensor.WithShape(1, 1, kernelShape[0], kernelShape[1]))
+	weight := 1 / float64(kernelShape.TotalSize())
+	switch x.Dtype() {
+	case tensor.Float32:
+		err = kernel.Memset(float32(weight))
+	case tensor.Float64:
+		err = kernel.Memset(weight)
+	default:
+		err = errors.Errorf("unable to average values of %v", x.Dtype())
+	}
+	if err != nil {
+		return nil, err
+	}
+
+	// the kernel is not a weight: it is not returned by Model, so it is never trained
+	filter := G.NewTensor(x.Graph(), x.Dtype(), 4, G.WithShape(kernel.Shape()...), G.WithValue(kernel), G.WithName(fmt.Sprintf("avgpool_kernel_%dx%d", kernelShape[0], kernelShape[1])))
+	if retVal, err = G.Conv2d(retVal, filter, kernelShape, pad, stride, []int{1, 1}); err != nil {
+		return nil, err
+	}
+	out := retVal.Shape()
+	return G.Reshape(retVal, tensor.Shape{n, c, out[2], out[3]})
+}
+
+// avgPoolFLOPs computes the FLOPs of an average pooling that produces the given output shape.
+func avgPoolFLOPs(output tensor.Shape, kernelShape tensor.Shape, dropout bool) int {
+	// a sum over the window, and a division
+	retVal := output.TotalSize() * kernelShape.TotalSize()
+	if dropout {
+		retVal += output.TotalSize() // dropout is an elementwise mul
+	}
+	return retVal
+}
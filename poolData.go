@@ -0,0 +1,97 @@
+package golgi
+
+import (
+	"github.com/pkg/errors"
+	G "gorgonia.org/gorgonia"
+	"gorgonia.org/tensor"
+)
+
+var (
+	_ Data   = &AvgPoolData{}
+	_ Data   = &GlobalPoolData{}
+	_ Data   = &AdaptiveAvgPoolData{}
+	_ Dataer = &AvgPool{}
+	_ Dataer = &GlobalPool{}
+	_ Dataer = &AdaptiveAvgPool{}
+)
+
+// AvgPoolData represents the configuration of an average pooling layer. An average pooling layer has no weights.
+type AvgPoolData struct {
+	Name        string
+	KernelShape []int
+	Pad, Stride []int
+	Dropout     *float64
+}
+
+// Make creates a *AvgPool. The graph is unused. If name is empty, the name of the snapshotted layer is used.
+func (d *AvgPoolData) Make(_ *G.ExprGraph, name string) (Layer, error) {
+	if name == "" {
+		name = d.Name
+	}
+	opts := []ConsOpt{
+		WithName(name),
+		WithKernelShape(tensor.Shape(d.KernelShape)),
+		WithPad(d.Pad),
+		WithStride(d.Stride),
+	}
+	if d.Dropout != nil {
+		opts = append(opts, WithProbability(*d.Dropout))
+	}
+	l, err := NewAvgPool(opts...)
+	if err != nil {
+		return nil, errors.Wrapf(err, "Unable to make AvgPool %v", name)
+	}
+	return l, nil
+}
+
+// ToData snapshots the configuration of the average pooling layer.
+func (l *AvgPool) ToData() (Data, error) {
+	return &AvgPoolData{
+		Name:        l.name,
+		KernelShape: cloneInts(l.kernelShape),
+		Pad:         cloneInts(l.pad),
+		Stride:      cloneInts(l.stride),
+		Dropout:     cloneProb(l.dropout),
+	}, nil
+}
+
+// GlobalPoolData represents the configuration of a global pooling layer. A global pooling layer has no weights.
+type GlobalPoolData struct {
+	Name string
+	Max  bool
+}
+
+// Make creates a *GlobalPool. The graph is unused. If name is empty, the name of the snapshotted layer is used.
+func (d *GlobalPoolData) Make(_ *G.ExprGraph, name string) (Layer, error) {
+	if name == "" {
+		name = d.Name
+	}
+	return &GlobalPool{name: name, max: d.Max}, nil
+}
+
+// ToData snapshots the configuration of the global pooling layer.
+func (l *GlobalPool) ToData() (Data, error) { return &GlobalPoolData{Name: l.name, Max: l.max}, nil }
+
+// AdaptiveAvgPoolData represents the configuration of an adaptive average pooling layer. An adaptive average pooling layer has no
+// weights.
+type AdaptiveAvgPoolData struct {
+	Name string
+	Size []int
+}
+
+// Make creates a *AdaptiveAvgPool. The graph is unused. If name is empty, the name of the snapshotted layer is used.
+func (d *AdaptiveAvgPoolData) Make(_ *G.ExprGraph, name string) (Layer, error) {
+	if name == "" {
+		name = d.Name
+	}
+	l := &AdaptiveAvgPool{name: name}
+	if err := l.SetSize(d.Size...); err != nil {
+		return nil, errors.Wrapf(err, "Unable to make AdaptiveAvgPool %v", name)
+	}
+	return l, nil
+}
+
+// ToData snapshots the configuration of the adaptive average pooling layer.
+func (l *AdaptiveAvgPool) ToData() (Data, error) {
+	return &AdaptiveAvgPoolData{Name: l.name, Size: cloneInts(l.size)}, nil
+}
@@ -0,0 +1,222 @@
+package golgi
+
+import (
+	"bytes"
+	"testing"
+
+	"github.com/stretchr/testify/require"
+	"gorgonia.org/gorgonia"
+	"gorgonia.org/tensor"
+)
+
+// avgPoolRef averages a (1, 1, h, w) input over the windows of an unpadded pooling.
+func avgPoolRef(xs []float64, h, w, kh, kw, sh, sw int) []float64 {
+	var retVal []float64
+	for i := 0; i+kh <= h; i += sh {
+		for j := 0; j+kw <= w; j += sw {
+			var acc float64
+			for a := 0; a < kh; a++ {
+				for b := 0; b < kw; b++ {
+					acc += xs[(i+a)*w+j+b]
+				}
+			}
+			retVal = append(retVal, acc/float64(kh*kw))
+		}
+	}
+	return retVal
+}
+
+func poolInput(g *gorgonia.ExprGraph, shape ...int) (*gorgonia.Node, []float64) {
+	xs := make([]float64, tensor.Shape(shape).TotalSize())
+	for i := range xs {
+		xs[i] = float64((i*7)%11) - 5
+	}
+	x := gorgonia.NewTensor(g, tensor.Float64, 4, gorgonia.WithName("x"), gorgonia.WithShape(shape...), gorgonia.WithValue(tensor.New(tensor.WithShape(shape...), tensor.WithBacking(xs))))
+	return x, xs
+}
+
+func TestAvgPool(t *testing.T) {
+	c := require.New(t)
+	g := gorgonia.NewGraph()
+	x, xs := poolInput(g, 1, 1, 5, 4)
+
+	l, err := ConsAvgPool(x, WithName("pool"), WithKernelShape(tensor.Shape{3, 2}), WithStride([]int{2, 1}), ComputeFLOPs(true))
+	c.NoError(err)
+	out := l.Fwd(x)
+	c.NoError(gorgonia.CheckOne(out))
+	c.Equal(tensor.Shape{1, 1, 2, 3}, out.Node().Shape())
+	c.Equal(6*6, l.(*AvgPool).FLOPs())
+
+	cost, err := gorgonia.Sum(out.Node())
+	c.NoError(err)
+	_, err = gorgonia.Grad(cost, x)
+	c.NoError(err)
+	c.InDeltaSlice(avgPoolRef(xs, 5, 4, 3, 2, 2, 1), runValue(t, g, out.Node()), 1e-10)
+
+	desc, err := l.Describe()
+	c.NoError(err)
+	c.Equal([]string{"AveragePool"}, opTypes(desc))
+	checkWellFormed(t, desc)
+
+	_, err = ConsAvgPool(gorgonia.NewMatrix(g, tensor.Float64, gorgonia.WithShape(2, 2)))
+	c.Error(err)
+}
+
+func TestGlobalPool(t *testing.T) {
+	c := require.New(t)
+	g := gorgonia.NewGraph()
+	x, xs := poolInput(g, 2, 3, 2, 2)
+
+	avg, err := ConsGlobalAvgPool(x, WithName("gap"), ComputeFLOPs(true))
+	c.NoError(err)
+	max, err := ConsGlobalMaxPool(x, WithName("gmp"), ComputeFLOPs(true))
+	c.NoError(err)
+	avgOut, maxOut := avg.Fwd(x), max.Fwd(x)
+	c.NoError(gorgonia.CheckOne(avgOut))
+	c.NoError(gorgonia.CheckOne(maxOut))
+	c.Equal(tensor.Shape{2, 3}, avgOut.Node().Shape())
+	c.Equal(tensor.Shape{2, 3}, maxOut.Node().Shape())
+	c.Equal(24+6, avg.(*GlobalPool).FLOPs())
+	c.Equal(24, max.(*GlobalPool).FLOPs())
+
+	var avgs, maxes []float64
+	for i := 0; i < 6; i++ {
+		window := xs[i*4 : i*4+4]
+		var sum float64
+		m := window[0]
+		for _, v := range window {
+			sum += v
+			if v > m {
+				m = v
+			}
+		}
+		avgs = append(avgs, sum/4)
+		maxes = append(maxes, m)
+	}
+	c.InDeltaSlice(avgs, runValue(t, g, avgOut.Node()), 1e-10)
+	c.InDeltaSlice(maxes, runValue(t, g, maxOut.Node()), 1e-10)
+
+	desc, err := max.Describe()
+	c.NoError(err)
+	c.Equal([]string{"GlobalMaxPool", "Flatten"}, opTypes(desc))
+	checkWellFormed(t, desc)
+}
+
+func TestAdaptiveAvgPool(t *testing.T) {
+	c := require.New(t)
+	g := gorgonia.NewGraph()
+	x, xs := poolInput(g, 1, 1, 6, 7)
+
+	l, err := ConsAdaptiveAvgPool(x, WithName("adaptive"), WithSize(3, 2), ComputeFLOPs(true))
+	c.NoError(err)
+	_, err = l.Describe()
+	c.Error(err)
+
+	out := l.Fwd(x)
+	c.NoError(gorgonia.CheckOne(out))
+	c.Equal(tensor.Shape{1, 1, 3, 2}, out.Node().Shape())
+	pool := l.(*AdaptiveAvgPool)
+	c.Equal(tensor.Shape{2, 4}, pool.kernelShape)
+	c.Equal([]int{2, 3}, pool.stride)
+	c.Equal(6*8, pool.FLOPs())
+	c.InDeltaSlice(avgPoolRef(xs, 6, 7, 2, 4, 2, 3), runValue(t, g, out.Node()), 1e-10)
+
+	desc, err := l.Describe()
+	c.NoError(err)
+	checkWellFormed(t, desc)
+
+	// inputs smaller than the output cannot be pooled
+	small, _ := poolInput(gorgonia.NewGraph(), 1, 1, 2, 2)
+	c.Error(gorgonia.CheckOne(l.Fwd(small)))
+	_, err = ConsAdaptiveAvgPool(x)
+	c.Error(err)
+	_, err = ConsAdaptiveAvgPool(x, WithSize(1, 2, 3))
+	c.Error(err)
+}
+
+func TestAdaptiveAvgPool_NonDivisible(t *testing.T) {
+	c := require.New(t)
+	g := gorgonia.NewGraph()
+	x, xs := poolInput(g, 1, 2, 10, 10)
+
+	l, err := ConsAdaptiveAvgPool(x, WithName("adaptive"), WithSize(4), ComputeFLOPs(true))
+	c.NoError(err)
+	out := l.Fwd(x)
+	c.NoError(gorgonia.CheckOne(out))
+	c.Equal(tensor.Shape{1, 2, 4, 4}, out.Node().Shape())
+
+	// the windows of 10 → 4 are [0, 3), [2, 5), [5, 8) and [7, 10), which cannot be expressed as a kernel and a stride
+	pool := l.(*AdaptiveAvgPool)
+	windows := [][2]int{{0, 3}, {2, 5}, {5, 8}, {7, 10}}
+	c.Equal(windows, pool.windows[0])
+	c.Nil(pool.kernelShape)
+	c.Equal(2*12*12, pool.FLOPs())
+
+	var want []float64
+	for ch := 0; ch < 2; ch++ {
+		for _, wh := range windows {
+			for _, ww := range windows {
+				var acc float64
+				for i := wh[0]; i < wh[1]; i++ {
+					for j := ww[0]; j < ww[1]; j++ {
+						acc += xs[ch*100+i*10+j]
+					}
+				}
+				want = append(want, acc/9)
+			}
+		}
+	}
+	cost, err := gorgonia.Sum(out.Node())
+	c.NoError(err)
+	_, err = gorgonia.Grad(cost, x)
+	c.NoError(err)
+	c.InDeltaSlice(want, runValue(t, g, out.Node()), 1e-10)
+
+	desc, err := l.Describe()
+	c.NoError(err)
+	c.Equal([]string{"MatMul", "MatMul"}, opTypes(desc))
+	checkWellFormed(t, desc)
+}
+
+func TestMaxPool_FLOPs(t *testing.T) {
+	c := require.New(t)
+	g := gorgonia.NewGraph()
+	x, _ := poolInput(g, 1, 2, 4, 4)
+	l, err := ConsMaxPool(x, ComputeFLOPs(true))
+	c.NoError(err)
+	c.NoError(gorgonia.CheckOne(l.Fwd(x)))
+	c.Equal(32, l.(*MaxPool).FLOPs())
+}
+
+func TestPool_Checkpoint(t *testing.T) {
+	c := require.New(t)
+	g := gorgonia.NewGraph()
+	x, xs := poolInput(g, 2, 3, 6, 7)
+	nn, err := ComposeSeq(
+		x,
+		L(ConsAvgPool, WithName("avg"), WithKernelShape(tensor.Shape{3, 2}), WithStride([]int{1, 1}), WithProbability(0.1)),
+		L(ConsAdaptiveAvgPool, WithName("adaptive"), WithSize(2)),
+		L(ConsGlobalMaxPool, WithName("gmp")),
+	)
+	c.NoError(err)
+	out := nn.Fwd(x)
+	c.NoError(gorgonia.CheckOne(out))
+
+	var buf bytes.Buffer
+	c.NoError(SaveCheckpoint(&buf, nn))
+	g2 := gorgonia.NewGraph()
+	x2 := gorgonia.NewTensor(g2, tensor.Float64, 4, gorgonia.WithName("x"), gorgonia.WithShape(2, 3, 6, 7), gorgonia.WithValue(tensor.New(tensor.WithShape(2, 3, 6, 7), tensor.WithBacking(xs))))
+	loaded, err := LoadCheckpoint(g2, &buf)
+	c.NoError(err)
+
+	avg := loaded.(*Composition).ByName("avg").(*AvgPool)
+	c.Equal(tensor.Shape{3, 2}, avg.kernelShape)
+	c.Equal([]int{1, 1}, avg.stride)
+	c.Equal(0.1, *avg.dropout)
+	c.Equal([]int{2, 2}, loaded.(*Composition).ByName("adaptive").(*AdaptiveAvgPool).size)
+	c.True(loaded.(*Composition).ByName("gmp").(*GlobalPool).max)
+
+	out2 := loaded.Fwd(x2)
+	c.NoError(gorgonia.CheckOne(out2))
+	c.Equal(out.Node().Shape(), out2.Node().Shape())
+}
@@ -0,0 +1,67 @@
+// +build !cuda
+
+package golgi
+
+import (
+	"github.com/pkg/errors"
+	G "gorgonia.org/gorgonia"
+	"gorgonia.org/tensor"
+)
+
+// ConsSeparableConv is a construction function for a depthwise-separable 2D convolution. It takes a gorgonia.Input that has a
+// *gorgonia.Node of shape (N, C, H, W).
+//
+// A depthwise-separable convolution is a depthwise convolution, which convolves every input channel with its own kernel, composed
+// with a 1×1 pointwise convolution, which mixes the channels. It is much cheaper than a full convolution with the same kernel.
+//
+// The options are the options of ConsConv. WithSize(out, in) sets the channels, and the kernel shape, pads, padding, stride and
+// dilation are those of the depthwise convolution. The activation function, the bias and the dropout are those of the pointwise
+// convolution. The layers are named name_depthwise and name_pointwise.
+func ConsSeparableConv(in G.Input, opts ...ConsOpt) (retVal Layer, err error) {
+	x := in.Node()
+	if x == nil {
+		return nil, errors.Errorf("ConsSeparableConv expects a *Node. Got input %v of %T instead", in, in)
+	}
+	if x.Dims() != 4 {
+		return nil, errors.Errorf("Expected the input of a separable convolution to be of shape (N, C, H, W). Got %v instead", x.Shape())
+	}
+
+	var base string
+	depthwise, err := NewConv(append(opts[:len(opts):len(opts)], withNameSuffix(&base, "depthwise"))...)
+	if err != nil {
+		return nil, err
+	}
+	pointwise, err := NewConv(append(opts[:len(opts):len(opts)], withNameSuffix(&base, "pointwise"))...)
+	if err != nil {
+		return nil, err
+	}
+	if len(depthwise.size) != 2 {
+		return nil, errors.Errorf("Expected the size of separable convolution %v to be (out channels, in channels). Got %v", base, depthwise.size)
+	}
+	if depthwise.w != nil || depthwise.groups > 1 {
+		return nil, errors.Errorf("Separable convolution %v does not support WithWeights or WithGroups", base)
+	}
+	out, channels := depthwise.size[0], depthwise.size[1]
+
+	depthwise.size = []int{channels, channels}
+	depthwise.groups = channels
+	depthwise.nobias = true
+	depthwise.act = nil
+	depthwise.dropout = nil
+
+	pointwise.size = []int{out, channels}
+	pointwise.kernelShape = tensor.Shape{1, 1}
+	pointwise.pad = []int{0, 0}
+	pointwise.stride = []int{1, 1}
+	pointwise.dilation = []int{1, 1}
+	pointwise.padding = ExplicitPadding
+
+	if err = depthwise.Init(x); err != nil {
+		return nil, err
+	}
+	// the pointwise convolution only needs an input with the right number of dimensions and channels
+	if err = pointwise.Init(x); err != nil {
+		return nil, err
+	}
+	return Compose(depthwise, pointwise), nil
+}
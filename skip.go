@@ -3,6 +3,7 @@ package golgi
 import (
 	"github.com/chewxy/hm"
 	"github.com/pkg/errors"
+	"gorgonia.org/golgi/onnx"
 	G "gorgonia.org/gorgonia"
 	"gorgonia.org/tensor"
 )
@@ -41,4 +42,12 @@ func (l *skip) Type() hm.Type { return hm.NewFnType(l.b.Type(), l.b.Type()) }
 
 func (l *skip) Shape() tensor.Shape { return l.b.Shape() }
 
-func (l *skip) Describe() {}
+func (l *skip) Describe() (*onnx.GraphProto, error) {
+	f := newFragment("", "skip", l.b.Dtype())
+	b, err := f.weight(l.b)
+	if err != nil {
+		return nil, err
+	}
+	f.apply("Add", []string{b})
+	return f.graph(), nil
+}
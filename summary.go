@@ -0,0 +1,158 @@
+package golgi
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"text/tabwriter"
+
+	"github.com/pkg/errors"
+	G "gorgonia.org/gorgonia"
+	"gorgonia.org/tensor"
+)
+
+// LayerSummary summarizes a single layer of a model.
+type LayerSummary struct {
+	Name        string       `json:"name"`
+	Type        string       `json:"type"`
+	OutputShape tensor.Shape `json:"output_shape"`
+	Params      int          `json:"params"`
+	FLOPs       int          `json:"flops"`
+}
+
+// Report is the summary of a model, as returned by Summary. It is rendered as an aligned text table by String, and as JSON by JSON.
+type Report struct {
+	Layers []LayerSummary `json:"layers"`
+
+	// Params is the total number of trainable parameters. A weight that is shared by several layers is only counted once.
+	Params int `json:"params"`
+	// FLOPs is the total number of floating point operations of the layers that compute them. See ComputeFLOPs.
+	FLOPs int `json:"flops"`
+}
+
+// Summary applies the model to the input, and reports the name, Go type, output shape, number of trainable parameters and FLOPs of
+// every layer, in the order they are applied.
+//
+// Compositions and joins are walked into, so that each of their layers gets its own row. The merge of a join gets a row of its own,
+// named after its op. Other layers, such as a DAG, are reported as a single row.
+//
+// As with Fwd, the thunked layers are constructed and a new forward path is added to the graph of the input. The FLOPs are only
+// reported for the layers that were constructed with ComputeFLOPs(true).
+func Summary(l Layer, input G.Input) (Report, error) {
+	var r Report
+	if err := G.CheckOne(input); err != nil {
+		return r, errors.Wrapf(err, "Summary of %v", l.Name())
+	}
+	if n := input.Node(); n != nil {
+		if _, err := r.apply(l, n); err != nil {
+			return r, errors.Wrapf(err, "Summary of %v", l.Name())
+		}
+	} else {
+		// a tuple of inputs, as taken by a DAG, is not a Term
+		res := l.Fwd(input)
+		if err := G.CheckOne(res); err != nil {
+			return r, errors.Wrapf(err, "Summary of %v", l.Name())
+		}
+		r.recordLayer(l, res)
+	}
+
+	seen := make(map[*G.Node]struct{})
+	for _, n := range l.Model() {
+		if _, ok := seen[n]; ok || n == nil {
+			continue
+		}
+		seen[n] = struct{}{}
+		r.Params += n.Shape().TotalSize()
+	}
+	for _, row := range r.Layers {
+		r.FLOPs += row.FLOPs
+	}
+	return r, nil
+}
+
+// apply has the semantics of Apply, and records a row for every layer that is applied. Compositions and joins apply their terms
+// with it, so that their layers are recorded in the order they are applied.
+func (r *Report) apply(a, b Term) (Term, error) {
+	in, isInput := b.(G.Input)
+	var res G.Result
+	switch at := a.(type) {
+	case *Composition:
+		if !isInput {
+			return Apply(a, b)
+		}
+		res = at.fwd(in, r.apply)
+	case *Join:
+		if !isInput {
+			return Apply(a, b)
+		}
+		res = at.fwd(in, r.apply)
+	default:
+		retVal, err := Apply(a, b)
+		if err != nil {
+			return nil, err
+		}
+		l, out := a, retVal
+		if t, ok := retVal.(tag); ok {
+			l, out = t.a, t.b
+		}
+		lt, isLayer := l.(Layer)
+		if res, ok := out.(G.Input); ok && isLayer {
+			r.recordLayer(lt, res)
+		}
+		return retVal, nil
+	}
+	if err := G.CheckOne(res); err != nil {
+		return nil, err
+	}
+	if j, ok := a.(*Join); ok {
+		r.record(j.op.String(), j, res, 0, 0)
+	}
+	return res.(Term), nil
+}
+
+// recordLayer records a row for a layer that is not walked into.
+func (r *Report) recordLayer(l Layer, res G.Input) {
+	var params int
+	for _, n := range l.Model() {
+		if n != nil {
+			params += n.Shape().TotalSize()
+		}
+	}
+	var flops int
+	if f, ok := l.(flopser); ok {
+		flops = f.FLOPs()
+	}
+	r.record(l.Name(), l, res, params, flops)
+}
+
+func (r *Report) record(name string, l Layer, res G.Input, params, flops int) {
+	var shp tensor.Shape
+	if n := res.Node(); n != nil {
+		shp = n.Shape().Clone()
+	} else if ns := res.Nodes(); len(ns) > 0 && ns[0] != nil {
+		shp = ns[0].Shape().Clone()
+	}
+	r.Layers = append(r.Layers, LayerSummary{
+		Name:        name,
+		Type:        fmt.Sprintf("%T", l),
+		OutputShape: shp,
+		Params:      params,
+		FLOPs:       flops,
+	})
+}
+
+// String renders the report as an aligned text table, followed by the totals.
+func (r Report) String() string {
+	var buf bytes.Buffer
+	w := tabwriter.NewWriter(&buf, 0, 0, 2, ' ', 0)
+	fmt.Fprintln(w, "Name\tType\tOutput Shape\tParams\tFLOPs\t")
+	for _, row := range r.Layers {
+		fmt.Fprintf(w, "%v\t%v\t%v\t%d\t%d\t\n", row.Name, row.Type, row.OutputShape, row.Params, row.FLOPs)
+	}
+	w.Flush()
+	fmt.Fprintf(&buf, "Total params: %d\nTotal FLOPs: %d\n", r.Params, r.FLOPs)
+	return buf.String()
+}
+
+// JSON renders the report as indented JSON.
+func (r Report) JSON() ([]byte, error) { return json.MarshalIndent(r, "", "  ") }
@@ -0,0 +1,99 @@
+package golgi
+
+import (
+	"encoding/json"
+	"fmt"
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/require"
+	"gorgonia.org/gorgonia"
+	"gorgonia.org/tensor"
+)
+
+func TestSummary(t *testing.T) {
+	c := require.New(t)
+	g := gorgonia.NewGraph()
+	x := gorgonia.NewMatrix(g, tensor.Float64, gorgonia.WithName("x"), gorgonia.WithShape(2, 3), gorgonia.WithInit(gorgonia.GlorotU(1)))
+	nn, err := ComposeSeq(
+		x,
+		L(ConsFC, WithName("l0"), WithSize(4), WithActivation(gorgonia.Tanh), ComputeFLOPs(true)),
+		Concat(1, L(ConsFC, WithName("tower"), WithSize(2), ComputeFLOPs(true)), I{}),
+		L(ConsLSTM, WithName("lstm"), WithSize(5), ComputeFLOPs(true)),
+		L(ConsFC, WithName("out"), WithSize(3)),
+	)
+	c.NoError(err)
+
+	r, err := Summary(nn, x)
+	c.NoError(err)
+	var names, types []string
+	var shapes []tensor.Shape
+	for _, row := range r.Layers {
+		names = append(names, row.Name)
+		types = append(types, row.Type)
+		shapes = append(shapes, row.OutputShape)
+	}
+	c.Equal([]string{"l0", "tower", "Concat", "lstm", "out"}, names)
+	c.Equal([]string{"*golgi.FC", "*golgi.FC", "*golgi.Join", "*golgi.LSTM", "*golgi.FC"}, types)
+	c.Equal([]tensor.Shape{{2, 4}, {2, 2}, {2, 6}, {2, 5}, {2, 3}}, shapes)
+
+	// the thunks are constructed, so the model can be inspected and applied
+	l0 := nn.ByName("l0").(*FC)
+	lstm := nn.ByName("lstm").(*LSTM)
+	c.Equal(l0.w.Shape().TotalSize()+l0.b.Shape().TotalSize(), r.Layers[0].Params)
+	c.Equal(0, r.Layers[2].Params)
+	c.Equal(4*(6*5+5*5+5), r.Layers[3].Params)
+	c.Equal(l0.FLOPs(), r.Layers[0].FLOPs)
+	c.Equal(0, r.Layers[4].FLOPs, "out does not compute its FLOPs")
+	var params, flops int
+	for _, row := range r.Layers {
+		params += row.Params
+		flops += row.FLOPs
+	}
+	c.Equal(params, r.Params)
+	c.Equal(flops, r.FLOPs)
+	c.NoError(gorgonia.CheckOne(nn.Fwd(x)))
+
+	// each gate is 2·n·in·size + 2·n·size·size + 3·n·size, and the cell and hidden state are 5·n·size
+	c.Equal(4*(2*2*6*5+2*2*5*5+3*2*5)+5*2*5, lstm.FLOPs())
+	c.Equal(lstm.FLOPs(), r.Layers[3].FLOPs)
+
+	// the columns of the table are aligned
+	lines := strings.Split(strings.TrimSpace(r.String()), "\n")
+	c.Len(lines, len(r.Layers)+3)
+	col := strings.Index(lines[0], "Output Shape")
+	for i, row := range r.Layers {
+		c.Equal(col, strings.Index(lines[i+1], fmt.Sprintf("%v", row.OutputShape)), lines[i+1])
+	}
+	c.Contains(r.String(), "Total params: ")
+
+	js, err := r.JSON()
+	c.NoError(err)
+	var decoded Report
+	c.NoError(json.Unmarshal(js, &decoded))
+	c.Equal(r, decoded)
+	c.Contains(string(js), `"output_shape": [`)
+}
+
+func TestSummary_FLOPs(t *testing.T) {
+	c := require.New(t)
+	g := gorgonia.NewGraph()
+
+	// in sequence mode, every time step is counted
+	seq := gorgonia.NewTensor(g, tensor.Float64, 3, gorgonia.WithName("seq"), gorgonia.WithShape(2, 7, 3), gorgonia.WithInit(gorgonia.GlorotU(1)))
+	l, err := ConsLSTM(seq, WithName("lstm"), WithSize(4), AsSequence(BatchMajor), ComputeFLOPs(true))
+	c.NoError(err)
+	c.NoError(gorgonia.CheckOne(l.Fwd(seq)))
+	c.Equal(7*(4*(2*2*3*4+2*2*4*4+3*2*4)+5*2*4), l.(*LSTM).FLOPs())
+
+	classes, dims := 6, 4
+	oh := gorgonia.NewMatrix(g, tensor.Float64, gorgonia.WithName("oh"), gorgonia.WithShape(5, classes), gorgonia.WithInit(gorgonia.Zeroes()))
+	emb := NewEmbedding(Of(tensor.Float64), WithName("emb"), WithSize(dims), WithClasses(classes), WithOneHotInput(), ComputeFLOPs(true))
+	c.NoError(gorgonia.CheckOne(emb.Fwd(oh)))
+	c.Equal(2*5*classes*dims, emb.FLOPs())
+
+	indices := gorgonia.NewVector(g, tensor.Int, gorgonia.WithName("indices"), gorgonia.WithShape(5), gorgonia.WithInit(gorgonia.Zeroes()))
+	gather := NewEmbedding(Of(tensor.Float64), WithName("gather"), WithSize(dims), WithClasses(classes), ComputeFLOPs(true))
+	c.NoError(gorgonia.CheckOne(gather.Fwd(indices)))
+	c.Equal(0, gather.FLOPs())
+}
@@ -0,0 +1,224 @@
+package golgi
+
+import (
+	"context"
+	"io"
+
+	"github.com/pkg/errors"
+	G "gorgonia.org/gorgonia"
+)
+
+// CostFn is a function that constructs a cost, given the prediction and the target. RMS is a CostFn.
+type CostFn func(yHat, y G.Input) (*G.Node, error)
+
+// Iterator iterates over the batches of a dataset.
+type Iterator interface {
+	// Next returns the inputs and targets of the next batch. io.EOF is returned when there are no more batches in the epoch.
+	Next() (x, y G.Value, err error)
+
+	// Reset rewinds the iterator to the first batch. It is called at the start of every epoch.
+	Reset() error
+}
+
+// TrainerOpt is an option for a *Trainer.
+type TrainerOpt func(t *Trainer) error
+
+// OnEpochEnd is a TrainerOpt that calls fn with the mean loss of every epoch after the epoch ends.
+func OnEpochEnd(fn func(epoch int, loss float64)) TrainerOpt {
+	return func(t *Trainer) error {
+		t.onEpochEnd = fn
+		return nil
+	}
+}
+
+// WithVMOpts is a TrainerOpt that passes additional options to the *gorgonia.tapeMachine used for training.
+func WithVMOpts(opts ...G.VMOpt) TrainerOpt {
+	return func(t *Trainer) error {
+		t.vmOpts = append(t.vmOpts, opts...)
+		return nil
+	}
+}
+
+// Trainer trains a Layer. It handles the construction of the cost and its gradients, and the machine that runs it.
+//
+// The input and target are placeholders: the value of each batch is bound to them with gorgonia.Let before every step.
+// Before every step, all the Runners of the layer are run with the input. This is required by layers such as an Embedding
+// constructed AsRunner(). The Runners that depend on the result of the step, such as a BatchNorm, which updates its running
+// statistics, are run after the step of the solver instead.
+type Trainer struct {
+	layer  Layer
+	x, y   *G.Node
+	cost   CostFn
+	solver G.Solver
+
+	// options
+	onEpochEnd func(epoch int, loss float64)
+	vmOpts     []G.VMOpt
+
+	// constructed lazily, when the first batch is bound
+	costNode *G.Node
+	costVal  G.Value
+	model    G.Nodes
+	runners  []Runner
+	steppers []postStepper
+	vm       G.VM
+}
+
+// NewTrainer creates a new *Trainer. x and y are the input and target placeholders.
+func NewTrainer(l Layer, x, y *G.Node, cost CostFn, solver G.Solver, opts ...TrainerOpt) (*Trainer, error) {
+	switch {
+	case l == nil:
+		return nil, errors.New("Cannot create a Trainer without a Layer")
+	case x == nil || y == nil:
+		return nil, errors.New("Cannot create a Trainer without the input and target placeholders")
+	case cost == nil:
+		return nil, errors.New("Cannot create a Trainer without a cost function")
+	case solver == nil:
+		return nil, errors.New("Cannot create a Trainer without a solver")
+	}
+	t := &Trainer{
+		layer:  l,
+		x:      x,
+		y:      y,
+		cost:   cost,
+		solver: solver,
+	}
+	for _, opt := range opts {
+		if err := opt(t); err != nil {
+			return nil, err
+		}
+	}
+	return t, nil
+}
+
+// Fit trains the layer for the given number of epochs. It returns the mean loss of each epoch that was completed.
+//
+// Fit stops early if the context is cancelled, in which case the context's error is returned.
+func (t *Trainer) Fit(ctx context.Context, batches Iterator, epochs int) (losses []float64, err error) {
+	for epoch := 0; epoch < epochs; epoch++ {
+		if err = batches.Reset(); err != nil {
+			return losses, errors.Wrapf(err, "Unable to reset the batches at epoch %d", epoch)
+		}
+
+		var total float64
+		var n int
+		for {
+			if err = ctx.Err(); err != nil {
+				return losses, err
+			}
+
+			var xv, yv G.Value
+			if xv, yv, err = batches.Next(); err == io.EOF {
+				break
+			} else if err != nil {
+				return losses, errors.Wrapf(err, "Unable to get batch %d of epoch %d", n, epoch)
+			}
+
+			var loss float64
+			if loss, err = t.Step(xv, yv); err != nil {
+				return losses, errors.Wrapf(err, "Step %d of epoch %d failed", n, epoch)
+			}
+			total += loss
+			n++
+		}
+		if n == 0 {
+			return losses, errors.Errorf("Epoch %d has no batches", epoch)
+		}
+
+		loss := total / float64(n)
+		losses = append(losses, loss)
+		if t.onEpochEnd != nil {
+			t.onEpochEnd(epoch, loss)
+		}
+	}
+	return losses, nil
+}
+
+// Step trains the layer on a single batch, and returns the loss of the batch.
+func (t *Trainer) Step(xv, yv G.Value) (loss float64, err error) {
+	if err = G.Let(t.x, xv); err != nil {
+		return 0, errors.Wrapf(err, "Unable to bind the input %v", t.x.Name())
+	}
+	if err = G.Let(t.y, yv); err != nil {
+		return 0, errors.Wrapf(err, "Unable to bind the target %v", t.y.Name())
+	}
+	if t.vm == nil {
+		if err = t.build(); err != nil {
+			return 0, err
+		}
+	}
+	for _, r := range t.runners {
+		if err = r.Run(t.x); err != nil {
+			return 0, errors.Wrap(err, "Unable to run Runner")
+		}
+	}
+
+	defer t.vm.Reset()
+	if err = t.vm.RunAll(); err != nil {
+		return 0, err
+	}
+	if err = t.solver.Step(G.NodesToValueGrads(t.model)); err != nil {
+		return 0, errors.Wrap(err, "Solver failed")
+	}
+	for _, p := range t.steppers {
+		if err = p.postStep(); err != nil {
+			return 0, errors.Wrap(err, "Unable to run Runner after the step")
+		}
+	}
+	return scalarOf(t.costVal)
+}
+
+// Close closes the underlying machine.
+func (t *Trainer) Close() error {
+	if t.vm == nil {
+		return nil
+	}
+	return t.vm.Close()
+}
+
+// build constructs the cost, the gradients and the machine.
+func (t *Trainer) build() (err error) {
+	out := t.layer.Fwd(t.x)
+	if err = G.CheckOne(out); err != nil {
+		return errors.Wrapf(err, "Forward of %v failed", t.layer.Name())
+	}
+	if t.costNode, err = t.cost(out, t.y); err != nil {
+		return errors.Wrap(err, "Unable to construct the cost")
+	}
+	t.model = t.layer.Model()
+	var grads G.Nodes
+	if grads, err = G.Grad(t.costNode, t.model...); err != nil {
+		return errors.Wrap(err, "Unable to compute the gradients of the cost")
+	}
+	G.Read(t.costNode, &t.costVal)
+	if rs, ok := t.layer.(Runnerser); ok {
+		for _, r := range rs.Runners() {
+			if p, ok := r.(postStepper); ok {
+				t.steppers = append(t.steppers, p)
+				continue
+			}
+			t.runners = append(t.runners, r)
+		}
+	}
+
+	// only the cost and its gradients are compiled, so that the rest of the graph, such as an earlier forward pass of the layer
+	// for evaluation, is not run on every step. The reads of the subgraph, such as the statistics of a BatchNorm, are kept.
+	sub := t.x.Graph().SubgraphRoots(append(G.Nodes{t.costNode}, grads...)...)
+	opts := append([]G.VMOpt{G.BindDualValues(t.model...)}, t.vmOpts...)
+	t.vm = G.NewTapeMachine(sub, opts...)
+	return nil
+}
+
+// scalarOf returns the float64 of a scalar value.
+func scalarOf(v G.Value) (float64, error) {
+	if v == nil {
+		return 0, errors.New("Expected a scalar value. Got nil instead")
+	}
+	switch d := v.Data().(type) {
+	case float64:
+		return d, nil
+	case float32:
+		return float64(d), nil
+	}
+	return 0, errors.Errorf("Expected a float scalar. Got %v of %T instead", v, v.Data())
+}
@@ -0,0 +1,188 @@
+package golgi
+
+import (
+	"context"
+	"io"
+	"testing"
+
+	"github.com/stretchr/testify/require"
+	"gorgonia.org/gorgonia"
+	"gorgonia.org/tensor"
+)
+
+// sliceIterator iterates over pre-made batches.
+type sliceIterator struct {
+	xs, ys []gorgonia.Value
+	i      int
+}
+
+func (it *sliceIterator) Next() (x, y gorgonia.Value, err error) {
+	if it.i >= len(it.xs) {
+		return nil, nil, io.EOF
+	}
+	x, y = it.xs[it.i], it.ys[it.i]
+	it.i++
+	return x, y, nil
+}
+
+func (it *sliceIterator) Reset() error { it.i = 0; return nil }
+
+// linearBatches makes batches of y = 2x₀ - x₁ + 0.5.
+func linearBatches(batches, bs int) *sliceIterator {
+	it := new(sliceIterator)
+	for b := 0; b < batches; b++ {
+		x := make([]float64, bs*2)
+		y := make([]float64, bs)
+		for i := 0; i < bs; i++ {
+			x0 := float64((b*bs+i)%7) / 7
+			x1 := float64((b*bs+i)%5) / 5
+			x[i*2], x[i*2+1] = x0, x1
+			y[i] = 2*x0 - x1 + 0.5
+		}
+		it.xs = append(it.xs, tensor.New(tensor.WithShape(bs, 2), tensor.WithBacking(x)))
+		it.ys = append(it.ys, tensor.New(tensor.WithShape(bs, 1), tensor.WithBacking(y)))
+	}
+	return it
+}
+
+func TestTrainer_Fit(t *testing.T) {
+	c := require.New(t)
+	bs := 8
+	g := gorgonia.NewGraph()
+	x := gorgonia.NewMatrix(g, tensor.Float64, gorgonia.WithName("x"), gorgonia.WithShape(bs, 2))
+	y := gorgonia.NewMatrix(g, tensor.Float64, gorgonia.WithName("y"), gorgonia.WithShape(bs, 1))
+	fc := NewFC(WithName("fc"), WithSize(1), AsBatched(true))
+
+	var reported []int
+	tr, err := NewTrainer(fc, x, y, RMS, gorgonia.NewVanillaSolver(gorgonia.WithLearnRate(0.1)),
+		OnEpochEnd(func(epoch int, loss float64) { reported = append(reported, epoch) }))
+	c.NoError(err)
+	defer tr.Close()
+
+	losses, err := tr.Fit(context.Background(), linearBatches(4, bs), 50)
+	c.NoError(err)
+	c.Len(losses, 50)
+	c.Len(reported, 50)
+	c.Less(losses[49], losses[0]/10)
+}
+
+func TestTrainer_Runners(t *testing.T) {
+	c := require.New(t)
+	bs, classes := 4, 6
+	g := gorgonia.NewGraph()
+	x := gorgonia.NewVector(g, tensor.Float64, gorgonia.WithName("x"), gorgonia.WithShape(bs))
+	y := gorgonia.NewMatrix(g, tensor.Float64, gorgonia.WithName("y"), gorgonia.WithShape(bs, 1))
+	nn := Compose(
+		NewEmbedding(WithName("emb"), WithClasses(classes), WithSize(3), WithBatchSize(bs), AsRunner()),
+		NewFC(WithName("fc"), WithSize(1), AsBatched(true)),
+	)
+	tr, err := NewTrainer(nn, x, y, RMS, gorgonia.NewAdamSolver(gorgonia.WithLearnRate(0.05)))
+	c.NoError(err)
+	defer tr.Close()
+
+	// the target depends on the class, so the one-hot matrix must be updated before every step
+	it := new(sliceIterator)
+	for _, classes := range [][]float64{{0, 1, 2, 3}, {4, 5, 0, 1}, {2, 3, 4, 5}} {
+		ys := make([]float64, len(classes))
+		for i, cl := range classes {
+			ys[i] = cl / 5
+		}
+		it.xs = append(it.xs, tensor.New(tensor.WithShape(bs), tensor.WithBacking(classes)))
+		it.ys = append(it.ys, tensor.New(tensor.WithShape(bs, 1), tensor.WithBacking(ys)))
+	}
+	losses, err := tr.Fit(context.Background(), it, 100)
+	c.NoError(err)
+	c.Less(losses[99], 0.05)
+}
+
+// countingRunner counts the number of times it is run.
+type countingRunner struct {
+	*FC
+	runs int
+}
+
+func (r *countingRunner) Run(a gorgonia.Input) error { r.runs++; return nil }
+func (r *countingRunner) Runners() []Runner          { return []Runner{r} }
+
+func TestTrainer_BatchNorm(t *testing.T) {
+	c := require.New(t)
+	g := gorgonia.NewGraph()
+	x := gorgonia.NewMatrix(g, tensor.Float64, gorgonia.WithName("x"), gorgonia.WithShape(4, 2))
+	y := gorgonia.NewMatrix(g, tensor.Float64, gorgonia.WithName("y"), gorgonia.WithShape(4, 1))
+	bn, err := NewBatchNorm(WithName("bn"), WithMomentum(0.5))
+	c.NoError(err)
+	fc := &countingRunner{FC: NewFC(WithName("fc"), WithSize(1), AsBatched(true))}
+	tr, err := NewTrainer(Compose(bn, fc), x, y, RMS, gorgonia.NewVanillaSolver())
+	c.NoError(err)
+	defer tr.Close()
+
+	xv := tensor.New(tensor.WithShape(4, 2), tensor.WithBacking([]float64{1, 10, 2, 20, 3, 30, 4, 40}))
+	yv := tensor.New(tensor.WithShape(4, 1), tensor.WithBacking([]float64{0, 1, 0, 1}))
+
+	// the runners are run once before every step, and the running statistics are updated once after every step
+	_, err = tr.Step(xv, yv)
+	c.NoError(err)
+	c.Equal(1, fc.runs)
+	mean, _ := bn.Stats()
+	c.InDeltaSlice([]float64{1.25, 12.5}, mean.Value().Data(), 1e-10)
+
+	_, err = tr.Step(xv, yv)
+	c.NoError(err)
+	c.Equal(2, fc.runs)
+	c.InDeltaSlice([]float64{1.875, 18.75}, mean.Value().Data(), 1e-10)
+}
+
+func TestTrainer_Subgraph(t *testing.T) {
+	c := require.New(t)
+	bs := 8
+	g := gorgonia.NewGraph()
+	x := gorgonia.NewMatrix(g, tensor.Float64, gorgonia.WithName("x"), gorgonia.WithShape(bs, 2))
+	y := gorgonia.NewMatrix(g, tensor.Float64, gorgonia.WithName("y"), gorgonia.WithShape(bs, 1))
+	fc := NewFC(WithName("fc"), WithSize(1), AsBatched(true))
+
+	// an evaluation head, applied to another input before training
+	xe := gorgonia.NewMatrix(g, tensor.Float64, gorgonia.WithName("xe"), gorgonia.WithShape(2, 2), gorgonia.WithInit(gorgonia.Ones()))
+	eval := fc.Fwd(xe)
+	c.NoError(gorgonia.CheckOne(eval))
+	var ev gorgonia.Value
+	gorgonia.Read(eval.Node(), &ev)
+
+	tr, err := NewTrainer(fc, x, y, RMS, gorgonia.NewVanillaSolver())
+	c.NoError(err)
+	defer tr.Close()
+
+	it := linearBatches(1, bs)
+	xv, yv, err := it.Next()
+	c.NoError(err)
+	_, err = tr.Step(xv, yv)
+	c.NoError(err)
+
+	// only the cost and its gradients are run on every step
+	c.Nil(ev)
+}
+
+func TestTrainer_Cancel(t *testing.T) {
+	c := require.New(t)
+	bs := 8
+	g := gorgonia.NewGraph()
+	x := gorgonia.NewMatrix(g, tensor.Float64, gorgonia.WithName("x"), gorgonia.WithShape(bs, 2))
+	y := gorgonia.NewMatrix(g, tensor.Float64, gorgonia.WithName("y"), gorgonia.WithShape(bs, 1))
+	fc := NewFC(WithName("fc"), WithSize(1), AsBatched(true))
+
+	ctx, cancel := context.WithCancel(context.Background())
+	tr, err := NewTrainer(fc, x, y, RMS, gorgonia.NewVanillaSolver(),
+		OnEpochEnd(func(epoch int, loss float64) {
+			if epoch == 2 {
+				cancel()
+			}
+		}))
+	c.NoError(err)
+	defer tr.Close()
+
+	losses, err := tr.Fit(ctx, linearBatches(2, bs), 10)
+	c.Equal(context.Canceled, err)
+	c.Len(losses, 3)
+
+	_, err = NewTrainer(fc, x, y, nil, gorgonia.NewVanillaSolver())
+	c.Error(err)
+}
@@ -4,6 +4,8 @@ import (
 	"fmt"
 
 	"github.com/chewxy/hm"
+	"github.com/pkg/errors"
+	"gorgonia.org/golgi/onnx"
 	G "gorgonia.org/gorgonia"
 	"gorgonia.org/tensor"
 )
@@ -19,9 +21,12 @@ func (l id) Fwd(x G.Input) G.Result { return x.Node() }
 func (l id) Type() hm.Type          { return hm.NewFnType(hm.TypeVariable('a'), hm.TypeVariable('a')) }
 func (l id) Shape() tensor.Shape    { panic("not implemented") }
 func (l id) Name() string           { return "I" }
-func (l id) Describe()              {}
 func (l id) unnamed()               {}
 
+func (l id) Describe() (*onnx.GraphProto, error) {
+	return identityFragment(""), nil
+}
+
 type k struct{ *G.Node }
 
 func (l k) Model() G.Nodes         { return nil }
@@ -29,12 +34,24 @@ func (l k) Fwd(x G.Input) G.Result { return l.Node }
 func (l k) Type() hm.Type          { return hm.NewFnType(hm.TypeVariable('a'), l.Node.Type()) }
 func (l k) Shape() tensor.Shape    { panic("not implemented") }
 func (l k) Name() string           { return "K" }
-func (l k) Describe()              {}
 func (l k) unnamed()               {}
 
+func (l k) Describe() (*onnx.GraphProto, error) {
+	f := newFragment("", "K", l.Node.Dtype())
+	c, err := f.weight(l.Node)
+	if err != nil {
+		return nil, err
+	}
+	f.node("Identity", []string{c})
+	return f.graph(), nil
+}
+
 type reshape tensor.Shape
 
 // ConsReshape is a construction function for a reshaping layer. It ignores the `x` input.
+//
+// The target shape is given with ToShape. It may contain a 0, which copies the dimension of the input, and a -1, which is inferred
+// from the size of the input. Both are resolved every time the layer is applied, so ToShape(0, -1) works for any batch size.
 func ConsReshape(_ G.Input, opts ...ConsOpt) (l Layer, err error) {
 	l = reshape(nil)
 	for _, opt := range opts {
@@ -45,24 +62,86 @@ func ConsReshape(_ G.Input, opts ...ConsOpt) (l Layer, err error) {
 	return l, nil
 }
 
+// ConsFlatten is a construction function for a flattening layer. It keeps the leading batch axis and collapses the rest, so an
+// input of shape (N, C, H, W) is reshaped to (N, C×H×W). It ignores the `x` input.
+//
+// A flattening layer is a reshaping layer to the shape (0, -1).
+func ConsFlatten(_ G.Input, opts ...ConsOpt) (l Layer, err error) {
+	l = reshape{0, -1}
+	for _, opt := range opts {
+		if l, err = opt(l); err != nil {
+			return nil, err
+		}
+	}
+	return l, nil
+}
+
 func (l reshape) Model() G.Nodes { return nil }
 func (l reshape) Fwd(x G.Input) G.Result {
 	if err := G.CheckOne(x); err != nil {
 		return G.Err(err)
 	}
-	to := tensor.Shape(l)
 	n := x.Node()
+	to, err := l.resolve(n.Shape())
+	if err != nil {
+		return G.Err(err)
+	}
 	if to.Eq(n.Shape()) {
 		return n
 	}
-	return G.LiftResult(G.Reshape(x.Node(), tensor.Shape(l)))
+	return G.LiftResult(G.Reshape(n, to))
+}
+
+// resolve resolves the target shape against the shape of the input.
+// As with ONNX's Reshape, a 0 copies the dimension of the input, and a -1 is inferred from the remaining dimensions.
+func (l reshape) resolve(from tensor.Shape) (tensor.Shape, error) {
+	to := make(tensor.Shape, len(l))
+	infer := -1
+	known := 1
+	for i, d := range l {
+		switch {
+		case d == 0:
+			if i >= len(from) {
+				return nil, errors.Errorf("Cannot copy dimension %d of %v", i, from)
+			}
+			to[i] = from[i]
+		case d == -1:
+			if infer >= 0 {
+				return nil, errors.Errorf("Cannot infer more than one dimension of %v", tensor.Shape(l))
+			}
+			infer = i
+			continue
+		case d < 0:
+			return nil, errors.Errorf("Invalid dimension %d in %v", d, tensor.Shape(l))
+		default:
+			to[i] = d
+		}
+		known *= to[i]
+	}
+	if infer >= 0 {
+		if known == 0 || from.TotalSize()%known != 0 {
+			return nil, errors.Errorf("Cannot reshape %v to %v", from, tensor.Shape(l))
+		}
+		to[infer] = from.TotalSize() / known
+	}
+	return to, nil
 }
+
+// OutputShape returns the shape of the output of the layer for an input of the given shape, without applying the layer.
+func (l reshape) OutputShape(in tensor.Shape) (tensor.Shape, error) { return l.resolve(in) }
+
 func (l reshape) Type() hm.Type       { return hm.NewFnType(hm.TypeVariable('a'), hm.TypeVariable('a')) }
 func (l reshape) Shape() tensor.Shape { return tensor.Shape(l) }
 func (l reshape) Name() string        { return fmt.Sprintf("Reshape%v", tensor.Shape(l)) }
-func (l reshape) Describe()           {}
 func (l reshape) unnamed()            {}
 
+func (l reshape) Describe() (*onnx.GraphProto, error) {
+	f := newFragment("", "Reshape", tensor.Float64)
+	shp := f.ints("Reshape_shape", l...)
+	f.apply("Reshape", []string{shp})
+	return f.graph(), nil
+}
+
 type dropout float64
 
 // ConsDropout creates a dropout layer. It ignores the `x` input
@@ -86,5 +165,10 @@ func (l dropout) Fwd(x G.Input) G.Result {
 func (l dropout) Type() hm.Type       { return hm.NewFnType(hm.TypeVariable('a'), hm.TypeVariable('a')) }
 func (l dropout) Shape() tensor.Shape { panic("not implemented") }
 func (l dropout) Name() string        { return fmt.Sprintf("Dropout(%v)", float64(l)) }
-func (l dropout) Describe()           {}
 func (l dropout) unnamed()            {}
+
+func (l dropout) Describe() (*onnx.GraphProto, error) {
+	f := newFragment("", "Dropout", tensor.Float64)
+	f.dropout(float64(l))
+	return f.graph(), nil
+}
@@ -0,0 +1,71 @@
+package golgi
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/require"
+	"gorgonia.org/gorgonia"
+	"gorgonia.org/tensor"
+)
+
+func TestReshape_Resolve(t *testing.T) {
+	cases := []struct {
+		to       []int
+		from     tensor.Shape
+		expected tensor.Shape
+	}{
+		{[]int{0, -1}, tensor.Shape{2, 3, 4, 4}, tensor.Shape{2, 48}},
+		{[]int{0, -1}, tensor.Shape{7, 3, 4, 4}, tensor.Shape{7, 48}},
+		{[]int{-1, 4}, tensor.Shape{2, 3, 4}, tensor.Shape{6, 4}},
+		{[]int{0, 0, -1}, tensor.Shape{2, 3, 4, 5}, tensor.Shape{2, 3, 20}},
+		{[]int{3, 8}, tensor.Shape{2, 3, 4}, tensor.Shape{3, 8}},
+	}
+	for _, tc := range cases {
+		c := require.New(t)
+		l, err := ConsReshape(nil, ToShape(tc.to...))
+		c.NoError(err)
+		shp, err := l.(OutputShaper).OutputShape(tc.from)
+		c.NoError(err)
+		c.Equal(tc.expected, shp, "%v of %v", tc.to, tc.from)
+	}
+
+	c := require.New(t)
+	_, err := ConsReshape(nil, ToShape(-1, 2, -1))
+	c.Error(err)
+	_, err = ConsReshape(nil, ToShape(2, -2))
+	c.Error(err)
+	_, err = reshape{-1, 5}.resolve(tensor.Shape{2, 3})
+	c.Error(err)
+	_, err = reshape{0, 0, 0}.resolve(tensor.Shape{2, 3})
+	c.Error(err)
+}
+
+func TestFlatten(t *testing.T) {
+	c := require.New(t)
+	flat, err := ConsFlatten(nil)
+	c.NoError(err)
+
+	// the same layers are applied to inputs of any batch size
+	newModel := func() *Composition {
+		return Compose(
+			L(ConsConv, WithName("conv"), WithSize(2, 1), WithKernelShape(tensor.Shape{3, 3}), WithPadding(Same)),
+			Compose(flat, L(ConsFC, WithName("fc"), WithSize(3))),
+		)
+	}
+	for _, bs := range []int{1, 4} {
+		g := gorgonia.NewGraph()
+		x := gorgonia.NewTensor(g, tensor.Float64, 4, gorgonia.WithName("x"), gorgonia.WithShape(bs, 1, 5, 5), gorgonia.WithInit(gorgonia.GlorotU(1)))
+		y := flat.Fwd(x)
+		c.NoError(gorgonia.CheckOne(y))
+		c.Equal(tensor.Shape{bs, 25}, y.Node().Shape())
+
+		out := newModel().Fwd(x)
+		c.NoError(gorgonia.CheckOne(out))
+		c.Equal(tensor.Shape{bs, 3}, out.Node().Shape())
+	}
+
+	desc, err := flat.Describe()
+	c.NoError(err)
+	c.Equal([]string{"Reshape"}, opTypes(desc))
+	checkWellFormed(t, desc)
+}